@@ -0,0 +1,189 @@
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/containerd/console"
+	vc "github.com/kata-containers/runtime/virtcontainers"
+	"github.com/kata-containers/runtime/virtcontainers/pkg/oci"
+	"github.com/mdlayher/vsock"
+	"github.com/urfave/cli"
+)
+
+// debugConsoleEscape is the key detaching from the debug console (Ctrl-]).
+const debugConsoleEscape = 0x1d
+
+var debugConsoleCLICommand = cli.Command{
+	Name:  "debug-console",
+	Usage: "attach a terminal to the guest debug console of a sandbox",
+	ArgsUsage: `<sandbox-id>
+
+   <sandbox-id> is the ID of the sandbox whose guest VM to attach to.`,
+	Description: `The debug console is a shell started by the agent in the guest VM, either
+   on the guest console or on a vsock port. It must be enabled with the
+   "debug_console_enabled" option of the agent configuration.
+
+   The guest console does not carry the terminal size, which is left to
+   the guest default and not updated when the terminal is resized: set it
+   from the guest shell with "stty rows <rows> cols <columns>" if needed.
+   Press Ctrl-] to detach.`,
+	Action: func(context *cli.Context) error {
+		ctx, err := cliContextToContext(context)
+		if err != nil {
+			return err
+		}
+
+		runtimeConfig, ok := context.App.Metadata["runtimeConfig"].(oci.RuntimeConfig)
+		if !ok {
+			return errors.New("invalid runtime config")
+		}
+
+		return debugConsole(ctx, runtimeConfig, context.Args().First(), os.Stdin, defaultOutputFile)
+	},
+}
+
+func debugConsole(ctx context.Context, runtimeConfig oci.RuntimeConfig, sandboxID string, in *os.File, out io.Writer) error {
+	agentConfig, ok := runtimeConfig.AgentConfig.(vc.KataAgentConfig)
+	if !ok || !agentConfig.DebugConsole {
+		return errors.New("Guest debug console is disabled, enable debug_console_enabled in the agent configuration")
+	}
+
+	if sandboxID == "" {
+		return fmt.Errorf("Missing sandbox ID")
+	}
+
+	kataLog = kataLog.WithField("sandbox", sandboxID)
+	setExternalLoggers(ctx, kataLog)
+
+	consoleURL, err := vci.DebugConsoleURL(ctx, sandboxID)
+	if err != nil {
+		return err
+	}
+
+	kataLog.WithField("console", consoleURL).Info("attaching to guest debug console")
+
+	conn, err := dialDebugConsole(consoleURL)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return attachDebugConsole(conn, in, out)
+}
+
+// dialDebugConsole connects to the debug console reachable through
+// consoleURL, either a unix://<path> or a vsock://<cid>:<port> URL.
+var dialDebugConsole = func(consoleURL string) (net.Conn, error) {
+	u, err := url.Parse(consoleURL)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "unix":
+		return net.Dial("unix", u.Path)
+	case "vsock":
+		cid, err := strconv.ParseUint(u.Hostname(), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid vsock context ID in %q: %v", consoleURL, err)
+		}
+
+		port, err := strconv.ParseUint(u.Port(), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid vsock port in %q: %v", consoleURL, err)
+		}
+
+		return vsock.Dial(uint32(cid), uint32(port))
+	default:
+		return nil, fmt.Errorf("Unsupported debug console URL %q", consoleURL)
+	}
+}
+
+// attachDebugConsole copies in to conn and conn to out, until the console
+// is closed or the user detaches from it. If in is a terminal, it is put in
+// raw mode. Its size is not given to the guest, the console having no
+// channel for it.
+func attachDebugConsole(conn io.ReadWriter, in *os.File, out io.Writer) error {
+	fmt.Fprintln(out, "Attached to the guest debug console, press Ctrl-] to detach")
+
+	if isTerminal(in.Fd()) {
+		c, err := console.ConsoleFromFile(in)
+		if err != nil {
+			return err
+		}
+
+		if err := c.SetRaw(); err != nil {
+			return err
+		}
+		defer c.Reset()
+	}
+
+	errCh := make(chan error, 2)
+
+	go func() {
+		_, err := io.Copy(out, conn)
+		errCh <- err
+	}()
+
+	go func() {
+		errCh <- copyUntilEscape(conn, in)
+	}()
+
+	err := <-errCh
+	if err != nil {
+		kataLog.WithError(err).Warn("guest debug console closed")
+	}
+
+	return err
+}
+
+// copyUntilEscape copies src to dst until the end of src or until the
+// debug console escape key is read.
+func copyUntilEscape(dst io.Writer, src io.Reader) error {
+	buf := make([]byte, 1024)
+
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			data := buf[:n]
+
+			escaped := false
+			for i, b := range data {
+				if b == debugConsoleEscape {
+					data = data[:i]
+					escaped = true
+					break
+				}
+			}
+
+			if _, err := dst.Write(data); err != nil {
+				return err
+			}
+
+			if escaped {
+				kataLog.Info("detached from guest debug console")
+				return nil
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	vc "github.com/kata-containers/runtime/virtcontainers"
+	"github.com/kata-containers/runtime/virtcontainers/pkg/oci"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebugConsoleDisabled(t *testing.T) {
+	assert := assert.New(t)
+
+	var out bytes.Buffer
+
+	// Not a Kata agent
+	err := debugConsole(context.Background(), oci.RuntimeConfig{}, testSandboxID, os.Stdin, &out)
+	assert.Error(err)
+
+	runtimeConfig := oci.RuntimeConfig{
+		AgentConfig: vc.KataAgentConfig{},
+	}
+
+	err = debugConsole(context.Background(), runtimeConfig, testSandboxID, os.Stdin, &out)
+	assert.Error(err)
+}
+
+func TestDebugConsoleMissingSandboxID(t *testing.T) {
+	assert := assert.New(t)
+
+	var out bytes.Buffer
+
+	runtimeConfig := oci.RuntimeConfig{
+		AgentConfig: vc.KataAgentConfig{DebugConsole: true},
+	}
+
+	err := debugConsole(context.Background(), runtimeConfig, "", os.Stdin, &out)
+	assert.Error(err)
+}
+
+func TestDebugConsoleURLFailure(t *testing.T) {
+	assert := assert.New(t)
+
+	var out bytes.Buffer
+
+	runtimeConfig := oci.RuntimeConfig{
+		AgentConfig: vc.KataAgentConfig{DebugConsole: true},
+	}
+
+	testingImpl.DebugConsoleURLFunc = func(ctx context.Context, sandboxID string) (string, error) {
+		return "", errors.New("no debug console")
+	}
+	defer func() {
+		testingImpl.DebugConsoleURLFunc = nil
+	}()
+
+	err := debugConsole(context.Background(), runtimeConfig, testSandboxID, os.Stdin, &out)
+	assert.Error(err)
+}
+
+func TestDebugConsole(t *testing.T) {
+	assert := assert.New(t)
+
+	sockPath := filepath.Join(testDir, "debug-console.sock")
+	defer os.Remove(sockPath)
+
+	l, err := net.Listen("unix", sockPath)
+	assert.NoError(err)
+	defer l.Close()
+
+	// The guest side reads a command and answers it before closing the
+	// console.
+	received := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			received <- ""
+			return
+		}
+		defer conn.Close()
+
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		received <- line
+
+		conn.Write([]byte("hello from the guest\n"))
+	}()
+
+	testingImpl.DebugConsoleURLFunc = func(ctx context.Context, sandboxID string) (string, error) {
+		assert.Equal(testSandboxID, sandboxID)
+		return "unix://" + sockPath, nil
+	}
+	defer func() {
+		testingImpl.DebugConsoleURLFunc = nil
+	}()
+
+	inReader, inWriter, err := os.Pipe()
+	assert.NoError(err)
+	defer inReader.Close()
+	defer inWriter.Close()
+
+	_, err = inWriter.Write([]byte("uname\n"))
+	assert.NoError(err)
+
+	runtimeConfig := oci.RuntimeConfig{
+		AgentConfig: vc.KataAgentConfig{DebugConsole: true},
+	}
+
+	var out bytes.Buffer
+
+	err = debugConsole(context.Background(), runtimeConfig, testSandboxID, inReader, &out)
+	assert.NoError(err)
+
+	assert.Equal("uname\n", <-received)
+	assert.Contains(out.String(), "hello from the guest\n")
+}
+
+func TestDialDebugConsoleInvalidURL(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, consoleURL := range []string{
+		"",
+		"tcp://127.0.0.1:1026",
+		"vsock://foo:1026",
+		"vsock://3:foo",
+		"unix:///does/not/exist",
+	} {
+		_, err := dialDebugConsole(consoleURL)
+		assert.Error(err, "URL: %q", consoleURL)
+	}
+}
+
+func TestCopyUntilEscape(t *testing.T) {
+	assert := assert.New(t)
+
+	type testData struct {
+		input    string
+		expected string
+	}
+
+	data := []testData{
+		{"", ""},
+		{"ls -l\n", "ls -l\n"},
+		{"ls\x1d-l\n", "ls"},
+		{"\x1dls\n", ""},
+	}
+
+	for _, d := range data {
+		var dst bytes.Buffer
+
+		err := copyUntilEscape(&dst, bytes.NewBufferString(d.input))
+		assert.NoError(err)
+		assert.Equal(d.expected, dst.String(), "input: %q", d.input)
+	}
+}
@@ -124,7 +124,9 @@ func delete(ctx context.Context, containerID string, force bool) error {
 	}
 
 	// Run post-stop OCI hooks.
-	if err := katautils.PostStopHooks(ctx, ociSpec, sandboxID, status.Annotations[vcAnnot.BundlePathKey]); err != nil {
+	// The sandbox VM may be gone at this point, only its ID is known.
+	hs := katautils.HookState{SandboxID: sandboxID}
+	if err := katautils.PostStopHooks(ctx, ociSpec, sandboxID, status.Annotations[vcAnnot.BundlePathKey], hs); err != nil {
 		return err
 	}
 
@@ -8,17 +8,20 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"net"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"github.com/gogo/protobuf/types"
 	pb "github.com/kata-containers/runtime/protocols/cache"
 	vc "github.com/kata-containers/runtime/virtcontainers"
 	vf "github.com/kata-containers/runtime/virtcontainers/factory"
+	"github.com/kata-containers/runtime/virtcontainers/factory/template"
 	"github.com/kata-containers/runtime/virtcontainers/pkg/oci"
 	"github.com/pkg/errors"
 	"github.com/urfave/cli"
@@ -30,6 +33,9 @@ var factorySubCmds = []cli.Command{
 	initFactoryCommand,
 	destroyFactoryCommand,
 	statusFactoryCommand,
+	listFactoryCommand,
+	rebuildFactoryCommand,
+	pruneFactoryCommand,
 }
 
 var factoryCLICommand = cli.Command{
@@ -333,3 +339,154 @@ var statusFactoryCommand = cli.Command{
 		return nil
 	},
 }
+
+// templateFactoryConfig returns the template factory configuration of the
+// runtime configuration.
+func templateFactoryConfig(runtimeConfig oci.RuntimeConfig) vf.Config {
+	return vf.Config{
+		Template:     true,
+		TemplatePath: runtimeConfig.FactoryConfig.TemplatePath,
+		VMConfig: vc.VMConfig{
+			HypervisorType:   runtimeConfig.HypervisorType,
+			HypervisorConfig: runtimeConfig.HypervisorConfig,
+			AgentType:        runtimeConfig.AgentType,
+			AgentConfig:      runtimeConfig.AgentConfig,
+			ProxyType:        runtimeConfig.ProxyType,
+			ProxyConfig:      runtimeConfig.ProxyConfig,
+		},
+	}
+}
+
+func writeTemplates(templates []template.Info, file io.Writer) error {
+	// values used by runc
+	flags := uint(0)
+	minWidth := 12
+	tabWidth := 1
+	padding := 3
+
+	w := tabwriter.NewWriter(file, minWidth, tabWidth, padding, ' ', flags)
+
+	fmt.Fprint(w, "ID\tCREATED\tHYPERVISOR\tVCPUS\tMEMORY\tSTATUS\tPATH\n")
+
+	for _, t := range templates {
+		id := t.ID
+		if id == "" {
+			id = "legacy"
+		}
+
+		status := "available"
+		if t.Stale {
+			status = "stale"
+		} else if t.Current {
+			status = "current"
+		}
+
+		created := ""
+		if !t.Created.IsZero() {
+			created = t.Created.Format(time.RFC3339)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%dMiB\t%s\t%s\n",
+			id,
+			created,
+			t.HypervisorType,
+			t.NumVCPUs,
+			t.MemorySize,
+			status,
+			t.Path)
+	}
+
+	return w.Flush()
+}
+
+var listFactoryCommand = cli.Command{
+	Name:  "list",
+	Usage: "list the template VMs of the VM factory",
+	Description: `Template VMs are kept side by side, one for every VM configuration and
+   set of assets (hypervisor, firmware, kernel, image or initrd) they were
+   booted from. The template VM matching the configuration is current, the
+   ones booted from assets which changed since are stale.`,
+	Action: func(c *cli.Context) error {
+		runtimeConfig, ok := c.App.Metadata["runtimeConfig"].(oci.RuntimeConfig)
+		if !ok {
+			return errors.New("invalid runtime config")
+		}
+
+		if !runtimeConfig.FactoryConfig.Template {
+			fmt.Fprintln(defaultOutputFile, "vm factory not enabled")
+			return nil
+		}
+
+		templates, err := vf.ListTemplates(templateFactoryConfig(runtimeConfig))
+		if err != nil {
+			return err
+		}
+
+		return writeTemplates(templates, defaultOutputFile)
+	},
+}
+
+var rebuildFactoryCommand = cli.Command{
+	Name:  "rebuild",
+	Usage: "rebuild the template VM matching the configuration",
+	Action: func(c *cli.Context) error {
+		ctx, err := cliContextToContext(c)
+		if err != nil {
+			return err
+		}
+
+		runtimeConfig, ok := c.App.Metadata["runtimeConfig"].(oci.RuntimeConfig)
+		if !ok {
+			return errors.New("invalid runtime config")
+		}
+
+		if !runtimeConfig.FactoryConfig.Template {
+			fmt.Fprintln(defaultOutputFile, "vm factory not enabled")
+			return nil
+		}
+
+		factoryConfig := templateFactoryConfig(runtimeConfig)
+
+		kataLog.WithField("factory", factoryConfig).Info("load vm factory")
+		f, err := vf.NewFactory(ctx, factoryConfig, true)
+		if err != nil {
+			kataLog.WithError(err).Info("no vm factory to destroy")
+		} else {
+			f.CloseFactory(ctx)
+		}
+
+		kataLog.WithField("factory", factoryConfig).Info("create vm factory")
+		if _, err = vf.NewFactory(ctx, factoryConfig, false); err != nil {
+			kataLog.WithError(err).Error("create vm factory failed")
+			return err
+		}
+
+		fmt.Fprintln(defaultOutputFile, "vm factory rebuilt")
+		return nil
+	},
+}
+
+var pruneFactoryCommand = cli.Command{
+	Name:  "prune",
+	Usage: "remove the stale template VMs of the VM factory",
+	Description: `Stale template VMs were booted from assets which changed since. The VMs
+   created from them keep running.`,
+	Action: func(c *cli.Context) error {
+		runtimeConfig, ok := c.App.Metadata["runtimeConfig"].(oci.RuntimeConfig)
+		if !ok {
+			return errors.New("invalid runtime config")
+		}
+
+		if !runtimeConfig.FactoryConfig.Template {
+			fmt.Fprintln(defaultOutputFile, "vm factory not enabled")
+			return nil
+		}
+
+		pruned, err := vf.PruneTemplates(templateFactoryConfig(runtimeConfig))
+		for _, t := range pruned {
+			fmt.Fprintf(defaultOutputFile, "removed template VM %s\n", t.Path)
+		}
+
+		return err
+	},
+}
@@ -6,16 +6,22 @@
 package main
 
 import (
+	"bytes"
 	"flag"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/urfave/cli"
 
 	ktu "github.com/kata-containers/runtime/pkg/katatestutils"
 	vc "github.com/kata-containers/runtime/virtcontainers"
+	vf "github.com/kata-containers/runtime/virtcontainers/factory"
+	"github.com/kata-containers/runtime/virtcontainers/factory/template"
 )
 
 const testDisabledAsNonRoot = "Test disabled as requires root privileges"
@@ -150,3 +156,104 @@ func TestFactoryCLIFunctionStatus(t *testing.T) {
 	err = fn(ctx)
 	assert.Nil(err)
 }
+
+func TestFactoryCLIFunctionTemplates(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpdir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(tmpdir)
+
+	runtimeConfig, err := newTestRuntimeConfig(tmpdir, testConsole, true)
+	assert.NoError(err)
+
+	set := flag.NewFlagSet("", 0)
+
+	set.String("console-socket", "", "")
+
+	ctx := createCLIContext(set)
+	ctx.App.Name = "foo"
+
+	// No template
+	ctx.App.Metadata["runtimeConfig"] = runtimeConfig
+
+	for _, cmd := range []cli.Command{listFactoryCommand, rebuildFactoryCommand, pruneFactoryCommand} {
+		fn, ok := cmd.Action.(func(context *cli.Context) error)
+		assert.True(ok)
+		err = fn(ctx)
+		assert.Nil(err)
+	}
+
+	// With template
+	if tc.NotValid(ktu.NeedRoot()) {
+		t.Skip(testDisabledAsNonRoot)
+	}
+
+	runtimeConfig.FactoryConfig.Template = true
+	runtimeConfig.FactoryConfig.TemplatePath = filepath.Join(tmpdir, "template")
+	runtimeConfig.HypervisorType = vc.MockHypervisor
+	runtimeConfig.AgentType = vc.NoopAgentType
+	runtimeConfig.ProxyType = vc.NoopProxyType
+	ctx.App.Metadata["runtimeConfig"] = runtimeConfig
+
+	fn, ok := rebuildFactoryCommand.Action.(func(context *cli.Context) error)
+	assert.True(ok)
+	err = fn(ctx)
+	assert.Nil(err)
+
+	templates, err := vf.ListTemplates(templateFactoryConfig(runtimeConfig))
+	assert.NoError(err)
+	assert.Len(templates, 1)
+	assert.True(templates[0].Current)
+
+	// The mock hypervisor does not save the template VM, which cannot be
+	// fetched and destroyed.
+	defer syscall.Unmount(templates[0].Path, syscall.MNT_DETACH)
+
+	// Rebuilt in place
+	err = fn(ctx)
+	assert.Nil(err)
+
+	rebuilt, err := vf.ListTemplates(templateFactoryConfig(runtimeConfig))
+	assert.NoError(err)
+	assert.Len(rebuilt, 1)
+	assert.Equal(templates[0].ID, rebuilt[0].ID)
+
+	for _, cmd := range []cli.Command{listFactoryCommand, pruneFactoryCommand} {
+		fn, ok := cmd.Action.(func(context *cli.Context) error)
+		assert.True(ok)
+		err = fn(ctx)
+		assert.Nil(err)
+	}
+
+	// Nothing stale
+	templates, err = vf.ListTemplates(templateFactoryConfig(runtimeConfig))
+	assert.NoError(err)
+	assert.Len(templates, 1)
+
+	// Destroy
+	fn, ok = destroyFactoryCommand.Action.(func(context *cli.Context) error)
+	assert.True(ok)
+	err = fn(ctx)
+	assert.Nil(err)
+}
+
+func TestFactoryWriteTemplates(t *testing.T) {
+	assert := assert.New(t)
+
+	var out bytes.Buffer
+
+	err := writeTemplates([]template.Info{
+		{ID: "0123456789abcdef", HypervisorType: vc.QemuHypervisor, MemorySize: 2048, Current: true, Created: time.Now()},
+		{ID: "fedcba9876543210", HypervisorType: vc.QemuHypervisor, Stale: true},
+		{Path: "/run/vc/vm/template", Stale: true},
+	}, &out)
+	assert.NoError(err)
+
+	output := out.String()
+	assert.Contains(output, "0123456789abcdef")
+	assert.Contains(output, "2048MiB")
+	assert.Contains(output, "current")
+	assert.Contains(output, "stale")
+	assert.Contains(output, "legacy")
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	vc "github.com/kata-containers/runtime/virtcontainers"
+	"github.com/urfave/cli"
+)
+
+var gcCLICommand = cli.Command{
+	Name:  "gc",
+	Usage: "clean up the resources of orphaned sandboxes",
+	Description: `A sandbox is orphaned when its hypervisor is not running anymore while it
+   was not deleted, for instance after the runtime or the shim was killed.
+   Its helper processes, network, mounts, cgroups and directories are
+   left behind on the host.
+
+   Stopped sandboxes, and sandboxes whose hypervisor may still be starting,
+   are left alone.`,
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "list the resources of orphaned sandboxes without removing them",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		ctx, err := cliContextToContext(context)
+		if err != nil {
+			return err
+		}
+
+		return gc(ctx, context.Bool("dry-run"), defaultOutputFile)
+	},
+}
+
+func gc(ctx context.Context, dryRun bool, out io.Writer) error {
+	setExternalLoggers(ctx, kataLog)
+
+	resources, err := vci.GarbageCollect(ctx, dryRun)
+	if err != nil {
+		return err
+	}
+
+	return gcWrite(resources, dryRun, out)
+}
+
+func gcWrite(resources []vc.GCResource, dryRun bool, out io.Writer) error {
+	// values used by runc
+	flags := uint(0)
+	minWidth := 12
+	tabWidth := 1
+	padding := 3
+
+	w := tabwriter.NewWriter(out, minWidth, tabWidth, padding, ' ', flags)
+
+	fmt.Fprint(w, "SANDBOX\tTYPE\tRESOURCE\tSTATUS\n")
+
+	failed := 0
+	for _, r := range resources {
+		status := "removed"
+		if dryRun {
+			status = "leaked"
+		} else if r.Err != nil {
+			status = r.Err.Error()
+			failed++
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.SandboxID, r.Type, r.Resource, status)
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d resources of orphaned sandboxes could not be removed", failed)
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	vc "github.com/kata-containers/runtime/virtcontainers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGCFailure(t *testing.T) {
+	assert := assert.New(t)
+
+	testingImpl.GarbageCollectFunc = func(ctx context.Context, dryRun bool) ([]vc.GCResource, error) {
+		return nil, errors.New("gc failed")
+	}
+	defer func() {
+		testingImpl.GarbageCollectFunc = nil
+	}()
+
+	var out bytes.Buffer
+
+	err := gc(context.Background(), false, &out)
+	assert.Error(err)
+	assert.Empty(out.String())
+}
+
+func TestGC(t *testing.T) {
+	assert := assert.New(t)
+
+	resources := []vc.GCResource{
+		{SandboxID: testSandboxID, Type: vc.GCProcess, Resource: "1234 (kata-proxy)"},
+		{SandboxID: testSandboxID, Type: vc.GCDirectory, Resource: "/run/vc/sbs/" + testSandboxID},
+	}
+
+	testingImpl.GarbageCollectFunc = func(ctx context.Context, dryRun bool) ([]vc.GCResource, error) {
+		return resources, nil
+	}
+	defer func() {
+		testingImpl.GarbageCollectFunc = nil
+	}()
+
+	var out bytes.Buffer
+
+	err := gc(context.Background(), true, &out)
+	assert.NoError(err)
+	assert.Contains(out.String(), "SANDBOX")
+	assert.Contains(out.String(), "1234 (kata-proxy)")
+	assert.Contains(out.String(), "leaked")
+
+	out.Reset()
+	err = gc(context.Background(), false, &out)
+	assert.NoError(err)
+	assert.Contains(out.String(), "removed")
+	assert.NotContains(out.String(), "leaked")
+
+	// A resource could not be removed
+	resources[1].Err = errors.New("device or resource busy")
+
+	out.Reset()
+	err = gc(context.Background(), false, &out)
+	assert.Error(err)
+	assert.Contains(out.String(), "device or resource busy")
+}
@@ -0,0 +1,503 @@
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/kata-containers/runtime/pkg/katautils"
+	vc "github.com/kata-containers/runtime/virtcontainers"
+	"github.com/kata-containers/runtime/virtcontainers/device/config"
+	vcAnnotations "github.com/kata-containers/runtime/virtcontainers/pkg/annotations"
+	"github.com/kata-containers/runtime/virtcontainers/pkg/oci"
+	"github.com/kata-containers/runtime/virtcontainers/types"
+	vcUtils "github.com/kata-containers/runtime/virtcontainers/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+const (
+	cgroupLayoutLegacy  = "legacy"
+	cgroupLayoutHybrid  = "hybrid"
+	cgroupLayoutUnified = "unified"
+)
+
+// variables rather than consts to allow tests to modify them
+var (
+	procMemInfo    = "/proc/meminfo"
+	procCgroups    = "/proc/cgroups"
+	sysFsCgroupDir = "/sys/fs/cgroup"
+)
+
+// minHypervisorVersions maps a hypervisor type to the oldest version
+// known to work with the runtime.
+var minHypervisorVersions = map[vc.HypervisorType]string{
+	vc.QemuHypervisor:        "2.11.0",
+	vc.FirecrackerHypervisor: "0.17.0",
+}
+
+// requiredCgroupControllers lists the cgroup controllers used by the runtime.
+var requiredCgroupControllers = []string{"cpu", "cpuset", "devices", "memory"}
+
+var versionRegex = regexp.MustCompile(`\d+\.\d+(\.\d+)?`)
+
+// checkRuntimeComponents checks that the components used by the runtime
+// configuration are available on the host. All results are logged and
+// recorded, an error being returned if any of them failed.
+func checkRuntimeComponents(context *cli.Context) error {
+	if configErr, ok := context.App.Metadata["configError"].(error); ok {
+		result := checkResult{
+			Type:        "configuration",
+			Name:        "configuration",
+			Description: "runtime configuration file",
+			Status:      checkFail,
+			Found:       configErr.Error(),
+			Remediation: "install a valid configuration file",
+		}
+
+		logCheckResult(result)
+		recordCheck(result)
+
+		return configErr
+	}
+
+	runtimeConfig, ok := context.App.Metadata["runtimeConfig"].(oci.RuntimeConfig)
+	if !ok {
+		kataLog.Debug("no runtime configuration, skipping component checks")
+		return nil
+	}
+
+	hConfig := runtimeConfig.HypervisorConfig
+
+	results := []checkResult{
+		checkHypervisorVersion(runtimeConfig.HypervisorType, hConfig.HypervisorPath),
+		checkGuestAsset("kernel", hConfig.KernelPath),
+	}
+
+	if hConfig.InitrdPath != "" {
+		results = append(results, checkGuestAsset("initrd", hConfig.InitrdPath))
+	} else {
+		results = append(results, checkGuestAsset("image", hConfig.ImagePath))
+	}
+
+	results = append(results,
+		checkVSock(hConfig.UseVSock),
+		checkVirtioFSDaemon(hConfig.SharedFS, hConfig.VirtioFSDaemon),
+		checkHugePages(hConfig.HugePages, hConfig.MemorySize),
+		checkCgroupLayout())
+
+	results = append(results, checkCgroupControllers()...)
+
+	failures := 0
+
+	for _, result := range results {
+		logCheckResult(result)
+		recordCheck(result)
+
+		if result.Status == checkFail {
+			failures++
+		}
+	}
+
+	if failures != 0 {
+		return fmt.Errorf("ERROR: %d runtime component checks failed", failures)
+	}
+
+	return nil
+}
+
+func logCheckResult(result checkResult) {
+	fields := logrus.Fields{
+		"type":        result.Type,
+		"name":        result.Name,
+		"description": result.Description,
+	}
+
+	if result.Found != "" {
+		fields["found"] = result.Found
+	}
+
+	if result.Required != "" {
+		fields["required"] = result.Required
+	}
+
+	logger := kataLog.WithFields(fields)
+
+	switch result.Status {
+	case checkPass:
+		logger.Info("component check passed")
+	case checkWarn:
+		logger.WithField("remediation", result.Remediation).Warn("component check failed")
+	case checkFail:
+		logger.WithField("remediation", result.Remediation).Error("component check failed")
+	default:
+		logger.Debug("component check skipped")
+	}
+}
+
+// parseVersion returns the major, minor and patch numbers of the
+// specified dotted version string.
+func parseVersion(version string) ([3]int, error) {
+	var numbers [3]int
+
+	fields := strings.Split(version, ".")
+	if len(fields) < 2 || len(fields) > 3 {
+		return numbers, fmt.Errorf("invalid version %q", version)
+	}
+
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return numbers, fmt.Errorf("invalid version %q: %v", version, err)
+		}
+
+		numbers[i] = n
+	}
+
+	return numbers, nil
+}
+
+// versionLessThan returns true if version is older than required.
+func versionLessThan(version, required string) (bool, error) {
+	v, err := parseVersion(version)
+	if err != nil {
+		return false, err
+	}
+
+	r, err := parseVersion(required)
+	if err != nil {
+		return false, err
+	}
+
+	for i := range v {
+		if v[i] != r[i] {
+			return v[i] < r[i], nil
+		}
+	}
+
+	return false, nil
+}
+
+func checkHypervisorVersion(hType vc.HypervisorType, path string) checkResult {
+	result := checkResult{
+		Type:        "hypervisor",
+		Name:        string(hType),
+		Description: "hypervisor " + path,
+		Required:    minHypervisorVersions[hType],
+	}
+
+	if !katautils.FileExists(path) {
+		result.Status = checkFail
+		result.Remediation = fmt.Sprintf("install %s or change the hypervisor path in the configuration file", path)
+		return result
+	}
+
+	output, err := getCommandVersion(path)
+	if err != nil {
+		result.Status = checkFail
+		result.Remediation = fmt.Sprintf("check that '%s --version' runs", path)
+		return result
+	}
+
+	version := versionRegex.FindString(output)
+	if version == "" {
+		result.Status = checkWarn
+		result.Found = strings.SplitN(output, "\n", 2)[0]
+		result.Remediation = "check the hypervisor version manually"
+		return result
+	}
+
+	result.Found = version
+
+	if result.Required == "" {
+		result.Status = checkPass
+		return result
+	}
+
+	old, err := versionLessThan(version, result.Required)
+	if err != nil {
+		result.Status = checkWarn
+		result.Remediation = "check the hypervisor version manually"
+		return result
+	}
+
+	if old {
+		result.Status = checkFail
+		result.Remediation = fmt.Sprintf("upgrade %s to version %s or newer", path, result.Required)
+		return result
+	}
+
+	result.Status = checkPass
+	return result
+}
+
+func checkGuestAsset(name, path string) checkResult {
+	result := checkResult{
+		Type:        "guest",
+		Name:        name,
+		Description: "guest " + name + " " + path,
+	}
+
+	if path == "" {
+		result.Status = checkFail
+		result.Remediation = fmt.Sprintf("set the guest %s path in the configuration file", name)
+		return result
+	}
+
+	hash, err := types.FileHash(path, vcAnnotations.SHA512)
+	if err != nil {
+		result.Status = checkFail
+		result.Remediation = fmt.Sprintf("install the guest %s at %s: %v", name, path, err)
+		return result
+	}
+
+	result.Status = checkPass
+	result.Found = vcAnnotations.SHA512 + ":" + hash
+	return result
+}
+
+func checkVSock(required bool) checkResult {
+	result := checkResult{
+		Type:        "device",
+		Name:        "vhost-vsock",
+		Description: "Host Support for Linux VM Sockets",
+	}
+
+	if required {
+		result.Required = vcUtils.VHostVSockDevicePath
+	}
+
+	if vcUtils.SupportsVsocks() {
+		result.Status = checkPass
+		result.Found = vcUtils.VHostVSockDevicePath
+		return result
+	}
+
+	result.Status = checkWarn
+	if required {
+		result.Status = checkFail
+	}
+
+	result.Remediation = fmt.Sprintf("load the vhost_vsock module with '%s vhost_vsock'", modProbeCmd)
+	return result
+}
+
+func checkVirtioFSDaemon(sharedFS, daemon string) checkResult {
+	result := checkResult{
+		Type:        "daemon",
+		Name:        "virtiofsd",
+		Description: "virtio-fs vhost-user daemon",
+	}
+
+	if sharedFS != config.VirtioFS {
+		result.Status = checkSkip
+		return result
+	}
+
+	result.Required = daemon
+
+	if daemon == "" {
+		result.Status = checkFail
+		result.Remediation = "set the virtio-fs daemon path in the configuration file"
+		return result
+	}
+
+	if !katautils.FileExists(daemon) {
+		result.Status = checkFail
+		result.Remediation = fmt.Sprintf("install virtiofsd at %s", daemon)
+		return result
+	}
+
+	result.Status = checkPass
+	result.Found = daemon
+	return result
+}
+
+// getHugePagesInfo returns the number of free huge pages and the
+// huge page size in KiB.
+func getHugePagesInfo() (free, size uint64, err error) {
+	f, err := os.Open(procMemInfo)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	var foundFree, foundSize bool
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		switch fields[0] {
+		case "HugePages_Free:":
+			free, err = strconv.ParseUint(fields[1], 10, 64)
+			foundFree = true
+		case "Hugepagesize:":
+			size, err = strconv.ParseUint(fields[1], 10, 64)
+			foundSize = true
+		}
+
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	if !foundFree || !foundSize || size == 0 {
+		return 0, 0, errors.New("cannot find huge pages details")
+	}
+
+	return free, size, nil
+}
+
+// checkHugePages checks enough huge pages are free to back the default
+// VM memory, when the hypervisor is configured to use them.
+func checkHugePages(enabled bool, memorySizeMiB uint32) checkResult {
+	result := checkResult{
+		Type:        "memory",
+		Name:        "hugepages",
+		Description: "free huge pages",
+	}
+
+	free, size, err := getHugePagesInfo()
+	if err != nil {
+		result.Status = checkSkip
+		if enabled {
+			result.Status = checkFail
+			result.Remediation = fmt.Sprintf("use a host kernel with huge pages support: %v", err)
+		}
+
+		return result
+	}
+
+	result.Found = fmt.Sprintf("%d x %d KiB", free, size)
+
+	if !enabled {
+		result.Status = checkSkip
+		return result
+	}
+
+	memKiB := uint64(memorySizeMiB) * 1024
+	needed := (memKiB + size - 1) / size
+
+	result.Required = fmt.Sprintf("%d x %d KiB", needed, size)
+
+	if free < needed {
+		result.Status = checkFail
+		result.Remediation = "reserve more huge pages, for example through /proc/sys/vm/nr_hugepages"
+		return result
+	}
+
+	result.Status = checkPass
+	return result
+}
+
+// getCgroupLayout returns the layout of the cgroup hierarchies.
+func getCgroupLayout() string {
+	if katautils.FileExists(filepath.Join(sysFsCgroupDir, "cgroup.controllers")) {
+		return cgroupLayoutUnified
+	}
+
+	if katautils.FileExists(filepath.Join(sysFsCgroupDir, cgroupLayoutUnified)) {
+		return cgroupLayoutHybrid
+	}
+
+	return cgroupLayoutLegacy
+}
+
+// checkCgroupLayout checks the runtime cgroup v1 support can be used.
+func checkCgroupLayout() checkResult {
+	result := checkResult{
+		Type:        "cgroup",
+		Name:        "layout",
+		Description: "cgroup hierarchies layout",
+		Found:       getCgroupLayout(),
+		Required:    cgroupLayoutLegacy + " or " + cgroupLayoutHybrid,
+	}
+
+	if result.Found == cgroupLayoutUnified {
+		result.Status = checkFail
+		result.Remediation = "boot the host with systemd.unified_cgroup_hierarchy=0"
+		return result
+	}
+
+	result.Status = checkPass
+	return result
+}
+
+// getEnabledCgroupControllers returns the cgroup controllers enabled on
+// the host.
+func getEnabledCgroupControllers() (map[string]bool, error) {
+	f, err := os.Open(procCgroups)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	controllers := make(map[string]bool)
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// subsys_name hierarchy num_cgroups enabled
+		fields := strings.Fields(line)
+		if len(fields) != 4 {
+			continue
+		}
+
+		controllers[fields[0]] = fields[3] == "1"
+	}
+
+	return controllers, scanner.Err()
+}
+
+func checkCgroupControllers() []checkResult {
+	var results []checkResult
+
+	controllers, err := getEnabledCgroupControllers()
+
+	for _, controller := range requiredCgroupControllers {
+		result := checkResult{
+			Type:        "cgroup",
+			Name:        controller,
+			Description: "cgroup controller",
+			Required:    controller,
+		}
+
+		switch {
+		case err != nil:
+			result.Status = checkFail
+			result.Remediation = fmt.Sprintf("check %s can be read: %v", procCgroups, err)
+		case !controllers[controller]:
+			result.Status = checkFail
+			result.Remediation = fmt.Sprintf("enable the %s cgroup controller in the host kernel", controller)
+		default:
+			result.Status = checkPass
+			result.Found = controller
+		}
+
+		results = append(results, result)
+	}
+
+	return results
+}
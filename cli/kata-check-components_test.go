@@ -0,0 +1,289 @@
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	vc "github.com/kata-containers/runtime/virtcontainers"
+	"github.com/kata-containers/runtime/virtcontainers/device/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersionLessThan(t *testing.T) {
+	assert := assert.New(t)
+
+	type testData struct {
+		version     string
+		required    string
+		expectLess  bool
+		expectError bool
+	}
+
+	data := []testData{
+		{"4.0.0", "2.11.0", false, false},
+		{"2.11.0", "2.11.0", false, false},
+		{"2.11", "2.11.0", false, false},
+		{"2.10.1", "2.11.0", true, false},
+		{"0.16.9", "0.17.0", true, false},
+		{"1", "0.17.0", false, true},
+		{"a.b.c", "0.17.0", false, true},
+		{"0.17.0", "x.y", false, true},
+	}
+
+	for i, d := range data {
+		less, err := versionLessThan(d.version, d.required)
+		if d.expectError {
+			assert.Error(err, "test %d (%+v)", i, d)
+			continue
+		}
+
+		assert.NoError(err, "test %d (%+v)", i, d)
+		assert.Equal(d.expectLess, less, "test %d (%+v)", i, d)
+	}
+}
+
+func TestCheckHypervisorVersion(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	hypervisor := filepath.Join(dir, "hypervisor")
+
+	// Doesn't exist
+	result := checkHypervisorVersion(vc.QemuHypervisor, hypervisor)
+	assert.Equal(checkFail, result.Status)
+	assert.NotEmpty(result.Remediation)
+
+	for _, d := range []struct {
+		version string
+		status  checkStatus
+	}{
+		{"4.0.0", checkPass},
+		{"2.5.0", checkFail},
+	} {
+		script := fmt.Sprintf("#!/bin/sh\necho 'QEMU emulator version %s'\n", d.version)
+		err = ioutil.WriteFile(hypervisor, []byte(script), testExeFileMode)
+		assert.NoError(err)
+
+		result = checkHypervisorVersion(vc.QemuHypervisor, hypervisor)
+		assert.Equal(d.status, result.Status)
+		assert.Equal(d.version, result.Found)
+		assert.Equal(minHypervisorVersions[vc.QemuHypervisor], result.Required)
+	}
+
+	// No known minimum version
+	result = checkHypervisorVersion(vc.MockHypervisor, hypervisor)
+	assert.Equal(checkPass, result.Status)
+	assert.Empty(result.Required)
+}
+
+func TestCheckGuestAsset(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	result := checkGuestAsset("kernel", "")
+	assert.Equal(checkFail, result.Status)
+
+	kernel := filepath.Join(dir, "kernel")
+
+	result = checkGuestAsset("kernel", kernel)
+	assert.Equal(checkFail, result.Status)
+
+	// Empty file
+	err = createFile(kernel, "")
+	assert.NoError(err)
+
+	result = checkGuestAsset("kernel", kernel)
+	assert.Equal(checkFail, result.Status)
+
+	err = createFile(kernel, "foo")
+	assert.NoError(err)
+
+	result = checkGuestAsset("kernel", kernel)
+	assert.Equal(checkPass, result.Status)
+	assert.True(strings.HasPrefix(result.Found, "sha512:"))
+
+	// SHA-512 of "foo"
+	assert.Equal("sha512:f7fbba6e0636f890e56fbbf3283e524c6fa3204ae298382d624741d0dc6638326e282c41be5e4254d8820772c5518a2c5a8c0c7f7eda19594a7eb539453e1ed7", result.Found)
+}
+
+func TestCheckVirtioFSDaemon(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	result := checkVirtioFSDaemon(config.Virtio9P, "")
+	assert.Equal(checkSkip, result.Status)
+
+	result = checkVirtioFSDaemon(config.VirtioFS, "")
+	assert.Equal(checkFail, result.Status)
+
+	daemon := filepath.Join(dir, "virtiofsd")
+
+	result = checkVirtioFSDaemon(config.VirtioFS, daemon)
+	assert.Equal(checkFail, result.Status)
+	assert.Equal(daemon, result.Required)
+
+	err = createFile(daemon, "")
+	assert.NoError(err)
+
+	result = checkVirtioFSDaemon(config.VirtioFS, daemon)
+	assert.Equal(checkPass, result.Status)
+	assert.Equal(daemon, result.Found)
+}
+
+func TestCheckHugePages(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	savedProcMemInfo := procMemInfo
+	defer func() {
+		procMemInfo = savedProcMemInfo
+	}()
+
+	procMemInfo = filepath.Join(dir, "meminfo")
+
+	// Doesn't exist
+	result := checkHugePages(false, 2048)
+	assert.Equal(checkSkip, result.Status)
+
+	result = checkHugePages(true, 2048)
+	assert.Equal(checkFail, result.Status)
+
+	memInfo := `MemTotal:       16312524 kB
+HugePages_Total:    1024
+HugePages_Free:     1000
+Hugepagesize:       2048 kB
+`
+	err = createFile(procMemInfo, memInfo)
+	assert.NoError(err)
+
+	result = checkHugePages(false, 2048)
+	assert.Equal(checkSkip, result.Status)
+	assert.Equal("1000 x 2048 KiB", result.Found)
+
+	result = checkHugePages(true, 2000)
+	assert.Equal(checkPass, result.Status)
+	assert.Equal("1000 x 2048 KiB", result.Required)
+
+	result = checkHugePages(true, 2048)
+	assert.Equal(checkFail, result.Status)
+	assert.Equal("1024 x 2048 KiB", result.Required)
+	assert.NotEmpty(result.Remediation)
+}
+
+func TestCheckCgroupLayout(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	savedSysFsCgroupDir := sysFsCgroupDir
+	defer func() {
+		sysFsCgroupDir = savedSysFsCgroupDir
+	}()
+
+	sysFsCgroupDir = dir
+
+	result := checkCgroupLayout()
+	assert.Equal(checkPass, result.Status)
+	assert.Equal(cgroupLayoutLegacy, result.Found)
+
+	err = os.MkdirAll(filepath.Join(dir, "unified"), testDirMode)
+	assert.NoError(err)
+
+	result = checkCgroupLayout()
+	assert.Equal(checkPass, result.Status)
+	assert.Equal(cgroupLayoutHybrid, result.Found)
+
+	err = createFile(filepath.Join(dir, "cgroup.controllers"), "")
+	assert.NoError(err)
+
+	result = checkCgroupLayout()
+	assert.Equal(checkFail, result.Status)
+	assert.Equal(cgroupLayoutUnified, result.Found)
+	assert.NotEmpty(result.Remediation)
+}
+
+func TestCheckCgroupControllers(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	savedProcCgroups := procCgroups
+	defer func() {
+		procCgroups = savedProcCgroups
+	}()
+
+	procCgroups = filepath.Join(dir, "cgroups")
+
+	// Doesn't exist
+	for _, result := range checkCgroupControllers() {
+		assert.Equal(checkFail, result.Status)
+	}
+
+	cgroups := `#subsys_name	hierarchy	num_cgroups	enabled
+cpuset	2	1	1
+cpu	3	64	1
+devices	5	64	1
+memory	4	64	0
+`
+	err = createFile(procCgroups, cgroups)
+	assert.NoError(err)
+
+	results := checkCgroupControllers()
+	assert.Len(results, len(requiredCgroupControllers))
+
+	for _, result := range results {
+		if result.Name == "memory" {
+			assert.Equal(checkFail, result.Status)
+			assert.NotEmpty(result.Remediation)
+		} else {
+			assert.Equal(checkPass, result.Status, "controller %s", result.Name)
+		}
+	}
+}
+
+func TestCheckRuntimeComponentsNoConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	resetCheckResults()
+
+	ctx := createCheckCLIContext(checkFormatJSON)
+
+	// No configuration, nothing to check
+	err := checkRuntimeComponents(ctx)
+	assert.NoError(err)
+	assert.Empty(checkResults)
+
+	// Invalid configuration
+	ctx.App.Metadata["configError"] = errors.New("invalid configuration")
+
+	err = checkRuntimeComponents(ctx)
+	assert.Error(err)
+	assert.Len(checkResults, 1)
+	assert.Equal(checkFail, checkResults[0].Status)
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+)
+
+// checkStatus is the outcome of a single kata-check check.
+type checkStatus string
+
+const (
+	// checkPass means the host satisfies the check.
+	checkPass checkStatus = "pass"
+
+	// checkWarn means the check failed but is not fatal.
+	checkWarn checkStatus = "warn"
+
+	// checkFail means the host does not satisfy the check.
+	checkFail checkStatus = "fail"
+
+	// checkSkip means the check does not apply to the configuration.
+	checkSkip checkStatus = "skip"
+)
+
+const (
+	checkFormatText = "text"
+	checkFormatJSON = "json"
+)
+
+// checkResult describes a single check of the kata-check report.
+type checkResult struct {
+	// Type is the kind of check ("flag", "module", "hypervisor", ...)
+	Type string `json:"type"`
+
+	// Name identifies the item checked
+	Name string `json:"name"`
+
+	// Description is a human-readable description of the item checked
+	Description string `json:"description,omitempty"`
+
+	// Status is the outcome of the check
+	Status checkStatus `json:"status"`
+
+	// Found is the value found on the host
+	Found string `json:"found,omitempty"`
+
+	// Required is the value required by the runtime
+	Required string `json:"required,omitempty"`
+
+	// Remediation tells how to fix a failed check
+	Remediation string `json:"remediation,omitempty"`
+}
+
+// checkReport is the machine-readable kata-check report.
+type checkReport struct {
+	// Capable is true if all the checks passed
+	Capable bool `json:"capable"`
+
+	// Error is the error which made kata-check fail
+	Error string `json:"error,omitempty"`
+
+	Checks []checkResult `json:"checks"`
+}
+
+// checkResults holds the results of the checks run so far.
+var checkResults []checkResult
+
+func resetCheckResults() {
+	checkResults = []checkResult{}
+}
+
+func recordCheck(result checkResult) {
+	checkResults = append(checkResults, result)
+}
+
+func newCheckReport(checkErr error) checkReport {
+	report := checkReport{
+		Capable: checkErr == nil,
+		Checks:  checkResults,
+	}
+
+	if report.Checks == nil {
+		report.Checks = []checkResult{}
+	}
+
+	if checkErr != nil {
+		report.Error = checkErr.Error()
+	}
+
+	return report
+}
+
+func writeJSONCheckReport(file *os.File, checkErr error) error {
+	if file == nil {
+		return errors.New("Invalid output file specified")
+	}
+
+	encoder := json.NewEncoder(file)
+
+	// Make it more human readable
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(newCheckReport(checkErr))
+}
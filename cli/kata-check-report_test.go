@@ -0,0 +1,90 @@
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+func readJSONCheckReport(assert *assert.Assertions, file *os.File) checkReport {
+	_, err := file.Seek(0, 0)
+	assert.NoError(err)
+
+	bytes, err := ioutil.ReadAll(file)
+	assert.NoError(err)
+
+	var report checkReport
+	err = json.Unmarshal(bytes, &report)
+	assert.NoError(err)
+
+	return report
+}
+
+func TestWriteJSONCheckReport(t *testing.T) {
+	assert := assert.New(t)
+
+	err := writeJSONCheckReport(nil, nil)
+	assert.Error(err)
+
+	file, err := ioutil.TempFile("", "")
+	assert.NoError(err)
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	resetCheckResults()
+
+	err = writeJSONCheckReport(file, nil)
+	assert.NoError(err)
+
+	report := readJSONCheckReport(assert, file)
+	assert.True(report.Capable)
+	assert.Empty(report.Error)
+	assert.NotNil(report.Checks)
+	assert.Empty(report.Checks)
+
+	result := checkResult{
+		Type:        "module",
+		Name:        "vhost_net",
+		Description: "Host kernel accelerator for virtio network",
+		Status:      checkFail,
+		Required:    "vhost_net",
+		Remediation: "load the vhost_net module with 'modprobe vhost_net'",
+	}
+
+	recordCheck(result)
+
+	err = file.Truncate(0)
+	assert.NoError(err)
+	_, err = file.Seek(0, 0)
+	assert.NoError(err)
+
+	err = writeJSONCheckReport(file, errors.New("check failed"))
+	assert.NoError(err)
+
+	report = readJSONCheckReport(assert, file)
+	assert.False(report.Capable)
+	assert.Equal("check failed", report.Error)
+	assert.Equal([]checkResult{result}, report.Checks)
+}
+
+func TestCheckCLIFunctionInvalidFormat(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := createCheckCLIContext("yaml")
+
+	fn, ok := kataCheckCLICommand.Action.(func(context *cli.Context) error)
+	assert.True(ok)
+
+	err := fn(ctx)
+	assert.Error(err)
+}
@@ -26,6 +26,7 @@ import (
 
 	"github.com/kata-containers/runtime/pkg/katautils"
 	vc "github.com/kata-containers/runtime/virtcontainers"
+	"github.com/kata-containers/runtime/virtcontainers/pkg/oci"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
 )
@@ -159,15 +160,30 @@ func checkCPU(tag, cpuinfo string, attribs map[string]string) (count uint32) {
 			"description": desc,
 		}
 
+		result := checkResult{
+			Type:        tag,
+			Name:        attrib,
+			Description: desc,
+			Required:    attrib,
+		}
+
 		found := findAnchoredString(cpuinfo, attrib)
 		if !found {
 			kataLog.WithFields(fields).Errorf("CPU property not found")
 			count++
+
+			result.Status = checkFail
+			result.Remediation = fmt.Sprintf("use a host CPU providing %q, or enable it in the system firmware", desc)
+			recordCheck(result)
 			continue
 
 		}
 
 		kataLog.WithFields(fields).Infof("CPU property found")
+
+		result.Status = checkPass
+		result.Found = attrib
+		recordCheck(result)
 	}
 
 	return count
@@ -214,16 +230,32 @@ func checkKernelModules(modules map[string]kernelModule, handler kernelParamHand
 			"description": details.desc,
 		}
 
+		result := checkResult{
+			Type:        "module",
+			Name:        module,
+			Description: details.desc,
+			Required:    module,
+		}
+
 		if !haveKernelModule(module) {
 			kataLog.WithFields(fields).Error("kernel property not found")
+			result.Status = checkWarn
 			if details.required {
 				count++
+				result.Status = checkFail
 			}
+
+			result.Remediation = fmt.Sprintf("load the %s module with '%s %s'", module, modProbeCmd, module)
+			recordCheck(result)
 			continue
 		}
 
 		kataLog.WithFields(fields).Infof("kernel property found")
 
+		result.Status = checkPass
+		result.Found = module
+		recordCheck(result)
+
 		for param, expected := range details.parameters {
 			path := filepath.Join(sysModuleDir, module, moduleParamDir, param)
 			value, err := katautils.GetFileContents(path)
@@ -236,23 +268,40 @@ func checkKernelModules(modules map[string]kernelModule, handler kernelParamHand
 			fields["parameter"] = param
 			fields["value"] = value
 
+			result := checkResult{
+				Type:        "module parameter",
+				Name:        module + "." + param,
+				Description: details.desc,
+				Status:      checkPass,
+				Found:       value,
+				Required:    expected,
+			}
+
 			if value != expected {
 				fields["expected"] = expected
 
 				msg := "kernel module parameter has unexpected value"
+				result.Remediation = fmt.Sprintf("set the %s parameter of the %s module to %q", param, module, expected)
 
 				if handler != nil {
 					ignoreError := handler(onVMM, fields, msg)
 					if ignoreError {
+						result.Status = checkWarn
+						recordCheck(result)
 						continue
 					}
 				}
 
 				kataLog.WithFields(fields).Error(msg)
 				count++
+
+				result.Status = checkFail
+				recordCheck(result)
+				continue
 			}
 
 			kataLog.WithFields(fields).Info(kernelPropertyCorrect)
+			recordCheck(result)
 		}
 	}
 
@@ -299,9 +348,73 @@ func genericHostIsVMContainerCapable(details vmContainerCapableDetails) error {
 	return fmt.Errorf("ERROR: %s", failMessage)
 }
 
+// requireKernelModule returns a copy of the modules, where the module name
+// is required.
+func requireKernelModule(modules map[string]kernelModule, name string) map[string]kernelModule {
+	required := make(map[string]kernelModule, len(modules))
+	for k, v := range modules {
+		required[k] = v
+	}
+
+	if module, ok := required[name]; ok {
+		module.required = true
+		required[name] = module
+	}
+
+	return required
+}
+
+// hostIsCapable runs the host checks, logging and recording all results.
+// The vhost_vsock module is required when the hypervisor, such as
+// firecracker, talks to the agent through a vsock.
+func hostIsCapable(requireVSock bool) error {
+	err := setCPUtype()
+	if err != nil {
+		return err
+	}
+
+	modules := archRequiredKernelModules
+	if requireVSock {
+		modules = requireKernelModule(modules, "vhost_vsock")
+	}
+
+	details := vmContainerCapableDetails{
+		cpuInfoFile:           procCPUInfo,
+		requiredCPUFlags:      archRequiredCPUFlags,
+		requiredCPUAttribs:    archRequiredCPUAttribs,
+		requiredKernelModules: modules,
+	}
+
+	err = hostIsVMContainerCapable(details)
+
+	if err != nil {
+		return err
+	}
+
+	kataLog.Info(successMessageCapable)
+
+	if os.Geteuid() == 0 {
+		err = archHostCanCreateVMContainer()
+		if err != nil {
+			return err
+		}
+
+		kataLog.Info(successMessageCreate)
+	}
+
+	return nil
+}
+
 var kataCheckCLICommand = cli.Command{
 	Name:  checkCmd,
 	Usage: "tests if system can run " + project,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "format",
+			Value: checkFormatText,
+			Usage: `output format ("text" logs each check, "json" also writes a report to stdout)`,
+		},
+	},
 	Action: func(context *cli.Context) error {
 		ctx, err := cliContextToContext(context)
 		if err != nil {
@@ -311,36 +424,35 @@ var kataCheckCLICommand = cli.Command{
 		span, _ := katautils.Trace(ctx, "kata-check")
 		defer span.Finish()
 
-		err = setCPUtype()
-		if err != nil {
-			return err
+		format := context.String("format")
+		if format == "" {
+			format = checkFormatText
 		}
 
-		details := vmContainerCapableDetails{
-			cpuInfoFile:           procCPUInfo,
-			requiredCPUFlags:      archRequiredCPUFlags,
-			requiredCPUAttribs:    archRequiredCPUAttribs,
-			requiredKernelModules: archRequiredKernelModules,
+		if format != checkFormatText && format != checkFormatJSON {
+			return fmt.Errorf("unknown format %q", format)
 		}
 
-		err = hostIsVMContainerCapable(details)
+		resetCheckResults()
 
-		if err != nil {
-			return err
-		}
+		runtimeConfig, ok := context.App.Metadata["runtimeConfig"].(oci.RuntimeConfig)
+		requireVSock := ok && runtimeConfig.HypervisorConfig.UseVSock
 
-		kataLog.Info(successMessageCapable)
+		// Run all the checks, even if the host checks failed, so
+		// that the report is complete.
+		err = hostIsCapable(requireVSock)
 
-		if os.Geteuid() == 0 {
-			err = archHostCanCreateVMContainer()
-			if err != nil {
-				return err
-			}
+		if compErr := checkRuntimeComponents(context); compErr != nil && err == nil {
+			err = compErr
+		}
 
-			kataLog.Info(successMessageCreate)
+		if format == checkFormatJSON {
+			if reportErr := writeJSONCheckReport(defaultOutputFile, err); reportErr != nil {
+				return reportErr
+			}
 		}
 
-		return nil
+		return err
 	},
 }
 
@@ -371,8 +483,18 @@ func genericArchKernelParamHandler(onVMM bool, fields logrus.Fields, msg string)
 func genericKvmIsUsable() error {
 	flags := syscall.O_RDWR | syscall.O_CLOEXEC
 
+	deviceResult := checkResult{
+		Type:        "device",
+		Name:        kvmDevice,
+		Description: "KVM device",
+		Required:    kvmDevice,
+	}
+
 	f, err := syscall.Open(kvmDevice, flags, 0)
 	if err != nil {
+		deviceResult.Status = checkFail
+		deviceResult.Remediation = fmt.Sprintf("load the KVM modules and check %s is accessible", kvmDevice)
+		recordCheck(deviceResult)
 		return err
 	}
 	defer syscall.Close(f)
@@ -381,21 +503,38 @@ func genericKvmIsUsable() error {
 
 	fieldLogger.WithField("device", kvmDevice).Info("device available")
 
+	deviceResult.Status = checkPass
+	deviceResult.Found = kvmDevice
+	recordCheck(deviceResult)
+
+	vmResult := checkResult{
+		Type:        "feature",
+		Name:        "create-vm",
+		Description: "Create a KVM virtual machine",
+	}
+
 	vm, _, errno := syscall.Syscall(syscall.SYS_IOCTL,
 		uintptr(f),
 		uintptr(C.ioctl_KVM_CREATE_VM),
 		0)
 	if errno != 0 {
+		vmResult.Status = checkFail
+		vmResult.Found = errno.Error()
 		if errno == syscall.EBUSY {
 			fieldLogger.WithField("reason", "another hypervisor running").Error("cannot create VM")
+			vmResult.Remediation = "stop the other hypervisor using the virtualization extensions"
 		}
 
+		recordCheck(vmResult)
 		return errno
 	}
 	defer syscall.Close(int(vm))
 
 	fieldLogger.WithField("feature", "create-vm").Info("feature available")
 
+	vmResult.Status = checkPass
+	recordCheck(vmResult)
+
 	return nil
 }
 
@@ -424,15 +563,29 @@ func genericCheckKVMExtensions(extensions map[string]kvmExtension) (map[string]i
 			uintptr(C.ioctl_KVM_CHECK_EXTENSION),
 			uintptr(extension.id))
 
+		result := checkResult{
+			Type:        "kvm extension",
+			Name:        name,
+			Description: extension.desc,
+		}
+
 		// Generally return value(ret) 0 means no and 1 means yes,
 		// but some extensions may report additional information in the integer return value.
 		if errno != 0 || ret <= 0 {
 			kataLog.WithFields(fields).Error("is not supported")
+
+			result.Status = checkFail
+			result.Remediation = "use a host kernel supporting the KVM extension"
+			recordCheck(result)
 			return results, errno
 		}
 
 		results[name] = int(ret)
 		kataLog.WithFields(fields).Info("kvm extension is supported")
+
+		result.Status = checkPass
+		result.Found = fmt.Sprintf("%d", int(ret))
+		recordCheck(result)
 	}
 
 	return results, nil
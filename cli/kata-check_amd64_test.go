@@ -106,7 +106,7 @@ func TestCCCheckCLIFunction(t *testing.T) {
 
 	setupCheckHostIsVMContainerCapable(assert, cpuInfoFile, cpuData, moduleData)
 
-	ctx := createCLIContext(nil)
+	ctx := createCheckCLIContext(checkFormatText)
 	ctx.App.Name = "foo"
 
 	// create buffer to save logger output
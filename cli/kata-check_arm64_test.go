@@ -76,7 +76,7 @@ func TestCCCheckCLIFunction(t *testing.T) {
 
 	setupCheckHostIsVMContainerCapable(assert, cpuInfoFile, moduleData)
 
-	ctx := createCLIContext(nil)
+	ctx := createCheckCLIContext(checkFormatText)
 	ctx.App.Name = "foo"
 
 	// create buffer to save logger output
@@ -7,6 +7,7 @@ package main
 
 import (
 	"bytes"
+	"flag"
 	"fmt"
 	"html/template"
 	"io/ioutil"
@@ -43,6 +44,15 @@ type testCPUDetail struct {
 	expectError    bool
 }
 
+// createCheckCLIContext returns a CLI context for the check command
+// using the specified output format.
+func createCheckCLIContext(format string) *cli.Context {
+	set := flag.NewFlagSet("", 0)
+	set.String("format", format, "")
+
+	return createCLIContext(set)
+}
+
 func createFile(file, contents string) error {
 	return ioutil.WriteFile(file, []byte(contents), testFileMode)
 }
@@ -553,6 +563,32 @@ func TestCheckCheckKernelModules(t *testing.T) {
 	assert.Equal(count, uint32(0))
 }
 
+func TestCheckRequireKernelModule(t *testing.T) {
+	assert := assert.New(t)
+
+	modules := map[string]kernelModule{
+		"vhost": {
+			desc:     "vhost",
+			required: true,
+		},
+		"vhost_vsock": {
+			desc:     "vsock",
+			required: false,
+		},
+	}
+
+	required := requireKernelModule(modules, "vhost_vsock")
+	assert.True(required["vhost_vsock"].required)
+	assert.True(required["vhost"].required)
+	assert.Equal("vsock", required["vhost_vsock"].desc)
+
+	// The original modules are left untouched
+	assert.False(modules["vhost_vsock"].required)
+
+	required = requireKernelModule(modules, "foo")
+	assert.Equal(modules, required)
+}
+
 func TestCheckCheckKernelModulesUnreadableFile(t *testing.T) {
 	assert := assert.New(t)
 
@@ -668,7 +704,7 @@ func TestCheckCLIFunctionFail(t *testing.T) {
 		procCPUInfo = oldProcCPUInfo
 	}()
 
-	ctx := createCLIContext(nil)
+	ctx := createCheckCLIContext(checkFormatText)
 	ctx.App.Name = "foo"
 
 	fn, ok := kataCheckCLICommand.Action.(func(context *cli.Context) error)
@@ -0,0 +1,100 @@
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kata-containers/runtime/pkg/katautils"
+	"github.com/kata-containers/runtime/virtcontainers/store"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+var locksCLICommand = cli.Command{
+	Name:  "locks",
+	Usage: "show the processes holding the lock of a sandbox",
+	ArgsUsage: `<container-id>
+
+   <container-id> is the ID of a container of the sandbox.`,
+	Description: `Every runtime operation on a container locks its sandbox. When an operation
+   hangs while holding the lock, the later operations on the sandbox wait
+   for it, until the "lock_timeout" of the runtime configuration expires.
+
+   The lock is not taken, the processes holding it are listed with the time
+   they took it. Stale holders died while holding the lock, which was
+   released with them.`,
+	Action: func(context *cli.Context) error {
+		ctx, err := cliContextToContext(context)
+		if err != nil {
+			return err
+		}
+
+		return locks(ctx, context.Args().First(), defaultOutputFile)
+	},
+}
+
+func locks(ctx context.Context, containerID string, out io.Writer) error {
+	// The sandbox ID is looked up without locking the sandbox.
+	sandboxID, err := katautils.FetchContainerIDMapping(containerID)
+	if err != nil {
+		return err
+	}
+
+	if sandboxID == "" {
+		return fmt.Errorf("Container ID (%v) does not exist", containerID)
+	}
+
+	kataLog = kataLog.WithFields(logrus.Fields{
+		"container": containerID,
+		"sandbox":   sandboxID,
+	})
+	setExternalLoggers(ctx, kataLog)
+
+	holders, err := vci.SandboxLockHolders(ctx, sandboxID)
+	if err != nil {
+		return err
+	}
+
+	return locksWrite(holders, out)
+}
+
+func locksWrite(holders []store.LockHolder, out io.Writer) error {
+	// values used by runc
+	flags := uint(0)
+	minWidth := 12
+	tabWidth := 1
+	padding := 3
+
+	w := tabwriter.NewWriter(out, minWidth, tabWidth, padding, ' ', flags)
+
+	fmt.Fprint(w, "PID\tMODE\tACQUIRED\tSTATUS\tCOMMAND\n")
+
+	for _, h := range holders {
+		mode := "shared"
+		if h.Exclusive {
+			mode = "exclusive"
+		}
+
+		status := "held"
+		if h.Stale {
+			status = "stale"
+		}
+
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n",
+			h.Pid,
+			mode,
+			h.Acquired.Format(time.RFC3339Nano),
+			status,
+			h.Command)
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/kata-containers/runtime/virtcontainers/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocksMissingContainer(t *testing.T) {
+	assert := assert.New(t)
+
+	var out bytes.Buffer
+
+	err := locks(context.Background(), "", &out)
+	assert.Error(err)
+
+	path, err := createTempContainerIDMapping("foo", testSandboxID)
+	assert.NoError(err)
+	defer os.RemoveAll(path)
+
+	err = locks(context.Background(), testContainerID, &out)
+	assert.Error(err)
+}
+
+func TestLocksFailure(t *testing.T) {
+	assert := assert.New(t)
+
+	path, err := createTempContainerIDMapping(testContainerID, testSandboxID)
+	assert.NoError(err)
+	defer os.RemoveAll(path)
+
+	testingImpl.SandboxLockHoldersFunc = func(ctx context.Context, sandboxID string) ([]store.LockHolder, error) {
+		return nil, errors.New("no such sandbox")
+	}
+	defer func() {
+		testingImpl.SandboxLockHoldersFunc = nil
+	}()
+
+	var out bytes.Buffer
+
+	err = locks(context.Background(), testContainerID, &out)
+	assert.Error(err)
+}
+
+func TestLocks(t *testing.T) {
+	assert := assert.New(t)
+
+	path, err := createTempContainerIDMapping(testContainerID, testSandboxID)
+	assert.NoError(err)
+	defer os.RemoveAll(path)
+
+	testingImpl.SandboxLockHoldersFunc = func(ctx context.Context, sandboxID string) ([]store.LockHolder, error) {
+		assert.Equal(testSandboxID, sandboxID)
+		return []store.LockHolder{
+			{
+				Pid:       1234,
+				Command:   "kata-runtime state " + testContainerID,
+				Acquired:  time.Now(),
+				Exclusive: true,
+			},
+			{
+				Pid:     1235,
+				Command: "kata-runtime kill " + testContainerID,
+				Stale:   true,
+			},
+		}, nil
+	}
+	defer func() {
+		testingImpl.SandboxLockHoldersFunc = nil
+	}()
+
+	var out bytes.Buffer
+
+	err = locks(context.Background(), testContainerID, &out)
+	assert.NoError(err)
+
+	output := out.String()
+	assert.Contains(output, "1234")
+	assert.Contains(output, "exclusive")
+	assert.Contains(output, "held")
+	assert.Contains(output, "kata-runtime state "+testContainerID)
+	assert.Contains(output, "1235")
+	assert.Contains(output, "stale")
+}
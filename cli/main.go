@@ -134,6 +134,9 @@ var runtimeCommands = []cli.Command{
 	kataEnvCLICommand,
 	kataNetworkCLICommand,
 	factoryCLICommand,
+	debugConsoleCLICommand,
+	gcCLICommand,
+	locksCLICommand,
 }
 
 // runtimeBeforeSubcommands is the function to run before command-line
@@ -254,11 +257,17 @@ func beforeSubcommands(c *cli.Context) error {
 
 	handleShowConfig(c)
 
-	if userWantsUsage(c) || (c.NArg() == 1 && (c.Args()[0] == checkCmd)) {
+	if userWantsUsage(c) {
 		// No setup required if the user just
-		// wants to see the usage statement or are
-		// running a command that does not manipulate
-		// containers.
+		// wants to see the usage statement.
+		return nil
+	}
+
+	if c.NArg() >= 1 && c.Args()[0] == checkCmd {
+		// No setup required for a command that does not
+		// manipulate containers, but its checks need the
+		// configuration.
+		loadCheckConfiguration(c)
 		return nil
 	}
 
@@ -312,6 +321,8 @@ func beforeSubcommands(c *cli.Context) error {
 	debug = runtimeConfig.Debug
 	crashOnError = runtimeConfig.Debug
 
+	vci.SetLockTimeout(context.Background(), runtimeConfig.LockTimeout)
+
 	if traceRootSpan != "" {
 		// Create the tracer.
 		//
@@ -342,6 +353,20 @@ func beforeSubcommands(c *cli.Context) error {
 	return nil
 }
 
+// loadCheckConfiguration makes the configuration accessible to the check
+// command. A configuration error is not fatal as the command reports it
+// as a failed check.
+func loadCheckConfiguration(c *cli.Context) {
+	configFile, runtimeConfig, err := katautils.LoadConfiguration(c.GlobalString(configFilePathOption), true, false)
+	if err != nil {
+		c.App.Metadata["configError"] = err
+		return
+	}
+
+	c.App.Metadata["runtimeConfig"] = runtimeConfig
+	c.App.Metadata["configFile"] = configFile
+}
+
 // handleShowConfig determines if the user wishes to see the configuration
 // paths. If so, it will display them and then exit.
 func handleShowConfig(context *cli.Context) {
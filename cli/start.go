@@ -103,7 +103,7 @@ func start(ctx context.Context, containerID string) (vc.VCSandbox, error) {
 
 	// Run post-start OCI hooks.
 	err = katautils.EnterNetNS(sandbox.GetNetNs(), func() error {
-		return katautils.PostStartHooks(ctx, ociSpec, sandboxID, status.Annotations[vcAnnot.BundlePathKey])
+		return katautils.PostStartHooks(ctx, ociSpec, sandboxID, status.Annotations[vcAnnot.BundlePathKey], katautils.NewHookState(sandbox))
 	})
 	if err != nil {
 		return nil, err
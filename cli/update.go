@@ -7,6 +7,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -14,6 +15,7 @@ import (
 
 	"github.com/docker/go-units"
 	"github.com/kata-containers/runtime/pkg/katautils"
+	vc "github.com/kata-containers/runtime/virtcontainers"
 	"github.com/kata-containers/runtime/virtcontainers/types"
 
 	"github.com/opencontainers/runtime-spec/specs-go"
@@ -29,7 +31,18 @@ var updateCLICommand = cli.Command{
 	Name:      "update",
 	Usage:     "update container resource constraints",
 	ArgsUsage: `<container-id>`,
+	Description: `With --sandbox, <container-id> refers to a sandbox, and the vCPUs and
+   memory reserved for the sandbox itself, on top of the resources of its
+   containers, are updated from the --vcpus and --memory options.`,
 	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "sandbox",
+			Usage: "update the resources of the sandbox instead of a container",
+		},
+		cli.IntFlag{
+			Name:  "vcpus",
+			Usage: "Number of vCPUs reserved for the sandbox (with --sandbox)",
+		},
 		cli.StringFlag{
 			Name:  "resources, r",
 			Value: "",
@@ -109,7 +122,7 @@ other options are ignored.
 		},
 		cli.StringFlag{
 			Name:  "memory",
-			Usage: "Memory limit (in bytes)",
+			Usage: "Memory limit (in bytes), or memory reserved for the sandbox with --sandbox",
 		},
 		cli.StringFlag{
 			Name:  "memory-reservation",
@@ -164,6 +177,10 @@ other options are ignored.
 		span.SetTag("container", containerID)
 		span.SetTag("sandbox", sandboxID)
 
+		if context.Bool("sandbox") {
+			return updateSandbox(ctx, context, sandboxID)
+		}
+
 		// container MUST be running
 		if state := status.State.State; !(state == types.StateRunning || state == types.StateReady) {
 			return fmt.Errorf("Container %s is not running or Ready, the state is %s", containerID, state)
@@ -286,3 +303,39 @@ other options are ignored.
 		return vci.UpdateContainer(ctx, sandboxID, containerID, r)
 	},
 }
+
+// updateSandbox sets the vCPUs and the memory reserved for the sandbox
+// itself, unset options keeping their current values.
+func updateSandbox(ctx context.Context, context *cli.Context, sandboxID string) error {
+	var resources vc.SandboxResources
+
+	if val := context.Int("vcpus"); val < 0 {
+		return fmt.Errorf("invalid value for vcpus: %d", val)
+	} else if val > 0 {
+		resources.VCPUs = uint32(val)
+	}
+
+	if val := context.String("memory"); val != "" {
+		v, err := units.RAMInBytes(val)
+		if err != nil {
+			return fmt.Errorf("invalid value for memory: %s", err)
+		}
+
+		if v < units.MiB {
+			return fmt.Errorf("invalid value for memory: %s is less than 1MiB", val)
+		}
+
+		resources.MemoryMB = uint32(v / units.MiB)
+	}
+
+	if resources.VCPUs == 0 && resources.MemoryMB == 0 {
+		return fmt.Errorf("Missing sandbox resources, set vcpus or memory")
+	}
+
+	kataLog.WithFields(logrus.Fields{
+		"vcpus":     resources.VCPUs,
+		"memory-mb": resources.MemoryMB,
+	}).Info("updating sandbox resources")
+
+	return vci.UpdateSandbox(ctx, sandboxID, resources)
+}
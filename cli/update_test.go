@@ -206,3 +206,83 @@ func TestUpdateCLISuccessful(t *testing.T) {
 	err = actionFunc(ctx)
 	assert.NoError(err)
 }
+
+func TestUpdateCLISandbox(t *testing.T) {
+	assert := assert.New(t)
+
+	sandbox := &vcmock.Sandbox{
+		MockID: testContainerID,
+	}
+
+	sandbox.MockContainers = []*vcmock.Container{
+		{
+			MockID:      sandbox.ID(),
+			MockSandbox: sandbox,
+		},
+	}
+
+	testingImpl.StatusContainerFunc = func(ctx context.Context, sandboxID, containerID string) (vc.ContainerStatus, error) {
+		return vc.ContainerStatus{
+			ID: sandbox.ID(),
+			Annotations: map[string]string{
+				vcAnnotations.ContainerTypeKey: string(vc.PodSandbox),
+			},
+			State: types.ContainerState{
+				State: types.StateRunning,
+			},
+		}, nil
+	}
+
+	var updated vc.SandboxResources
+	testingImpl.UpdateSandboxFunc = func(ctx context.Context, sandboxID string, resources vc.SandboxResources) error {
+		assert.Equal(sandbox.ID(), sandboxID)
+		updated = resources
+		return nil
+	}
+	defer func() {
+		testingImpl.StatusContainerFunc = nil
+		testingImpl.UpdateSandboxFunc = nil
+	}()
+
+	path, err := createTempContainerIDMapping(sandbox.ID(), sandbox.ID())
+	assert.NoError(err)
+	defer os.RemoveAll(path)
+	actionFunc, ok := updateCLICommand.Action.(func(ctx *cli.Context) error)
+	assert.True(ok)
+
+	type testData struct {
+		vcpus    int
+		memory   string
+		valid    bool
+		expected vc.SandboxResources
+	}
+
+	data := []testData{
+		{0, "", false, vc.SandboxResources{}},
+		{-1, "", false, vc.SandboxResources{}},
+		{0, "foo", false, vc.SandboxResources{}},
+		{0, "1000", false, vc.SandboxResources{}},
+		{2, "", true, vc.SandboxResources{VCPUs: 2}},
+		{0, "512M", true, vc.SandboxResources{MemoryMB: 512}},
+		{4, "2G", true, vc.SandboxResources{VCPUs: 4, MemoryMB: 2048}},
+	}
+
+	for _, d := range data {
+		updated = vc.SandboxResources{}
+
+		flagSet := flag.NewFlagSet("update", flag.ContinueOnError)
+		flagSet.Bool("sandbox", true, "")
+		flagSet.Int("vcpus", d.vcpus, "")
+		flagSet.String("memory", d.memory, "")
+		flagSet.Parse([]string{testContainerID})
+		ctx := createCLIContext(flagSet)
+
+		err = actionFunc(ctx)
+		if d.valid {
+			assert.NoError(err, "test data: %+v", d)
+		} else {
+			assert.Error(err, "test data: %+v", d)
+		}
+		assert.Equal(d.expected, updated, "test data: %+v", d)
+	}
+}
@@ -15,7 +15,9 @@ import (
 
 	"github.com/containerd/typeurl"
 	vc "github.com/kata-containers/runtime/virtcontainers"
+	vcAnnotations "github.com/kata-containers/runtime/virtcontainers/pkg/annotations"
 	"github.com/kata-containers/runtime/virtcontainers/pkg/oci"
+	opentracing "github.com/opentracing/opentracing-go"
 	"github.com/pkg/errors"
 
 	taskAPI "github.com/containerd/containerd/runtime/v2/task"
@@ -65,6 +67,14 @@ func create(ctx context.Context, s *service, r *taskAPI.CreateTaskRequest) (*con
 			return nil, err
 		}
 
+		if s.config.Trace {
+			span, err := startTracing(s, ociSpec)
+			if err != nil {
+				return nil, err
+			}
+			defer span.Finish()
+		}
+
 		defer func() {
 			if err != nil && s.mount {
 				if err2 := mount.UnmountAll(rootfs, 0); err2 != nil {
@@ -125,6 +135,41 @@ func create(ctx context.Context, s *service, r *taskAPI.CreateTaskRequest) (*con
 	return container, nil
 }
 
+// startTracing creates the shim tracer and the root span of the sandbox,
+// which is stored in the service context so that the sandbox, hypervisor and
+// agent spans are its children. The root span is itself a child of the trace
+// context the caller passes through the sandbox annotations, if any. The
+// ttrpc requests of containerd do not carry any trace context.
+func startTracing(s *service, ociSpec *oci.CompatOCISpec) (opentracing.Span, error) {
+	tracer, err := katautils.CreateTracer(shimTracerName)
+	if err != nil {
+		return nil, err
+	}
+
+	var opts []opentracing.StartSpanOption
+	if parent := traceParent(tracer, ociSpec); parent != nil {
+		opts = append(opts, opentracing.ChildOf(parent))
+	}
+
+	span := tracer.StartSpan("create", opts...)
+	span.SetTag("source", "runtime")
+	span.SetTag("subsystem", "shim")
+	span.SetTag("sandbox", s.id)
+
+	s.ctx = opentracing.ContextWithSpan(s.ctx, span)
+
+	return span, nil
+}
+
+// traceParent returns the trace context set in the sandbox annotations, if any.
+func traceParent(tracer opentracing.Tracer, ociSpec *oci.CompatOCISpec) opentracing.SpanContext {
+	if ociSpec == nil {
+		return nil
+	}
+
+	return katautils.ExtractSpanContext(tracer, ociSpec.Annotations[vcAnnotations.TraceContext])
+}
+
 func loadSpec(r *taskAPI.CreateTaskRequest) (*oci.CompatOCISpec, string, error) {
 	// Checks the MUST and MUST NOT from OCI runtime specification
 	bundlePath, err := validBundle(r.ID, r.Bundle)
@@ -174,6 +219,8 @@ func loadRuntimeConfig(s *service, r *taskAPI.CreateTaskRequest) (*oci.RuntimeCo
 		return nil, err
 	}
 
+	vci.SetLockTimeout(s.ctx, runtimeConfig.LockTimeout)
+
 	// For the unit test, the config will be predefined
 	if s.config == nil {
 		s.config = &runtimeConfig
@@ -17,12 +17,15 @@ import (
 	taskAPI "github.com/containerd/containerd/runtime/v2/task"
 
 	vc "github.com/kata-containers/runtime/virtcontainers"
+	vcAnnotations "github.com/kata-containers/runtime/virtcontainers/pkg/annotations"
+	"github.com/kata-containers/runtime/virtcontainers/pkg/oci"
 	"github.com/kata-containers/runtime/virtcontainers/pkg/vcmock"
 
 	ktu "github.com/kata-containers/runtime/pkg/katatestutils"
 	"github.com/kata-containers/runtime/pkg/katautils"
 	specs "github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/stretchr/testify/assert"
+	jaeger "github.com/uber/jaeger-client-go"
 )
 
 func TestCreateSandboxSuccess(t *testing.T) {
@@ -369,3 +372,30 @@ func TestCreateContainerConfigFail(t *testing.T) {
 	_, err = s.Create(ctx, req)
 	assert.Error(err)
 }
+
+func TestTraceParent(t *testing.T) {
+	assert := assert.New(t)
+
+	tracer, closer := jaeger.NewTracer("test", jaeger.NewConstSampler(true), jaeger.NewNullReporter())
+	defer closer.Close()
+
+	// no trace context propagated
+	assert.Nil(traceParent(tracer, nil))
+	assert.Nil(traceParent(tracer, &oci.CompatOCISpec{}))
+
+	// trace context propagated through the sandbox annotations
+	ociSpec := &oci.CompatOCISpec{
+		Spec: specs.Spec{
+			Annotations: map[string]string{
+				vcAnnotations.TraceContext: "abc:def:0:1",
+			},
+		},
+	}
+	parent := traceParent(tracer, ociSpec)
+	assert.NotNil(parent)
+	assert.Equal("abc", parent.(jaeger.SpanContext).TraceID().String())
+
+	// invalid trace context
+	ociSpec.Annotations[vcAnnotations.TraceContext] = "foo"
+	assert.Nil(traceParent(tracer, ociSpec))
+}
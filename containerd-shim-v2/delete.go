@@ -35,7 +35,7 @@ func deleteContainer(ctx context.Context, s *service, c *container) error {
 	}
 
 	// Run post-stop OCI hooks.
-	if err := katautils.PostStopHooks(ctx, *c.spec, s.sandbox.ID(), c.bundle); err != nil {
+	if err := katautils.PostStopHooks(ctx, *c.spec, s.sandbox.ID(), c.bundle, katautils.NewHookState(s.sandbox)); err != nil {
 		return err
 	}
 
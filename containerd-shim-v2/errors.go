@@ -29,6 +29,11 @@ func toGRPC(err error) error {
 	}
 
 	err = errors.Cause(err)
+	if agentErr, ok := err.(*vc.AgentError); ok {
+		// keep the code of the failed agent request
+		return agentErr.GRPCStatus().Err()
+	}
+
 	switch {
 	case isInvalidArgument(err):
 		return status.Errorf(codes.InvalidArgument, err.Error())
@@ -6,11 +6,14 @@
 package containerdshim
 
 import (
+	"errors"
 	"syscall"
 	"testing"
 
 	vc "github.com/kata-containers/runtime/virtcontainers/pkg/types"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestToGRPC(t *testing.T) {
@@ -27,3 +30,17 @@ func TestToGRPC(t *testing.T) {
 		assert.True(isGRPCError(err))
 	}
 }
+
+func TestToGRPCAgentError(t *testing.T) {
+	assert := assert.New(t)
+
+	agentErr := &vc.AgentError{
+		Request: "grpc.CreateContainerRequest",
+		Code:    codes.DeadlineExceeded,
+		Err:     errors.New("context deadline exceeded"),
+	}
+
+	err := toGRPCf(agentErr, "creating container")
+	assert.True(isGRPCError(err))
+	assert.Equal(codes.DeadlineExceeded, status.Code(err))
+}
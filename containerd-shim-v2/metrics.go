@@ -19,7 +19,13 @@ func marshalMetrics(s *service, containerID string) (*google_protobuf.Any, error
 		return nil, err
 	}
 
-	metrics := statsToMetrics(stats.CgroupStats)
+	metrics := statsToMetrics(&stats)
+
+	// The VMM statistics cover the whole sandbox: they are reported with
+	// the sandbox container metrics only, not as each container ones.
+	if containerID == s.sandbox.ID() && stats.HypervisorStats != nil {
+		metrics.Blkio = vmmBlkIOStat(&stats.HypervisorStats.Block)
+	}
 
 	data, err := typeurl.MarshalAny(metrics)
 	if err != nil {
@@ -29,7 +35,13 @@ func marshalMetrics(s *service, containerID string) (*google_protobuf.Any, error
 	return data, nil
 }
 
-func statsToMetrics(cgStats *vc.CgroupStats) *cgroups.Metrics {
+// vmmBlkIODevice is the device the I/O of the VM block devices, as
+// accounted by the VMM, is reported for in the sandbox container metrics.
+const vmmBlkIODevice = "vmm"
+
+func statsToMetrics(stats *vc.ContainerStats) *cgroups.Metrics {
+	cgStats := stats.CgroupStats
+
 	var hugetlb []*cgroups.HugetlbStat
 	for _, v := range cgStats.HugetlbStats {
 		hugetlb = append(
@@ -67,3 +79,16 @@ func statsToMetrics(cgStats *vc.CgroupStats) *cgroups.Metrics {
 
 	return metrics
 }
+
+func vmmBlkIOStat(block *vc.BlockStats) *cgroups.BlkIOStat {
+	return &cgroups.BlkIOStat{
+		IoServiceBytesRecursive: []*cgroups.BlkIOEntry{
+			{Op: "Read", Device: vmmBlkIODevice, Value: block.ReadBytes},
+			{Op: "Write", Device: vmmBlkIODevice, Value: block.WriteBytes},
+		},
+		IoServicedRecursive: []*cgroups.BlkIOEntry{
+			{Op: "Read", Device: vmmBlkIODevice, Value: block.ReadCount},
+			{Op: "Write", Device: vmmBlkIODevice, Value: block.WriteCount},
+		},
+	}
+}
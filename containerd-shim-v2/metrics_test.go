@@ -0,0 +1,52 @@
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package containerdshim
+
+import (
+	"testing"
+
+	"github.com/containerd/cgroups"
+	"github.com/stretchr/testify/assert"
+
+	vc "github.com/kata-containers/runtime/virtcontainers"
+)
+
+func TestStatsToMetrics(t *testing.T) {
+	assert := assert.New(t)
+
+	stats := &vc.ContainerStats{
+		CgroupStats: &vc.CgroupStats{},
+	}
+	stats.CgroupStats.PidsStats.Current = 3
+
+	metrics := statsToMetrics(stats)
+	assert.Equal(uint64(3), metrics.Pids.Current)
+	assert.Nil(metrics.Blkio)
+
+	stats.HypervisorStats = &vc.HypervisorStats{
+		Block: vc.BlockStats{
+			ReadBytes:  1024,
+			WriteBytes: 512,
+			ReadCount:  2,
+			WriteCount: 1,
+		},
+	}
+
+	// The VMM statistics are not the container ones
+	metrics = statsToMetrics(stats)
+	assert.Nil(metrics.Blkio)
+
+	assert.Equal(&cgroups.BlkIOStat{
+		IoServiceBytesRecursive: []*cgroups.BlkIOEntry{
+			{Op: "Read", Device: vmmBlkIODevice, Value: 1024},
+			{Op: "Write", Device: vmmBlkIODevice, Value: 512},
+		},
+		IoServicedRecursive: []*cgroups.BlkIOEntry{
+			{Op: "Read", Device: vmmBlkIODevice, Value: 2},
+			{Op: "Write", Device: vmmBlkIODevice, Value: 1},
+		},
+	}, vmmBlkIOStat(&stats.HypervisorStats.Block))
+}
@@ -47,6 +47,9 @@ const (
 	// A time span used to wait for publish a containerd event,
 	// once it costs a longer time than timeOut, it will be canceld.
 	timeOut = 5 * time.Second
+
+	// shimTracerName is the service name of the shim trace spans.
+	shimTracerName = "kata-shim-v2"
 )
 
 var (
@@ -768,6 +771,9 @@ func (s *service) Shutdown(ctx context.Context, r *taskAPI.ShutdownRequest) (_ *
 	}
 	s.mu.Unlock()
 
+	// report all the spans to the collector before exiting
+	katautils.StopTracing(ctx)
+
 	s.cancel()
 
 	os.Exit(0)
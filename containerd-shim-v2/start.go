@@ -39,7 +39,7 @@ func startContainer(ctx context.Context, s *service, c *container) error {
 
 	// Run post-start OCI hooks.
 	err := katautils.EnterNetNS(s.sandbox.GetNetNs(), func() error {
-		return katautils.PostStartHooks(ctx, *c.spec, s.sandbox.ID(), c.bundle)
+		return katautils.PostStartHooks(ctx, *c.spec, s.sandbox.ID(), c.bundle, katautils.NewHookState(s.sandbox))
 	})
 	if err != nil {
 		return err
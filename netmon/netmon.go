@@ -51,8 +51,8 @@ var (
 	// version is the netmon version. This variable is populated at build time.
 	version = "unknown"
 
-	// For simplicity the code will only focus on IPv4 addresses for now.
-	netlinkFamily = netlink.FAMILY_V4
+	// Both IPv4 and IPv6 addresses and routes are monitored.
+	netlinkFamily = netlink.FAMILY_ALL
 
 	storageParentPath = "/var/run/kata-containers/netmon/sbs"
 )
@@ -272,10 +272,21 @@ func convertInterface(linkAttrs *netlink.LinkAttrs, linkType string, addrs []net
 			continue
 		}
 
+		family := netlink.FAMILY_V4
+		if addr.IP.To4() == nil {
+			family = netlink.FAMILY_V6
+
+			// The guest configures the link-local address of the
+			// interface from its hardware address.
+			if addr.IP.IsLinkLocalUnicast() {
+				continue
+			}
+		}
+
 		netMask, _ := addr.Mask.Size()
 
 		ipAddr := &vcTypes.IPAddress{
-			Family:  netlinkFamily,
+			Family:  family,
 			Address: addr.IP.String(),
 			Mask:    fmt.Sprintf("%d", netMask),
 		}
@@ -303,41 +314,54 @@ func convertInterface(linkAttrs *netlink.LinkAttrs, linkType string, addrs []net
 func convertRoutes(netRoutes []netlink.Route) []vcTypes.Route {
 	var routes []vcTypes.Route
 
-	// Ignore routes with IPv6 addresses as this is not supported
-	// by Kata yet.
 	for _, netRoute := range netRoutes {
 		dst := ""
 		if netRoute.Dst != nil {
-			if netRoute.Dst.IP.To4() != nil {
-				dst = netRoute.Dst.String()
-			} else {
-				netmonLog.WithField("destination", netRoute.Dst.IP.String()).Warn("Not IPv4 format")
+			// The guest adds the IPv6 link-local and multicast
+			// routes by itself.
+			if netRoute.Dst.IP.To4() == nil &&
+				(netRoute.Dst.IP.IsLinkLocalUnicast() || netRoute.Dst.IP.IsMulticast()) {
+				continue
 			}
+			dst = netRoute.Dst.String()
 		}
 
 		src := ""
-		if netRoute.Src.To4() != nil {
+		if netRoute.Src != nil {
 			src = netRoute.Src.String()
-		} else {
-			netmonLog.WithField("source", netRoute.Src.String()).Warn("Not IPv4 format")
 		}
 
-		gw := ""
-		if netRoute.Gw.To4() != nil {
-			gw = netRoute.Gw.String()
-		} else {
-			netmonLog.WithField("gateway", netRoute.Gw.String()).Warn("Not IPv4 format")
+		gw := netRoute.Gw
+		linkIndex := netRoute.LinkIndex
+		if len(netRoute.MultiPath) > 0 {
+			// Kata cannot set multipath routes up, they are replaced
+			// by a route through their heaviest nexthop.
+			nexthop := netRoute.MultiPath[0]
+			for _, nh := range netRoute.MultiPath[1:] {
+				if nh.Hops > nexthop.Hops {
+					nexthop = nh
+				}
+			}
+			gw = nexthop.Gw
+			linkIndex = nexthop.LinkIndex
+
+			netmonLog.WithField("nexthops", netRoute.MultiPath).Warn("Multipath route replaced by its heaviest nexthop")
+		}
+
+		gateway := ""
+		if gw != nil {
+			gateway = gw.String()
 		}
 
 		dev := ""
-		iface, err := net.InterfaceByIndex(netRoute.LinkIndex)
+		iface, err := net.InterfaceByIndex(linkIndex)
 		if err == nil {
 			dev = iface.Name
 		}
 
 		route := vcTypes.Route{
 			Dest:    dst,
-			Gateway: gw,
+			Gateway: gateway,
 			Device:  dev,
 			Source:  src,
 			Scope:   uint32(netRoute.Scope),
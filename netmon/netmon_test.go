@@ -37,6 +37,8 @@ const (
 	testHwAddr             = "02:00:ca:fe:00:48"
 	testIPAddress          = "192.168.0.15"
 	testIPAddressWithMask  = "192.168.0.15/32"
+	testIPv6Address        = "2001:db8::15"
+	testIPv6Gateway        = "2001:db8::1"
 	testScope              = 1
 	testTxQLen             = -1
 	testIfaceIndex         = 5
@@ -186,7 +188,7 @@ func TestConvertInterface(t *testing.T) {
 		HwAddr: testHwAddr,
 		IPAddresses: []*vcTypes.IPAddress{
 			{
-				Family:  netlinkFamily,
+				Family:  netlink.FAMILY_V4,
 				Address: testIPAddress,
 				Mask:    "0",
 			},
@@ -228,6 +230,78 @@ func TestConvertRoutes(t *testing.T) {
 		"Got %+v\nExpected %+v", got, expected)
 }
 
+func TestConvertInterfaceIPv6(t *testing.T) {
+	addrs := []netlink.Addr{
+		{
+			IPNet: &net.IPNet{
+				IP:   net.ParseIP(testIPv6Address),
+				Mask: net.CIDRMask(64, 128),
+			},
+		},
+		{
+			IPNet: &net.IPNet{
+				IP:   net.ParseIP("fe80::ff:fe00:48"),
+				Mask: net.CIDRMask(64, 128),
+			},
+		},
+	}
+
+	linkAttrs := &netlink.LinkAttrs{
+		Name: testIfaceName,
+		MTU:  testMTU,
+	}
+
+	got := convertInterface(linkAttrs, "", addrs)
+
+	// The link-local address is configured by the guest
+	expected := []*vcTypes.IPAddress{
+		{
+			Family:  netlink.FAMILY_V6,
+			Address: testIPv6Address,
+			Mask:    "64",
+		},
+	}
+	assert.Equal(t, expected, got.IPAddresses)
+}
+
+func TestConvertRoutesIPv6(t *testing.T) {
+	_, dst, err := net.ParseCIDR("2001:db8:1::/64")
+	assert.Nil(t, err)
+	_, linkLocal, err := net.ParseCIDR("fe80::/64")
+	assert.Nil(t, err)
+
+	routes := []netlink.Route{
+		{
+			Gw:        net.ParseIP(testIPv6Gateway),
+			LinkIndex: -1,
+		},
+		{
+			Dst:       linkLocal,
+			LinkIndex: -1,
+		},
+		{
+			Dst: dst,
+			MultiPath: []*netlink.NexthopInfo{
+				{LinkIndex: -1, Gw: net.ParseIP("2001:db8::10")},
+				{LinkIndex: -1, Gw: net.ParseIP("2001:db8::11"), Hops: 1},
+			},
+		},
+	}
+
+	expected := []vcTypes.Route{
+		{
+			Gateway: testIPv6Gateway,
+		},
+		{
+			Dest:    "2001:db8:1::/64",
+			Gateway: "2001:db8::11",
+		},
+	}
+
+	got := convertRoutes(routes)
+	assert.Equal(t, expected, got)
+}
+
 type testTeardownNetwork func()
 
 func testSetupNetwork(t *testing.T) testTeardownNetwork {
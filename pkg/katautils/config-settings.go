@@ -26,6 +26,7 @@ const defaultMemSize uint32 = 2048 // MiB
 const defaultMemSlots uint32 = 10
 const defaultMemOffset uint32 = 0 // MiB
 const defaultBridgesCount uint32 = 1
+const defaultPCIeRootPort uint32 = 0
 const defaultInterNetworkingModel = "macvtap"
 const defaultDisableBlockDeviceUse bool = false
 const defaultBlockDeviceDriver = "virtio-scsi"
@@ -41,8 +42,15 @@ const defaultEnableDebug bool = false
 const defaultDisableNestingChecks bool = false
 const defaultMsize9p uint32 = 8192
 const defaultHotplugVFIOOnRootBus bool = false
+const defaultVCPUPinning bool = false
+const defaultMemoryBalloon bool = false
+const defaultFreePageReporting bool = false
 const defaultEntropySource = "/dev/urandom"
 const defaultGuestHookPath string = ""
+const defaultVhostUserStorePath string = "/var/run/kata-containers/vhost-user/"
+
+const defaultTracingSamplerType = "const"
+const defaultTracingSamplerParam float64 = 1
 
 const defaultTemplatePath string = "/run/vc/vm/template"
 const defaultVMCacheEndpoint string = "/var/run/kata-containers/cache.sock"
@@ -10,8 +10,11 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/url"
+	"path/filepath"
 	goruntime "runtime"
 	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	vc "github.com/kata-containers/runtime/virtcontainers"
@@ -20,6 +23,7 @@ import (
 	"github.com/kata-containers/runtime/virtcontainers/pkg/oci"
 	"github.com/kata-containers/runtime/virtcontainers/utils"
 	"github.com/sirupsen/logrus"
+	jaeger "github.com/uber/jaeger-client-go"
 )
 
 const (
@@ -62,6 +66,9 @@ const (
 
 	// the maximum amount of PCI bridges that can be cold plugged in a VM
 	maxPCIBridges uint32 = 5
+
+	// the maximum amount of PCIe root ports that can be cold plugged in a VM
+	maxPCIeRootPorts uint32 = 16
 )
 
 type tomlConfig struct {
@@ -82,42 +89,56 @@ type factory struct {
 }
 
 type hypervisor struct {
-	Path                    string `toml:"path"`
-	Kernel                  string `toml:"kernel"`
-	Initrd                  string `toml:"initrd"`
-	Image                   string `toml:"image"`
-	Firmware                string `toml:"firmware"`
-	MachineAccelerators     string `toml:"machine_accelerators"`
-	KernelParams            string `toml:"kernel_params"`
-	MachineType             string `toml:"machine_type"`
-	BlockDeviceDriver       string `toml:"block_device_driver"`
-	EntropySource           string `toml:"entropy_source"`
-	SharedFS                string `toml:"shared_fs"`
-	VirtioFSDaemon          string `toml:"virtio_fs_daemon"`
-	VirtioFSCache           string `toml:"virtio_fs_cache"`
-	VirtioFSCacheSize       uint32 `toml:"virtio_fs_cache_size"`
-	BlockDeviceCacheSet     bool   `toml:"block_device_cache_set"`
-	BlockDeviceCacheDirect  bool   `toml:"block_device_cache_direct"`
-	BlockDeviceCacheNoflush bool   `toml:"block_device_cache_noflush"`
-	NumVCPUs                int32  `toml:"default_vcpus"`
-	DefaultMaxVCPUs         uint32 `toml:"default_maxvcpus"`
-	MemorySize              uint32 `toml:"default_memory"`
-	MemSlots                uint32 `toml:"memory_slots"`
-	MemOffset               uint32 `toml:"memory_offset"`
-	DefaultBridges          uint32 `toml:"default_bridges"`
-	Msize9p                 uint32 `toml:"msize_9p"`
-	DisableBlockDeviceUse   bool   `toml:"disable_block_device_use"`
-	MemPrealloc             bool   `toml:"enable_mem_prealloc"`
-	HugePages               bool   `toml:"enable_hugepages"`
-	FileBackedMemRootDir    string `toml:"file_mem_backend"`
-	Swap                    bool   `toml:"enable_swap"`
-	Debug                   bool   `toml:"enable_debug"`
-	DisableNestingChecks    bool   `toml:"disable_nesting_checks"`
-	EnableIOThreads         bool   `toml:"enable_iothreads"`
-	UseVSock                bool   `toml:"use_vsock"`
-	HotplugVFIOOnRootBus    bool   `toml:"hotplug_vfio_on_root_bus"`
-	DisableVhostNet         bool   `toml:"disable_vhost_net"`
-	GuestHookPath           string `toml:"guest_hook_path"`
+	Path                    string   `toml:"path"`
+	Kernel                  string   `toml:"kernel"`
+	Initrd                  string   `toml:"initrd"`
+	Image                   string   `toml:"image"`
+	Firmware                string   `toml:"firmware"`
+	MachineAccelerators     string   `toml:"machine_accelerators"`
+	KernelParams            string   `toml:"kernel_params"`
+	MachineType             string   `toml:"machine_type"`
+	BlockDeviceDriver       string   `toml:"block_device_driver"`
+	EntropySource           string   `toml:"entropy_source"`
+	SharedFS                string   `toml:"shared_fs"`
+	VirtioFSDaemon          string   `toml:"virtio_fs_daemon"`
+	VirtioFSCache           string   `toml:"virtio_fs_cache"`
+	VirtioFSCacheSize       uint32   `toml:"virtio_fs_cache_size"`
+	BlockDeviceCacheSet     bool     `toml:"block_device_cache_set"`
+	BlockDeviceCacheDirect  bool     `toml:"block_device_cache_direct"`
+	BlockDeviceCacheNoflush bool     `toml:"block_device_cache_noflush"`
+	NumVCPUs                int32    `toml:"default_vcpus"`
+	DefaultMaxVCPUs         uint32   `toml:"default_maxvcpus"`
+	MemorySize              uint32   `toml:"default_memory"`
+	MemSlots                uint32   `toml:"memory_slots"`
+	MemOffset               uint32   `toml:"memory_offset"`
+	DefaultBridges          uint32   `toml:"default_bridges"`
+	PCIeRootPort            uint32   `toml:"pcie_root_port"`
+	Msize9p                 uint32   `toml:"msize_9p"`
+	DisableBlockDeviceUse   bool     `toml:"disable_block_device_use"`
+	MemPrealloc             bool     `toml:"enable_mem_prealloc"`
+	HugePages               bool     `toml:"enable_hugepages"`
+	FileBackedMemRootDir    string   `toml:"file_mem_backend"`
+	Swap                    bool     `toml:"enable_swap"`
+	Debug                   bool     `toml:"enable_debug"`
+	DisableNestingChecks    bool     `toml:"disable_nesting_checks"`
+	EnableIOThreads         bool     `toml:"enable_iothreads"`
+	UseVSock                bool     `toml:"use_vsock"`
+	HotplugVFIOOnRootBus    bool     `toml:"hotplug_vfio_on_root_bus"`
+	VCPUPinning             bool     `toml:"vcpu_pinning"`
+	MemoryBalloon           bool     `toml:"enable_balloon"`
+	FreePageReporting       bool     `toml:"balloon_free_page_reporting"`
+	CustomAssetDirs         []string `toml:"custom_asset_dirs"`
+	CustomAssetManifest     string   `toml:"custom_asset_manifest"`
+	CustomAssetManifestKey  string   `toml:"custom_asset_manifest_key"`
+	DisableVhostNet         bool     `toml:"disable_vhost_net"`
+	GuestHookPath           string   `toml:"guest_hook_path"`
+	EnableVhostUserStore    bool     `toml:"enable_vhost_user_store"`
+	VhostUserStorePath      string   `toml:"vhost_user_store_path"`
+	JailerPath              string   `toml:"jailer_path"`
+	JailerUID               uint32   `toml:"jailer_uid"`
+	JailerGID               uint32   `toml:"jailer_gid"`
+	DrivePoolSize           uint32   `toml:"drive_pool_size"`
+	EnableMMDS              bool     `toml:"enable_mmds"`
 }
 
 type proxy struct {
@@ -128,8 +149,16 @@ type proxy struct {
 type runtime struct {
 	Debug               bool     `toml:"enable_debug"`
 	Tracing             bool     `toml:"enable_tracing"`
+	TracingSamplerType  string   `toml:"tracing_sampler_type"`
+	TracingSamplerParam float64  `toml:"tracing_sampler_param"`
+	TracingCollector    string   `toml:"tracing_collector_endpoint"`
+	TracingAgent        string   `toml:"tracing_agent_endpoint"`
 	DisableNewNetNs     bool     `toml:"disable_new_netns"`
 	DisableGuestSeccomp bool     `toml:"disable_guest_seccomp"`
+	ClockSyncInterval   uint32   `toml:"guest_clock_sync_interval"`
+	LockTimeout         uint32   `toml:"lock_timeout"`
+	CopyMounts          []string `toml:"copy_mounts"`
+	ReadOnlyMounts      []string `toml:"readonly_mounts"`
 	Experimental        []string `toml:"experimental"`
 	InterNetworkModel   string   `toml:"internetworking_model"`
 }
@@ -141,10 +170,15 @@ type shim struct {
 }
 
 type agent struct {
-	Debug     bool   `toml:"enable_debug"`
-	Tracing   bool   `toml:"enable_tracing"`
-	TraceMode string `toml:"trace_mode"`
-	TraceType string `toml:"trace_type"`
+	Debug        bool   `toml:"enable_debug"`
+	DebugConsole bool   `toml:"debug_console_enabled"`
+	Tracing      bool   `toml:"enable_tracing"`
+	TraceMode    string `toml:"trace_mode"`
+	TraceType    string `toml:"trace_type"`
+	CheckTimeout uint32 `toml:"check_timeout"`
+	ReqTimeout   uint32 `toml:"request_timeout"`
+	LongTimeout  uint32 `toml:"long_request_timeout"`
+	ReqRetries   uint32 `toml:"request_retries"`
 }
 
 type netmon struct {
@@ -163,6 +197,14 @@ func (h hypervisor) path() (string, error) {
 	return ResolvePath(p)
 }
 
+func (h hypervisor) jailerPath() (string, error) {
+	if h.JailerPath == "" {
+		return "", nil
+	}
+
+	return ResolvePath(h.JailerPath)
+}
+
 func (h hypervisor) kernel() (string, error) {
 	p := h.Kernel
 
@@ -315,6 +357,14 @@ func (h hypervisor) defaultBridges() uint32 {
 	return h.DefaultBridges
 }
 
+func (h hypervisor) pcieRootPort() uint32 {
+	if h.PCIeRootPort > maxPCIeRootPorts {
+		return maxPCIeRootPorts
+	}
+
+	return h.PCIeRootPort
+}
+
 func (h hypervisor) blockDeviceDriver() (string, error) {
 	supportedBlockDrivers := []string{config.VirtioSCSI, config.VirtioBlock, config.VirtioMmio, config.Nvdimm}
 
@@ -366,6 +416,27 @@ func (h hypervisor) guestHookPath() string {
 	return h.GuestHookPath
 }
 
+func (h hypervisor) vhostUserStorePath() string {
+	if h.VhostUserStorePath == "" {
+		return defaultVhostUserStorePath
+	}
+	return h.VhostUserStorePath
+}
+
+func (h hypervisor) checkCustomAssetPolicy() error {
+	for _, dir := range h.CustomAssetDirs {
+		if !filepath.IsAbs(dir) {
+			return fmt.Errorf("custom asset directory %s is not an absolute path", dir)
+		}
+	}
+
+	if h.CustomAssetManifest != "" && h.CustomAssetManifestKey == "" {
+		return errors.New("cannot enable custom asset manifest without its public key in configuration file")
+	}
+
+	return nil
+}
+
 func (h hypervisor) getInitrdAndImage() (initrd string, image string, err error) {
 	initrd, errInitrd := h.initrd()
 
@@ -417,6 +488,26 @@ func (a agent) debug() bool {
 	return a.Debug
 }
 
+func (a agent) debugConsole() bool {
+	return a.DebugConsole
+}
+
+func (a agent) checkTimeout() time.Duration {
+	return time.Duration(a.CheckTimeout) * time.Second
+}
+
+func (a agent) requestTimeout() time.Duration {
+	return time.Duration(a.ReqTimeout) * time.Second
+}
+
+func (a agent) longRequestTimeout() time.Duration {
+	return time.Duration(a.LongTimeout) * time.Second
+}
+
+func (a agent) requestRetries() uint32 {
+	return a.ReqRetries
+}
+
 func (a agent) trace() bool {
 	return a.Tracing
 }
@@ -451,6 +542,11 @@ func newFirecrackerHypervisorConfig(h hypervisor) (vc.HypervisorConfig, error) {
 		return vc.HypervisorConfig{}, err
 	}
 
+	jailer, err := h.jailerPath()
+	if err != nil {
+		return vc.HypervisorConfig{}, err
+	}
+
 	kernel, err := h.kernel()
 	if err != nil {
 		return vc.HypervisorConfig{}, err
@@ -477,28 +573,41 @@ func newFirecrackerHypervisorConfig(h hypervisor) (vc.HypervisorConfig, error) {
 		return vc.HypervisorConfig{}, errors.New("No vsock support, firecracker cannot be used")
 	}
 
+	if err := h.checkCustomAssetPolicy(); err != nil {
+		return vc.HypervisorConfig{}, err
+	}
+
 	return vc.HypervisorConfig{
-		HypervisorPath:        hypervisor,
-		KernelPath:            kernel,
-		InitrdPath:            initrd,
-		ImagePath:             image,
-		FirmwarePath:          firmware,
-		KernelParams:          vc.DeserializeParams(strings.Fields(kernelParams)),
-		NumVCPUs:              h.defaultVCPUs(),
-		DefaultMaxVCPUs:       h.defaultMaxVCPUs(),
-		MemorySize:            h.defaultMemSz(),
-		MemSlots:              h.defaultMemSlots(),
-		EntropySource:         h.GetEntropySource(),
-		DefaultBridges:        h.defaultBridges(),
-		DisableBlockDeviceUse: h.DisableBlockDeviceUse,
-		HugePages:             h.HugePages,
-		Mlock:                 !h.Swap,
-		Debug:                 h.Debug,
-		DisableNestingChecks:  h.DisableNestingChecks,
-		BlockDeviceDriver:     blockDriver,
-		EnableIOThreads:       h.EnableIOThreads,
-		UseVSock:              true,
-		GuestHookPath:         h.guestHookPath(),
+		HypervisorPath:         hypervisor,
+		JailerPath:             jailer,
+		JailerUID:              h.JailerUID,
+		JailerGID:              h.JailerGID,
+		DrivePoolSize:          h.DrivePoolSize,
+		EnableMMDS:             h.EnableMMDS,
+		KernelPath:             kernel,
+		InitrdPath:             initrd,
+		ImagePath:              image,
+		FirmwarePath:           firmware,
+		KernelParams:           vc.DeserializeParams(strings.Fields(kernelParams)),
+		NumVCPUs:               h.defaultVCPUs(),
+		DefaultMaxVCPUs:        h.defaultMaxVCPUs(),
+		MemorySize:             h.defaultMemSz(),
+		MemSlots:               h.defaultMemSlots(),
+		EntropySource:          h.GetEntropySource(),
+		DefaultBridges:         h.defaultBridges(),
+		DisableBlockDeviceUse:  h.DisableBlockDeviceUse,
+		HugePages:              h.HugePages,
+		Mlock:                  !h.Swap,
+		Debug:                  h.Debug,
+		DisableNestingChecks:   h.DisableNestingChecks,
+		BlockDeviceDriver:      blockDriver,
+		EnableIOThreads:        h.EnableIOThreads,
+		UseVSock:               true,
+		VCPUPinning:            h.VCPUPinning,
+		CustomAssetDirs:        h.CustomAssetDirs,
+		CustomAssetManifest:    h.CustomAssetManifest,
+		CustomAssetManifestKey: h.CustomAssetManifestKey,
+		GuestHookPath:          h.guestHookPath(),
 	}, nil
 }
 
@@ -552,6 +661,10 @@ func newQemuHypervisorConfig(h hypervisor) (vc.HypervisorConfig, error) {
 			errors.New("cannot enable virtio-fs without daemon path in configuration file")
 	}
 
+	if err := h.checkCustomAssetPolicy(); err != nil {
+		return vc.HypervisorConfig{}, err
+	}
+
 	useVSock := false
 	if h.useVSock() {
 		if utils.SupportsVsocks() {
@@ -578,6 +691,7 @@ func newQemuHypervisorConfig(h hypervisor) (vc.HypervisorConfig, error) {
 		MemOffset:               h.defaultMemOffset(),
 		EntropySource:           h.GetEntropySource(),
 		DefaultBridges:          h.defaultBridges(),
+		PCIeRootPort:            h.pcieRootPort(),
 		DisableBlockDeviceUse:   h.DisableBlockDeviceUse,
 		SharedFS:                sharedFS,
 		VirtioFSDaemon:          h.VirtioFSDaemon,
@@ -597,8 +711,16 @@ func newQemuHypervisorConfig(h hypervisor) (vc.HypervisorConfig, error) {
 		Msize9p:                 h.msize9p(),
 		UseVSock:                useVSock,
 		HotplugVFIOOnRootBus:    h.HotplugVFIOOnRootBus,
+		VCPUPinning:             h.VCPUPinning,
+		MemoryBalloon:           h.MemoryBalloon,
+		FreePageReporting:       h.FreePageReporting,
+		CustomAssetDirs:         h.CustomAssetDirs,
+		CustomAssetManifest:     h.CustomAssetManifest,
+		CustomAssetManifestKey:  h.CustomAssetManifestKey,
 		DisableVhostNet:         h.DisableVhostNet,
 		GuestHookPath:           h.guestHookPath(),
+		EnableVhostUserStore:    h.EnableVhostUserStore,
+		VhostUserStorePath:      h.vhostUserStorePath(),
 	}, nil
 }
 
@@ -617,6 +739,37 @@ func newFactoryConfig(f factory) (oci.FactoryConfig, error) {
 	}, nil
 }
 
+func newTraceConfig(r runtime) (oci.TraceConfig, error) {
+	samplerType := r.TracingSamplerType
+	samplerParam := r.TracingSamplerParam
+
+	switch samplerType {
+	case "":
+		samplerType = defaultTracingSamplerType
+		samplerParam = defaultTracingSamplerParam
+	case jaeger.SamplerTypeConst, jaeger.SamplerTypeRateLimiting, jaeger.SamplerTypeRemote:
+	case jaeger.SamplerTypeProbabilistic:
+		if samplerParam < 0 || samplerParam > 1 {
+			return oci.TraceConfig{}, fmt.Errorf("Invalid probabilistic tracing sampler rate %v, must be between 0 and 1", samplerParam)
+		}
+	default:
+		return oci.TraceConfig{}, fmt.Errorf("Invalid tracing sampler type %q", samplerType)
+	}
+
+	if r.TracingCollector != "" {
+		if _, err := url.ParseRequestURI(r.TracingCollector); err != nil {
+			return oci.TraceConfig{}, fmt.Errorf("Invalid tracing collector endpoint %q: %v", r.TracingCollector, err)
+		}
+	}
+
+	return oci.TraceConfig{
+		SamplerType:       samplerType,
+		SamplerParam:      samplerParam,
+		CollectorEndpoint: r.TracingCollector,
+		AgentEndpoint:     r.TracingAgent,
+	}, nil
+}
+
 func newShimConfig(s shim) (vc.ShimConfig, error) {
 	path, err := s.path()
 	if err != nil {
@@ -692,11 +845,26 @@ func updateRuntimeConfigAgent(configPath string, tomlConf tomlConfig, config *oc
 		// to everything being disabled.
 		agentConfig, _ = config.AgentConfig.(vc.KataAgentConfig)
 
+		// The debug console and the request policy are handled by the
+		// agent and its client, the shim v2 only needs to set them.
+		if agent, ok := tomlConf.Agent[kataAgentTableType]; ok {
+			agentConfig.DebugConsole = agent.debugConsole()
+			agentConfig.CheckTimeout = agent.checkTimeout()
+			agentConfig.RequestTimeout = agent.requestTimeout()
+			agentConfig.LongRequestTimeout = agent.longRequestTimeout()
+			agentConfig.RequestRetries = agent.requestRetries()
+		}
+
 		config.AgentType = vc.KataContainersAgent
 		config.AgentConfig = vc.KataAgentConfig{
-			LongLiveConn: true,
-			UseVSock:     config.HypervisorConfig.UseVSock,
-			Debug:        agentConfig.Debug,
+			LongLiveConn:       true,
+			UseVSock:           config.HypervisorConfig.UseVSock,
+			Debug:              agentConfig.Debug,
+			DebugConsole:       agentConfig.DebugConsole,
+			CheckTimeout:       agentConfig.CheckTimeout,
+			RequestTimeout:     agentConfig.RequestTimeout,
+			LongRequestTimeout: agentConfig.LongRequestTimeout,
+			RequestRetries:     agentConfig.RequestRetries,
 		}
 
 		return nil
@@ -707,11 +875,16 @@ func updateRuntimeConfigAgent(configPath string, tomlConf tomlConfig, config *oc
 		case kataAgentTableType:
 			config.AgentType = vc.KataContainersAgent
 			config.AgentConfig = vc.KataAgentConfig{
-				UseVSock:  config.HypervisorConfig.UseVSock,
-				Debug:     agent.debug(),
-				Trace:     agent.trace(),
-				TraceMode: agent.traceMode(),
-				TraceType: agent.traceType(),
+				UseVSock:           config.HypervisorConfig.UseVSock,
+				Debug:              agent.debug(),
+				DebugConsole:       agent.debugConsole(),
+				Trace:              agent.trace(),
+				TraceMode:          agent.traceMode(),
+				TraceType:          agent.traceType(),
+				CheckTimeout:       agent.checkTimeout(),
+				RequestTimeout:     agent.requestTimeout(),
+				LongRequestTimeout: agent.longRequestTimeout(),
+				RequestRetries:     agent.requestRetries(),
 			}
 		default:
 			return fmt.Errorf("%s agent type is not supported", k)
@@ -850,6 +1023,7 @@ func GetDefaultHypervisorConfig() vc.HypervisorConfig {
 		MemOffset:               defaultMemOffset,
 		DisableBlockDeviceUse:   defaultDisableBlockDeviceUse,
 		DefaultBridges:          defaultBridgesCount,
+		PCIeRootPort:            defaultPCIeRootPort,
 		MemPrealloc:             defaultEnableMemPrealloc,
 		HugePages:               defaultEnableHugePages,
 		FileBackedMemRootDir:    defaultFileBackedMemRootDir,
@@ -863,7 +1037,11 @@ func GetDefaultHypervisorConfig() vc.HypervisorConfig {
 		EnableIOThreads:         defaultEnableIOThreads,
 		Msize9p:                 defaultMsize9p,
 		HotplugVFIOOnRootBus:    defaultHotplugVFIOOnRootBus,
+		VCPUPinning:             defaultVCPUPinning,
+		MemoryBalloon:           defaultMemoryBalloon,
+		FreePageReporting:       defaultFreePageReporting,
 		GuestHookPath:           defaultGuestHookPath,
+		VhostUserStorePath:      defaultVhostUserStorePath,
 	}
 }
 
@@ -919,6 +1097,12 @@ func LoadConfiguration(configPath string, ignoreLogging, builtIn bool) (resolved
 	config.Trace = tomlConf.Runtime.Tracing
 	tracing = config.Trace
 
+	config.TraceConfig, err = newTraceConfig(tomlConf.Runtime)
+	if err != nil {
+		return "", config, err
+	}
+	traceConfig = config.TraceConfig
+
 	if tomlConf.Runtime.InterNetworkModel != "" {
 		err = config.InterNetworkModel.SetModel(tomlConf.Runtime.InterNetworkModel)
 		if err != nil {
@@ -953,6 +1137,12 @@ func LoadConfiguration(configPath string, ignoreLogging, builtIn bool) (resolved
 	}
 
 	config.DisableNewNetNs = tomlConf.Runtime.DisableNewNetNs
+	config.GuestClockSyncInterval = time.Duration(tomlConf.Runtime.ClockSyncInterval) * time.Second
+	config.LockTimeout = time.Duration(tomlConf.Runtime.LockTimeout) * time.Second
+	config.SharedMountPolicy = vc.SharedMountPolicy{
+		CopyMounts:     tomlConf.Runtime.CopyMounts,
+		ReadOnlyMounts: tomlConf.Runtime.ReadOnlyMounts,
+	}
 	for _, f := range tomlConf.Runtime.Experimental {
 		feature := exp.Get(f)
 		if feature == nil {
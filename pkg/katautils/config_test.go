@@ -18,6 +18,7 @@ import (
 	"strings"
 	"syscall"
 	"testing"
+	"time"
 
 	ktu "github.com/kata-containers/runtime/pkg/katatestutils"
 	vc "github.com/kata-containers/runtime/virtcontainers"
@@ -162,6 +163,7 @@ func createAllRuntimeConfigFiles(dir, hypervisor string) (config testRuntimeConf
 		MemSlots:              defaultMemSlots,
 		EntropySource:         defaultEntropySource,
 		GuestHookPath:         defaultGuestHookPath,
+		VhostUserStorePath:    defaultVhostUserStorePath,
 		SharedFS:              sharedFS,
 		VirtioFSDaemon:        "/path/to/virtiofsd",
 	}
@@ -187,6 +189,11 @@ func createAllRuntimeConfigFiles(dir, hypervisor string) (config testRuntimeConf
 		VMCacheEndpoint: defaultVMCacheEndpoint,
 	}
 
+	traceConfig := oci.TraceConfig{
+		SamplerType:  defaultTracingSamplerType,
+		SamplerParam: defaultTracingSamplerParam,
+	}
+
 	runtimeConfig := oci.RuntimeConfig{
 		HypervisorType:   defaultHypervisor,
 		HypervisorConfig: hypervisorConfig,
@@ -204,6 +211,7 @@ func createAllRuntimeConfigFiles(dir, hypervisor string) (config testRuntimeConf
 		DisableNewNetNs: disableNewNetNs,
 
 		FactoryConfig: factoryConfig,
+		TraceConfig:   traceConfig,
 	}
 
 	err = SetKernelParams(&runtimeConfig)
@@ -613,6 +621,7 @@ func TestMinimalRuntimeConfig(t *testing.T) {
 		BlockDeviceDriver:     defaultBlockDeviceDriver,
 		Msize9p:               defaultMsize9p,
 		GuestHookPath:         defaultGuestHookPath,
+		VhostUserStorePath:    defaultVhostUserStorePath,
 	}
 
 	expectedAgentConfig := vc.KataAgentConfig{}
@@ -652,6 +661,10 @@ func TestMinimalRuntimeConfig(t *testing.T) {
 		NetmonConfig: expectedNetmonConfig,
 
 		FactoryConfig: expectedFactoryConfig,
+		TraceConfig: oci.TraceConfig{
+			SamplerType:  defaultTracingSamplerType,
+			SamplerParam: defaultTracingSamplerParam,
+		},
 	}
 	err = SetKernelParams(&expectedConfig)
 	if err != nil {
@@ -748,6 +761,65 @@ func TestMinimalRuntimeConfigWithVsock(t *testing.T) {
 	}
 }
 
+func TestSharedMountPolicyConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir(testDir, "shared-mount-policy-config-")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	imagePath := path.Join(dir, "image.img")
+	proxyPath := path.Join(dir, "proxy")
+	shimPath := path.Join(dir, "shim")
+	hypervisorPath := path.Join(dir, "hypervisor")
+	kernelPath := path.Join(dir, "kernel")
+
+	savedDefaultHypervisorPath := defaultHypervisorPath
+	savedDefaultKernelPath := defaultKernelPath
+
+	defer func() {
+		defaultHypervisorPath = savedDefaultHypervisorPath
+		defaultKernelPath = savedDefaultKernelPath
+	}()
+
+	defaultHypervisorPath = hypervisorPath
+	defaultKernelPath = kernelPath
+
+	for _, file := range []string{proxyPath, shimPath, hypervisorPath, kernelPath, imagePath} {
+		err = WriteFile(file, "foo", testFileMode)
+		assert.NoError(err)
+	}
+
+	runtimeConfig := `
+	[hypervisor.qemu]
+	image = "` + imagePath + `"
+
+	[proxy.kata]
+	path = "` + proxyPath + `"
+
+	[shim.kata]
+	path = "` + shimPath + `"
+
+	[agent.kata]
+
+	[runtime]
+	copy_mounts = ["kubernetes.io~secret", "kubernetes.io~configmap"]
+	readonly_mounts = ["/etc/*"]
+`
+	configPath := path.Join(dir, "runtime.toml")
+	err = createConfig(configPath, runtimeConfig)
+	assert.NoError(err)
+
+	_, config, err := LoadConfiguration(configPath, false, false)
+	assert.NoError(err)
+
+	expected := vc.SharedMountPolicy{
+		CopyMounts:     []string{"kubernetes.io~secret", "kubernetes.io~configmap"},
+		ReadOnlyMounts: []string{"/etc/*"},
+	}
+	assert.Equal(expected, config.SharedMountPolicy)
+}
+
 func TestNewQemuHypervisorConfig(t *testing.T) {
 	dir, err := ioutil.TempDir(testDir, "hypervisor-config-")
 	if err != nil {
@@ -1114,6 +1186,65 @@ func TestHypervisorDefaultsGuestHookPath(t *testing.T) {
 	assert.Equal(guestHookPath, testGuestHookPath, "custom guest hook path wrong")
 }
 
+func TestHypervisorDefaultsVhostUserStorePath(t *testing.T) {
+	assert := assert.New(t)
+
+	h := hypervisor{}
+	vhostUserStorePath := h.vhostUserStorePath()
+	assert.Equal(vhostUserStorePath, defaultVhostUserStorePath, "default vhost-user store path wrong")
+
+	testVhostUserStorePath := "/test/vhost/user/store/path"
+	h = hypervisor{
+		VhostUserStorePath: testVhostUserStorePath,
+	}
+	vhostUserStorePath = h.vhostUserStorePath()
+	assert.Equal(vhostUserStorePath, testVhostUserStorePath, "custom vhost-user store path wrong")
+}
+
+func TestHypervisorJailerPath(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir(testDir, "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	h := hypervisor{}
+	p, err := h.jailerPath()
+	assert.NoError(err)
+	assert.Empty(p)
+
+	jailer := filepath.Join(dir, "jailer")
+	h.JailerPath = jailer
+	_, err = h.jailerPath()
+	assert.Error(err)
+
+	err = createEmptyFile(jailer)
+	assert.NoError(err)
+
+	p, err = h.jailerPath()
+	assert.NoError(err)
+	assert.Equal(jailer, p)
+}
+
+func TestHypervisorCheckCustomAssetPolicy(t *testing.T) {
+	assert := assert.New(t)
+
+	h := hypervisor{}
+	assert.NoError(h.checkCustomAssetPolicy())
+
+	h.CustomAssetDirs = []string{"/opt/kata/assets", "assets"}
+	assert.Error(h.checkCustomAssetPolicy())
+
+	h.CustomAssetDirs = []string{"/opt/kata/assets"}
+	assert.NoError(h.checkCustomAssetPolicy())
+
+	h.CustomAssetManifest = "/etc/kata-containers/assets.manifest"
+	assert.Error(h.checkCustomAssetPolicy())
+
+	h.CustomAssetManifestKey = "/etc/kata-containers/assets.pub"
+	assert.NoError(h.checkCustomAssetPolicy())
+}
+
 func TestProxyDefaults(t *testing.T) {
 	assert := assert.New(t)
 
@@ -1208,6 +1339,11 @@ func TestAgentDefaults(t *testing.T) {
 	a.Debug = true
 	assert.Equal(a.debug(), a.Debug)
 
+	assert.Equal(a.debugConsole(), a.DebugConsole)
+
+	a.DebugConsole = true
+	assert.Equal(a.debugConsole(), a.DebugConsole)
+
 	assert.Equal(a.trace(), a.Tracing)
 
 	a.Tracing = true
@@ -1215,6 +1351,49 @@ func TestAgentDefaults(t *testing.T) {
 
 	assert.Equal(a.traceMode(), a.TraceMode)
 	assert.Equal(a.traceType(), a.TraceType)
+
+	assert.Equal(time.Duration(0), a.checkTimeout())
+	assert.Equal(time.Duration(0), a.requestTimeout())
+	assert.Equal(time.Duration(0), a.longRequestTimeout())
+	assert.Equal(uint32(0), a.requestRetries())
+
+	a.CheckTimeout = 10
+	a.ReqTimeout = 20
+	a.LongTimeout = 30
+	a.ReqRetries = 4
+	assert.Equal(10*time.Second, a.checkTimeout())
+	assert.Equal(20*time.Second, a.requestTimeout())
+	assert.Equal(30*time.Second, a.longRequestTimeout())
+	assert.Equal(uint32(4), a.requestRetries())
+}
+
+func TestUpdateRuntimeConfigAgentOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	tomlConf := tomlConfig{
+		Agent: map[string]agent{
+			kataAgentTableType: {
+				DebugConsole: true,
+				ReqTimeout:   20,
+				ReqRetries:   4,
+			},
+		},
+	}
+
+	for _, builtIn := range []bool{false, true} {
+		config := oci.RuntimeConfig{}
+		config.HypervisorConfig.UseVSock = true
+
+		err := updateRuntimeConfigAgent("", tomlConf, &config, builtIn)
+		assert.NoError(err)
+
+		agentConfig, ok := config.AgentConfig.(vc.KataAgentConfig)
+		assert.True(ok)
+		assert.True(agentConfig.DebugConsole, "builtIn: %v", builtIn)
+		assert.True(agentConfig.UseVSock, "builtIn: %v", builtIn)
+		assert.Equal(20*time.Second, agentConfig.RequestTimeout, "builtIn: %v", builtIn)
+		assert.Equal(uint32(4), agentConfig.RequestRetries, "builtIn: %v", builtIn)
+	}
 }
 
 func TestGetDefaultConfigFilePaths(t *testing.T) {
@@ -1300,6 +1479,19 @@ func TestDefaultBridges(t *testing.T) {
 	assert.Equal(maxPCIBridges, bridges)
 }
 
+func TestPCIeRootPort(t *testing.T) {
+	assert := assert.New(t)
+
+	h := hypervisor{PCIeRootPort: 0}
+	assert.Equal(defaultPCIeRootPort, h.pcieRootPort())
+
+	h.PCIeRootPort = 2
+	assert.Equal(uint32(2), h.pcieRootPort())
+
+	h.PCIeRootPort = maxPCIeRootPorts + 1
+	assert.Equal(maxPCIeRootPorts, h.pcieRootPort())
+}
+
 func TestDefaultFirmware(t *testing.T) {
 	assert := assert.New(t)
 
@@ -1443,6 +1635,45 @@ func TestUpdateRuntimeConfigurationFactoryConfig(t *testing.T) {
 	assert.Equal(expectedFactoryConfig, config.FactoryConfig)
 }
 
+func TestNewTraceConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	// defaults to a const sampler tracing every request
+	traceConfig, err := newTraceConfig(runtime{})
+	assert.NoError(err)
+	assert.Equal(oci.TraceConfig{
+		SamplerType:  defaultTracingSamplerType,
+		SamplerParam: defaultTracingSamplerParam,
+	}, traceConfig)
+
+	r := runtime{
+		TracingSamplerType:  "probabilistic",
+		TracingSamplerParam: 0.25,
+		TracingCollector:    "http://localhost:14268/api/traces",
+		TracingAgent:        "localhost:6831",
+	}
+	traceConfig, err = newTraceConfig(r)
+	assert.NoError(err)
+	assert.Equal(oci.TraceConfig{
+		SamplerType:       "probabilistic",
+		SamplerParam:      0.25,
+		CollectorEndpoint: "http://localhost:14268/api/traces",
+		AgentEndpoint:     "localhost:6831",
+	}, traceConfig)
+
+	r.TracingSamplerParam = 2
+	_, err = newTraceConfig(r)
+	assert.Error(err)
+
+	r.TracingSamplerParam = 0.5
+	r.TracingCollector = "not a URL"
+	_, err = newTraceConfig(r)
+	assert.Error(err)
+
+	_, err = newTraceConfig(runtime{TracingSamplerType: "foo"})
+	assert.Error(err)
+}
+
 func TestUpdateRuntimeConfigurationInvalidKernelParams(t *testing.T) {
 	assert := assert.New(t)
 
@@ -91,6 +91,7 @@ func HandleFactory(ctx context.Context, vci vc.VC, runtimeConfig *oci.RuntimeCon
 // For the given pod ephemeral volume is created only once
 // backed by tmpfs inside the VM. For successive containers
 // of the same pod the already existing volume is reused.
+// The volume size limit, if any, is passed as a mount option.
 func SetEphemeralStorageType(ociSpec oci.CompatOCISpec) oci.CompatOCISpec {
 	for idx, mnt := range ociSpec.Mounts {
 		if vc.IsEphemeralStorage(mnt.Source) {
@@ -99,6 +100,20 @@ func SetEphemeralStorageType(ociSpec oci.CompatOCISpec) oci.CompatOCISpec {
 		if vc.Isk8sHostEmptyDir(mnt.Source) {
 			ociSpec.Mounts[idx].Type = vc.KataLocalDevType
 		}
+
+		if t := ociSpec.Mounts[idx].Type; t != vc.KataEphemeralDevType && t != vc.KataLocalDevType {
+			continue
+		}
+
+		size, err := vc.StorageSizeLimit(mnt.Source, ociSpec.Annotations)
+		if err != nil {
+			kataUtilsLogger.WithError(err).WithField("volume", mnt.Source).Warn("Could not get volume size limit")
+			continue
+		}
+
+		if size > 0 {
+			ociSpec.Mounts[idx].Options = append(ociSpec.Mounts[idx].Options, vc.StorageSizeOption(size))
+		}
 	}
 	return ociSpec
 }
@@ -109,6 +124,10 @@ func CreateSandbox(ctx context.Context, vci vc.VC, ociSpec oci.CompatOCISpec, ru
 	span, ctx := Trace(ctx, "createSandbox")
 	defer span.Finish()
 
+	if err := checkHooks(ociSpec); err != nil {
+		return nil, vc.Process{}, err
+	}
+
 	sandboxConfig, err := oci.SandboxConfig(ociSpec, runtimeConfig, bundlePath, containerID, console, disableOutput, systemdCgroup)
 	if err != nil {
 		return nil, vc.Process{}, err
@@ -146,9 +165,14 @@ func CreateSandbox(ctx context.Context, vci vc.VC, ociSpec oci.CompatOCISpec, ru
 		}
 	}()
 
-	// Run pre-start OCI hooks.
+	// Run pre-start OCI hooks. They run before the VM is created, so
+	// that hooks setting up the network are seen by the sandbox.
 	err = EnterNetNS(sandboxConfig.NetworkConfig.NetNSPath, func() error {
-		return PreStartHooks(ctx, ociSpec, containerID, bundlePath)
+		hs := HookState{
+			SandboxID: containerID,
+			NetNsPath: sandboxConfig.NetworkConfig.NetNSPath,
+		}
+		return PreStartHooks(ctx, ociSpec, containerID, bundlePath, hs)
 	})
 	if err != nil {
 		return nil, vc.Process{}, err
@@ -159,6 +183,20 @@ func CreateSandbox(ctx context.Context, vci vc.VC, ociSpec oci.CompatOCISpec, ru
 		return nil, vc.Process{}, err
 	}
 
+	// Run create-runtime and create-container OCI hooks.
+	err = EnterNetNS(sandbox.GetNetNs(), func() error {
+		return createHooks(ctx, ociSpec, containerID, bundlePath, NewHookState(sandbox))
+	})
+	if err != nil {
+		// Do not leak the VM, the shim and the sandbox storage.
+		if ex := sandbox.Stop(); ex != nil {
+			kataUtilsLogger.WithError(ex).Warn("failed to stop sandbox after hooks failure")
+		} else if ex := sandbox.Delete(); ex != nil {
+			kataUtilsLogger.WithError(ex).Warn("failed to delete sandbox after hooks failure")
+		}
+		return nil, vc.Process{}, err
+	}
+
 	sid := sandbox.ID()
 	kataUtilsLogger = kataUtilsLogger.WithField("sandbox", sid)
 	span.SetTag("sandbox", sid)
@@ -185,6 +223,10 @@ func CreateContainer(ctx context.Context, vci vc.VC, sandbox vc.VCSandbox, ociSp
 	span, ctx := Trace(ctx, "createContainer")
 	defer span.Finish()
 
+	if err := checkHooks(ociSpec); err != nil {
+		return vc.Process{}, err
+	}
+
 	ociSpec = SetEphemeralStorageType(ociSpec)
 
 	contConfig, err := oci.ContainerConfig(ociSpec, bundlePath, containerID, console, disableOutput)
@@ -228,9 +270,14 @@ func CreateContainer(ctx context.Context, vci vc.VC, sandbox vc.VCSandbox, ociSp
 		}
 	}
 
-	// Run pre-start OCI hooks.
+	// Run pre-start, create-runtime and create-container OCI hooks.
 	err = EnterNetNS(sandbox.GetNetNs(), func() error {
-		return PreStartHooks(ctx, ociSpec, containerID, bundlePath)
+		hs := NewHookState(sandbox)
+		if err := PreStartHooks(ctx, ociSpec, containerID, bundlePath, hs); err != nil {
+			return err
+		}
+
+		return createHooks(ctx, ociSpec, containerID, bundlePath, hs)
 	})
 	if err != nil {
 		return vc.Process{}, err
@@ -238,3 +285,13 @@ func CreateContainer(ctx context.Context, vci vc.VC, sandbox vc.VCSandbox, ociSp
 
 	return c.Process(), nil
 }
+
+// createHooks runs the OCI hooks expected once the runtime environment and
+// the container have been created.
+func createHooks(ctx context.Context, ociSpec oci.CompatOCISpec, containerID, bundlePath string, hs HookState) error {
+	if err := CreateRuntimeHooks(ctx, ociSpec, containerID, bundlePath, hs); err != nil {
+		return err
+	}
+
+	return CreateContainerHooks(ctx, ociSpec, containerID, bundlePath, hs)
+}
@@ -21,6 +21,7 @@ import (
 
 	ktu "github.com/kata-containers/runtime/pkg/katatestutils"
 	vc "github.com/kata-containers/runtime/virtcontainers"
+	vcAnnotations "github.com/kata-containers/runtime/virtcontainers/pkg/annotations"
 	"github.com/kata-containers/runtime/virtcontainers/pkg/oci"
 	"github.com/kata-containers/runtime/virtcontainers/pkg/vcmock"
 	"github.com/opencontainers/runtime-spec/specs-go"
@@ -218,6 +219,15 @@ func TestSetEphemeralStorageType(t *testing.T) {
 	mountType := ociSpec.Mounts[0].Type
 	assert.Equal(mountType, "ephemeral",
 		"Unexpected mount type, got %s expected ephemeral", mountType)
+	assert.Empty(ociSpec.Mounts[0].Options)
+
+	// Size limit set by annotation
+	ociSpec.Mounts[0].Type = ""
+	ociSpec.Annotations = map[string]string{
+		vcAnnotations.StorageSizeLimitPrefix + "tmp-volume": "1Mi",
+	}
+	ociSpec = SetEphemeralStorageType(ociSpec)
+	assert.Equal([]string{vc.StorageSizeOption(1 << 20)}, ociSpec.Mounts[0].Options)
 }
 
 func TestSetKernelParams(t *testing.T) {
@@ -12,10 +12,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"os/exec"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
+	vc "github.com/kata-containers/runtime/virtcontainers"
+	vcAnnotations "github.com/kata-containers/runtime/virtcontainers/pkg/annotations"
 	"github.com/kata-containers/runtime/virtcontainers/pkg/oci"
 	"github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/opentracing/opentracing-go/log"
@@ -27,7 +30,67 @@ func hookLogger() *logrus.Entry {
 	return kataUtilsLogger.WithField("subsystem", "hook")
 }
 
-func runHook(ctx context.Context, hook specs.Hook, cid, bundlePath string) error {
+// HookState holds the sandbox information given to the OCI hooks on top
+// of the standard OCI state, so that hooks can find the VM and the sandbox
+// network namespace.
+type HookState struct {
+	SandboxID     string
+	NetNsPath     string
+	HypervisorPid int
+}
+
+// NewHookState returns the hook state describing the given sandbox.
+func NewHookState(sandbox vc.VCSandbox) HookState {
+	hs := HookState{
+		SandboxID: sandbox.ID(),
+		NetNsPath: sandbox.GetNetNs(),
+	}
+
+	pid, err := sandbox.GetHypervisorPid()
+	if err != nil {
+		hookLogger().WithError(err).Debug("hypervisor PID not available for hooks")
+	} else {
+		hs.HypervisorPid = pid
+	}
+
+	return hs
+}
+
+// ociState builds the OCI state passed to the hooks. When the VM is
+// running, the state PID is the hypervisor one, otherwise it falls back
+// to the runtime thread ID.
+func ociState(spec oci.CompatOCISpec, cid, bundlePath, status string, hs HookState) specs.State {
+	annotations := make(map[string]string)
+	for k, v := range spec.Annotations {
+		annotations[k] = v
+	}
+
+	pid := hs.HypervisorPid
+	if pid > 0 {
+		annotations[vcAnnotations.HypervisorPidKey] = strconv.Itoa(pid)
+	} else {
+		pid = syscall.Gettid()
+	}
+
+	if hs.SandboxID != "" {
+		annotations[vcAnnotations.SandboxIDKey] = hs.SandboxID
+	}
+
+	if hs.NetNsPath != "" {
+		annotations[vcAnnotations.NetNsPathKey] = hs.NetNsPath
+	}
+
+	return specs.State{
+		Version:     specs.Version,
+		ID:          cid,
+		Status:      status,
+		Pid:         pid,
+		Bundle:      bundlePath,
+		Annotations: annotations,
+	}
+}
+
+func runHook(ctx context.Context, hook specs.Hook, state specs.State) error {
 	span, _ := Trace(ctx, "hook")
 	defer span.Finish()
 
@@ -37,12 +100,6 @@ func runHook(ctx context.Context, hook specs.Hook, cid, bundlePath string) error
 		log.String("hook-name", hook.Path),
 		log.String("hook-args", strings.Join(hook.Args, " ")))
 
-	state := specs.State{
-		Pid:    syscall.Gettid(),
-		Bundle: bundlePath,
-		ID:     cid,
-	}
-
 	stateJSON, err := json.Marshal(state)
 	if err != nil {
 		return err
@@ -90,14 +147,14 @@ func runHook(ctx context.Context, hook specs.Hook, cid, bundlePath string) error
 	return nil
 }
 
-func runHooks(ctx context.Context, hooks []specs.Hook, cid, bundlePath, hookType string) error {
+func runHooks(ctx context.Context, hooks []specs.Hook, state specs.State, hookType string) error {
 	span, _ := Trace(ctx, "hooks")
 	defer span.Finish()
 
 	span.SetTag("subsystem", hookType)
 
 	for _, hook := range hooks {
-		if err := runHook(ctx, hook, cid, bundlePath); err != nil {
+		if err := runHook(ctx, hook, state); err != nil {
 			hookLogger().WithFields(logrus.Fields{
 				"hook-type": hookType,
 				"error":     err,
@@ -111,31 +168,68 @@ func runHooks(ctx context.Context, hooks []specs.Hook, cid, bundlePath, hookType
 }
 
 // PreStartHooks run the hooks before start container
-func PreStartHooks(ctx context.Context, spec oci.CompatOCISpec, cid, bundlePath string) error {
+func PreStartHooks(ctx context.Context, spec oci.CompatOCISpec, cid, bundlePath string, hs HookState) error {
 	// If no hook available, nothing needs to be done.
 	if spec.Hooks == nil {
 		return nil
 	}
 
-	return runHooks(ctx, spec.Hooks.Prestart, cid, bundlePath, "pre-start")
+	state := ociState(spec, cid, bundlePath, oci.StateCreated, hs)
+	return runHooks(ctx, spec.Hooks.Prestart, state, "pre-start")
+}
+
+// CreateRuntimeHooks run the hooks once the runtime environment, that is
+// the sandbox VM, has been created
+func CreateRuntimeHooks(ctx context.Context, spec oci.CompatOCISpec, cid, bundlePath string, hs HookState) error {
+	// If no hook available, nothing needs to be done.
+	if spec.CompatHooks == nil {
+		return nil
+	}
+
+	state := ociState(spec, cid, bundlePath, oci.StateCreating, hs)
+	return runHooks(ctx, spec.CompatHooks.CreateRuntime, state, "create-runtime")
+}
+
+// CreateContainerHooks run the hooks once the container has been created
+func CreateContainerHooks(ctx context.Context, spec oci.CompatOCISpec, cid, bundlePath string, hs HookState) error {
+	// If no hook available, nothing needs to be done.
+	if spec.CompatHooks == nil {
+		return nil
+	}
+
+	state := ociState(spec, cid, bundlePath, oci.StateCreating, hs)
+	return runHooks(ctx, spec.CompatHooks.CreateContainer, state, "create-container")
 }
 
 // PostStartHooks run the hooks just after start container
-func PostStartHooks(ctx context.Context, spec oci.CompatOCISpec, cid, bundlePath string) error {
+func PostStartHooks(ctx context.Context, spec oci.CompatOCISpec, cid, bundlePath string, hs HookState) error {
 	// If no hook available, nothing needs to be done.
 	if spec.Hooks == nil {
 		return nil
 	}
 
-	return runHooks(ctx, spec.Hooks.Poststart, cid, bundlePath, "post-start")
+	state := ociState(spec, cid, bundlePath, oci.StateRunning, hs)
+	return runHooks(ctx, spec.Hooks.Poststart, state, "post-start")
 }
 
 // PostStopHooks run the hooks after stop container
-func PostStopHooks(ctx context.Context, spec oci.CompatOCISpec, cid, bundlePath string) error {
+func PostStopHooks(ctx context.Context, spec oci.CompatOCISpec, cid, bundlePath string, hs HookState) error {
 	// If no hook available, nothing needs to be done.
 	if spec.Hooks == nil {
 		return nil
 	}
 
-	return runHooks(ctx, spec.Hooks.Poststop, cid, bundlePath, "post-stop")
+	state := ociState(spec, cid, bundlePath, oci.StateStopped, hs)
+	return runHooks(ctx, spec.Hooks.Poststop, state, "post-stop")
+}
+
+// checkHooks rejects the OCI hooks which cannot be run. The startContainer
+// hooks must run in the container namespaces, which live inside the VM, and
+// the agent does not run OCI hooks.
+func checkHooks(spec oci.CompatOCISpec) error {
+	if spec.CompatHooks != nil && len(spec.CompatHooks.StartContainer) > 0 {
+		return fmt.Errorf("startContainer hooks are not supported, they would have to run inside the VM")
+	}
+
+	return nil
 }
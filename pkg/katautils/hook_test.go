@@ -12,8 +12,10 @@ import (
 	"testing"
 
 	ktu "github.com/kata-containers/runtime/pkg/katatestutils"
+	vcAnnotations "github.com/kata-containers/runtime/virtcontainers/pkg/annotations"
 	. "github.com/kata-containers/runtime/virtcontainers/pkg/mock"
 	"github.com/kata-containers/runtime/virtcontainers/pkg/oci"
+	"github.com/kata-containers/runtime/virtcontainers/pkg/vcmock"
 	"github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/stretchr/testify/assert"
 )
@@ -63,26 +65,31 @@ func TestRunHook(t *testing.T) {
 	assert := assert.New(t)
 
 	ctx := context.Background()
+	state := specs.State{
+		Pid:    os.Getpid(),
+		Bundle: testBundlePath,
+		ID:     testSandboxID,
+	}
 
 	// Run with timeout 0
 	hook := createHook(0)
-	err := runHook(ctx, hook, testSandboxID, testBundlePath)
+	err := runHook(ctx, hook, state)
 	assert.NoError(err)
 
 	// Run with timeout 1
 	hook = createHook(1)
-	err = runHook(ctx, hook, testSandboxID, testBundlePath)
+	err = runHook(ctx, hook, state)
 	assert.NoError(err)
 
 	// Run timeout failure
 	hook = createHook(1)
 	hook.Args = append(hook.Args, "2")
-	err = runHook(ctx, hook, testSandboxID, testBundlePath)
+	err = runHook(ctx, hook, state)
 	assert.Error(err)
 
 	// Failure due to wrong hook
 	hook = createWrongHook()
-	err = runHook(ctx, hook, testSandboxID, testBundlePath)
+	err = runHook(ctx, hook, state)
 	assert.Error(err)
 }
 
@@ -97,7 +104,7 @@ func TestPreStartHooks(t *testing.T) {
 
 	// Hooks field is nil
 	spec := oci.CompatOCISpec{}
-	err := PreStartHooks(ctx, spec, "", "")
+	err := PreStartHooks(ctx, spec, "", "", HookState{})
 	assert.NoError(err)
 
 	// Hooks list is empty
@@ -106,7 +113,7 @@ func TestPreStartHooks(t *testing.T) {
 			Hooks: &specs.Hooks{},
 		},
 	}
-	err = PreStartHooks(ctx, spec, "", "")
+	err = PreStartHooks(ctx, spec, "", "", HookState{})
 	assert.NoError(err)
 
 	// Run with timeout 0
@@ -118,7 +125,7 @@ func TestPreStartHooks(t *testing.T) {
 			},
 		},
 	}
-	err = PreStartHooks(ctx, spec, testSandboxID, testBundlePath)
+	err = PreStartHooks(ctx, spec, testSandboxID, testBundlePath, HookState{})
 	assert.NoError(err)
 
 	// Failure due to wrong hook
@@ -130,7 +137,7 @@ func TestPreStartHooks(t *testing.T) {
 			},
 		},
 	}
-	err = PreStartHooks(ctx, spec, testSandboxID, testBundlePath)
+	err = PreStartHooks(ctx, spec, testSandboxID, testBundlePath, HookState{})
 	assert.Error(err)
 }
 
@@ -145,7 +152,7 @@ func TestPostStartHooks(t *testing.T) {
 
 	// Hooks field is nil
 	spec := oci.CompatOCISpec{}
-	err := PostStartHooks(ctx, spec, "", "")
+	err := PostStartHooks(ctx, spec, "", "", HookState{})
 	assert.NoError(err)
 
 	// Hooks list is empty
@@ -154,7 +161,7 @@ func TestPostStartHooks(t *testing.T) {
 			Hooks: &specs.Hooks{},
 		},
 	}
-	err = PostStartHooks(ctx, spec, "", "")
+	err = PostStartHooks(ctx, spec, "", "", HookState{})
 	assert.NoError(err)
 
 	// Run with timeout 0
@@ -166,7 +173,7 @@ func TestPostStartHooks(t *testing.T) {
 			},
 		},
 	}
-	err = PostStartHooks(ctx, spec, testSandboxID, testBundlePath)
+	err = PostStartHooks(ctx, spec, testSandboxID, testBundlePath, HookState{})
 	assert.NoError(err)
 
 	// Failure due to wrong hook
@@ -178,7 +185,7 @@ func TestPostStartHooks(t *testing.T) {
 			},
 		},
 	}
-	err = PostStartHooks(ctx, spec, testSandboxID, testBundlePath)
+	err = PostStartHooks(ctx, spec, testSandboxID, testBundlePath, HookState{})
 	assert.Error(err)
 }
 
@@ -193,7 +200,7 @@ func TestPostStopHooks(t *testing.T) {
 
 	// Hooks field is nil
 	spec := oci.CompatOCISpec{}
-	err := PostStopHooks(ctx, spec, "", "")
+	err := PostStopHooks(ctx, spec, "", "", HookState{})
 	assert.NoError(err)
 
 	// Hooks list is empty
@@ -202,7 +209,7 @@ func TestPostStopHooks(t *testing.T) {
 			Hooks: &specs.Hooks{},
 		},
 	}
-	err = PostStopHooks(ctx, spec, "", "")
+	err = PostStopHooks(ctx, spec, "", "", HookState{})
 	assert.NoError(err)
 
 	// Run with timeout 0
@@ -214,7 +221,7 @@ func TestPostStopHooks(t *testing.T) {
 			},
 		},
 	}
-	err = PostStopHooks(ctx, spec, testSandboxID, testBundlePath)
+	err = PostStopHooks(ctx, spec, testSandboxID, testBundlePath, HookState{})
 	assert.NoError(err)
 
 	// Failure due to wrong hook
@@ -226,6 +233,129 @@ func TestPostStopHooks(t *testing.T) {
 			},
 		},
 	}
-	err = PostStopHooks(ctx, spec, testSandboxID, testBundlePath)
+	err = PostStopHooks(ctx, spec, testSandboxID, testBundlePath, HookState{})
 	assert.Error(err)
 }
+
+func TestCreateHooks(t *testing.T) {
+	if tc.NotValid(ktu.NeedRoot()) {
+		t.Skip(ktu.TestDisabledNeedRoot)
+	}
+
+	assert := assert.New(t)
+
+	ctx := context.Background()
+
+	type hooksFunc func(context.Context, oci.CompatOCISpec, string, string, HookState) error
+
+	hs := HookState{
+		SandboxID:     testSandboxID,
+		NetNsPath:     "/foo/bar/ns/net",
+		HypervisorPid: os.Getpid(),
+	}
+
+	for _, hooksFn := range []hooksFunc{CreateRuntimeHooks, CreateContainerHooks} {
+		// Hooks field is nil
+		spec := oci.CompatOCISpec{}
+		err := hooksFn(ctx, spec, "", "", HookState{})
+		assert.NoError(err)
+
+		// Hooks list is empty
+		spec = oci.CompatOCISpec{
+			CompatHooks: &oci.CompatOCIHooks{},
+		}
+		err = hooksFn(ctx, spec, "", "", HookState{})
+		assert.NoError(err)
+
+		// Run with timeout 0
+		hook := createHook(0)
+		spec = oci.CompatOCISpec{
+			CompatHooks: &oci.CompatOCIHooks{
+				CreateRuntime:   []specs.Hook{hook},
+				CreateContainer: []specs.Hook{hook},
+			},
+		}
+		err = hooksFn(ctx, spec, testSandboxID, testBundlePath, hs)
+		assert.NoError(err)
+
+		// Failure due to wrong hook
+		hook = createWrongHook()
+		spec = oci.CompatOCISpec{
+			CompatHooks: &oci.CompatOCIHooks{
+				CreateRuntime:   []specs.Hook{hook},
+				CreateContainer: []specs.Hook{hook},
+			},
+		}
+		err = hooksFn(ctx, spec, testSandboxID, testBundlePath, hs)
+		assert.Error(err)
+	}
+}
+
+func TestCheckHooks(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.NoError(checkHooks(oci.CompatOCISpec{}))
+
+	spec := oci.CompatOCISpec{
+		CompatHooks: &oci.CompatOCIHooks{
+			CreateRuntime: []specs.Hook{createHook(0)},
+		},
+	}
+	assert.NoError(checkHooks(spec))
+
+	spec.CompatHooks.StartContainer = []specs.Hook{createHook(0)}
+	assert.Error(checkHooks(spec))
+}
+
+func TestOCIState(t *testing.T) {
+	assert := assert.New(t)
+
+	spec := oci.CompatOCISpec{
+		Spec: specs.Spec{
+			Annotations: map[string]string{"foo": "bar"},
+		},
+	}
+
+	// No sandbox information, falls back to the runtime thread ID
+	state := ociState(spec, testContainerID, testBundlePath, oci.StateCreated, HookState{})
+	assert.Equal(specs.Version, state.Version)
+	assert.Equal(testContainerID, state.ID)
+	assert.Equal(oci.StateCreated, state.Status)
+	assert.Equal(testBundlePath, state.Bundle)
+	assert.True(state.Pid > 0)
+	assert.Equal(map[string]string{"foo": "bar"}, state.Annotations)
+
+	hs := HookState{
+		SandboxID:     testSandboxID,
+		NetNsPath:     "/foo/bar/ns/net",
+		HypervisorPid: 1234,
+	}
+
+	state = ociState(spec, testContainerID, testBundlePath, oci.StateRunning, hs)
+	assert.Equal(1234, state.Pid)
+	assert.Equal(oci.StateRunning, state.Status)
+	assert.Equal("bar", state.Annotations["foo"])
+	assert.Equal(testSandboxID, state.Annotations[vcAnnotations.SandboxIDKey])
+	assert.Equal("/foo/bar/ns/net", state.Annotations[vcAnnotations.NetNsPathKey])
+	assert.Equal("1234", state.Annotations[vcAnnotations.HypervisorPidKey])
+
+	// The spec annotations must not be modified
+	assert.Len(spec.Annotations, 1)
+}
+
+func TestNewHookState(t *testing.T) {
+	assert := assert.New(t)
+
+	sandbox := &vcmock.Sandbox{
+		MockID:            testSandboxID,
+		MockNetNs:         "/foo/bar/ns/net",
+		MockHypervisorPid: 1234,
+	}
+
+	hs := NewHookState(sandbox)
+	assert.Equal(HookState{
+		SandboxID:     testSandboxID,
+		NetNsPath:     "/foo/bar/ns/net",
+		HypervisorPid: 1234,
+	}, hs)
+}
@@ -9,7 +9,9 @@ import (
 	"context"
 	"io"
 
+	"github.com/kata-containers/runtime/virtcontainers/pkg/oci"
 	opentracing "github.com/opentracing/opentracing-go"
+	jaeger "github.com/uber/jaeger-client-go"
 	"github.com/uber/jaeger-client-go/config"
 )
 
@@ -21,6 +23,12 @@ type traceLogger struct {
 // is used by stopTracing().
 var tracerCloser io.Closer
 
+// traceConfig is the tracer configuration loaded from the configuration file.
+var traceConfig = oci.TraceConfig{
+	SamplerType:  defaultTracingSamplerType,
+	SamplerParam: defaultTracingSamplerParam,
+}
+
 func (t traceLogger) Error(msg string) {
 	kataUtilsLogger.Error(msg)
 }
@@ -41,8 +49,13 @@ func CreateTracer(name string) (opentracing.Tracer, error) {
 		// it pollutes the output stream which causes (atleast) the
 		// "state" command to fail under Docker.
 		Sampler: &config.SamplerConfig{
-			Type:  "const",
-			Param: 1,
+			Type:  traceConfig.SamplerType,
+			Param: traceConfig.SamplerParam,
+		},
+
+		Reporter: &config.ReporterConfig{
+			LocalAgentHostPort: traceConfig.AgentEndpoint,
+			CollectorEndpoint:  traceConfig.CollectorEndpoint,
 		},
 	}
 
@@ -62,6 +75,26 @@ func CreateTracer(name string) (opentracing.Tracer, error) {
 	return tracer, nil
 }
 
+// ExtractSpanContext returns the span context propagated by a caller in
+// the Jaeger "uber-trace-id" format, or nil if it cannot be decoded.
+func ExtractSpanContext(tracer opentracing.Tracer, traceContext string) opentracing.SpanContext {
+	if tracer == nil || traceContext == "" {
+		return nil
+	}
+
+	carrier := opentracing.TextMapCarrier{
+		jaeger.TraceContextHeaderName: traceContext,
+	}
+
+	spanCtx, err := tracer.Extract(opentracing.TextMap, carrier)
+	if err != nil {
+		kataUtilsLogger.WithError(err).WithField("trace-context", traceContext).Warn("Invalid trace context")
+		return nil
+	}
+
+	return spanCtx
+}
+
 // StopTracing ends all tracing, reporting the spans to the collector.
 func StopTracing(ctx context.Context) {
 	if !tracing {
@@ -250,6 +250,9 @@ type agent interface {
 	// copyFile copies file from host to container's rootfs
 	copyFile(src, dst string) error
 
+	// debugConsoleURL returns the URL of the guest debug console
+	debugConsoleURL(sandbox *Sandbox) (string, error)
+
 	// cleanup removes all on disk information generated by the agent
 	cleanup(id string)
 }
@@ -7,9 +7,11 @@ package virtcontainers
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"runtime"
 	"syscall"
+	"time"
 
 	deviceApi "github.com/kata-containers/runtime/virtcontainers/device/api"
 	deviceConfig "github.com/kata-containers/runtime/virtcontainers/device/config"
@@ -51,6 +53,13 @@ func SetLogger(ctx context.Context, logger *logrus.Entry) {
 	store.SetLogger(virtLog)
 }
 
+// SetLockTimeout sets how long the sandbox and container operations wait
+// for the sandbox lock held by other processes. A zero timeout waits
+// forever.
+func SetLockTimeout(ctx context.Context, timeout time.Duration) {
+	store.SetLockTimeout(timeout)
+}
+
 // CreateSandbox is the virtcontainers sandbox creation entry point.
 // CreateSandbox creates a sandbox and its containers. It does not start them.
 func CreateSandbox(ctx context.Context, sandboxConfig SandboxConfig, factory Factory) (VCSandbox, error) {
@@ -723,6 +732,31 @@ func UpdateContainer(ctx context.Context, sandboxID, containerID string, resourc
 	return s.UpdateContainer(containerID, resources)
 }
 
+// UpdateSandbox is the virtcontainers entry point to update the
+// resources reserved for a sandbox itself.
+func UpdateSandbox(ctx context.Context, sandboxID string, resources SandboxResources) error {
+	span, ctx := trace(ctx, "UpdateSandbox")
+	defer span.Finish()
+
+	if sandboxID == "" {
+		return vcTypes.ErrNeedSandboxID
+	}
+
+	lockFile, err := rwLockSandbox(ctx, sandboxID)
+	if err != nil {
+		return err
+	}
+	defer unlockSandbox(ctx, sandboxID, lockFile)
+
+	s, err := fetchSandbox(ctx, sandboxID)
+	if err != nil {
+		return err
+	}
+	defer s.releaseStatelessSandbox()
+
+	return s.UpdateResources(resources)
+}
+
 // StatsContainer is the virtcontainers container stats entry point.
 // StatsContainer returns a detailed container stats.
 func StatsContainer(ctx context.Context, sandboxID, containerID string) (ContainerStats, error) {
@@ -931,3 +965,60 @@ func ListRoutes(ctx context.Context, sandboxID string) ([]*vcTypes.Route, error)
 
 	return s.ListRoutes()
 }
+
+// DebugConsoleURL is the virtcontainers entry point returning the URL of
+// the guest debug console of a sandbox.
+func DebugConsoleURL(ctx context.Context, sandboxID string) (string, error) {
+	span, ctx := trace(ctx, "DebugConsoleURL")
+	defer span.Finish()
+
+	if sandboxID == "" {
+		return "", vcTypes.ErrNeedSandboxID
+	}
+
+	lockFile, err := rLockSandbox(ctx, sandboxID)
+	if err != nil {
+		return "", err
+	}
+	defer unlockSandbox(ctx, sandboxID, lockFile)
+
+	s, err := fetchSandbox(ctx, sandboxID)
+	if err != nil {
+		return "", err
+	}
+	defer s.releaseStatelessSandbox()
+
+	return s.debugConsoleURL()
+}
+
+// GarbageCollect is the virtcontainers entry point cleaning up the resources
+// left behind by the sandboxes whose hypervisor is not running anymore, or
+// only reporting them with dryRun.
+func GarbageCollect(ctx context.Context, dryRun bool) ([]GCResource, error) {
+	span, ctx := trace(ctx, "GarbageCollect")
+	defer span.Finish()
+
+	return garbageCollect(ctx, dryRun)
+}
+
+// SandboxLockHolders returns the processes holding the lock of a sandbox,
+// and the ones which died holding it. It does not take the lock.
+func SandboxLockHolders(ctx context.Context, sandboxID string) ([]store.LockHolder, error) {
+	span, ctx := trace(ctx, "SandboxLockHolders")
+	defer span.Finish()
+
+	if sandboxID == "" {
+		return nil, vcTypes.ErrNeedSandboxID
+	}
+
+	if !store.VCSandboxStoreExists(ctx, sandboxID) {
+		return nil, fmt.Errorf("Sandbox %s does not exist", sandboxID)
+	}
+
+	vcStore, err := store.NewVCSandboxStore(ctx, sandboxID)
+	if err != nil {
+		return nil, err
+	}
+
+	return vcStore.LockHolders()
+}
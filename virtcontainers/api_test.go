@@ -14,6 +14,7 @@ import (
 	"strings"
 	"syscall"
 	"testing"
+	"time"
 
 	ktu "github.com/kata-containers/runtime/pkg/katatestutils"
 	"github.com/kata-containers/runtime/virtcontainers/pkg/annotations"
@@ -1859,4 +1860,46 @@ func TestNetworkOperation(t *testing.T) {
 
 	_, err = ListRoutes(ctx, s.ID())
 	assert.NoError(err)
+
+	// The noop agent has no debug console.
+	_, err = DebugConsoleURL(ctx, s.ID())
+	assert.Error(err)
+
+	_, err = DebugConsoleURL(ctx, "")
+	assert.Error(err)
+}
+
+func TestSandboxLockHolders(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := context.Background()
+
+	_, err := SandboxLockHolders(ctx, "")
+	assert.Error(err)
+
+	_, err = SandboxLockHolders(ctx, testSandboxID)
+	assert.Error(err)
+
+	token, err := rwLockSandbox(ctx, testSandboxID)
+	assert.NoError(err)
+	defer cleanUp()
+
+	holders, err := SandboxLockHolders(ctx, testSandboxID)
+	assert.NoError(err)
+	assert.Len(holders, 1)
+	assert.Equal(os.Getpid(), holders[0].Pid)
+	assert.True(holders[0].Exclusive)
+
+	// The sandbox is locked, the lock is not taken by SandboxLockHolders
+	SetLockTimeout(ctx, 50*time.Millisecond)
+	defer SetLockTimeout(ctx, 0)
+
+	_, err = rLockSandbox(ctx, testSandboxID)
+	assert.True(store.IsLockTimeout(err))
+
+	assert.NoError(unlockSandbox(ctx, testSandboxID, token))
+
+	holders, err = SandboxLockHolders(ctx, testSandboxID)
+	assert.NoError(err)
+	assert.Empty(holders)
 }
@@ -147,7 +147,7 @@ func parentCgroup(hierarchy cgroups.Hierarchy, path string) (cgroups.Cgroup, err
 func (s *Sandbox) updateCgroups() error {
 	if s.state.CgroupPath == "" {
 		s.Logger().Warn("sandbox's cgroup won't be updated: cgroup path is empty")
-		return nil
+		return s.pinVCPUs()
 	}
 
 	cgroup, err := cgroupsLoadFunc(V1Constraints, cgroups.StaticPath(s.state.CgroupPath))
@@ -159,21 +159,20 @@ func (s *Sandbox) updateCgroups() error {
 		return err
 	}
 
-	if len(s.containers) <= 1 {
-		// nothing to update
-		return nil
-	}
-
-	resources, err := s.resources()
-	if err != nil {
-		return err
-	}
+	if len(s.containers) > 1 {
+		resources, err := s.resources()
+		if err != nil {
+			return err
+		}
 
-	if err := cgroup.Update(&resources); err != nil {
-		return fmt.Errorf("Could not update cgroup %v: %v", s.state.CgroupPath, err)
+		if err := cgroup.Update(&resources); err != nil {
+			return fmt.Errorf("Could not update cgroup %v: %v", s.state.CgroupPath, err)
+		}
 	}
 
-	return nil
+	// Pin the vCPUs once they have been moved to the cgroup and the
+	// cgroup cpuset has been updated, as both reset their affinity.
+	return s.pinVCPUs()
 }
 
 func (s *Sandbox) deleteCgroups() error {
@@ -12,6 +12,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"syscall"
@@ -197,6 +198,10 @@ type CgroupStats struct {
 // ContainerStats describes a container stats.
 type ContainerStats struct {
 	CgroupStats *CgroupStats
+
+	// HypervisorStats are the statistics of the VMM running the sandbox
+	// of the container, if the hypervisor reports any.
+	HypervisorStats *HypervisorStats
 }
 
 // ContainerResources describes container resources
@@ -500,10 +505,36 @@ func (c *Container) shareFiles(m Mount, idx int, hostSharedDir, guestSharedDir s
 		if err := c.sandbox.agent.copyFile(m.Source, guestDest); err != nil {
 			return "", false, err
 		}
+
+		return guestDest, false, nil
+	}
+
+	mode, err := c.sharedMountMode(m)
+	if err != nil {
+		return "", false, err
+	}
+
+	if mode == SharedMountCopy {
+		// Copy the mount into a guest private directory: the guest shared
+		// directory is the host shared directory mounted in the guest, and
+		// the copy would otherwise land on the host, next to the mounts of
+		// the other containers.
+		guestDest = filepath.Join(kataGuestCopiedDir, c.id, filename)
+		c.Logger().WithFields(logrus.Fields{
+			"source": m.Source,
+			"dest":   guestDest,
+		}).Debug("Copying mount into the guest")
+
+		if err := c.copyMount(m.Source, guestDest); err != nil {
+			return "", false, err
+		}
 	} else {
-		// These mounts are created in the shared dir
+		// These mounts are created in the shared dir. Read-only ones are
+		// also bind mounted read-only on the host, so that the guest
+		// cannot write to them whatever the container does.
+		readonly := mode == SharedMountReadOnly || isReadOnlyMount(m)
 		mountDest := filepath.Join(hostSharedDir, c.sandbox.id, filename)
-		if err := bindMount(c.ctx, m.Source, mountDest, false); err != nil {
+		if err := bindMount(c.ctx, m.Source, mountDest, readonly); err != nil {
 			return "", false, err
 		}
 		// Save HostPath mount value into the mount list of the container.
@@ -513,6 +544,50 @@ func (c *Container) shareFiles(m Mount, idx int, hostSharedDir, guestSharedDir s
 	return guestDest, false, nil
 }
 
+// sharedMountMode returns how the bind mount m is provided to the guest,
+// according to the sandbox shared mount policy and the container annotations.
+func (c *Container) sharedMountMode(m Mount) (SharedMountMode, error) {
+	var policy SharedMountPolicy
+	if c.sandbox.config != nil {
+		policy = c.sandbox.config.SharedMountPolicy
+	}
+
+	return policy.Mode(m, c.config.Annotations)
+}
+
+// copyMount copies the regular files of the source, a file or a directory,
+// to the guest destination. Symbolic links are followed, as Kubernetes
+// secrets and configmaps are made of links to the actual files, and all
+// other files are ignored.
+func (c *Container) copyMount(source, guestDest string) error {
+	fileInfo, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+
+	if fileInfo.Mode().IsRegular() {
+		return c.sandbox.agent.copyFile(source, guestDest)
+	}
+
+	if !fileInfo.IsDir() {
+		c.Logger().WithField("ignored-file", source).Debug("Ignoring non-regular file in copied mount")
+		return nil
+	}
+
+	entries, err := ioutil.ReadDir(source)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if err := c.copyMount(filepath.Join(source, e.Name()), filepath.Join(guestDest, e.Name())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // mountSharedDirMounts handles bind-mounts by bindmounting to the host shared
 // directory which is mounted through 9pfs in the VM.
 // It also updates the container mount list with the HostPath info, and store
@@ -577,19 +652,12 @@ func (c *Container) mountSharedDirMounts(hostSharedDir, guestSharedDir string) (
 
 		// Check if mount is readonly, let the agent handle the readonly mount
 		// within the VM.
-		readonly := false
-		for _, flag := range m.Options {
-			if flag == "ro" {
-				readonly = true
-			}
-		}
-
 		sharedDirMount := Mount{
 			Source:      guestDest,
 			Destination: m.Destination,
 			Type:        m.Type,
 			Options:     m.Options,
-			ReadOnly:    readonly,
+			ReadOnly:    isReadOnlyMount(m),
 		}
 
 		sharedDirMounts = append(sharedDirMounts, sharedDirMount)
@@ -1153,7 +1221,19 @@ func (c *Container) stats() (*ContainerStats, error) {
 	if err := c.checkSandboxRunning("stats"); err != nil {
 		return nil, err
 	}
-	return c.sandbox.agent.statsContainer(c.sandbox, *c)
+
+	stats, err := c.sandbox.agent.statsContainer(c.sandbox, *c)
+	if err != nil {
+		return nil, err
+	}
+
+	hvStats, err := c.sandbox.hypervisor.getStats()
+	if err != nil {
+		return nil, err
+	}
+	stats.HypervisorStats = hvStats
+
+	return stats, nil
 }
 
 func (c *Container) update(resources specs.LinuxResources) error {
@@ -94,7 +94,7 @@ func TestContainerRemoveDrive(t *testing.T) {
 	sandbox := &Sandbox{
 		ctx:        context.Background(),
 		id:         "sandbox",
-		devManager: manager.NewDeviceManager(manager.VirtioSCSI, nil),
+		devManager: manager.NewDeviceManager(manager.VirtioSCSI, false, "", nil),
 		config:     &SandboxConfig{},
 	}
 
@@ -223,7 +223,7 @@ func TestContainerAddDriveDir(t *testing.T) {
 	sandbox := &Sandbox{
 		ctx:        context.Background(),
 		id:         testSandboxID,
-		devManager: manager.NewDeviceManager(manager.VirtioSCSI, nil),
+		devManager: manager.NewDeviceManager(manager.VirtioSCSI, false, "", nil),
 		hypervisor: &mockHypervisor{},
 		agent:      &noopAgent{},
 		config: &SandboxConfig{
@@ -485,3 +485,107 @@ func TestProcessIOStream(t *testing.T) {
 	_, _, _, err = c.ioStream(processID)
 	assert.Error(err)
 }
+
+type copyFileAgent struct {
+	noopAgent
+	copied map[string]string
+}
+
+func (a *copyFileAgent) copyFile(src, dst string) error {
+	a.copied[dst] = src
+	return nil
+}
+
+func TestContainerCopyMount(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir(testDir, "copy-mount-")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	// Lay out the source like a Kubernetes secret volume
+	dataDir := filepath.Join(dir, "..2019_10_01")
+	assert.NoError(os.MkdirAll(dataDir, testDirMode))
+	assert.NoError(ioutil.WriteFile(filepath.Join(dataDir, "token"), []byte("secret"), 0600))
+	assert.NoError(os.Symlink(filepath.Base(dataDir), filepath.Join(dir, "..data")))
+	assert.NoError(os.Symlink(filepath.Join("..data", "token"), filepath.Join(dir, "token")))
+	assert.NoError(syscall.Mkfifo(filepath.Join(dir, "fifo"), 0600))
+
+	agent := &copyFileAgent{copied: make(map[string]string)}
+	c := &Container{
+		id:      "testContainer",
+		sandbox: &Sandbox{agent: agent},
+	}
+
+	err = c.copyMount(dir, "/guest/dest")
+	assert.NoError(err)
+
+	expected := map[string]string{
+		"/guest/dest/token":              filepath.Join(dir, "token"),
+		"/guest/dest/..data/token":       filepath.Join(dir, "..data", "token"),
+		"/guest/dest/..2019_10_01/token": filepath.Join(dataDir, "token"),
+	}
+	assert.Equal(expected, agent.copied)
+
+	agent.copied = make(map[string]string)
+	err = c.copyMount(filepath.Join(dir, "token"), "/guest/token")
+	assert.NoError(err)
+	assert.Equal(map[string]string{"/guest/token": filepath.Join(dir, "token")}, agent.copied)
+
+	err = c.copyMount(filepath.Join(dir, "missing"), "/guest/missing")
+	assert.Error(err)
+}
+
+func TestContainerShareFilesCopy(t *testing.T) {
+	assert := assert.New(t)
+
+	hostSharedDir, err := ioutil.TempDir(testDir, "shared-")
+	assert.NoError(err)
+	defer os.RemoveAll(hostSharedDir)
+
+	source, err := ioutil.TempDir(testDir, "secret-")
+	assert.NoError(err)
+	defer os.RemoveAll(source)
+	assert.NoError(ioutil.WriteFile(filepath.Join(source, "token"), []byte("secret"), 0600))
+
+	agent := &copyFileAgent{copied: make(map[string]string)}
+	sandbox := &Sandbox{
+		ctx:        context.Background(),
+		id:         "sharefilessandbox",
+		agent:      agent,
+		hypervisor: &mockHypervisor{},
+		config: &SandboxConfig{
+			SharedMountPolicy: SharedMountPolicy{
+				CopyMounts: []string{filepath.Base(source)},
+			},
+		},
+	}
+	c := &Container{
+		ctx:     sandbox.ctx,
+		id:      "sharefilescontainer",
+		sandbox: sandbox,
+		config:  &ContainerConfig{},
+		mounts: []Mount{
+			{
+				Source:      source,
+				Destination: "/secret",
+				Type:        "bind",
+			},
+		},
+	}
+
+	guestDest, ignored, err := c.shareFiles(c.mounts[0], 0, hostSharedDir, kataGuestSharedDir)
+	assert.NoError(err)
+	assert.False(ignored)
+	assert.True(strings.HasPrefix(guestDest, filepath.Join(kataGuestCopiedDir, c.id)+"/"))
+	assert.False(strings.HasPrefix(guestDest, kataGuestSharedDir))
+	assert.Empty(c.mounts[0].HostPath)
+
+	assert.Equal(map[string]string{
+		filepath.Join(guestDest, "token"): filepath.Join(source, "token"),
+	}, agent.copied)
+
+	// Nothing of the copied mount is visible from the host shared directory
+	_, err = os.Stat(filepath.Join(hostSharedDir, sandbox.id))
+	assert.True(os.IsNotExist(err))
+}
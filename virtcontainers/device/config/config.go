@@ -8,11 +8,14 @@ package config
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strconv"
+	"syscall"
 
 	"github.com/go-ini/ini"
+	"golang.org/x/sys/unix"
 )
 
 // DeviceType indicates device type
@@ -63,6 +66,16 @@ const (
 	VirtioFS = "virtio-fs"
 )
 
+const (
+	// VhostUserBlkMajor is the major number of the block device nodes
+	// standing for vhost-user-blk devices in the vhost-user store.
+	VhostUserBlkMajor = 241
+
+	// VhostUserSCSIMajor is the major number of the block device nodes
+	// standing for vhost-user-scsi devices in the vhost-user store.
+	VhostUserSCSIMajor = 242
+)
+
 // Defining these as a variable instead of a const, to allow
 // overriding this in the tests.
 
@@ -167,6 +180,10 @@ type VFIODev struct {
 
 	// sysfsdev of VFIO mediated device
 	SysfsDev string
+
+	// PCIAddr is the guest PCI address of the device, in the format
+	// bus-addr/device-addr, when it was hotplugged on a bridge or root port.
+	PCIAddr string
 }
 
 // RNGDev represents a random number generator device
@@ -183,8 +200,16 @@ type VhostUserDeviceAttrs struct {
 	SocketPath string
 	Type       DeviceType
 
-	// MacAddress is only meaningful for vhost user net device
+	// These are only meaningful for vhost user net devices
 	MacAddress string
+	// Server is set when the hypervisor creates the socket
+	Server bool
+	// Queues is the number of queue pairs
+	Queues uint32
+
+	// PCIAddr is the guest PCI address of a hotplugged vhost-user device,
+	// in the format bus-addr/device-addr
+	PCIAddr string
 
 	// These are only meaningful for vhost user fs devices
 	Tag       string
@@ -198,11 +223,16 @@ var GetHostPathFunc = GetHostPath
 // GetHostPath is used to fetch the host path for the device.
 // The path passed in the spec refers to the path that should appear inside the container.
 // We need to find the actual device path on the host based on the major-minor numbers of the device.
-func GetHostPath(devInfo DeviceInfo) (string, error) {
+// When the vhost-user store is enabled, the host path of a vhost-user block device is its socket.
+func GetHostPath(devInfo DeviceInfo, vhostUserStoreEnabled bool, vhostUserStorePath string) (string, error) {
 	if devInfo.ContainerPath == "" {
 		return "", fmt.Errorf("Empty path provided for device")
 	}
 
+	if vhostUserStoreEnabled && IsVhostUserBlockDevice(devInfo) {
+		return getVhostUserHostPath(devInfo, vhostUserStorePath)
+	}
+
 	var pathComp string
 
 	switch devInfo.DevType {
@@ -245,3 +275,42 @@ func GetHostPath(devInfo DeviceInfo) (string, error) {
 
 	return filepath.Join("/dev", devName.String()), nil
 }
+
+// IsVhostUserBlockDevice checks if the device is the placeholder node of a
+// vhost-user-blk or vhost-user-scsi device.
+func IsVhostUserBlockDevice(devInfo DeviceInfo) bool {
+	return devInfo.DevType == "b" &&
+		(devInfo.Major == VhostUserBlkMajor || devInfo.Major == VhostUserSCSIMajor)
+}
+
+// getVhostUserHostPath returns the socket of a vhost-user block device. The
+// vhost-user store holds a placeholder block node for each device, in the
+// block/devices directory, and the device socket under the same name in the
+// block/sockets directory. The placeholder is found from the major-minor
+// numbers of the device.
+func getVhostUserHostPath(devInfo DeviceInfo, vhostUserStorePath string) (string, error) {
+	devicesDir := filepath.Join(vhostUserStorePath, "block", "devices")
+
+	files, err := ioutil.ReadDir(devicesDir)
+	if err != nil {
+		return "", err
+	}
+
+	for _, f := range files {
+		if f.Mode()&os.ModeDevice == 0 || f.Mode()&os.ModeCharDevice != 0 {
+			continue
+		}
+
+		stat, ok := f.Sys().(*syscall.Stat_t)
+		if !ok {
+			continue
+		}
+
+		rdev := uint64(stat.Rdev)
+		if int64(unix.Major(rdev)) == devInfo.Major && int64(unix.Minor(rdev)) == devInfo.Minor {
+			return filepath.Join(vhostUserStorePath, "block", "sockets", f.Name()), nil
+		}
+	}
+
+	return "", fmt.Errorf("No vhost-user device %d:%d found in %s", devInfo.Major, devInfo.Minor, devicesDir)
+}
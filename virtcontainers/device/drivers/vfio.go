@@ -153,6 +153,7 @@ func (device *VFIODevice) Save() persistapi.DeviceState {
 				Type:     uint32(dev.Type),
 				BDF:      dev.BDF,
 				SysfsDev: dev.SysfsDev,
+				PCIAddr:  dev.PCIAddr,
 			})
 		}
 	}
@@ -170,6 +171,7 @@ func (device *VFIODevice) Load(ds persistapi.DeviceState) {
 			Type:     config.VFIODeviceType(dev.Type),
 			BDF:      dev.BDF,
 			SysfsDev: dev.SysfsDev,
+			PCIAddr:  dev.PCIAddr,
 		})
 	}
 }
@@ -21,6 +21,20 @@ type VhostUserBlkDevice struct {
 	config.VhostUserDeviceAttrs
 }
 
+// NewVhostUserBlkDevice creates a new vhost-user block device based on DeviceInfo,
+// the host path of the device being its vhost-user socket
+func NewVhostUserBlkDevice(devInfo *config.DeviceInfo) *VhostUserBlkDevice {
+	return &VhostUserBlkDevice{
+		GenericDevice: &GenericDevice{
+			ID:         devInfo.ID,
+			DeviceInfo: devInfo,
+		},
+		VhostUserDeviceAttrs: config.VhostUserDeviceAttrs{
+			SocketPath: devInfo.HostPath,
+		},
+	}
+}
+
 //
 // VhostUserBlkDevice's implementation of the device interface:
 //
@@ -51,14 +65,35 @@ func (device *VhostUserBlkDevice) Attach(devReceiver api.DeviceReceiver) (err er
 	device.DevID = id
 	device.Type = device.DeviceType()
 
-	return devReceiver.AppendDevice(device)
+	deviceLogger().WithField("socket", device.SocketPath).Info("Attaching vhost-user block device")
+
+	return devReceiver.HotplugAddDevice(device, device.DeviceType())
 }
 
 // Detach is standard interface of api.Device, it's used to remove device from some
 // DeviceReceiver
-func (device *VhostUserBlkDevice) Detach(devReceiver api.DeviceReceiver) error {
-	_, err := device.bumpAttachCount(false)
-	return err
+func (device *VhostUserBlkDevice) Detach(devReceiver api.DeviceReceiver) (err error) {
+	skip, err := device.bumpAttachCount(false)
+	if err != nil {
+		return err
+	}
+	if skip {
+		return nil
+	}
+
+	defer func() {
+		if err != nil {
+			device.bumpAttachCount(true)
+		}
+	}()
+
+	deviceLogger().WithField("socket", device.SocketPath).Info("Unplugging vhost-user block device")
+
+	if err = devReceiver.HotplugRemoveDevice(device, device.DeviceType()); err != nil {
+		deviceLogger().WithError(err).Error("Failed to unplug vhost-user block device")
+		return err
+	}
+	return nil
 }
 
 // DeviceType is standard interface of api.Device, it returns device type
@@ -81,6 +116,7 @@ func (device *VhostUserBlkDevice) Save() persistapi.DeviceState {
 		SocketPath: device.SocketPath,
 		Type:       string(device.Type),
 		MacAddress: device.MacAddress,
+		PCIAddr:    device.PCIAddr,
 	}
 	return ds
 }
@@ -100,6 +136,7 @@ func (device *VhostUserBlkDevice) Load(ds persistapi.DeviceState) {
 		SocketPath: dev.SocketPath,
 		Type:       config.DeviceType(dev.Type),
 		MacAddress: dev.MacAddress,
+		PCIAddr:    dev.PCIAddr,
 	}
 }
 
@@ -21,6 +21,20 @@ type VhostUserSCSIDevice struct {
 	config.VhostUserDeviceAttrs
 }
 
+// NewVhostUserSCSIDevice creates a new vhost-user SCSI device based on DeviceInfo,
+// the host path of the device being its vhost-user socket
+func NewVhostUserSCSIDevice(devInfo *config.DeviceInfo) *VhostUserSCSIDevice {
+	return &VhostUserSCSIDevice{
+		GenericDevice: &GenericDevice{
+			ID:         devInfo.ID,
+			DeviceInfo: devInfo,
+		},
+		VhostUserDeviceAttrs: config.VhostUserDeviceAttrs{
+			SocketPath: devInfo.HostPath,
+		},
+	}
+}
+
 //
 // VhostUserSCSIDevice's implementation of the device interface:
 //
@@ -52,14 +66,35 @@ func (device *VhostUserSCSIDevice) Attach(devReceiver api.DeviceReceiver) (err e
 	device.DevID = id
 	device.Type = device.DeviceType()
 
-	return devReceiver.AppendDevice(device)
+	deviceLogger().WithField("socket", device.SocketPath).Info("Attaching vhost-user SCSI device")
+
+	return devReceiver.HotplugAddDevice(device, device.DeviceType())
 }
 
 // Detach is standard interface of api.Device, it's used to remove device from some
 // DeviceReceiver
-func (device *VhostUserSCSIDevice) Detach(devReceiver api.DeviceReceiver) error {
-	_, err := device.bumpAttachCount(false)
-	return err
+func (device *VhostUserSCSIDevice) Detach(devReceiver api.DeviceReceiver) (err error) {
+	skip, err := device.bumpAttachCount(false)
+	if err != nil {
+		return err
+	}
+	if skip {
+		return nil
+	}
+
+	defer func() {
+		if err != nil {
+			device.bumpAttachCount(true)
+		}
+	}()
+
+	deviceLogger().WithField("socket", device.SocketPath).Info("Unplugging vhost-user SCSI device")
+
+	if err = devReceiver.HotplugRemoveDevice(device, device.DeviceType()); err != nil {
+		deviceLogger().WithError(err).Error("Failed to unplug vhost-user SCSI device")
+		return err
+	}
+	return nil
 }
 
 // DeviceType is standard interface of api.Device, it returns device type
@@ -82,6 +117,7 @@ func (device *VhostUserSCSIDevice) Save() persistapi.DeviceState {
 		SocketPath: device.SocketPath,
 		Type:       string(device.Type),
 		MacAddress: device.MacAddress,
+		PCIAddr:    device.PCIAddr,
 	}
 	return ds
 }
@@ -101,6 +137,7 @@ func (device *VhostUserSCSIDevice) Load(ds persistapi.DeviceState) {
 		SocketPath: dev.SocketPath,
 		Type:       config.DeviceType(dev.Type),
 		MacAddress: dev.MacAddress,
+		PCIAddr:    dev.PCIAddr,
 	}
 }
 
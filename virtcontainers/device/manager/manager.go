@@ -47,6 +47,11 @@ var (
 type deviceManager struct {
 	blockDriver string
 
+	// vhostUserStoreEnabled tells if block devices can be backed by the
+	// vhost-user sockets of the store at vhostUserStorePath.
+	vhostUserStoreEnabled bool
+	vhostUserStorePath    string
+
 	devices map[string]api.Device
 	sync.RWMutex
 }
@@ -56,9 +61,11 @@ func deviceLogger() *logrus.Entry {
 }
 
 // NewDeviceManager creates a deviceManager object behaved as api.DeviceManager
-func NewDeviceManager(blockDriver string, devices []api.Device) api.DeviceManager {
+func NewDeviceManager(blockDriver string, vhostUserStoreEnabled bool, vhostUserStorePath string, devices []api.Device) api.DeviceManager {
 	dm := &deviceManager{
-		devices: make(map[string]api.Device),
+		vhostUserStoreEnabled: vhostUserStoreEnabled,
+		vhostUserStorePath:    vhostUserStorePath,
+		devices:               make(map[string]api.Device),
 	}
 	if blockDriver == VirtioMmio {
 		dm.blockDriver = VirtioMmio
@@ -86,23 +93,48 @@ func (dm *deviceManager) findDeviceByMajorMinor(major, minor int64) api.Device {
 	return nil
 }
 
-// createDevice creates one device based on DeviceInfo
-func (dm *deviceManager) createDevice(devInfo config.DeviceInfo) (dev api.Device, err error) {
-	path, err := config.GetHostPathFunc(devInfo)
-	if err != nil {
-		return nil, err
+// findBlockImage returns the block device backed by the image file at path.
+func (dm *deviceManager) findBlockImage(path string) api.Device {
+	for _, dev := range dm.devices {
+		b, ok := dev.(*drivers.BlockDevice)
+		if !ok {
+			continue
+		}
+
+		if (b.DeviceInfo != nil && b.DeviceInfo.HostPath == path) ||
+			(b.BlockDrive != nil && b.BlockDrive.File == path) {
+			return dev
+		}
 	}
-	devInfo.HostPath = path
+	return nil
+}
 
+// createDevice creates one device based on DeviceInfo
+func (dm *deviceManager) createDevice(devInfo config.DeviceInfo) (dev api.Device, err error) {
 	defer func() {
 		if err == nil {
 			dev.Reference()
 		}
 	}()
 
-	if existingDev := dm.findDeviceByMajorMinor(devInfo.Major, devInfo.Minor); existingDev != nil {
-		return existingDev, nil
+	// Block device images are regular files, without device numbers,
+	// which are found from their host path.
+	if isBlockImage(devInfo) {
+		if existingDev := dm.findBlockImage(devInfo.HostPath); existingDev != nil {
+			return existingDev, nil
+		}
+	} else {
+		path, err := config.GetHostPathFunc(devInfo, dm.vhostUserStoreEnabled, dm.vhostUserStorePath)
+		if err != nil {
+			return nil, err
+		}
+		devInfo.HostPath = path
+
+		if existingDev := dm.findDeviceByMajorMinor(devInfo.Major, devInfo.Minor); existingDev != nil {
+			return existingDev, nil
+		}
 	}
+	path := devInfo.HostPath
 
 	// device ID must be generated by manager instead of device itself
 	// in case of ID collision
@@ -111,6 +143,10 @@ func (dm *deviceManager) createDevice(devInfo config.DeviceInfo) (dev api.Device
 	}
 	if isVFIO(path) {
 		return drivers.NewVFIODevice(&devInfo), nil
+	} else if dm.vhostUserStoreEnabled && isVhostUserBlk(devInfo) {
+		return drivers.NewVhostUserBlkDevice(&devInfo), nil
+	} else if dm.vhostUserStoreEnabled && isVhostUserSCSI(devInfo) {
+		return drivers.NewVhostUserSCSIDevice(&devInfo), nil
 	} else if isBlock(devInfo) {
 		if devInfo.DriverOptions == nil {
 			devInfo.DriverOptions = make(map[string]string)
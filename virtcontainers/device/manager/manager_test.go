@@ -14,6 +14,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
 
 	"github.com/kata-containers/runtime/virtcontainers/device/api"
 	"github.com/kata-containers/runtime/virtcontainers/device/config"
@@ -202,8 +203,117 @@ func TestAttachBlockDevice(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestNewBlockImageDevice(t *testing.T) {
+	dm := &deviceManager{
+		blockDriver: VirtioBlock,
+		devices:     make(map[string]api.Device),
+	}
+
+	first, err := dm.NewDevice(config.DeviceInfo{
+		HostPath:      "/volumes/first/image.img",
+		ContainerPath: "/guest/first",
+		DevType:       "b",
+	})
+	assert.Nil(t, err)
+	_, ok := first.(*drivers.BlockDevice)
+	assert.True(t, ok)
+
+	second, err := dm.NewDevice(config.DeviceInfo{
+		HostPath:      "/volumes/second/image.img",
+		ContainerPath: "/guest/second",
+		DevType:       "b",
+	})
+	assert.Nil(t, err)
+	assert.NotEqual(t, first.DeviceID(), second.DeviceID())
+
+	devReceiver := &api.MockDeviceReceiver{}
+	err = second.Attach(devReceiver)
+	assert.Nil(t, err)
+	drive, ok := second.GetDeviceInfo().(*config.BlockDrive)
+	assert.True(t, ok)
+	assert.Equal(t, "/volumes/second/image.img", drive.File)
+
+	// Images are found from their path
+	again, err := dm.NewDevice(config.DeviceInfo{
+		HostPath:      "/volumes/first/image.img",
+		ContainerPath: "/guest/first",
+		DevType:       "b",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, first.DeviceID(), again.DeviceID())
+	assert.Equal(t, uint(2), again.(*drivers.BlockDevice).RefCount)
+}
+
+func TestAttachVhostUserBlkDevice(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("Test disabled as requires root user")
+	}
+
+	tmpDir, err := ioutil.TempDir("", "")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	devicesDir := filepath.Join(tmpDir, "block", "devices")
+	err = os.MkdirAll(devicesDir, dirMode)
+	assert.Nil(t, err)
+
+	// placeholder nodes of the vhost-user devices
+	blkNode := filepath.Join(devicesDir, "vhost-blk0")
+	err = unix.Mknod(blkNode, unix.S_IFBLK, int(unix.Mkdev(config.VhostUserBlkMajor, 0)))
+	assert.Nil(t, err)
+
+	scsiNode := filepath.Join(devicesDir, "vhost-scsi0")
+	err = unix.Mknod(scsiNode, unix.S_IFBLK, int(unix.Mkdev(config.VhostUserSCSIMajor, 0)))
+	assert.Nil(t, err)
+
+	dm := NewDeviceManager(VirtioSCSI, true, tmpDir, nil)
+	devReceiver := &api.MockDeviceReceiver{}
+
+	deviceInfo := config.DeviceInfo{
+		ContainerPath: "/dev/vda",
+		DevType:       "b",
+		Major:         config.VhostUserBlkMajor,
+		Minor:         0,
+	}
+
+	device, err := dm.NewDevice(deviceInfo)
+	assert.Nil(t, err)
+	blkDev, ok := device.(*drivers.VhostUserBlkDevice)
+	assert.True(t, ok)
+	assert.Equal(t, filepath.Join(tmpDir, "block", "sockets", "vhost-blk0"), blkDev.SocketPath)
+
+	err = device.Attach(devReceiver)
+	assert.Nil(t, err)
+	assert.Equal(t, config.DeviceType(config.VhostUserBlk), blkDev.Type)
+
+	err = device.Detach(devReceiver)
+	assert.Nil(t, err)
+
+	deviceInfo.ContainerPath = "/dev/sda"
+	deviceInfo.Major = config.VhostUserSCSIMajor
+
+	device, err = dm.NewDevice(deviceInfo)
+	assert.Nil(t, err)
+	scsiDev, ok := device.(*drivers.VhostUserSCSIDevice)
+	assert.True(t, ok)
+	assert.Equal(t, filepath.Join(tmpDir, "block", "sockets", "vhost-scsi0"), scsiDev.SocketPath)
+
+	// unknown device of the store
+	deviceInfo.Minor = 1
+	_, err = dm.NewDevice(deviceInfo)
+	assert.NotNil(t, err)
+
+	// the store is disabled, the device is a regular block device
+	dm = NewDeviceManager(VirtioSCSI, false, tmpDir, nil)
+	deviceInfo.HostPath = "/dev/sda"
+	device, err = dm.NewDevice(deviceInfo)
+	assert.Nil(t, err)
+	_, ok = device.(*drivers.BlockDevice)
+	assert.True(t, ok)
+}
+
 func TestAttachDetachDevice(t *testing.T) {
-	dm := NewDeviceManager(VirtioSCSI, nil)
+	dm := NewDeviceManager(VirtioSCSI, false, "", nil)
 
 	path := "/dev/hda"
 	deviceInfo := config.DeviceInfo{
@@ -35,3 +35,19 @@ func isVFIO(hostPath string) bool {
 func isBlock(devInfo config.DeviceInfo) bool {
 	return devInfo.DevType == "b"
 }
+
+// isBlockImage checks if the device is a regular file used as a block device
+// image, which has no device numbers.
+func isBlockImage(devInfo config.DeviceInfo) bool {
+	return devInfo.DevType == "b" && devInfo.Major == 0 && devInfo.Minor == 0 && devInfo.HostPath != ""
+}
+
+// isVhostUserBlk checks if the device is the placeholder of a vhost-user-blk device.
+func isVhostUserBlk(devInfo config.DeviceInfo) bool {
+	return devInfo.DevType == "b" && devInfo.Major == config.VhostUserBlkMajor
+}
+
+// isVhostUserSCSI checks if the device is the placeholder of a vhost-user-scsi device.
+func isVhostUserSCSI(devInfo config.DeviceInfo) bool {
+	return devInfo.DevType == "b" && devInfo.Major == config.VhostUserSCSIMajor
+}
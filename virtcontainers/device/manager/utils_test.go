@@ -53,3 +53,26 @@ func TestIsBlock(t *testing.T) {
 		assert.Equal(t, d.expected, isBlock)
 	}
 }
+
+func TestIsVhostUserBlkAndSCSI(t *testing.T) {
+	type testData struct {
+		devType      string
+		major        int64
+		expectedBlk  bool
+		expectedSCSI bool
+	}
+
+	data := []testData{
+		{"b", config.VhostUserBlkMajor, true, false},
+		{"b", config.VhostUserSCSIMajor, false, true},
+		{"c", config.VhostUserBlkMajor, false, false},
+		{"c", config.VhostUserSCSIMajor, false, false},
+		{"b", 8, false, false},
+	}
+
+	for _, d := range data {
+		devInfo := config.DeviceInfo{DevType: d.devType, Major: d.major}
+		assert.Equal(t, d.expectedBlk, isVhostUserBlk(devInfo))
+		assert.Equal(t, d.expectedSCSI, isVhostUserSCSI(devInfo))
+	}
+}
@@ -30,3 +30,15 @@ type FactoryBase interface {
 	// CloseFactory closes the base factory.
 	CloseFactory(ctx context.Context)
 }
+
+// ResetHypervisorConfig clears the parts of a VM configuration the VMs of a
+// base factory can differ in, as they are set when the VM is handed out.
+func ResetHypervisorConfig(config *vc.VMConfig) {
+	config.HypervisorConfig.NumVCPUs = 0
+	config.HypervisorConfig.MemorySize = 0
+	config.HypervisorConfig.BootToBeTemplate = false
+	config.HypervisorConfig.BootFromTemplate = false
+	config.HypervisorConfig.MemoryPath = ""
+	config.HypervisorConfig.DevicesStatePath = ""
+	config.ProxyConfig = vc.ProxyConfig{}
+}
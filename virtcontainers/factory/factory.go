@@ -92,6 +92,24 @@ func NewFactory(ctx context.Context, config Config, fetchOnly bool) (vc.Factory,
 	return &factory{b}, nil
 }
 
+// ListTemplates returns the template VMs of a template factory, the ones
+// matching its VM configuration are current.
+func ListTemplates(config Config) ([]template.Info, error) {
+	// The template VMs are created from the validated configuration,
+	// with its defaults set.
+	if err := config.VMConfig.Valid(); err != nil {
+		return nil, err
+	}
+
+	return template.List(config.VMConfig, config.TemplatePath)
+}
+
+// PruneTemplates removes the stale template VMs of a template factory, booted
+// from assets which changed since.
+func PruneTemplates(config Config) ([]template.Info, error) {
+	return template.Prune(config.TemplatePath)
+}
+
 // SetLogger sets the logger for the factory.
 func SetLogger(ctx context.Context, logger logrus.FieldLogger) {
 	fields := logrus.Fields{
@@ -105,16 +123,6 @@ func (f *factory) log() *logrus.Entry {
 	return factoryLogger.WithField("subsystem", "factory")
 }
 
-func resetHypervisorConfig(config *vc.VMConfig) {
-	config.HypervisorConfig.NumVCPUs = 0
-	config.HypervisorConfig.MemorySize = 0
-	config.HypervisorConfig.BootToBeTemplate = false
-	config.HypervisorConfig.BootFromTemplate = false
-	config.HypervisorConfig.MemoryPath = ""
-	config.HypervisorConfig.DevicesStatePath = ""
-	config.ProxyConfig = vc.ProxyConfig{}
-}
-
 // It's important that baseConfig and newConfig are passed by value!
 func checkVMConfig(config1, config2 vc.VMConfig) error {
 	if config1.HypervisorType != config2.HypervisorType {
@@ -126,8 +134,8 @@ func checkVMConfig(config1, config2 vc.VMConfig) error {
 	}
 
 	// check hypervisor config details
-	resetHypervisorConfig(&config1)
-	resetHypervisorConfig(&config2)
+	base.ResetHypervisorConfig(&config1)
+	base.ResetHypervisorConfig(&config2)
 
 	if !utils.DeepCompare(config1, config2) {
 		return fmt.Errorf("hypervisor config does not match, base: %+v. new: %+v", config1, config2)
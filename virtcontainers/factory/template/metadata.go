@@ -0,0 +1,296 @@
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package template
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+
+	vc "github.com/kata-containers/runtime/virtcontainers"
+	"github.com/kata-containers/runtime/virtcontainers/factory/base"
+	"github.com/kata-containers/runtime/virtcontainers/pkg/annotations"
+	"github.com/kata-containers/runtime/virtcontainers/types"
+)
+
+// templateInfoFile describes a template VM. It is written once the template
+// VM is saved, a template without it is incomplete.
+const templateInfoFile = "template.json"
+
+// templateIDLength is the number of hex digits of the template IDs.
+const templateIDLength = 16
+
+// Asset is a file the template VM was booted from.
+type Asset struct {
+	Path string `json:"path"`
+	Hash string `json:"hash"`
+
+	// The asset is hashed again only if its inode, size or modification
+	// time changed.
+	Inode   uint64    `json:"inode"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+}
+
+// Info describes a template VM.
+type Info struct {
+	// ID identifies the VM configuration and the assets the template VM
+	// was booted from.
+	ID         string    `json:"id"`
+	ConfigHash string    `json:"config_hash"`
+	Created    time.Time `json:"created"`
+
+	HypervisorType vc.HypervisorType `json:"hypervisor_type"`
+	NumVCPUs       uint32            `json:"vcpus"`
+	MemorySize     uint32            `json:"memory"`
+	Assets         []Asset           `json:"assets"`
+
+	// Path is the directory holding the template VM.
+	Path string `json:"-"`
+
+	// Stale is set when an asset changed since the template VM was
+	// created.
+	Stale bool `json:"-"`
+
+	// Current is set when the template VM matches the configuration it
+	// was listed for.
+	Current bool `json:"-"`
+}
+
+// configHash hashes the VM configuration, except what a VM created from the
+// template can differ in.
+func configHash(config vc.VMConfig) (string, error) {
+	base.ResetHypervisorConfig(&config)
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func assetPaths(config vc.VMConfig) []string {
+	var paths []string
+	for _, p := range []string{
+		config.HypervisorConfig.HypervisorPath,
+		config.HypervisorConfig.FirmwarePath,
+		config.HypervisorConfig.KernelPath,
+		config.HypervisorConfig.ImagePath,
+		config.HypervisorConfig.InitrdPath,
+	} {
+		if p != "" {
+			paths = append(paths, p)
+		}
+	}
+
+	return paths
+}
+
+// hashAsset returns the asset at path, reusing the hash of the recorded
+// asset if the file did not change.
+func hashAsset(path string, recorded *Asset) (Asset, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return Asset{}, err
+	}
+
+	asset := Asset{
+		Path:    path,
+		Size:    fi.Size(),
+		ModTime: fi.ModTime(),
+	}
+
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		asset.Inode = uint64(st.Ino)
+	}
+
+	if recorded != nil && recorded.Path == asset.Path && recorded.Inode == asset.Inode &&
+		recorded.Size == asset.Size && recorded.ModTime.Equal(asset.ModTime) {
+		asset.Hash = recorded.Hash
+		return asset, nil
+	}
+
+	// Only non empty regular files have a content to hash.
+	if !fi.Mode().IsRegular() || fi.Size() == 0 {
+		return asset, nil
+	}
+
+	if asset.Hash, err = types.FileHash(path, annotations.SHA256); err != nil {
+		return Asset{}, err
+	}
+
+	return asset, nil
+}
+
+// templateID returns the ID of the template VM booted from the given
+// configuration and assets.
+func templateID(configHash string, assets []Asset) string {
+	h := sha256.New()
+	fmt.Fprintln(h, configHash)
+	for _, a := range assets {
+		fmt.Fprintln(h, a.Path, a.Hash)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))[:templateIDLength]
+}
+
+// newInfo describes the template VM booted from config, hashing its assets.
+func newInfo(config vc.VMConfig, templatePath string) (*Info, error) {
+	hash, err := configHash(config)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &Info{
+		ConfigHash:     hash,
+		Created:        time.Now(),
+		HypervisorType: config.HypervisorType,
+		NumVCPUs:       config.HypervisorConfig.NumVCPUs,
+		MemorySize:     config.HypervisorConfig.MemorySize,
+	}
+
+	for _, p := range assetPaths(config) {
+		asset, err := hashAsset(p, nil)
+		if err != nil {
+			return nil, err
+		}
+		info.Assets = append(info.Assets, asset)
+	}
+
+	info.ID = templateID(info.ConfigHash, info.Assets)
+	info.Path = filepath.Join(templatePath, info.ID)
+
+	return info, nil
+}
+
+// checkAssets sets the template VM stale if one of its assets changed.
+func (info *Info) checkAssets() {
+	for i := range info.Assets {
+		recorded := &info.Assets[i]
+
+		asset, err := hashAsset(recorded.Path, recorded)
+		if err != nil || asset.Hash != recorded.Hash {
+			info.Stale = true
+			return
+		}
+	}
+}
+
+func (info *Info) save() error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(info.Path, templateInfoFile), data, 0600)
+}
+
+func loadInfo(path string) (*Info, error) {
+	data, err := ioutil.ReadFile(filepath.Join(path, templateInfoFile))
+	if err != nil {
+		return nil, err
+	}
+
+	var info Info
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, err
+	}
+	info.Path = path
+
+	return &info, nil
+}
+
+// List returns the template VMs in templatePath, the oldest first. The ones
+// matching config are current.
+func List(config vc.VMConfig, templatePath string) ([]Info, error) {
+	hash, err := configHash(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var infos []Info
+
+	// Template VMs created before they were versioned are saved in
+	// templatePath itself.
+	legacy := &template{statePath: templatePath}
+	if legacy.checkTemplateVM() == nil {
+		infos = append(infos, Info{Path: templatePath, Stale: true})
+	}
+
+	entries, err := ioutil.ReadDir(templatePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return infos, nil
+		}
+		return nil, err
+	}
+
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+
+		info, err := loadInfo(filepath.Join(templatePath, e.Name()))
+		if err != nil {
+			// Not a template VM, or one being created
+			continue
+		}
+
+		info.checkAssets()
+		info.Current = !info.Stale && info.ConfigHash == hash
+
+		infos = append(infos, *info)
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Created.Before(infos[j].Created)
+	})
+
+	return infos, nil
+}
+
+// Prune removes the stale template VMs in templatePath, and returns them.
+func Prune(templatePath string) ([]Info, error) {
+	lock, err := lockTemplates(templatePath, syscall.LOCK_EX)
+	if err != nil {
+		return nil, err
+	}
+	defer unlockTemplates(lock)
+
+	return prune(templatePath)
+}
+
+func prune(templatePath string) ([]Info, error) {
+	infos, err := List(vc.VMConfig{}, templatePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var pruned []Info
+	for _, info := range infos {
+		if !info.Stale {
+			continue
+		}
+
+		t := &template{statePath: info.Path}
+		if err := t.remove(); err != nil {
+			return pruned, err
+		}
+
+		pruned = append(pruned, info)
+	}
+
+	return pruned, nil
+}
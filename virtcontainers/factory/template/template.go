@@ -10,6 +10,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"syscall"
 	"time"
 
@@ -19,34 +20,112 @@ import (
 )
 
 type template struct {
-	statePath string
-	config    vc.VMConfig
+	statePath    string
+	templatePath string
+	config       vc.VMConfig
 }
 
 var templateWaitForAgent = 2 * time.Second
 
-// Fetch finds and returns a pre-built template factory.
-// TODO: save template metadata and fetch from storage.
+// lockTemplates takes a lock on the template VMs in templatePath, so that
+// they are not created or removed while in use. The lock file is next to
+// templatePath, where the legacy template VM tmpfs is mounted.
+func lockTemplates(templatePath string, how int) (*os.File, error) {
+	if templatePath == "" {
+		return nil, fmt.Errorf("Missing VM template path")
+	}
+
+	templatePath = filepath.Clean(templatePath)
+	if err := os.MkdirAll(filepath.Dir(templatePath), 0700); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(templatePath+".lock", os.O_RDONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		err = syscall.Flock(int(f.Fd()), how)
+		if err != syscall.EINTR {
+			break
+		}
+	}
+
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("Could not lock the VM templates in %s: %v", templatePath, err)
+	}
+
+	return f, nil
+}
+
+func unlockTemplates(f *os.File) {
+	syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+	f.Close()
+}
+
+// Fetch finds and returns a pre-built template factory, whose template VM
+// matches config and was booted from the current assets.
 func Fetch(config vc.VMConfig, templatePath string) (base.FactoryBase, error) {
-	t := &template{templatePath, config}
+	lock, err := lockTemplates(templatePath, syscall.LOCK_SH)
+	if err != nil {
+		return nil, err
+	}
+	defer unlockTemplates(lock)
 
-	err := t.checkTemplateVM()
+	return fetch(config, templatePath)
+}
+
+func fetch(config vc.VMConfig, templatePath string) (base.FactoryBase, error) {
+	infos, err := List(config, templatePath)
 	if err != nil {
 		return nil, err
 	}
 
-	return t, nil
+	for _, info := range infos {
+		if !info.Current {
+			continue
+		}
+
+		t := &template{info.Path, templatePath, config}
+		if err := t.checkTemplateVM(); err != nil {
+			return nil, err
+		}
+
+		return t, nil
+	}
+
+	return nil, fmt.Errorf("No VM template matching the configuration in %s", templatePath)
 }
 
-// New creates a new VM template factory.
+// New creates a new VM template factory. The stale template VMs, booted
+// from assets which changed since, are removed.
 func New(ctx context.Context, config vc.VMConfig, templatePath string) (base.FactoryBase, error) {
-	t := &template{templatePath, config}
+	lock, err := lockTemplates(templatePath, syscall.LOCK_EX)
+	if err != nil {
+		return nil, err
+	}
+	defer unlockTemplates(lock)
 
-	err := t.checkTemplateVM()
-	if err == nil {
+	if _, err := fetch(config, templatePath); err == nil {
 		return nil, fmt.Errorf("There is already a VM template in %s", templatePath)
 	}
 
+	if _, err := prune(templatePath); err != nil {
+		return nil, err
+	}
+
+	info, err := newInfo(config, templatePath)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &template{info.Path, templatePath, config}
+
+	// An incomplete template VM is replaced.
+	t.remove()
+
 	err = t.prepareTemplateFiles()
 	if err != nil {
 		return nil, err
@@ -62,6 +141,12 @@ func New(ctx context.Context, config vc.VMConfig, templatePath string) (base.Fac
 		return nil, err
 	}
 
+	// The template VM is complete once described.
+	err = info.save()
+	if err != nil {
+		return nil, err
+	}
+
 	return t, nil
 }
 
@@ -77,6 +162,10 @@ func (t *template) GetBaseVM(ctx context.Context, config vc.VMConfig) (*vc.VM, e
 
 // CloseFactory cleans up the template VM.
 func (t *template) CloseFactory(ctx context.Context) {
+	if lock, err := lockTemplates(t.templatePath, syscall.LOCK_EX); err == nil {
+		defer unlockTemplates(lock)
+	}
+
 	t.close()
 }
 
@@ -86,8 +175,29 @@ func (t *template) GetVMStatus() []*pb.GrpcVMStatus {
 }
 
 func (t *template) close() {
-	syscall.Unmount(t.statePath, 0)
-	os.RemoveAll(t.statePath)
+	if err := t.remove(); err != nil {
+		os.RemoveAll(t.statePath)
+	}
+}
+
+// remove removes the template VM. The VMs created from it keep their
+// memory, the tmpfs holding it is only detached.
+func (t *template) remove() error {
+	if err := syscall.Unmount(t.statePath, syscall.MNT_DETACH); err != nil && err != syscall.EINVAL {
+		return err
+	}
+
+	for _, f := range []string{"memory", "state", templateInfoFile} {
+		if err := os.Remove(filepath.Join(t.statePath, f)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	// Legacy template VMs are saved in the directory holding the others,
+	// which is left.
+	os.Remove(t.statePath)
+
+	return nil
 }
 
 func (t *template) prepareTemplateFiles() error {
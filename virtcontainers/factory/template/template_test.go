@@ -9,6 +9,8 @@ import (
 	"context"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"syscall"
 	"testing"
 	"time"
 
@@ -29,6 +31,7 @@ func TestTemplateFactory(t *testing.T) {
 	templateWaitForAgent = 1 * time.Microsecond
 
 	testDir, _ := ioutil.TempDir("", "vmfactory-tmp-")
+	defer os.Remove(testDir + ".lock")
 	hyperConfig := vc.HypervisorConfig{
 		KernelPath: testDir,
 		ImagePath:  testDir,
@@ -61,8 +64,9 @@ func TestTemplateFactory(t *testing.T) {
 
 	// Fetch
 	tt := template{
-		statePath: testDir,
-		config:    vmConfig,
+		statePath:    testDir,
+		templatePath: testDir,
+		config:       vmConfig,
 	}
 
 	assert.Equal(tt.Config(), vmConfig)
@@ -114,3 +118,219 @@ func TestTemplateFactory(t *testing.T) {
 	f.CloseFactory(ctx)
 	tt.CloseFactory(ctx)
 }
+
+func TestTemplateLock(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "vmfactory-tmp-")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	templatePath := filepath.Join(dir, "template")
+
+	lock, err := lockTemplates(templatePath, syscall.LOCK_EX)
+	assert.NoError(err)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := Prune(templatePath)
+		done <- err
+	}()
+
+	// Template VMs are not pruned while being created
+	select {
+	case <-done:
+		t.Fatal("templates pruned while locked")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	unlockTemplates(lock)
+
+	select {
+	case err := <-done:
+		assert.NoError(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("templates lock not released")
+	}
+
+	// Fetching only takes a shared lock
+	lock, err = lockTemplates(templatePath, syscall.LOCK_SH)
+	assert.NoError(err)
+	defer unlockTemplates(lock)
+
+	_, err = Fetch(vc.VMConfig{}, templatePath)
+	assert.Error(err)
+}
+
+func TestTemplateConfigHash(t *testing.T) {
+	assert := assert.New(t)
+
+	config := vc.VMConfig{
+		HypervisorType: vc.MockHypervisor,
+		HypervisorConfig: vc.HypervisorConfig{
+			KernelPath: "/foo/vmlinux",
+			ImagePath:  "/foo/image",
+			NumVCPUs:   1,
+			MemorySize: 128,
+		},
+		AgentType: vc.NoopAgentType,
+		ProxyType: vc.NoopProxyType,
+	}
+
+	hash, err := configHash(config)
+	assert.NoError(err)
+
+	// VMs created from the template can be bigger
+	config.HypervisorConfig.NumVCPUs = 2
+	config.HypervisorConfig.MemorySize = 256
+	h, err := configHash(config)
+	assert.NoError(err)
+	assert.Equal(hash, h)
+
+	config.HypervisorConfig.KernelParams = []vc.Param{{Key: "foo", Value: "bar"}}
+	h, err = configHash(config)
+	assert.NoError(err)
+	assert.NotEqual(hash, h)
+}
+
+func TestTemplateHashAsset(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "vmfactory-tmp-")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "vmlinux")
+	assert.NoError(ioutil.WriteFile(path, []byte("kernel"), 0644))
+
+	asset, err := hashAsset(path, nil)
+	assert.NoError(err)
+	assert.NotEmpty(asset.Hash)
+
+	// The hash of an unchanged asset is reused
+	recorded := asset
+	recorded.Hash = "foo"
+	a, err := hashAsset(path, &recorded)
+	assert.NoError(err)
+	assert.Equal("foo", a.Hash)
+
+	assert.NoError(ioutil.WriteFile(path, []byte("new kernel"), 0644))
+	a, err = hashAsset(path, &recorded)
+	assert.NoError(err)
+	assert.NotEqual("foo", a.Hash)
+	assert.NotEqual(asset.Hash, a.Hash)
+
+	_, err = hashAsset(filepath.Join(dir, "foo"), nil)
+	assert.Error(err)
+}
+
+func TestTemplateVersions(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip(testDisabledAsNonRoot)
+	}
+
+	assert := assert.New(t)
+
+	templateWaitForAgent = 1 * time.Microsecond
+
+	testDir, err := ioutil.TempDir("", "vmfactory-tmp-")
+	assert.NoError(err)
+	defer os.RemoveAll(testDir)
+
+	kernel := filepath.Join(testDir, "vmlinux")
+	image := filepath.Join(testDir, "image")
+	assert.NoError(ioutil.WriteFile(kernel, []byte("kernel"), 0644))
+	assert.NoError(ioutil.WriteFile(image, []byte("image"), 0644))
+
+	templatePath := filepath.Join(testDir, "template")
+
+	vmConfig := vc.VMConfig{
+		HypervisorType: vc.MockHypervisor,
+		HypervisorConfig: vc.HypervisorConfig{
+			KernelPath: kernel,
+			ImagePath:  image,
+		},
+		AgentType: vc.NoopAgentType,
+		ProxyType: vc.NoopProxyType,
+	}
+
+	ctx := context.Background()
+
+	// The mock hypervisor does not save the device state
+	newTemplate := func(config vc.VMConfig) Info {
+		_, err := New(ctx, config, templatePath)
+		assert.NoError(err)
+
+		infos, err := List(config, templatePath)
+		assert.NoError(err)
+
+		for _, info := range infos {
+			if info.Current {
+				_, err = os.Create(filepath.Join(info.Path, "state"))
+				assert.NoError(err)
+				return info
+			}
+		}
+
+		t.Fatal("no current template")
+		return Info{}
+	}
+
+	first := newTemplate(vmConfig)
+	defer (&template{statePath: first.Path}).close()
+	assert.Len(first.ID, templateIDLength)
+	assert.Equal(filepath.Join(templatePath, first.ID), first.Path)
+	assert.Len(first.Assets, 2)
+
+	_, err = Fetch(vmConfig, templatePath)
+	assert.NoError(err)
+
+	_, err = New(ctx, vmConfig, templatePath)
+	assert.Error(err)
+
+	// Templates of other configurations are kept side by side
+	otherConfig := vmConfig
+	otherConfig.HypervisorConfig.KernelParams = []vc.Param{{Key: "foo", Value: "bar"}}
+
+	other := newTemplate(otherConfig)
+	defer (&template{statePath: other.Path}).close()
+	assert.NotEqual(first.ID, other.ID)
+
+	infos, err := List(vmConfig, templatePath)
+	assert.NoError(err)
+	assert.Len(infos, 2)
+	assert.True(infos[0].Current)
+	assert.False(infos[1].Current)
+
+	// The kernel is upgraded, its templates are stale
+	assert.NoError(ioutil.WriteFile(kernel, []byte("new kernel"), 0644))
+
+	_, err = Fetch(vmConfig, templatePath)
+	assert.Error(err)
+
+	infos, err = List(vmConfig, templatePath)
+	assert.NoError(err)
+	assert.Len(infos, 2)
+	assert.True(infos[0].Stale)
+	assert.True(infos[1].Stale)
+
+	// and replaced by the new one
+	rebuilt := newTemplate(vmConfig)
+	defer (&template{statePath: rebuilt.Path}).close()
+	assert.NotEqual(first.ID, rebuilt.ID)
+
+	infos, err = List(vmConfig, templatePath)
+	assert.NoError(err)
+	assert.Len(infos, 1)
+	assert.Equal(rebuilt.ID, infos[0].ID)
+
+	_, err = os.Stat(first.Path)
+	assert.True(os.IsNotExist(err))
+
+	_, err = Fetch(vmConfig, templatePath)
+	assert.NoError(err)
+
+	pruned, err := Prune(templatePath)
+	assert.NoError(err)
+	assert.Empty(pruned)
+}
@@ -6,11 +6,13 @@
 package virtcontainers
 
 import (
+	"bufio"
 	"context"
 	"fmt"
 	"net"
 	"net/http"
 	"net/url"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"strconv"
@@ -19,14 +21,17 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/containerd/cgroups"
 	httptransport "github.com/go-openapi/runtime/client"
 	"github.com/go-openapi/strfmt"
 	"github.com/kata-containers/runtime/virtcontainers/pkg/firecracker/client"
 	models "github.com/kata-containers/runtime/virtcontainers/pkg/firecracker/client/models"
 	ops "github.com/kata-containers/runtime/virtcontainers/pkg/firecracker/client/operations"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
 	opentracing "github.com/opentracing/opentracing-go"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
 
 	"github.com/kata-containers/runtime/virtcontainers/device/config"
 	"github.com/kata-containers/runtime/virtcontainers/store"
@@ -47,11 +52,27 @@ const (
 	fcTimeout            = 10
 	fireSocket           = "firecracker.sock"
 	fcStopSandboxTimeout = 15
-	// This indicates the number of block devices that can be attached to the
-	// firecracker guest VM.
+	// This indicates the default number of block devices that can be attached
+	// to the firecracker guest VM.
 	// We attach a pool of placeholder drives before the guest has started, and then
 	// patch the replace placeholder drives with drives with actual contents.
 	fcDiskPoolSize = 8
+
+	// fcJailedSocket is the API socket of a jailed firecracker, relative
+	// to its chroot.
+	fcJailedSocket = "api.socket"
+	fcKernel       = "vmlinux"
+	fcRootfs       = "rootfs"
+)
+
+var (
+	// fcChrootBaseDir is the directory the jailer creates the chroots of
+	// the firecracker VMMs in.
+	fcChrootBaseDir = "/run/vc/firecracker"
+
+	// fcCgroupRoot is where the jailer creates the cgroups of the
+	// firecracker VMMs, in every cgroup controller hierarchy.
+	fcCgroupRoot = "/sys/fs/cgroup"
 )
 
 var fcKernelParams = append(commonVirtioblkKernelRootParams, []Param{
@@ -82,10 +103,25 @@ func (s vmmState) String() string {
 	return ""
 }
 
+// FirecrackerDrive is a slot of the pool of drives of a firecracker VM.
+type FirecrackerDrive struct {
+	// Placeholder is the host file backing the slot when it is free.
+	Placeholder string
+
+	// BlockDriveID is the ID of the block drive using the slot, empty
+	// when the slot is free.
+	BlockDriveID string
+}
+
 // FirecrackerInfo contains information related to the hypervisor that we
 // want to store on disk
 type FirecrackerInfo struct {
-	PID int
+	PID    int
+	Drives []FirecrackerDrive
+
+	// FreezerCgroup is the freezer cgroup the VMM was in before being
+	// paused, and is moved back to once the sandbox stops.
+	FreezerCgroup string
 }
 
 type firecrackerState struct {
@@ -110,6 +146,16 @@ type firecracker struct {
 	fcClient     *client.Firecracker //Tracks the current active connection
 	socketPath   string
 
+	jailed     bool   //Set when the VMM is started through the jailer
+	jailerRoot string //Chroot of the jailed VMM
+
+	vhostFd *os.File //Holds the vsock context ID until the VM starts
+
+	logFifo     *os.File //Logs of the VMM
+	metricsFifo *os.File //Metrics of the VMM
+	metrics     HypervisorStats
+	metricsLock sync.Mutex
+
 	store          *store.VCStore
 	config         HypervisorConfig
 	pendingDevices []firecrackerDevice // Devices to be added when the FC API is ready
@@ -151,9 +197,10 @@ func (fc *firecracker) createSandbox(ctx context.Context, id string, hypervisorC
 	//TODO: check validity of the hypervisor config provided
 	//https://github.com/kata-containers/runtime/issues/1065
 	fc.id = id
-	fc.socketPath = filepath.Join(store.SandboxRuntimeRootPath(fc.id), fireSocket)
 	fc.store = vcStore
 	fc.config = *hypervisorConfig
+
+	fc.setPaths()
 	fc.state.set(notReady)
 
 	// No need to return an error from there since there might be nothing
@@ -165,6 +212,19 @@ func (fc *firecracker) createSandbox(ctx context.Context, id string, hypervisorC
 	return nil
 }
 
+// setPaths sets the chroot of a jailed VMM and the path of the VMM API
+// socket up, from the VMM configuration.
+func (fc *firecracker) setPaths() {
+	fc.jailed = fc.config.JailerPath != ""
+	if fc.jailed {
+		// The jailer chroot is <base dir>/<VMM binary name>/<id>/root
+		fc.jailerRoot = filepath.Join(fcChrootBaseDir, filepath.Base(fc.config.HypervisorPath), fc.id, "root")
+		fc.socketPath = filepath.Join(fc.jailerRoot, fcJailedSocket)
+	} else {
+		fc.socketPath = filepath.Join(store.SandboxRuntimeRootPath(fc.id), fireSocket)
+	}
+}
+
 func (fc *firecracker) newFireClient() *client.Firecracker {
 	span, _ := fc.trace("newFireClient")
 	defer span.Finish()
@@ -239,9 +299,25 @@ func (fc *firecracker) fcInit(timeout int) error {
 	span, _ := fc.trace("fcInit")
 	defer span.Finish()
 
-	args := []string{"--api-sock", fc.socketPath}
+	var cmd *exec.Cmd
+	if fc.jailed {
+		// The jailer chroots to a directory of its own, creates the cgroups
+		// of the VMM, drops privileges and executes the VMM, which keeps
+		// the network namespace the sandbox is started in.
+		args := []string{
+			"--id", fc.id,
+			"--node", "0",
+			"--exec-file", fc.config.HypervisorPath,
+			"--uid", strconv.FormatUint(uint64(fc.config.JailerUID), 10),
+			"--gid", strconv.FormatUint(uint64(fc.config.JailerGID), 10),
+			"--chroot-base-dir", fcChrootBaseDir,
+		}
+		cmd = exec.Command(fc.config.JailerPath, args...)
+	} else {
+		args := []string{"--api-sock", fc.socketPath}
+		cmd = exec.Command(fc.config.HypervisorPath, args...)
+	}
 
-	cmd := exec.Command(fc.config.HypervisorPath, args...)
 	if err := cmd.Start(); err != nil {
 		fc.Logger().WithField("Error starting firecracker", err).Debug()
 		return err
@@ -310,6 +386,110 @@ func (fc *firecracker) fcEnd() (err error) {
 	return syscall.Kill(pid, syscall.SIGKILL)
 }
 
+// fcJailResource makes the host file src available at dst in the chroot of
+// a jailed VMM, and returns the path of src as seen by the VMM.
+func (fc *firecracker) fcJailResource(src, dst string, readonly bool) (string, error) {
+	if !fc.jailed {
+		return src, nil
+	}
+
+	if src == "" || dst == "" {
+		return "", fmt.Errorf("Invalid jail resource: src %q dst %q", src, dst)
+	}
+
+	if err := bindMount(fc.ctx, src, filepath.Join(fc.jailerRoot, dst), readonly); err != nil {
+		return "", err
+	}
+
+	return filepath.Join("/", dst), nil
+}
+
+// fcJailOwn gives the file at dst, created in the chroot of a jailed VMM,
+// to the user and group the VMM runs as. It must not be used on the host
+// files bound in the chroot.
+func (fc *firecracker) fcJailOwn(dst string) error {
+	if !fc.jailed || (fc.config.JailerUID == 0 && fc.config.JailerGID == 0) {
+		return nil
+	}
+
+	return os.Chown(filepath.Join(fc.jailerRoot, dst), int(fc.config.JailerUID), int(fc.config.JailerGID))
+}
+
+// fcJailDrive makes the host file src backing a drive available at dst in
+// the chroot of a jailed VMM, and returns the path of src as seen by the
+// VMM. A block device gets its own device node in the chroot, owned by the
+// user the VMM runs as, the host device is left untouched. Other files are
+// bound in the chroot, they cannot be used by a VMM not running as root.
+func (fc *firecracker) fcJailDrive(src, dst string) (string, error) {
+	if !fc.jailed {
+		return src, nil
+	}
+
+	var st unix.Stat_t
+	if err := unix.Stat(src, &st); err != nil {
+		return "", err
+	}
+
+	if st.Mode&unix.S_IFMT != unix.S_IFBLK {
+		if fc.config.JailerUID != 0 || fc.config.JailerGID != 0 {
+			return "", fmt.Errorf("Drive %s is not a block device, it cannot be used by firecracker running as %d:%d",
+				src, fc.config.JailerUID, fc.config.JailerGID)
+		}
+
+		return fc.fcJailResource(src, dst, false)
+	}
+
+	node := filepath.Join(fc.jailerRoot, dst)
+	if err := unix.Mknod(node, unix.S_IFBLK|0600, int(st.Rdev)); err != nil {
+		return "", fmt.Errorf("Could not create device node %s for drive %s: %v", node, src, err)
+	}
+
+	if err := fc.fcJailOwn(dst); err != nil {
+		os.Remove(node)
+		return "", err
+	}
+
+	return filepath.Join("/", dst), nil
+}
+
+// fcCleanupJail unmounts the resources of the chroot of a jailed VMM, and
+// removes the chroot and the cgroups created by the jailer.
+func (fc *firecracker) fcCleanupJail() error {
+	if !fc.jailed {
+		return nil
+	}
+
+	jailMounts, err := getMountsUnder(fc.jailerRoot)
+	if err != nil {
+		return err
+	}
+
+	// Unmount the deepest mounts first, in the reverse mount order.
+	for i := len(jailMounts) - 1; i >= 0; i-- {
+		if err := syscall.Unmount(jailMounts[i], syscall.MNT_DETACH); err != nil {
+			return fmt.Errorf("Could not unmount %v: %v", jailMounts[i], err)
+		}
+	}
+
+	// Never remove the chroot while resources are still mounted in it.
+	if err := os.RemoveAll(filepath.Dir(fc.jailerRoot)); err != nil {
+		return err
+	}
+
+	cgroups, err := filepath.Glob(filepath.Join(fcCgroupRoot, "*", filepath.Base(fc.config.HypervisorPath), fc.id))
+	if err != nil {
+		return err
+	}
+
+	for _, cg := range cgroups {
+		if err := os.Remove(cg); err != nil && !os.IsNotExist(err) {
+			fc.Logger().WithError(err).WithField("cgroup", cg).Warn("Could not remove jailer cgroup")
+		}
+	}
+
+	return nil
+}
+
 func (fc *firecracker) client() *client.Firecracker {
 	span, _ := fc.trace("client")
 	defer span.Finish()
@@ -388,6 +568,9 @@ func (fc *firecracker) fcStartVM() error {
 
 	fc.fcClient = fc.newFireClient()
 
+	// Still racy, another VM could get the context ID in the meantime.
+	fc.fcReleaseVsock()
+
 	actionParams := ops.NewCreateSyncActionParams()
 	actionType := "InstanceStart"
 	actionInfo := &models.InstanceActionInfo{
@@ -418,10 +601,16 @@ func (fc *firecracker) startSandbox(timeout int) error {
 
 	defer func() {
 		if err != nil {
+			fc.fcReleaseVsock()
+			fc.fcReleaseLogger()
 			fc.fcEnd()
 		}
 	}()
 
+	if err = fc.fcSetLogger(); err != nil {
+		return err
+	}
+
 	if err := fc.fcSetVMBaseConfig(int64(fc.config.MemorySize),
 		int64(fc.config.NumVCPUs),
 		false); err != nil {
@@ -433,6 +622,11 @@ func (fc *firecracker) startSandbox(timeout int) error {
 		return err
 	}
 
+	kernelPath, err = fc.fcJailResource(kernelPath, fcKernel, true)
+	if err != nil {
+		return err
+	}
+
 	kernelParams := append(fc.config.KernelParams, fcKernelParams...)
 	strParams := SerializeParams(kernelParams, "=")
 	formattedParams := strings.Join(strParams, " ")
@@ -451,8 +645,16 @@ func (fc *firecracker) startSandbox(timeout int) error {
 		}
 	}
 
+	image, err = fc.fcJailResource(image, fcRootfs, true)
+	if err != nil {
+		return err
+	}
+
 	fc.fcSetVMRootfs(image)
-	fc.createDiskPool()
+
+	if err = fc.createDiskPool(); err != nil {
+		return err
+	}
 
 	for _, d := range fc.pendingDevices {
 		if err = fc.addDevice(d.dev, d.devType); err != nil {
@@ -471,11 +673,89 @@ func fcDriveIndexToID(i int) string {
 	return "drive_" + strconv.Itoa(i)
 }
 
+func (fc *firecracker) drivePoolSize() int {
+	if fc.config.DrivePoolSize == 0 {
+		return fcDiskPoolSize
+	}
+
+	return int(fc.config.DrivePoolSize)
+}
+
+// fcDrivePlaceholder returns the path, as seen by the VMM, of the placeholder
+// backing the slot i of the drive pool when it is free.
+func (fc *firecracker) fcDrivePlaceholder(i int) string {
+	if fc.jailed {
+		return filepath.Join("/", fcDriveIndexToID(i))
+	}
+
+	return fc.info.Drives[i].Placeholder
+}
+
+// fcAllocDriveSlot reserves a free slot of the drive pool for the block
+// drive driveID.
+func (fc *firecracker) fcAllocDriveSlot(driveID string) (int, error) {
+	for i, d := range fc.info.Drives {
+		if d.BlockDriveID == "" {
+			fc.info.Drives[i].BlockDriveID = driveID
+			return i, nil
+		}
+	}
+
+	return -1, fmt.Errorf("No free drive in the pool of %d drives, cannot add drive %s", len(fc.info.Drives), driveID)
+}
+
+// fcFindDriveSlot returns the slot of the drive pool used by the block
+// drive driveID.
+func (fc *firecracker) fcFindDriveSlot(driveID string) (int, error) {
+	for i, d := range fc.info.Drives {
+		if d.BlockDriveID == driveID {
+			return i, nil
+		}
+	}
+
+	return -1, fmt.Errorf("Drive %s is not in the drive pool", driveID)
+}
+
+// fcCreateDrivePlaceholder creates the empty file backing the drive driveID
+// of the pool when it is free, and returns its path on the host and as seen
+// by the VMM. The placeholder of a jailed VMM is created in its chroot.
+func (fc *firecracker) fcCreateDrivePlaceholder(driveID string) (string, string, error) {
+	if fc.jailed {
+		path := filepath.Join(fc.jailerRoot, driveID)
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return "", "", err
+		}
+		f.Close()
+
+		if err := fc.fcJailOwn(driveID); err != nil {
+			return "", "", err
+		}
+
+		return path, filepath.Join("/", driveID), nil
+	}
+
+	hostURL, err := fc.store.Raw("")
+	if err != nil {
+		return "", "", err
+	}
+
+	// We get a full URL from Raw(), we need to parse it.
+	u, err := url.Parse(hostURL)
+	if err != nil {
+		return "", "", err
+	}
+
+	return u.Path, u.Path, nil
+}
+
 func (fc *firecracker) createDiskPool() error {
 	span, _ := fc.trace("createDiskPool")
 	defer span.Finish()
 
-	for i := 0; i < fcDiskPoolSize; i++ {
+	fc.info.Drives = nil
+
+	for i := 0; i < fc.drivePoolSize(); i++ {
 		driveID := fcDriveIndexToID(i)
 		driveParams := ops.NewPutGuestDriveByIDParams()
 		driveParams.SetDriveID(driveID)
@@ -483,13 +763,7 @@ func (fc *firecracker) createDiskPool() error {
 		isRootDevice := false
 
 		// Create a temporary file as a placeholder backend for the drive
-		hostURL, err := fc.store.Raw("")
-		if err != nil {
-			return err
-		}
-
-		// We get a full URL from Raw(), we need to parse it.
-		u, err := url.Parse(hostURL)
+		placeholder, drivePath, err := fc.fcCreateDrivePlaceholder(driveID)
 		if err != nil {
 			return err
 		}
@@ -498,16 +772,18 @@ func (fc *firecracker) createDiskPool() error {
 			DriveID:      &driveID,
 			IsReadOnly:   &isReadOnly,
 			IsRootDevice: &isRootDevice,
-			PathOnHost:   &u.Path,
+			PathOnHost:   &drivePath,
 		}
 		driveParams.SetBody(drive)
 		_, err = fc.client().Operations.PutGuestDriveByID(driveParams)
 		if err != nil {
 			return err
 		}
+
+		fc.info.Drives = append(fc.info.Drives, FirecrackerDrive{Placeholder: placeholder})
 	}
 
-	return nil
+	return fc.store.Store(store.Hypervisor, fc.info)
 }
 
 // stopSandbox will stop the Sandbox's VM.
@@ -515,18 +791,184 @@ func (fc *firecracker) stopSandbox() (err error) {
 	span, _ := fc.trace("stopSandbox")
 	defer span.Finish()
 
-	return fc.fcEnd()
+	// A frozen VMM cannot handle the signals stopping it
+	if err := fc.fcDeleteFreezer(); err != nil {
+		return err
+	}
+
+	if err := fc.fcEnd(); err != nil {
+		return err
+	}
+
+	fc.fcReleaseLogger()
+
+	return fc.fcCleanupJail()
+}
+
+// fcFreezerHierarchy is the cgroup hierarchy the VMM is frozen through,
+// made of the freezer subsystem only.
+func fcFreezerHierarchy() ([]cgroups.Subsystem, error) {
+	root, err := cgroupV1MountPoint()
+	if err != nil {
+		return nil, err
+	}
+
+	subsystems, err := cgroupsSubsystems([]cgroups.Subsystem{cgroups.NewFreezer(root)})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(subsystems) == 0 {
+		return nil, errors.New("freezer cgroup not available, cannot pause firecracker")
+	}
+
+	return subsystems, nil
+}
+
+// fcFreezerPath returns the freezer cgroup the VMM is moved to when the
+// sandbox is paused.
+func (fc *firecracker) fcFreezerPath() string {
+	return filepath.Join(cgroupKataPath, "firecracker", fc.id)
+}
+
+// fcProcessFreezerCgroup returns the freezer cgroup of the process.
+func fcProcessFreezerCgroup(pid int) (string, error) {
+	f, err := os.Open(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	// Lines look like "7:freezer:/path"
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+
+		for _, controller := range strings.Split(fields[1], ",") {
+			if controller == string(cgroups.Freezer) {
+				return fields[2], nil
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	return "", fmt.Errorf("No freezer cgroup found for process %d", pid)
+}
+
+// fcDeleteFreezer thaws the VMM, if it was frozen, and deletes its freezer
+// cgroup, moving it back to the freezer cgroup it was in before the pause.
+func (fc *firecracker) fcDeleteFreezer() error {
+	cgroup, err := cgroupsLoadFunc(fcFreezerHierarchy, cgroups.StaticPath(fc.fcFreezerPath()))
+	if err != nil {
+		// The sandbox has never been paused
+		return nil
+	}
+
+	if err := cgroup.Thaw(); err != nil {
+		return err
+	}
+
+	original, err := cgroupsLoadFunc(fcFreezerHierarchy, cgroups.StaticPath(fc.info.FreezerCgroup))
+	if err != nil {
+		fc.Logger().WithError(err).WithField("cgroup", fc.info.FreezerCgroup).Warn("Original freezer cgroup not found, moving firecracker to the root one")
+
+		if original, err = cgroupsLoadFunc(fcFreezerHierarchy, cgroups.RootPath); err != nil {
+			return err
+		}
+	}
+
+	if err := cgroup.MoveTo(original); err != nil {
+		return err
+	}
+
+	if err := cgroup.Delete(); err != nil {
+		return err
+	}
+
+	fc.info.FreezerCgroup = ""
+
+	return fc.storeInfo()
+}
+
+// storeInfo persists the VMM information, if the sandbox has a store.
+func (fc *firecracker) storeInfo() error {
+	if fc.store == nil {
+		return nil
+	}
+
+	return fc.store.Store(store.Hypervisor, fc.info)
 }
 
+// pauseSandbox freezes the VMM process through a freezer cgroup, firecracker
+// cannot pause the VM itself.
 func (fc *firecracker) pauseSandbox() error {
+	span, _ := fc.trace("pauseSandbox")
+	defer span.Finish()
+
+	pid := fc.info.PID
+	if pid <= 0 || syscall.Kill(pid, syscall.Signal(0)) != nil {
+		return fmt.Errorf("firecracker is not running, cannot pause sandbox %s", fc.id)
+	}
+
+	// Record the freezer cgroup of the VMM, unless already paused, to move
+	// it back there once the sandbox stops.
+	if fc.info.FreezerCgroup == "" {
+		original, err := fcProcessFreezerCgroup(pid)
+		if err != nil {
+			return err
+		}
+
+		fc.info.FreezerCgroup = original
+		if err := fc.storeInfo(); err != nil {
+			return err
+		}
+	}
+
+	cgroup, err := cgroupsNewFunc(fcFreezerHierarchy, cgroups.StaticPath(fc.fcFreezerPath()), &specs.LinuxResources{})
+	if err != nil {
+		return err
+	}
+
+	if err := cgroup.Add(cgroups.Process{Pid: pid}); err != nil {
+		return err
+	}
+
+	if err := cgroup.Freeze(); err != nil {
+		return err
+	}
+
+	fc.Logger().WithField("pid", pid).Info("Firecracker VM paused")
+
 	return nil
 }
 
+// saveSandbox fails, firecracker cannot save the state of the VM.
 func (fc *firecracker) saveSandbox() error {
-	return nil
+	return errors.New("firecracker does not support saving the VM state")
 }
 
+// resumeSandbox thaws the VMM process frozen by pauseSandbox.
 func (fc *firecracker) resumeSandbox() error {
+	span, _ := fc.trace("resumeSandbox")
+	defer span.Finish()
+
+	cgroup, err := cgroupsLoadFunc(fcFreezerHierarchy, cgroups.StaticPath(fc.fcFreezerPath()))
+	if err != nil {
+		return errors.Wrapf(err, "sandbox %s is not paused", fc.id)
+	}
+
+	if err := cgroup.Thaw(); err != nil {
+		return err
+	}
+
+	fc.Logger().WithField("pid", fc.info.PID).Info("Firecracker VM resumed")
+
 	return nil
 }
 
@@ -534,6 +976,11 @@ func (fc *firecracker) fcAddVsock(vs kataVSOCK) error {
 	span, _ := fc.trace("fcAddVsock")
 	defer span.Finish()
 
+	// The VMM opens the vhost-vsock device itself.
+	if _, err := fc.fcJailResource(utils.VHostVSockDevicePath, "dev/vhost-vsock", false); err != nil {
+		return err
+	}
+
 	vsockParams := ops.NewPutGuestVsockByIDParams()
 	vsockID := "root"
 	ctxID := int64(vs.contextID)
@@ -547,21 +994,34 @@ func (fc *firecracker) fcAddVsock(vs kataVSOCK) error {
 	if err != nil {
 		return err
 	}
-	//Still racy. There is no way to send an fd to the firecracker
-	//REST API. We could release this just before we start the instance
-	//but even that will not eliminate the race
-	vs.vhostFd.Close()
+
+	//There is no way to send an fd to the firecracker REST API, keep
+	//the context ID reserved until the VMM claims it, when the instance
+	//starts.
+	fc.vhostFd = vs.vhostFd
 	return nil
 }
 
+// fcReleaseVsock releases the vsock context ID reserved for the VM.
+func (fc *firecracker) fcReleaseVsock() {
+	if fc.vhostFd != nil {
+		fc.vhostFd.Close()
+		fc.vhostFd = nil
+	}
+}
+
 func (fc *firecracker) fcAddNetDevice(endpoint Endpoint) error {
 	span, _ := fc.trace("fcAddNetDevice")
 	defer span.Finish()
 
+	if err := fc.fcSetTapOwner(endpoint); err != nil {
+		return err
+	}
+
 	cfg := ops.NewPutGuestNetworkInterfaceByIDParams()
 	ifaceID := endpoint.Name()
 	ifaceCfg := &models.NetworkInterface{
-		AllowMmdsRequests: false,
+		AllowMmdsRequests: fc.config.EnableMMDS,
 		GuestMac:          endpoint.HardwareAddr(),
 		IfaceID:           &ifaceID,
 		HostDevName:       &endpoint.NetworkPair().TapInterface.TAPIface.Name,
@@ -572,6 +1032,29 @@ func (fc *firecracker) fcAddNetDevice(endpoint Endpoint) error {
 	return err
 }
 
+// fcSetTapOwner lets a jailed VMM, not running as root, attach to the tap
+// interface of the endpoint.
+func (fc *firecracker) fcSetTapOwner(endpoint Endpoint) error {
+	if !fc.jailed || (fc.config.JailerUID == 0 && fc.config.JailerGID == 0) {
+		return nil
+	}
+
+	fds := endpoint.NetworkPair().TapInterface.VMFds
+	if len(fds) == 0 {
+		return fmt.Errorf("No file descriptor to set the owner of tap %s", endpoint.NetworkPair().TapInterface.TAPIface.Name)
+	}
+
+	if err := unix.IoctlSetInt(int(fds[0].Fd()), unix.TUNSETOWNER, int(fc.config.JailerUID)); err != nil {
+		return fmt.Errorf("Could not set the owner of tap %s: %v", endpoint.NetworkPair().TapInterface.TAPIface.Name, err)
+	}
+
+	if err := unix.IoctlSetInt(int(fds[0].Fd()), unix.TUNSETGROUP, int(fc.config.JailerGID)); err != nil {
+		return fmt.Errorf("Could not set the group of tap %s: %v", endpoint.NetworkPair().TapInterface.TAPIface.Name, err)
+	}
+
+	return nil
+}
+
 func (fc *firecracker) fcAddBlockDrive(drive config.BlockDrive) error {
 	span, _ := fc.trace("fcAddBlockDrive")
 	defer span.Finish()
@@ -581,35 +1064,35 @@ func (fc *firecracker) fcAddBlockDrive(drive config.BlockDrive) error {
 	driveParams.SetDriveID(driveID)
 	isReadOnly := false
 	isRootDevice := false
+
+	drivePath, err := fc.fcJailDrive(drive.File, driveID)
+	if err != nil {
+		return err
+	}
+
 	driveFc := &models.Drive{
 		DriveID:      &driveID,
 		IsReadOnly:   &isReadOnly,
 		IsRootDevice: &isRootDevice,
-		PathOnHost:   &drive.File,
+		PathOnHost:   &drivePath,
 	}
 	driveParams.SetBody(driveFc)
-	_, err := fc.client().Operations.PutGuestDriveByID(driveParams)
+	_, err = fc.client().Operations.PutGuestDriveByID(driveParams)
 	return err
 }
 
-// Firecracker supports replacing the host drive used once the VM has booted up
-func (fc *firecracker) fcUpdateBlockDrive(drive config.BlockDrive) error {
-	span, _ := fc.trace("fcUpdateBlockDrive")
-	defer span.Finish()
-
-	// Use the global block index as an index into the pool of the devices
-	// created for firecracker.
-	driveID := fcDriveIndexToID(drive.Index)
+// fcPatchDrive replaces the host file backing the slot i of the drive pool.
+func (fc *firecracker) fcPatchDrive(i int, path string) error {
+	driveID := fcDriveIndexToID(i)
 	driveParams := ops.NewPatchGuestDriveByIDParams()
 	driveParams.SetDriveID(driveID)
 
 	driveFc := &models.PartialDrive{
 		DriveID:    &driveID,
-		PathOnHost: &drive.File, //This is the only property that can be modified
+		PathOnHost: &path, //This is the only property that can be modified
 	}
 	driveParams.SetBody(driveFc)
-	_, err := fc.client().Operations.PatchGuestDriveByID(driveParams)
-	if err != nil {
+	if _, err := fc.client().Operations.PatchGuestDriveByID(driveParams); err != nil {
 		return err
 	}
 
@@ -622,8 +1105,7 @@ func (fc *firecracker) fcUpdateBlockDrive(drive config.BlockDrive) error {
 			Payload:    driveID,
 		}
 		actionParams.SetInfo(actionInfo)
-		_, err = fc.client().Operations.CreateSyncAction(actionParams)
-		if err != nil {
+		if _, err := fc.client().Operations.CreateSyncAction(actionParams); err != nil {
 			return err
 		}
 	}
@@ -631,12 +1113,84 @@ func (fc *firecracker) fcUpdateBlockDrive(drive config.BlockDrive) error {
 	return nil
 }
 
+// Firecracker supports replacing the host drive used once the VM has booted up
+func (fc *firecracker) fcUpdateBlockDrive(drive *config.BlockDrive) (err error) {
+	span, _ := fc.trace("fcUpdateBlockDrive")
+	defer span.Finish()
+
+	// The drive takes a free slot of the pool of the devices created for
+	// firecracker, the guest sees the slots after the VM rootfs.
+	slot, err := fc.fcAllocDriveSlot(drive.ID)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			fc.info.Drives[slot].BlockDriveID = ""
+		}
+	}()
+
+	driveName, err := utils.GetVirtDriveName(slot + 1)
+	if err != nil {
+		return err
+	}
+
+	drivePath, err := fc.fcJailDrive(drive.File, drive.ID)
+	if err != nil {
+		return err
+	}
+
+	if err = fc.fcPatchDrive(slot, drivePath); err != nil {
+		return err
+	}
+
+	drive.VirtPath = filepath.Join("/dev", driveName)
+
+	return fc.store.Store(store.Hypervisor, fc.info)
+}
+
+// fcRemoveBlockDrive puts the placeholder back in the slot of the drive pool
+// used by the drive, and frees the slot.
+func (fc *firecracker) fcRemoveBlockDrive(drive *config.BlockDrive) error {
+	span, _ := fc.trace("fcRemoveBlockDrive")
+	defer span.Finish()
+
+	slot, err := fc.fcFindDriveSlot(drive.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := fc.fcPatchDrive(slot, fc.fcDrivePlaceholder(slot)); err != nil {
+		return err
+	}
+
+	if fc.jailed {
+		// Block devices have their own device node, not a mount.
+		path := filepath.Join(fc.jailerRoot, drive.ID)
+		if err := syscall.Unmount(path, syscall.MNT_DETACH); err != nil && err != syscall.EINVAL {
+			return err
+		}
+		os.Remove(path)
+	}
+
+	fc.info.Drives[slot].BlockDriveID = ""
+
+	return fc.store.Store(store.Hypervisor, fc.info)
+}
+
 // addDevice will add extra devices to firecracker.  Limited to configure before the
 // virtual machine starts.  Devices include drivers and network interfaces only.
 func (fc *firecracker) addDevice(devInfo interface{}, devType deviceType) error {
 	span, _ := fc.trace("addDevice")
 	defer span.Finish()
 
+	// Firecracker has no vhost-user backend, the device cannot be
+	// ignored.
+	if devType == vhostuserDev {
+		return fmt.Errorf("Firecracker does not support vhost-user devices")
+	}
+
 	fc.state.RLock()
 	defer fc.state.RUnlock()
 
@@ -675,7 +1229,7 @@ func (fc *firecracker) hotplugAddDevice(devInfo interface{}, devType deviceType)
 	switch devType {
 	case blockDev:
 		//The drive placeholder has to exist prior to Update
-		return nil, fc.fcUpdateBlockDrive(*devInfo.(*config.BlockDrive))
+		return nil, fc.fcUpdateBlockDrive(devInfo.(*config.BlockDrive))
 	default:
 		fc.Logger().WithFields(logrus.Fields{"devInfo": devInfo,
 			"deviceType": devType}).Warn("hotplugAddDevice: unsupported device")
@@ -684,9 +1238,18 @@ func (fc *firecracker) hotplugAddDevice(devInfo interface{}, devType deviceType)
 	}
 }
 
-// hotplugRemoveDevice supported in Firecracker VMM, but no-op
+// hotplugRemoveDevice supported in Firecracker VMM for block drives, which
+// give their slot of the drive pool back. No-op for the other devices.
 func (fc *firecracker) hotplugRemoveDevice(devInfo interface{}, devType deviceType) (interface{}, error) {
-	return nil, nil
+	span, _ := fc.trace("hotplugRemoveDevice")
+	defer span.Finish()
+
+	switch devType {
+	case blockDev:
+		return nil, fc.fcRemoveBlockDrive(devInfo.(*config.BlockDrive))
+	default:
+		return nil, nil
+	}
 }
 
 // getSandboxConsole builds the path of the console where we can read
@@ -725,6 +1288,10 @@ func (fc *firecracker) resizeVCPUs(reqVCPUs uint32) (currentVCPUs uint32, newVCP
 	return 0, 0, nil
 }
 
+func (fc *firecracker) resizeBalloon(reqMemMB uint32) (uint32, error) {
+	return 0, nil
+}
+
 // This is used to apply cgroup information on the host.
 //
 // As suggested by https://github.com/firecracker-microvm/firecracker/issues/718,
@@ -763,8 +1330,31 @@ func (fc *firecracker) getThreadIDs() (vcpuThreadIDs, error) {
 	return vcpuInfo, nil
 }
 
+// publishMetadata puts the sandbox metadata in the MMDS of the VM, when
+// it is enabled, under "kata".
+func (fc *firecracker) publishMetadata(metadata *SandboxMetadata) error {
+	if !fc.config.EnableMMDS {
+		return nil
+	}
+
+	span, _ := fc.trace("publishMetadata")
+	defer span.Finish()
+
+	param := ops.NewPutMmdsParams()
+	param.SetBody(map[string]interface{}{
+		"kata": metadata,
+	})
+
+	_, err := fc.client().Operations.PutMmds(param)
+	return err
+}
+
 func (fc *firecracker) cleanup() error {
-	return nil
+	if err := fc.fcDeleteFreezer(); err != nil {
+		return err
+	}
+
+	return fc.fcCleanupJail()
 }
 
 func (fc *firecracker) pid() int {
@@ -0,0 +1,221 @@
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	models "github.com/kata-containers/runtime/virtcontainers/pkg/firecracker/client/models"
+	ops "github.com/kata-containers/runtime/virtcontainers/pkg/firecracker/client/operations"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+
+	"github.com/kata-containers/runtime/virtcontainers/store"
+)
+
+const (
+	fcLogFifo     = "logs.fifo"
+	fcMetricsFifo = "metrics.fifo"
+
+	// fcMetricsMaxSize is the maximum size of a metrics flush, the VMM
+	// writes every flush as a single JSON line.
+	fcMetricsMaxSize = 1024 * 1024
+)
+
+// fcMetrics is the subset of the metrics flushed by firecracker the runtime
+// collects. The counters of a flush are reset once flushed.
+type fcMetrics struct {
+	Block fcBlockMetrics `json:"block"`
+}
+
+type fcBlockMetrics struct {
+	ReadBytes  uint64 `json:"read_bytes"`
+	WriteBytes uint64 `json:"write_bytes"`
+	ReadCount  uint64 `json:"read_count"`
+	WriteCount uint64 `json:"write_count"`
+	FlushCount uint64 `json:"flush_count"`
+}
+
+// add cumulates the counters of a metrics flush into stats.
+func (m *fcMetrics) add(stats *HypervisorStats) {
+	stats.Block.ReadBytes += m.Block.ReadBytes
+	stats.Block.WriteBytes += m.Block.WriteBytes
+	stats.Block.ReadCount += m.Block.ReadCount
+	stats.Block.WriteCount += m.Block.WriteCount
+	stats.Block.FlushCount += m.Block.FlushCount
+}
+
+// fcLogLevel returns the level of a firecracker log line, such as
+// "2019-04-10T12:00:00.000000000 [fc-id:WARN:src/vmm/src/lib.rs:123] msg".
+func fcLogLevel(line string) logrus.Level {
+	for _, l := range []struct {
+		name  string
+		level logrus.Level
+	}{
+		{"ERROR", logrus.ErrorLevel},
+		{"WARN", logrus.WarnLevel},
+		{"INFO", logrus.InfoLevel},
+		{"DEBUG", logrus.DebugLevel},
+		{"TRACE", logrus.DebugLevel},
+	} {
+		if strings.Contains(line, ":"+l.name+":") || strings.Contains(line, ":"+l.name+"]") {
+			return l.level
+		}
+	}
+
+	return logrus.InfoLevel
+}
+
+// fcCreateFifo creates a FIFO the VMM writes to, and returns it opened for
+// reading along with its path as seen by the VMM.
+func (fc *firecracker) fcCreateFifo(name string) (*os.File, string, error) {
+	path := filepath.Join(store.SandboxRuntimeRootPath(fc.id), name)
+	vmmPath := path
+	if fc.jailed {
+		path = filepath.Join(fc.jailerRoot, name)
+		vmmPath = filepath.Join("/", name)
+	}
+
+	os.Remove(path)
+	if err := unix.Mkfifo(path, 0600); err != nil {
+		return nil, "", err
+	}
+
+	if err := fc.fcJailOwn(name); err != nil {
+		return nil, "", err
+	}
+
+	// Opening the FIFO read-write doesn't block until the VMM opens it,
+	// and the reader doesn't get EOF if the VMM closes it.
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return f, vmmPath, nil
+}
+
+// fcSetLogger configures the logger of the VMM, forwarding its logs to the
+// runtime logs and collecting its metrics. Logs and metrics are collected
+// as long as the process that started the VMM runs.
+func (fc *firecracker) fcSetLogger() (err error) {
+	span, _ := fc.trace("fcSetLogger")
+	defer span.Finish()
+
+	defer func() {
+		if err != nil {
+			fc.fcReleaseLogger()
+		}
+	}()
+
+	logFifo, logPath, err := fc.fcCreateFifo(fcLogFifo)
+	if err != nil {
+		return err
+	}
+	fc.logFifo = logFifo
+
+	metricsFifo, metricsPath, err := fc.fcCreateFifo(fcMetricsFifo)
+	if err != nil {
+		return err
+	}
+	fc.metricsFifo = metricsFifo
+
+	level := "Warning"
+	if fc.config.Debug {
+		level = "Debug"
+	}
+	showLevel := true
+
+	param := ops.NewPutLoggerParams()
+	param.SetBody(&models.Logger{
+		Level:         &level,
+		LogFifo:       &logPath,
+		MetricsFifo:   &metricsPath,
+		ShowLevel:     &showLevel,
+		ShowLogOrigin: &fc.config.Debug,
+	})
+
+	if _, err = fc.client().Operations.PutLogger(param); err != nil {
+		return err
+	}
+
+	go fc.fcForwardLogs(logFifo)
+	go fc.fcCollectMetrics(metricsFifo)
+
+	return nil
+}
+
+// fcReleaseLogger closes the FIFOs of the VMM logger, which stops
+// forwarding its logs and collecting its metrics.
+func (fc *firecracker) fcReleaseLogger() {
+	if fc.logFifo != nil {
+		fc.logFifo.Close()
+		fc.logFifo = nil
+	}
+
+	if fc.metricsFifo != nil {
+		fc.metricsFifo.Close()
+		fc.metricsFifo = nil
+	}
+}
+
+func (fc *firecracker) fcForwardLogs(r io.Reader) {
+	logger := fc.Logger().WithFields(logrus.Fields{
+		"source":  "firecracker",
+		"sandbox": fc.id,
+	})
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch fcLogLevel(line) {
+		case logrus.ErrorLevel:
+			logger.Error(line)
+		case logrus.WarnLevel:
+			logger.Warn(line)
+		case logrus.DebugLevel:
+			logger.Debug(line)
+		default:
+			logger.Info(line)
+		}
+	}
+}
+
+func (fc *firecracker) fcCollectMetrics(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), fcMetricsMaxSize)
+
+	for scanner.Scan() {
+		var m fcMetrics
+		if err := json.Unmarshal(scanner.Bytes(), &m); err != nil {
+			fc.Logger().WithError(err).Warn("Invalid firecracker metrics")
+			continue
+		}
+
+		fc.metricsLock.Lock()
+		m.add(&fc.metrics)
+		fc.metricsLock.Unlock()
+	}
+}
+
+// getStats returns the metrics flushed by the VMM so far, the VMM flushes
+// them periodically. There are none when they are not collected.
+func (fc *firecracker) getStats() (*HypervisorStats, error) {
+	if fc.metricsFifo == nil {
+		return nil, nil
+	}
+
+	fc.metricsLock.Lock()
+	defer fc.metricsLock.Unlock()
+
+	stats := fc.metrics
+	return &stats, nil
+}
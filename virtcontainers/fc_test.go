@@ -0,0 +1,413 @@
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/containerd/cgroups"
+	ktu "github.com/kata-containers/runtime/pkg/katatestutils"
+	"github.com/kata-containers/runtime/virtcontainers/device/config"
+	"github.com/kata-containers/runtime/virtcontainers/store"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+func TestFCCreateSandboxJailed(t *testing.T) {
+	assert := assert.New(t)
+
+	fc := &firecracker{}
+	config := HypervisorConfig{
+		HypervisorPath: "/usr/bin/firecracker",
+	}
+
+	vcStore, err := store.NewVCSandboxStore(context.Background(), "fcsandbox")
+	assert.NoError(err)
+	defer store.DeleteAll()
+
+	err = fc.createSandbox(context.Background(), "fcsandbox", &config, vcStore)
+	assert.NoError(err)
+	assert.False(fc.jailed)
+	assert.Equal(filepath.Join(store.SandboxRuntimeRootPath("fcsandbox"), fireSocket), fc.socketPath)
+
+	path, err := fc.fcJailResource("/foo/vmlinux", fcKernel, true)
+	assert.NoError(err)
+	assert.Equal("/foo/vmlinux", path)
+
+	config.JailerPath = "/usr/bin/jailer"
+	err = fc.createSandbox(context.Background(), "fcsandbox", &config, vcStore)
+	assert.NoError(err)
+	assert.True(fc.jailed)
+	assert.Equal(filepath.Join(fcChrootBaseDir, "firecracker", "fcsandbox", "root"), fc.jailerRoot)
+	assert.Equal(filepath.Join(fc.jailerRoot, fcJailedSocket), fc.socketPath)
+}
+
+func TestFCJailResource(t *testing.T) {
+	if tc.NotValid(ktu.NeedRoot()) {
+		t.Skip(testDisabledAsNonRoot)
+	}
+
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir(testDir, "fc-jail-")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	savedChrootBaseDir := fcChrootBaseDir
+	savedCgroupRoot := fcCgroupRoot
+	defer func() {
+		fcChrootBaseDir = savedChrootBaseDir
+		fcCgroupRoot = savedCgroupRoot
+	}()
+	fcChrootBaseDir = filepath.Join(dir, "jailer")
+	fcCgroupRoot = filepath.Join(dir, "cgroup")
+
+	cgroup := filepath.Join(fcCgroupRoot, "cpu", "firecracker", "fcsandbox")
+	assert.NoError(os.MkdirAll(cgroup, testDirMode))
+
+	kernel := filepath.Join(dir, "vmlinux")
+	assert.NoError(ioutil.WriteFile(kernel, []byte("kernel"), 0644))
+
+	fc := &firecracker{
+		ctx: context.Background(),
+		id:  "fcsandbox",
+		config: HypervisorConfig{
+			HypervisorPath: "/usr/bin/firecracker",
+			JailerPath:     "/usr/bin/jailer",
+		},
+		jailed: true,
+	}
+	fc.jailerRoot = filepath.Join(fcChrootBaseDir, "firecracker", fc.id, "root")
+
+	_, err = fc.fcJailResource("", fcKernel, true)
+	assert.Error(err)
+
+	path, err := fc.fcJailResource(kernel, fcKernel, true)
+	assert.NoError(err)
+	assert.Equal("/"+fcKernel, path)
+
+	data, err := ioutil.ReadFile(filepath.Join(fc.jailerRoot, fcKernel))
+	assert.NoError(err)
+	assert.Equal("kernel", string(data))
+
+	// Resources are shared read-only with the VMM
+	err = ioutil.WriteFile(filepath.Join(fc.jailerRoot, fcKernel), []byte("foo"), 0644)
+	assert.Error(err)
+
+	err = fc.fcCleanupJail()
+	assert.NoError(err)
+
+	_, err = os.Stat(filepath.Dir(fc.jailerRoot))
+	assert.True(os.IsNotExist(err))
+
+	_, err = os.Stat(cgroup)
+	assert.True(os.IsNotExist(err))
+
+	// The host files are left untouched
+	data, err = ioutil.ReadFile(kernel)
+	assert.NoError(err)
+	assert.Equal("kernel", string(data))
+}
+
+func TestFCJailDrive(t *testing.T) {
+	if tc.NotValid(ktu.NeedRoot()) {
+		t.Skip(testDisabledAsNonRoot)
+	}
+
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir(testDir, "fc-jail-")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	fc := &firecracker{
+		ctx: context.Background(),
+		id:  "fcsandbox",
+		config: HypervisorConfig{
+			JailerUID: 1000,
+			JailerGID: 1000,
+		},
+		jailerRoot: filepath.Join(dir, "root"),
+	}
+	assert.NoError(os.MkdirAll(fc.jailerRoot, testDirMode))
+
+	// Not jailed
+	path, err := fc.fcJailDrive("/dev/foo", "drive_foo")
+	assert.NoError(err)
+	assert.Equal("/dev/foo", path)
+
+	fc.jailed = true
+
+	device := filepath.Join(dir, "blockdev")
+	assert.NoError(unix.Mknod(device, unix.S_IFBLK|0600, int(unix.Mkdev(7, 42))))
+
+	path, err = fc.fcJailDrive(device, "drive_block")
+	assert.NoError(err)
+	assert.Equal("/drive_block", path)
+
+	var st unix.Stat_t
+	assert.NoError(unix.Stat(filepath.Join(fc.jailerRoot, "drive_block"), &st))
+	assert.Equal(uint32(unix.S_IFBLK), st.Mode&unix.S_IFMT)
+	assert.Equal(unix.Mkdev(7, 42), st.Rdev)
+	assert.Equal(uint32(1000), st.Uid)
+	assert.Equal(uint32(1000), st.Gid)
+
+	// The host device is left untouched
+	assert.NoError(unix.Stat(device, &st))
+	assert.Equal(uint32(0), st.Uid)
+	assert.Equal(uint32(0), st.Gid)
+
+	// Image files cannot be given to the VMM user
+	image := filepath.Join(dir, "image")
+	assert.NoError(ioutil.WriteFile(image, []byte{}, 0600))
+
+	_, err = fc.fcJailDrive(image, "drive_image")
+	assert.Error(err)
+
+	fc.config.JailerUID = 0
+	fc.config.JailerGID = 0
+	path, err = fc.fcJailDrive(image, "drive_image")
+	assert.NoError(err)
+	assert.Equal("/drive_image", path)
+	defer syscall.Unmount(filepath.Join(fc.jailerRoot, "drive_image"), syscall.MNT_DETACH)
+
+	assert.NoError(unix.Stat(image, &st))
+	assert.Equal(uint32(0), st.Uid)
+}
+
+func TestFCReleaseVsock(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := ioutil.TempFile(testDir, "vhost-vsock-")
+	assert.NoError(err)
+	defer os.Remove(f.Name())
+
+	fc := &firecracker{vhostFd: f}
+	fc.fcReleaseVsock()
+	assert.Nil(fc.vhostFd)
+
+	// The file descriptor has been closed
+	_, err = f.Stat()
+	assert.Error(err)
+
+	// Releasing twice is harmless
+	fc.fcReleaseVsock()
+}
+
+func TestFCAddVhostUserDevice(t *testing.T) {
+	assert := assert.New(t)
+
+	fc := &firecracker{}
+
+	err := fc.addDevice(config.VhostUserDeviceAttrs{Type: config.VhostUserNet}, vhostuserDev)
+	assert.Error(err)
+	assert.Empty(fc.pendingDevices)
+}
+
+func TestFCLogLevel(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(logrus.ErrorLevel, fcLogLevel("2019-04-10T12:00:00.000000000 [fcsandbox:ERROR:src/vmm/src/lib.rs:1] foo"))
+	assert.Equal(logrus.WarnLevel, fcLogLevel("2019-04-10T12:00:00.000000000 [fcsandbox:WARN] foo"))
+	assert.Equal(logrus.DebugLevel, fcLogLevel("2019-04-10T12:00:00.000000000 [fcsandbox:DEBUG:src/main.rs:1] foo"))
+	assert.Equal(logrus.InfoLevel, fcLogLevel("foo ERROR"))
+}
+
+func TestFCCollectMetrics(t *testing.T) {
+	assert := assert.New(t)
+
+	fc := &firecracker{}
+
+	// Metrics not collected
+	stats, err := fc.getStats()
+	assert.NoError(err)
+	assert.Nil(stats)
+
+	f, err := ioutil.TempFile(testDir, "fc-metrics-")
+	assert.NoError(err)
+	defer os.Remove(f.Name())
+	defer f.Close()
+	fc.metricsFifo = f
+
+	stats, err = fc.getStats()
+	assert.NoError(err)
+	assert.Equal(&HypervisorStats{}, stats)
+
+	flushes := []string{
+		`{"block":{"read_bytes":512,"write_bytes":1024,"read_count":1,"write_count":2,"flush_count":1},` +
+			`"net":{"rx_bytes_count":100,"rx_packets_count":2,"tx_bytes_count":50,"tx_packets_count":1},` +
+			`"vcpu":{"exit_io_in":3,"exit_mmio_write":4},"vmm":{"device_events":1}}`,
+		`not a metrics flush`,
+		`{"block":{"read_bytes":512,"read_count":1},"net":{"tx_fails":1},"vcpu":{"exit_io_in":1,"failures":1}}`,
+	}
+	fc.fcCollectMetrics(strings.NewReader(strings.Join(flushes, "\n")))
+
+	stats, err = fc.getStats()
+	assert.NoError(err)
+	assert.Equal(&HypervisorStats{
+		Block: BlockStats{
+			ReadBytes:  1024,
+			WriteBytes: 1024,
+			ReadCount:  2,
+			WriteCount: 2,
+			FlushCount: 1,
+		},
+	}, stats)
+}
+
+func TestFCCreateFifo(t *testing.T) {
+	assert := assert.New(t)
+
+	vcStore, err := store.NewVCSandboxStore(context.Background(), "fcsandbox")
+	assert.NoError(err)
+	defer store.DeleteAll()
+
+	fc := &firecracker{id: "fcsandbox", store: vcStore}
+
+	f, path, err := fc.fcCreateFifo(fcLogFifo)
+	assert.NoError(err)
+	assert.Equal(filepath.Join(store.SandboxRuntimeRootPath(fc.id), fcLogFifo), path)
+	fc.logFifo = f
+
+	fi, err := os.Stat(path)
+	assert.NoError(err)
+	assert.True(fi.Mode()&os.ModeNamedPipe != 0)
+
+	fc.fcReleaseLogger()
+	assert.Nil(fc.logFifo)
+}
+
+func TestFCPauseResume(t *testing.T) {
+	assert := assert.New(t)
+
+	fc := &firecracker{
+		ctx: context.Background(),
+		id:  "fcsandbox",
+	}
+
+	// Not running
+	assert.Error(fc.pauseSandbox())
+	assert.Error(fc.saveSandbox())
+
+	if tc.NotValid(ktu.NeedRoot()) {
+		t.Skip(testDisabledAsNonRoot)
+	}
+
+	if _, err := fcFreezerHierarchy(); err != nil {
+		t.Skip("freezer cgroup not available")
+	}
+
+	savedCgroupsNew := cgroupsNewFunc
+	savedCgroupsLoad := cgroupsLoadFunc
+	cgroupsNewFunc = cgroups.New
+	cgroupsLoadFunc = cgroups.Load
+	defer func() {
+		cgroupsNewFunc = savedCgroupsNew
+		cgroupsLoadFunc = savedCgroupsLoad
+	}()
+
+	// Not paused
+	assert.Error(fc.resumeSandbox())
+	assert.NoError(fc.fcDeleteFreezer())
+
+	// fake VMM
+	cmd := exec.Command("tail", "-f", "/dev/null")
+	assert.NoError(cmd.Start())
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+	fc.info.PID = cmd.Process.Pid
+
+	original, err := fcProcessFreezerCgroup(fc.info.PID)
+	assert.NoError(err)
+
+	assert.NoError(fc.pauseSandbox())
+	defer fc.fcDeleteFreezer()
+	assert.Equal(original, fc.info.FreezerCgroup)
+
+	cgroup, err := cgroups.Load(fcFreezerHierarchy, cgroups.StaticPath(fc.fcFreezerPath()))
+	assert.NoError(err)
+	assert.Equal(cgroups.Frozen, cgroup.State())
+
+	assert.NoError(fc.resumeSandbox())
+	assert.Equal(cgroups.Thawed, cgroup.State())
+
+	assert.NoError(fc.pauseSandbox())
+	assert.NoError(fc.fcDeleteFreezer())
+
+	_, err = cgroups.Load(fcFreezerHierarchy, cgroups.StaticPath(fc.fcFreezerPath()))
+	assert.Equal(cgroups.ErrCgroupDeleted, err)
+
+	// The VMM is back in its original freezer cgroup
+	current, err := fcProcessFreezerCgroup(fc.info.PID)
+	assert.NoError(err)
+	assert.Equal(original, current)
+	assert.Empty(fc.info.FreezerCgroup)
+
+	// The VMM is not frozen anymore
+	assert.NoError(cmd.Process.Kill())
+	assert.Error(cmd.Wait())
+}
+
+func TestFCDrivePool(t *testing.T) {
+	assert := assert.New(t)
+
+	fc := &firecracker{}
+	assert.Equal(fcDiskPoolSize, fc.drivePoolSize())
+
+	fc.config.DrivePoolSize = 2
+	assert.Equal(2, fc.drivePoolSize())
+
+	fc.info.Drives = []FirecrackerDrive{
+		{Placeholder: "/run/vc/sbs/fcsandbox/placeholder0"},
+		{Placeholder: "/run/vc/sbs/fcsandbox/placeholder1"},
+	}
+
+	slot, err := fc.fcAllocDriveSlot("drive-a")
+	assert.NoError(err)
+	assert.Equal(0, slot)
+
+	slot, err = fc.fcAllocDriveSlot("drive-b")
+	assert.NoError(err)
+	assert.Equal(1, slot)
+
+	// The pool is exhausted
+	_, err = fc.fcAllocDriveSlot("drive-c")
+	assert.Error(err)
+
+	slot, err = fc.fcFindDriveSlot("drive-a")
+	assert.NoError(err)
+	assert.Equal(0, slot)
+
+	_, err = fc.fcFindDriveSlot("drive-c")
+	assert.Error(err)
+
+	// A released slot is reused
+	fc.info.Drives[0].BlockDriveID = ""
+	slot, err = fc.fcAllocDriveSlot("drive-c")
+	assert.NoError(err)
+	assert.Equal(0, slot)
+
+	assert.Equal("/run/vc/sbs/fcsandbox/placeholder1", fc.fcDrivePlaceholder(1))
+	fc.jailed = true
+	assert.Equal("/"+fcDriveIndexToID(1), fc.fcDrivePlaceholder(1))
+}
+
+func TestFCPublishMetadata(t *testing.T) {
+	fc := &firecracker{}
+
+	// The MMDS is disabled, nothing is published
+	assert.NoError(t, fc.publishMetadata(&SandboxMetadata{ID: "fcsandbox"}))
+}
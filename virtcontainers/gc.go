@@ -0,0 +1,495 @@
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/containerd/cgroups"
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/prometheus/procfs"
+	"github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+
+	"github.com/kata-containers/runtime/virtcontainers/store"
+	"github.com/kata-containers/runtime/virtcontainers/types"
+)
+
+// GCResourceType is the type of a resource leaked by a sandbox.
+type GCResourceType string
+
+const (
+	// GCProcess is a proxy, shim or virtiofsd process of the sandbox.
+	GCProcess GCResourceType = "process"
+
+	// GCNetwork is the tap interface of a sandbox network endpoint, and
+	// its tc filters, in a network namespace not created by virtcontainers.
+	GCNetwork GCResourceType = "network"
+
+	// GCNetNS is the network namespace created for the sandbox.
+	GCNetNS GCResourceType = "netns"
+
+	// GCJail is the chroot, and the cgroups, of a jailed firecracker.
+	GCJail GCResourceType = "jail"
+
+	// GCMount is a mount of the directory shared with the sandbox VM.
+	GCMount GCResourceType = "mount"
+
+	// GCCgroup is a cgroup created for the sandbox.
+	GCCgroup GCResourceType = "cgroup"
+
+	// GCDirectory is a storage or shared directory of the sandbox.
+	GCDirectory GCResourceType = "directory"
+)
+
+// GCResource is a resource leaked by a sandbox whose hypervisor is gone.
+type GCResource struct {
+	SandboxID string
+	Type      GCResourceType
+
+	// Resource identifies the resource, such as a path or a PID.
+	Resource string
+
+	// Err is set when the resource could not be removed.
+	Err error
+
+	remove func() error
+}
+
+// gcGracePeriod is how long a sandbox whose hypervisor PID is unknown is
+// left alone after its storage was modified, as it may be starting.
+var gcGracePeriod = time.Minute
+
+// gcHelpers are the processes, besides the hypervisor, started for a
+// sandbox and leaked with it.
+var gcHelpers = []string{"kata-proxy", "kata-shim", "virtiofsd"}
+
+// gcSharedDir is the directory holding the directories shared with the
+// sandbox VMs.
+var gcSharedDir = kataHostSharedDir
+
+type gcProcess struct {
+	pid     int
+	name    string
+	cmdline string
+}
+
+// gcListProcesses lists the processes of the host, with the base name of
+// their executable and their command line.
+var gcListProcesses = func() ([]gcProcess, error) {
+	procs, err := procfs.AllProcs()
+	if err != nil {
+		return nil, err
+	}
+
+	var processes []gcProcess
+	for _, p := range procs {
+		args, err := p.CmdLine()
+		if err != nil || len(args) == 0 {
+			// Gone or kernel thread
+			continue
+		}
+
+		processes = append(processes, gcProcess{
+			pid:     p.PID,
+			name:    filepath.Base(args[0]),
+			cmdline: strings.Join(args, " "),
+		})
+	}
+
+	return processes, nil
+}
+
+func gcLogger() *logrus.Entry {
+	return virtLog.WithField("subsystem", "gc")
+}
+
+// garbageCollect finds the resources of the sandboxes whose hypervisor is
+// not running anymore, and removes them unless dryRun is set.
+func garbageCollect(ctx context.Context, dryRun bool) ([]GCResource, error) {
+	ids, err := gcSandboxIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	processes, err := gcListProcesses()
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []GCResource
+	for _, id := range ids {
+		r, err := gcSandbox(ctx, id, processes, dryRun)
+		if err != nil {
+			gcLogger().WithError(err).WithField("sandbox", id).Warn("Could not collect sandbox")
+			continue
+		}
+
+		resources = append(resources, r...)
+	}
+
+	return resources, nil
+}
+
+// gcSandboxIDs returns the IDs of the sandboxes with storage or a shared
+// directory on the host.
+func gcSandboxIDs() ([]string, error) {
+	seen := make(map[string]bool)
+	var ids []string
+
+	for _, dir := range []string{store.ConfigStoragePath, store.RunStoragePath, gcSharedDir} {
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+
+		for _, e := range entries {
+			if !seen[e.Name()] {
+				seen[e.Name()] = true
+				ids = append(ids, e.Name())
+			}
+		}
+	}
+
+	sort.Strings(ids)
+
+	return ids, nil
+}
+
+func gcSandbox(ctx context.Context, id string, processes []gcProcess, dryRun bool) ([]GCResource, error) {
+	var (
+		config    SandboxConfig
+		state     types.SandboxState
+		networkNS NetworkNamespace
+		pid       int
+	)
+
+	if _, err := os.Stat(store.SandboxConfigurationRootPath(id)); err == nil {
+		lockFile, err := rwLockSandbox(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		defer unlockSandbox(ctx, id, lockFile)
+
+		vcStore, err := store.NewVCSandboxStore(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		// What cannot be loaded is not collected.
+		vcStore.Load(store.Configuration, &config)
+		vcStore.Load(store.Network, &networkNS)
+		state, _ = vcStore.LoadState()
+		pid = gcHypervisorPid(id, config.HypervisorType, vcStore)
+	}
+
+	if gcSandboxInUse(id, state, pid, config.HypervisorConfig.HypervisorPath, processes) {
+		return nil, nil
+	}
+
+	logger := gcLogger().WithField("sandbox", id)
+	logger.WithField("hypervisor-pid", pid).Info("Sandbox hypervisor not running")
+
+	resources := gcSandboxResources(ctx, id, config, state, networkNS, processes)
+
+	if !dryRun {
+		for i := range resources {
+			r := &resources[i]
+			if r.Err = r.remove(); r.Err != nil {
+				logger.WithError(r.Err).WithField("resource", r.Resource).Warnf("Could not remove %s", r.Type)
+			} else {
+				logger.WithField("resource", r.Resource).Infof("Removed %s", r.Type)
+			}
+		}
+	}
+
+	return resources, nil
+}
+
+// gcHypervisorPid returns the PID of the sandbox hypervisor, from the
+// hypervisor persisted state.
+func gcHypervisorPid(id string, hType HypervisorType, vcStore *store.VCStore) int {
+	switch hType {
+	case QemuHypervisor:
+		// With a VM factory, this is a link to the directory of the VM
+		q := &qemu{id: id}
+		data, err := ioutil.ReadFile(q.pidFile())
+		if err != nil {
+			return 0
+		}
+
+		pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		if err != nil {
+			return 0
+		}
+
+		return pid
+	case FirecrackerHypervisor:
+		var info FirecrackerInfo
+		if err := vcStore.Load(store.Hypervisor, &info); err != nil {
+			return 0
+		}
+
+		return info.PID
+	}
+
+	return 0
+}
+
+// gcSandboxInUse returns true if the hypervisor of the sandbox, or a
+// runtime process handling it, is running, or if it may be starting.
+func gcSandboxInUse(id string, state types.SandboxState, pid int, hypervisorPath string, processes []gcProcess) bool {
+	// Stopped sandboxes are left to the container manager deleting them.
+	if state.State == types.StateStopped {
+		return true
+	}
+
+	// The PID of the hypervisor may have been reused, it has to run the
+	// hypervisor binary.
+	if pid > 0 && gcHypervisorRunning(pid, hypervisorPath) {
+		return true
+	}
+
+	for _, p := range processes {
+		if p.pid == os.Getpid() || !strings.Contains(p.cmdline, id) {
+			continue
+		}
+
+		// A runtime process handling the sandbox, or its hypervisor
+		// when not started by a VM factory, which names the VM after
+		// its own ID.
+		if !gcIsHelper(p.name, nil) {
+			return true
+		}
+	}
+
+	if pid > 0 {
+		return false
+	}
+
+	for _, dir := range []string{
+		store.SandboxConfigurationRootPath(id),
+		store.SandboxRuntimeRootPath(id),
+		filepath.Join(gcSharedDir, id),
+	} {
+		if info, err := os.Stat(dir); err == nil && time.Since(info.ModTime()) < gcGracePeriod {
+			return true
+		}
+	}
+
+	return false
+}
+
+// gcHypervisorRunning returns true if the process pid is alive and runs
+// the hypervisor binary. The base names are compared, as a jailed
+// hypervisor runs a copy of the binary, and a binary upgraded while
+// running is reported as deleted. Any live process is assumed to be the
+// hypervisor when its path is unknown.
+func gcHypervisorRunning(pid int, hypervisorPath string) bool {
+	if err := syscall.Kill(pid, syscall.Signal(0)); err != nil && err != syscall.EPERM {
+		return false
+	}
+
+	if hypervisorPath == "" {
+		return true
+	}
+
+	exe, err := os.Readlink(filepath.Join("/proc", strconv.Itoa(pid), "exe"))
+	if err != nil {
+		// Gone, or a kernel thread
+		return false
+	}
+	exe = strings.TrimSuffix(exe, " (deleted)")
+
+	if realPath, err := filepath.EvalSymlinks(hypervisorPath); err == nil {
+		hypervisorPath = realPath
+	}
+
+	return filepath.Base(exe) == filepath.Base(hypervisorPath)
+}
+
+func gcIsHelper(name string, extra []string) bool {
+	for _, h := range append(gcHelpers, extra...) {
+		if h != "" && name == filepath.Base(h) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// gcSandboxResources returns the resources of an orphaned sandbox, in
+// removal order.
+func gcSandboxResources(ctx context.Context, id string, config SandboxConfig, state types.SandboxState, networkNS NetworkNamespace, processes []gcProcess) []GCResource {
+	var resources []GCResource
+
+	add := func(t GCResourceType, resource string, remove func() error) {
+		resources = append(resources, GCResource{
+			SandboxID: id,
+			Type:      t,
+			Resource:  resource,
+			remove:    remove,
+		})
+	}
+
+	// Processes, matched by sandbox or container ID
+	ids := []string{id}
+	for _, c := range config.Containers {
+		ids = append(ids, c.ID)
+	}
+
+	for _, p := range processes {
+		if !gcIsHelper(p.name, []string{config.HypervisorConfig.VirtioFSDaemon}) {
+			continue
+		}
+
+		for _, cid := range ids {
+			if cid != "" && strings.Contains(p.cmdline, cid) {
+				pid := p.pid
+				add(GCProcess, fmt.Sprintf("%d (%s)", pid, p.name), func() error {
+					if err := syscall.Kill(pid, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+						return err
+					}
+					return nil
+				})
+				break
+			}
+		}
+	}
+
+	// Network
+	if netNsPath := networkNS.NetNsPath; netNsPath != "" {
+		if _, err := os.Stat(netNsPath); err == nil {
+			if networkNS.NetNsCreated {
+				add(GCNetNS, netNsPath, func() error {
+					return deleteNetNS(netNsPath)
+				})
+			} else {
+				for _, endpoint := range networkNS.Endpoints {
+					pair := endpoint.NetworkPair()
+					if pair == nil {
+						continue
+					}
+
+					tap := pair.TapInterface.TAPIface.Name
+					if doNetNS(netNsPath, func(_ ns.NetNS) error {
+						_, err := netlink.LinkByName(tap)
+						return err
+					}) != nil {
+						continue
+					}
+
+					ep := endpoint
+					add(GCNetwork, netNsPath+" "+tap, func() error {
+						return doNetNS(netNsPath, func(_ ns.NetNS) error {
+							return xDisconnectVMNetwork(ep)
+						})
+					})
+				}
+			}
+		}
+	}
+
+	// Firecracker jail and freezer cgroup
+	if config.HypervisorType == FirecrackerHypervisor {
+		fc := &firecracker{
+			ctx:    ctx,
+			id:     id,
+			config: config.HypervisorConfig,
+		}
+		fc.setPaths()
+
+		if fc.jailed {
+			jail := filepath.Dir(fc.jailerRoot)
+			if _, err := os.Stat(jail); err == nil {
+				add(GCJail, jail, fc.fcCleanupJail)
+			}
+		}
+
+		if _, err := cgroupsLoadFunc(fcFreezerHierarchy, cgroups.StaticPath(fc.fcFreezerPath())); err == nil {
+			add(GCCgroup, fc.fcFreezerPath(), fc.fcDeleteFreezer)
+		}
+	}
+
+	// Mounts of the shared directory, the deepest first
+	sharedDir := filepath.Join(gcSharedDir, id)
+	mountRoot := sharedDir
+	if realDir, err := filepath.EvalSymlinks(sharedDir); err == nil {
+		mountRoot = realDir
+	}
+
+	if mounts, err := getMountsUnder(mountRoot); err == nil {
+		for i := len(mounts) - 1; i >= 0; i-- {
+			m := mounts[i]
+			add(GCMount, m, func() error {
+				return unix.Unmount(m, unix.MNT_DETACH)
+			})
+		}
+	}
+
+	// Cgroups
+	if state.CgroupPath != "" {
+		path := cgroupNoConstraintsPath(state.CgroupPath)
+		if _, err := cgroupsLoadFunc(V1NoConstraints, cgroups.StaticPath(path)); err == nil {
+			s := &Sandbox{id: id, state: state}
+			add(GCCgroup, path, s.deleteCgroups)
+		}
+	}
+
+	// Directories
+	dirs := []string{
+		sharedDir,
+		store.SandboxConfigurationRootPath(id),
+		store.SandboxRuntimeRootPath(id),
+	}
+
+	vmDir := filepath.Join(store.RunVMStoragePath, id)
+	if realDir, err := filepath.EvalSymlinks(vmDir); err == nil && realDir != vmDir {
+		// With a VM factory, the VM directory holds the shared directory
+		dirs = append(dirs, realDir)
+	}
+	dirs = append(dirs, vmDir)
+
+	for _, dir := range dirs {
+		if _, err := os.Lstat(dir); err != nil {
+			continue
+		}
+
+		d := dir
+		add(GCDirectory, d, func() error {
+			return gcRemoveDir(d)
+		})
+	}
+
+	return resources
+}
+
+// gcRemoveDir removes dir, unless something is still mounted below it.
+func gcRemoveDir(dir string) error {
+	mounts, err := getMountsUnder(dir)
+	if err != nil {
+		return err
+	}
+
+	if len(mounts) > 0 {
+		return fmt.Errorf("%d mounts left below %s, not removed", len(mounts), dir)
+	}
+
+	return os.RemoveAll(dir)
+}
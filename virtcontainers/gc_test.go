@@ -0,0 +1,227 @@
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	ktu "github.com/kata-containers/runtime/pkg/katatestutils"
+	"github.com/kata-containers/runtime/virtcontainers/store"
+	"github.com/kata-containers/runtime/virtcontainers/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGCSandboxInUse(t *testing.T) {
+	assert := assert.New(t)
+
+	id := "gcsandbox"
+	running := types.SandboxState{State: types.StateRunning}
+	proxy := gcProcess{pid: 1235, name: "kata-proxy", cmdline: "kata-proxy -sandbox " + id}
+
+	// Stopped sandboxes are left alone
+	assert.True(gcSandboxInUse(id, types.SandboxState{State: types.StateStopped}, 0, "", nil))
+
+	// fake hypervisor
+	hypervisorPath, err := exec.LookPath("tail")
+	assert.NoError(err)
+	cmd := exec.Command(hypervisorPath, "-f", "/dev/null")
+	assert.NoError(cmd.Start())
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+	pid := cmd.Process.Pid
+	hypervisor := gcProcess{pid: pid, name: "tail", cmdline: "tail -name sandbox-" + id}
+
+	// The hypervisor is running
+	assert.True(gcSandboxInUse(id, running, pid, hypervisorPath, []gcProcess{hypervisor, proxy}))
+	assert.True(gcSandboxInUse(id, running, pid, "", []gcProcess{hypervisor, proxy}))
+
+	// The hypervisor is running, started by a VM factory, and its command
+	// line does not hold the sandbox ID
+	factoryVM := gcProcess{pid: pid, name: "tail", cmdline: "tail -name sandbox-a3bd5ea4-3be6-4c5a-a7a5-e1d08e0b3f84"}
+	assert.True(gcSandboxInUse(id, running, pid, hypervisorPath, []gcProcess{factoryVM, proxy}))
+
+	// The PID of the hypervisor has been reused
+	assert.False(gcSandboxInUse(id, running, pid, "/usr/bin/qemu-system-x86_64", []gcProcess{proxy}))
+
+	// The hypervisor is gone
+	dead := exec.Command("true")
+	assert.NoError(dead.Run())
+	assert.False(gcSandboxInUse(id, running, dead.Process.Pid, hypervisorPath, []gcProcess{proxy}))
+
+	// A runtime process handles the sandbox
+	runtime := gcProcess{pid: 1236, name: "kata-runtime", cmdline: "kata-runtime start " + id}
+	assert.True(gcSandboxInUse(id, running, dead.Process.Pid, hypervisorPath, []gcProcess{runtime}))
+
+	// Unknown hypervisor PID, the sandbox may be starting
+	dir, err := ioutil.TempDir(testDir, "gc-")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	savedSharedDir := gcSharedDir
+	savedGracePeriod := gcGracePeriod
+	defer func() {
+		gcSharedDir = savedSharedDir
+		gcGracePeriod = savedGracePeriod
+	}()
+	gcSharedDir = dir
+
+	assert.NoError(os.Mkdir(filepath.Join(dir, id), testDirMode))
+	assert.True(gcSandboxInUse(id, types.SandboxState{}, 0, "", []gcProcess{proxy}))
+
+	gcGracePeriod = 0
+	assert.False(gcSandboxInUse(id, types.SandboxState{}, 0, "", []gcProcess{proxy}))
+}
+
+func TestGarbageCollect(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir(testDir, "gc-")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	savedSharedDir := gcSharedDir
+	savedGracePeriod := gcGracePeriod
+	savedListProcesses := gcListProcesses
+	defer func() {
+		gcSharedDir = savedSharedDir
+		gcGracePeriod = savedGracePeriod
+		gcListProcesses = savedListProcesses
+	}()
+	gcSharedDir = dir
+	gcGracePeriod = 0
+
+	id := "gcsandbox"
+	ctx := context.Background()
+
+	vcStore, err := store.NewVCSandboxStore(ctx, id)
+	assert.NoError(err)
+	defer store.DeleteAll()
+
+	config := SandboxConfig{
+		ID:             id,
+		HypervisorType: MockHypervisor,
+		Containers:     []ContainerConfig{{ID: "gccontainer"}},
+	}
+	assert.NoError(vcStore.Store(store.Configuration, config))
+	assert.NoError(vcStore.Store(store.State, types.SandboxState{State: types.StateRunning}))
+
+	sharedDir := filepath.Join(dir, id)
+	assert.NoError(os.MkdirAll(filepath.Join(sharedDir, "gccontainer"), testDirMode))
+
+	// fake shim of the sandbox container
+	cmd := exec.Command("tail", "-f", "/dev/null")
+	assert.NoError(cmd.Start())
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	gcListProcesses = func() ([]gcProcess, error) {
+		return []gcProcess{
+			{pid: cmd.Process.Pid, name: "kata-shim", cmdline: "kata-shim -container gccontainer"},
+			{pid: os.Getpid(), name: "kata-runtime", cmdline: "kata-runtime gc"},
+		}, nil
+	}
+
+	var mounted bool
+	if !tc.NotValid(ktu.NeedRoot()) {
+		mountDir := filepath.Join(sharedDir, "gccontainer")
+		assert.NoError(syscall.Mount(dir, mountDir, "bind", syscall.MS_BIND, ""))
+		mounted = true
+		defer syscall.Unmount(mountDir, syscall.MNT_DETACH)
+	}
+
+	resources, err := garbageCollect(ctx, true)
+	assert.NoError(err)
+
+	counts := make(map[GCResourceType]int)
+	for _, r := range resources {
+		assert.Equal(id, r.SandboxID)
+		assert.NoError(r.Err)
+		counts[r.Type]++
+	}
+
+	assert.Equal(1, counts[GCProcess])
+	assert.Equal(3, counts[GCDirectory])
+	if mounted {
+		assert.Equal(1, counts[GCMount])
+	}
+
+	// Nothing has been removed
+	_, err = os.Stat(sharedDir)
+	assert.NoError(err)
+	_, err = os.Stat(store.SandboxConfigurationRootPath(id))
+	assert.NoError(err)
+	assert.NoError(cmd.Process.Signal(syscall.Signal(0)))
+
+	resources, err = garbageCollect(ctx, false)
+	assert.NoError(err)
+	assert.NotEmpty(resources)
+
+	for _, r := range resources {
+		assert.NoError(r.Err, "%s %s", r.Type, r.Resource)
+	}
+
+	for _, d := range []string{
+		sharedDir,
+		store.SandboxConfigurationRootPath(id),
+		store.SandboxRuntimeRootPath(id),
+	} {
+		_, err = os.Stat(d)
+		assert.True(os.IsNotExist(err), d)
+	}
+
+	// The shim has been killed
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		assert.Error(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("shim not killed")
+	}
+
+	// Nothing left
+	resources, err = garbageCollect(ctx, true)
+	assert.NoError(err)
+	assert.Empty(resources)
+}
+
+func TestGCRemoveDir(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir(testDir, "gc-")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	sub := filepath.Join(dir, "sub")
+	assert.NoError(os.Mkdir(sub, testDirMode))
+
+	if !tc.NotValid(ktu.NeedRoot()) {
+		assert.NoError(syscall.Mount(dir, sub, "bind", syscall.MS_BIND, ""))
+
+		// Mounts are never removed with the directory
+		assert.Error(gcRemoveDir(dir))
+		assert.NoError(syscall.Unmount(sub, syscall.MNT_DETACH))
+	}
+
+	assert.NoError(gcRemoveDir(dir))
+
+	_, err = os.Stat(dir)
+	assert.True(os.IsNotExist(err))
+}
@@ -0,0 +1,208 @@
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// guestEntropySize is the amount of host entropy fed to the guest RNG.
+const guestEntropySize = 512
+
+// hostSuspendThreshold is the minimum host suspend duration triggering a
+// guest clock sync.
+const hostSuspendThreshold = time.Second
+
+// clockCheckInterval is how often the guest maintainer looks for a host
+// suspend.
+var clockCheckInterval = 5 * time.Second
+
+// hostSuspendedTime returns the time the host spent suspended since boot:
+// CLOCK_BOOTTIME keeps counting while the host is suspended, but
+// CLOCK_MONOTONIC does not.
+var hostSuspendedTime = func() (time.Duration, error) {
+	var boot, mono unix.Timespec
+
+	if err := unix.ClockGettime(unix.CLOCK_MONOTONIC, &mono); err != nil {
+		return 0, err
+	}
+
+	if err := unix.ClockGettime(unix.CLOCK_BOOTTIME, &boot); err != nil {
+		return 0, err
+	}
+
+	return time.Duration(boot.Nano() - mono.Nano()), nil
+}
+
+// hostEntropy returns random data read from the host.
+var hostEntropy = func() ([]byte, error) {
+	f, err := os.Open("/dev/urandom")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data := make([]byte, guestEntropySize)
+	if _, err := f.Read(data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// guestMaintainer keeps the guest clock of a stateful sandbox in sync with
+// the host one. The agent cannot report the guest time, so the drift is not
+// measured: the guest clock is synced every syncInterval, and whenever the
+// gap between CLOCK_BOOTTIME and CLOCK_MONOTONIC grows, that is when the host
+// comes back from a suspend, the guest RNG being reseeded at the same time.
+type guestMaintainer struct {
+	sync.Mutex
+
+	sandbox      *Sandbox
+	syncInterval time.Duration
+	wg           sync.WaitGroup
+	running      bool
+	stopCh       chan bool
+}
+
+func newGuestMaintainer(s *Sandbox) *guestMaintainer {
+	return &guestMaintainer{
+		sandbox:      s,
+		syncInterval: s.config.GuestClockSyncInterval,
+		stopCh:       make(chan bool, 1),
+	}
+}
+
+func (g *guestMaintainer) start() {
+	g.Lock()
+	defer g.Unlock()
+
+	if g.running {
+		return
+	}
+
+	suspended, err := hostSuspendedTime()
+	if err != nil {
+		g.sandbox.Logger().WithError(err).Warn("Cannot detect host suspend, not maintaining guest clock")
+		return
+	}
+
+	g.running = true
+	g.wg.Add(1)
+
+	go func() {
+		defer g.wg.Done()
+
+		lastSync := time.Now()
+		tick := time.NewTicker(clockCheckInterval)
+		defer tick.Stop()
+
+		for {
+			select {
+			case <-g.stopCh:
+				return
+			case <-tick.C:
+				suspended, lastSync = g.maintain(suspended, lastSync)
+			}
+		}
+	}()
+}
+
+// maintain syncs the guest clock if the host was suspended since the last
+// check or if the sync interval elapsed. It returns the updated suspended
+// time and last sync time.
+func (g *guestMaintainer) maintain(suspended time.Duration, lastSync time.Time) (time.Duration, time.Time) {
+	s := g.sandbox
+
+	now, err := hostSuspendedTime()
+	if err != nil {
+		s.Logger().WithError(err).Warn("Cannot detect host suspend")
+		return suspended, lastSync
+	}
+
+	if now-suspended >= hostSuspendThreshold {
+		s.Logger().WithField("suspended", now-suspended).Info("Host resumed from suspend")
+
+		if err := s.syncGuestClock(); err != nil {
+			s.Logger().WithError(err).Warn("Failed to sync guest time after host suspend")
+			return suspended, lastSync
+		}
+
+		if err := s.reseedGuestRNG(); err != nil {
+			s.Logger().WithError(err).Warn("Failed to reseed guest random number generator after host suspend")
+		}
+
+		return now, time.Now()
+	}
+
+	if g.syncInterval > 0 && time.Since(lastSync) >= g.syncInterval {
+		if err := s.syncGuestClock(); err != nil {
+			s.Logger().WithError(err).Warn("Failed to sync guest time")
+		} else {
+			lastSync = time.Now()
+		}
+	}
+
+	return now, lastSync
+}
+
+func (g *guestMaintainer) stop() {
+	// wait outside of the maintainer lock for the loop to exit.
+	defer g.wg.Wait()
+
+	g.Lock()
+	defer g.Unlock()
+
+	if !g.running {
+		return
+	}
+
+	g.stopCh <- true
+	g.running = false
+}
+
+// syncGuestClock sets the guest time to the host time.
+func (s *Sandbox) syncGuestClock() error {
+	now := time.Now()
+	s.Logger().WithField("time", now).Info("sync guest time")
+
+	return s.agent.setGuestDateTime(now)
+}
+
+// reseedGuestRNG adds host entropy to the guest random number generator
+// and reseeds it.
+func (s *Sandbox) reseedGuestRNG() error {
+	s.Logger().Info("reseed guest random number generator")
+
+	data, err := hostEntropy()
+	if err != nil {
+		return err
+	}
+
+	return s.agent.reseedRNG(data)
+}
+
+// maintainGuest starts the guest maintainer of the sandbox.
+func (s *Sandbox) maintainGuest() {
+	s.Lock()
+	if s.maintainer == nil {
+		s.maintainer = newGuestMaintainer(s)
+	}
+	s.Unlock()
+
+	s.maintainer.start()
+}
+
+// stopGuestMaintainer stops the guest maintainer, if running.
+func (s *Sandbox) stopGuestMaintainer() {
+	if s.maintainer != nil {
+		s.maintainer.stop()
+	}
+}
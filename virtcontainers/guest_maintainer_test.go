@@ -0,0 +1,218 @@
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// clockAgent is a noop agent counting the guest clock syncs and RNG reseeds.
+type clockAgent struct {
+	noopAgent
+
+	sync.Mutex
+	syncs   int
+	reseeds int
+	err     error
+}
+
+func (a *clockAgent) setGuestDateTime(time.Time) error {
+	a.Lock()
+	defer a.Unlock()
+
+	a.syncs++
+	return a.err
+}
+
+func (a *clockAgent) reseedRNG(data []byte) error {
+	a.Lock()
+	defer a.Unlock()
+
+	a.reseeds++
+	return a.err
+}
+
+func (a *clockAgent) counts() (int, int) {
+	a.Lock()
+	defer a.Unlock()
+
+	return a.syncs, a.reseeds
+}
+
+func newClockSandbox(interval time.Duration) (*Sandbox, *clockAgent) {
+	agent := &clockAgent{}
+
+	return &Sandbox{
+		id:    testSandboxID,
+		agent: agent,
+		config: &SandboxConfig{
+			GuestClockSyncInterval: interval,
+		},
+	}, agent
+}
+
+func TestSandboxSyncGuestClock(t *testing.T) {
+	assert := assert.New(t)
+
+	s, agent := newClockSandbox(0)
+
+	assert.NoError(s.syncGuestClock())
+	assert.NoError(s.reseedGuestRNG())
+
+	syncs, reseeds := agent.counts()
+	assert.Equal(1, syncs)
+	assert.Equal(1, reseeds)
+
+	agent.err = errors.New("agent error")
+	assert.Error(s.syncGuestClock())
+	assert.Error(s.reseedGuestRNG())
+
+	savedEntropy := hostEntropy
+	defer func() {
+		hostEntropy = savedEntropy
+	}()
+
+	hostEntropy = func() ([]byte, error) {
+		return nil, errors.New("no entropy")
+	}
+
+	agent.err = nil
+	assert.Error(s.reseedGuestRNG())
+
+	_, reseeds = agent.counts()
+	assert.Equal(2, reseeds)
+}
+
+func TestGuestMaintainerMaintain(t *testing.T) {
+	assert := assert.New(t)
+
+	savedSuspended := hostSuspendedTime
+	defer func() {
+		hostSuspendedTime = savedSuspended
+	}()
+
+	var suspended time.Duration
+	hostSuspendedTime = func() (time.Duration, error) {
+		return suspended, nil
+	}
+
+	s, agent := newClockSandbox(time.Hour)
+	g := newGuestMaintainer(s)
+
+	// Nothing happened since the last sync.
+	lastSync := time.Now()
+	newSuspended, newLastSync := g.maintain(0, lastSync)
+	assert.Equal(time.Duration(0), newSuspended)
+	assert.Equal(lastSync, newLastSync)
+
+	syncs, reseeds := agent.counts()
+	assert.Equal(0, syncs)
+	assert.Equal(0, reseeds)
+
+	// The host was suspended, the clock is synced and the RNG reseeded.
+	suspended = time.Minute
+	newSuspended, newLastSync = g.maintain(0, lastSync)
+	assert.Equal(time.Minute, newSuspended)
+	assert.True(newLastSync.After(lastSync))
+
+	syncs, reseeds = agent.counts()
+	assert.Equal(1, syncs)
+	assert.Equal(1, reseeds)
+
+	// The sync interval elapsed, only the clock is synced.
+	lastSync = time.Now().Add(-2 * time.Hour)
+	_, newLastSync = g.maintain(suspended, lastSync)
+	assert.True(newLastSync.After(lastSync))
+
+	syncs, reseeds = agent.counts()
+	assert.Equal(2, syncs)
+	assert.Equal(1, reseeds)
+
+	// A failed sync after a suspend is retried on the next check.
+	agent.err = errors.New("agent error")
+	newSuspended, newLastSync = g.maintain(0, lastSync)
+	assert.Equal(time.Duration(0), newSuspended)
+	assert.Equal(lastSync, newLastSync)
+
+	// No periodic sync when disabled.
+	agent.err = nil
+	g.syncInterval = 0
+	_, newLastSync = g.maintain(suspended, lastSync)
+	assert.Equal(lastSync, newLastSync)
+
+	syncs, _ = agent.counts()
+	assert.Equal(3, syncs)
+}
+
+func TestGuestMaintainerStartStop(t *testing.T) {
+	assert := assert.New(t)
+
+	savedSuspended := hostSuspendedTime
+	savedInterval := clockCheckInterval
+	defer func() {
+		hostSuspendedTime = savedSuspended
+		clockCheckInterval = savedInterval
+	}()
+
+	var lock sync.Mutex
+	var suspended time.Duration
+	hostSuspendedTime = func() (time.Duration, error) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		return suspended, nil
+	}
+	clockCheckInterval = time.Millisecond
+
+	s, agent := newClockSandbox(0)
+
+	s.maintainGuest()
+	assert.NotNil(s.maintainer)
+	assert.True(s.maintainer.running)
+
+	// Starting twice is a noop.
+	s.maintainGuest()
+
+	lock.Lock()
+	suspended = time.Minute
+	lock.Unlock()
+
+	for i := 0; i < 1000; i++ {
+		if syncs, _ := agent.counts(); syncs > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	s.stopGuestMaintainer()
+	assert.False(s.maintainer.running)
+
+	syncs, reseeds := agent.counts()
+	assert.Equal(1, syncs)
+	assert.Equal(1, reseeds)
+
+	// Stopping twice is a noop.
+	s.stopGuestMaintainer()
+
+	// The maintainer is not started if host suspend cannot be detected.
+	hostSuspendedTime = func() (time.Duration, error) {
+		return 0, errors.New("no clock")
+	}
+
+	s.maintainer.start()
+	assert.False(s.maintainer.running)
+}
+
+func TestHostSuspendedTime(t *testing.T) {
+	suspended, err := hostSuspendedTime()
+	assert.NoError(t, err)
+	assert.True(t, suspended >= 0)
+}
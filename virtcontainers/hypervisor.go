@@ -175,6 +175,11 @@ type HypervisorConfig struct {
 	// Bridges can be used to hot plug devices
 	DefaultBridges uint32
 
+	// PCIeRootPort specifies the number of PCIe root ports to cold plug
+	// in the VM. Devices are hotplugged on a free root port instead of a
+	// bridge when available. Only valid for the q35 machine type.
+	PCIeRootPort uint32
+
 	// Msize9p is used as the msize for 9p shares
 	Msize9p uint32
 
@@ -267,6 +272,33 @@ type HypervisorConfig struct {
 	// Supported currently for virtio-scsi driver.
 	EnableIOThreads bool
 
+	// EnableVhostUserStore enables the use of the vhost-user block devices
+	// of the store found at VhostUserStorePath.
+	EnableVhostUserStore bool
+
+	// VhostUserStorePath is the directory holding the placeholder nodes
+	// and the sockets of the vhost-user block devices.
+	VhostUserStorePath string
+
+	// JailerPath is the path of the firecracker jailer. When set, the
+	// firecracker VMM is started by the jailer, in a chroot.
+	JailerPath string
+
+	// JailerUID is the user ID the jailer runs the firecracker VMM as.
+	JailerUID uint32
+
+	// JailerGID is the group ID the jailer runs the firecracker VMM as.
+	JailerGID uint32
+
+	// DrivePoolSize is the number of placeholder drives attached to a
+	// firecracker VM before it starts, which bounds the number of block
+	// devices that can be hotplugged at the same time.
+	DrivePoolSize uint32
+
+	// EnableMMDS publishes the sandbox metadata to the firecracker MMDS,
+	// which the guest can query through its network interfaces.
+	EnableMMDS bool
+
 	// Debug changes the default hypervisor and kernel parameters to
 	// enable debug output where available.
 	Debug bool
@@ -299,6 +331,30 @@ type HypervisorConfig struct {
 	// root bus instead of a bridge.
 	HotplugVFIOOnRootBus bool
 
+	// VCPUPinning pins each vCPU thread to one CPU of the containers cpuset,
+	// and binds the guest memory to the matching host NUMA nodes.
+	VCPUPinning bool
+
+	// MemoryBalloon adds a virtio-balloon device to the VM, inflated to
+	// give memory back to the host when the sandbox memory shrinks.
+	MemoryBalloon bool
+
+	// FreePageReporting makes the guest report its free pages
+	// through the virtio-balloon device, returning them to the host.
+	FreePageReporting bool
+
+	// CustomAssetDirs lists the directories custom assets, requested
+	// through sandbox annotations, can be picked from.
+	CustomAssetDirs []string
+
+	// CustomAssetManifest is the path of a signed manifest listing the
+	// hashes of the approved custom assets.
+	CustomAssetManifest string
+
+	// CustomAssetManifestKey is the path of the public key verifying the
+	// custom asset manifest signature.
+	CustomAssetManifestKey string
+
 	// BootToBeTemplate used to indicate if the VM is created to be a template VM
 	BootToBeTemplate bool
 
@@ -321,6 +377,21 @@ type vcpuThreadIDs struct {
 	vcpus map[int]int
 }
 
+// BlockStats describes the block devices statistics of a VMM.
+type BlockStats struct {
+	ReadBytes  uint64
+	WriteBytes uint64
+	ReadCount  uint64
+	WriteCount uint64
+	FlushCount uint64
+}
+
+// HypervisorStats describes the statistics reported by a VMM, cumulated
+// since the VMM started.
+type HypervisorStats struct {
+	Block BlockStats
+}
+
 func (conf *HypervisorConfig) checkTemplateConfig() error {
 	if conf.BootToBeTemplate && conf.BootFromTemplate {
 		return fmt.Errorf("Cannot set both 'to be' and 'from' vm tempate")
@@ -412,6 +483,15 @@ func (conf *HypervisorConfig) addCustomAsset(a *types.Asset) error {
 	return nil
 }
 
+// assetPolicy returns the policy restricting the custom assets.
+func (conf *HypervisorConfig) assetPolicy() types.AssetPolicy {
+	return types.AssetPolicy{
+		AllowedDirs: conf.CustomAssetDirs,
+		Manifest:    conf.CustomAssetManifest,
+		ManifestKey: conf.CustomAssetManifestKey,
+	}
+}
+
 func (conf *HypervisorConfig) assetPath(t types.AssetType) (string, error) {
 	// Custom assets take precedence over the configured ones
 	a, ok := conf.customAssets[t]
@@ -631,11 +711,14 @@ type hypervisor interface {
 	hotplugRemoveDevice(devInfo interface{}, devType deviceType) (interface{}, error)
 	resizeMemory(memMB uint32, memoryBlockSizeMB uint32, probe bool) (uint32, memoryDevice, error)
 	resizeVCPUs(vcpus uint32) (uint32, uint32, error)
+	resizeBalloon(memMB uint32) (uint32, error)
 	getSandboxConsole(sandboxID string) (string, error)
 	disconnect()
 	capabilities() types.Capabilities
 	hypervisorConfig() HypervisorConfig
 	getThreadIDs() (vcpuThreadIDs, error)
+	getStats() (*HypervisorStats, error)
+	publishMetadata(metadata *SandboxMetadata) error
 	cleanup() error
 	pid() int
 	fromGrpc(ctx context.Context, hypervisorConfig *HypervisorConfig, store *store.VCStore, j []byte) error
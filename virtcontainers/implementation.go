@@ -12,10 +12,12 @@ package virtcontainers
 import (
 	"context"
 	"syscall"
+	"time"
 
 	"github.com/kata-containers/runtime/virtcontainers/device/api"
 	"github.com/kata-containers/runtime/virtcontainers/device/config"
 	vcTypes "github.com/kata-containers/runtime/virtcontainers/pkg/types"
+	"github.com/kata-containers/runtime/virtcontainers/store"
 	"github.com/kata-containers/runtime/virtcontainers/types"
 	specs "github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/sirupsen/logrus"
@@ -36,6 +38,11 @@ func (impl *VCImpl) SetFactory(ctx context.Context, factory Factory) {
 	impl.factory = factory
 }
 
+// SetLockTimeout implements the VC function of the same name.
+func (impl *VCImpl) SetLockTimeout(ctx context.Context, timeout time.Duration) {
+	SetLockTimeout(ctx, timeout)
+}
+
 // CreateSandbox implements the VC function of the same name.
 func (impl *VCImpl) CreateSandbox(ctx context.Context, sandboxConfig SandboxConfig) (VCSandbox, error) {
 	return CreateSandbox(ctx, sandboxConfig, impl.factory)
@@ -137,6 +144,11 @@ func (impl *VCImpl) UpdateContainer(ctx context.Context, sandboxID, containerID
 	return UpdateContainer(ctx, sandboxID, containerID, resources)
 }
 
+// UpdateSandbox implements the VC function of the same name.
+func (impl *VCImpl) UpdateSandbox(ctx context.Context, sandboxID string, resources SandboxResources) error {
+	return UpdateSandbox(ctx, sandboxID, resources)
+}
+
 // PauseContainer implements the VC function of the same name.
 func (impl *VCImpl) PauseContainer(ctx context.Context, sandboxID, containerID string) error {
 	return PauseContainer(ctx, sandboxID, containerID)
@@ -176,3 +188,18 @@ func (impl *VCImpl) UpdateRoutes(ctx context.Context, sandboxID string, routes [
 func (impl *VCImpl) ListRoutes(ctx context.Context, sandboxID string) ([]*vcTypes.Route, error) {
 	return ListRoutes(ctx, sandboxID)
 }
+
+// DebugConsoleURL implements the VC function of the same name.
+func (impl *VCImpl) DebugConsoleURL(ctx context.Context, sandboxID string) (string, error) {
+	return DebugConsoleURL(ctx, sandboxID)
+}
+
+// GarbageCollect implements the VC function of the same name.
+func (impl *VCImpl) GarbageCollect(ctx context.Context, dryRun bool) ([]GCResource, error) {
+	return GarbageCollect(ctx, dryRun)
+}
+
+// SandboxLockHolders implements the VC function of the same name.
+func (impl *VCImpl) SandboxLockHolders(ctx context.Context, sandboxID string) ([]store.LockHolder, error) {
+	return SandboxLockHolders(ctx, sandboxID)
+}
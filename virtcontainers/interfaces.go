@@ -9,10 +9,12 @@ import (
 	"context"
 	"io"
 	"syscall"
+	"time"
 
 	"github.com/kata-containers/runtime/virtcontainers/device/api"
 	"github.com/kata-containers/runtime/virtcontainers/device/config"
 	vcTypes "github.com/kata-containers/runtime/virtcontainers/pkg/types"
+	"github.com/kata-containers/runtime/virtcontainers/store"
 	"github.com/kata-containers/runtime/virtcontainers/types"
 	specs "github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/sirupsen/logrus"
@@ -22,6 +24,7 @@ import (
 type VC interface {
 	SetLogger(ctx context.Context, logger *logrus.Entry)
 	SetFactory(ctx context.Context, factory Factory)
+	SetLockTimeout(ctx context.Context, timeout time.Duration)
 
 	CreateSandbox(ctx context.Context, sandboxConfig SandboxConfig) (VCSandbox, error)
 	DeleteSandbox(ctx context.Context, sandboxID string) (VCSandbox, error)
@@ -44,6 +47,7 @@ type VC interface {
 	StopContainer(ctx context.Context, sandboxID, containerID string) (VCContainer, error)
 	ProcessListContainer(ctx context.Context, sandboxID, containerID string, options ProcessListOptions) (ProcessList, error)
 	UpdateContainer(ctx context.Context, sandboxID, containerID string, resources specs.LinuxResources) error
+	UpdateSandbox(ctx context.Context, sandboxID string, resources SandboxResources) error
 	PauseContainer(ctx context.Context, sandboxID, containerID string) error
 	ResumeContainer(ctx context.Context, sandboxID, containerID string) error
 
@@ -54,6 +58,12 @@ type VC interface {
 	ListInterfaces(ctx context.Context, sandboxID string) ([]*vcTypes.Interface, error)
 	UpdateRoutes(ctx context.Context, sandboxID string, routes []*vcTypes.Route) ([]*vcTypes.Route, error)
 	ListRoutes(ctx context.Context, sandboxID string) ([]*vcTypes.Route, error)
+
+	DebugConsoleURL(ctx context.Context, sandboxID string) (string, error)
+
+	GarbageCollect(ctx context.Context, dryRun bool) ([]GCResource, error)
+
+	SandboxLockHolders(ctx context.Context, sandboxID string) ([]store.LockHolder, error)
 }
 
 // VCSandbox is the Sandbox interface
@@ -61,6 +71,7 @@ type VC interface {
 type VCSandbox interface {
 	Annotations(key string) (string, error)
 	GetNetNs() string
+	GetHypervisorPid() (int, error)
 	GetAllContainers() []VCContainer
 	GetAnnotations() map[string]string
 	GetContainer(containerID string) VCContainer
@@ -86,6 +97,7 @@ type VCSandbox interface {
 	ResumeContainer(containerID string) error
 	EnterContainer(containerID string, cmd types.Cmd) (VCContainer, *Process, error)
 	UpdateContainer(containerID string, resources specs.LinuxResources) error
+	UpdateResources(resources SandboxResources) error
 	ProcessListContainer(containerID string, options ProcessListOptions) (ProcessList, error)
 	WaitProcess(containerID, processID string) (int32, error)
 	SignalProcess(containerID, processID string, signal syscall.Signal, all bool) error
@@ -10,7 +10,9 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/url"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strconv"
@@ -22,6 +24,7 @@ import (
 	aTypes "github.com/kata-containers/agent/pkg/types"
 	kataclient "github.com/kata-containers/agent/protocols/client"
 	"github.com/kata-containers/agent/protocols/grpc"
+	"github.com/kata-containers/runtime/virtcontainers/device/api"
 	"github.com/kata-containers/runtime/virtcontainers/device/config"
 	vcAnnotations "github.com/kata-containers/runtime/virtcontainers/pkg/annotations"
 	ns "github.com/kata-containers/runtime/virtcontainers/pkg/nsenter"
@@ -53,7 +56,6 @@ const (
 )
 
 var (
-	checkRequestTimeout   = 30 * time.Second
 	defaultKataSocketName = "kata.sock"
 	defaultKataChannel    = "agent.channel.0"
 	defaultKataDeviceID   = "channel0"
@@ -64,6 +66,7 @@ var (
 	kataGuestSharedDir    = "/run/kata-containers/shared/containers/"
 	mountGuest9pTag       = "kataShared"
 	kataGuestSandboxDir   = "/run/kata-containers/sandbox/"
+	kataGuestCopiedDir    = "/run/kata-containers/copied/"
 	type9pFs              = "9p"
 	typeVirtioFS          = "virtio_fs"
 	vsockSocketScheme     = "vsock"
@@ -83,6 +86,7 @@ var (
 	ephemeralPath            = filepath.Join(kataGuestSandboxDir, kataEphemeralDevType)
 	grpcMaxDataSize          = int64(1024 * 1024)
 	localDirOptions          = []string{"mode=0777"}
+	localStorageImage        = ".kata-local.img"
 	maxHostnameLen           = 64
 )
 
@@ -96,6 +100,19 @@ const (
 	defaultAgentTraceType = agentTraceTypeIsolated
 )
 
+const (
+	// agentDebugConsoleParam makes the agent start a shell on the guest
+	// console.
+	agentDebugConsoleParam = "agent.debug_console"
+
+	// agentDebugConsoleVPortParam makes the agent serve its debug console
+	// on a vsock port rather than on the guest console.
+	agentDebugConsoleVPortParam = "agent.debug_console_vport"
+
+	// debugConsoleVSockPort is the vsock port of the agent debug console.
+	debugConsoleVSockPort = 1026
+)
+
 // KataAgentConfig is a structure storing information needed
 // to reach the Kata Containers agent.
 type KataAgentConfig struct {
@@ -105,6 +122,18 @@ type KataAgentConfig struct {
 	Trace        bool
 	TraceMode    string
 	TraceType    string
+	DebugConsole bool
+
+	// Deadlines of the health checks, of the short requests, and of the
+	// requests creating or removing sandboxes, containers and processes.
+	// Zero values select the defaults.
+	CheckTimeout       time.Duration
+	RequestTimeout     time.Duration
+	LongRequestTimeout time.Duration
+
+	// RequestRetries is how many times an idempotent request is sent
+	// again after a transient failure. Zero selects the default.
+	RequestRetries uint32
 }
 
 type kataVSOCK struct {
@@ -133,6 +162,7 @@ type kataAgent struct {
 	client *kataclient.AgentClient
 
 	reqHandlers    map[string]reqFunc
+	reqPolicy      agentRequestPolicy
 	state          KataAgentState
 	keepConn       bool
 	proxyBuiltIn   bool
@@ -234,6 +264,14 @@ func KataAgentKernelParams(config KataAgentConfig) []Param {
 		params = append(params, Param{Key: "agent.trace", Value: config.TraceType})
 	}
 
+	if config.DebugConsole {
+		params = append(params, Param{Key: agentDebugConsoleParam})
+
+		if config.UseVSock {
+			params = append(params, Param{Key: agentDebugConsoleVPortParam, Value: strconv.Itoa(debugConsoleVSockPort)})
+		}
+	}
+
 	return params
 }
 
@@ -269,6 +307,7 @@ func (k *kataAgent) init(ctx context.Context, sandbox *Sandbox, config interface
 
 		disableVMShutdown = k.handleTraceSettings(c)
 		k.keepConn = c.LongLiveConn
+		k.reqPolicy = newAgentRequestPolicy(c)
 	default:
 		return false, vcTypes.ErrInvalidConfigType
 	}
@@ -321,6 +360,7 @@ func (k *kataAgent) internalConfigure(h hypervisor, id, sharePath string, builti
 				return err
 			}
 			k.keepConn = c.LongLiveConn
+			k.reqPolicy = newAgentRequestPolicy(c)
 		default:
 			return vcTypes.ErrInvalidConfigType
 		}
@@ -739,6 +779,11 @@ func (k *kataAgent) startSandbox(sandbox *Sandbox) error {
 		return err
 	}
 
+	dns, err := generateDNS(sandbox.config.Containers)
+	if err != nil {
+		return err
+	}
+
 	storages := []*grpc.Storage{}
 	caps := sandbox.hypervisor.capabilities()
 
@@ -791,6 +836,7 @@ func (k *kataAgent) startSandbox(sandbox *Sandbox) error {
 
 	req := &grpc.CreateSandboxRequest{
 		Hostname:      hostname,
+		Dns:           dns,
 		Storages:      storages,
 		SandboxPidns:  sandbox.sharePidNs,
 		SandboxId:     sandbox.id,
@@ -1005,6 +1051,13 @@ func (k *kataAgent) appendDevices(deviceList []*grpc.Device, c *Container) []*gr
 			return nil
 		}
 
+		if device.DeviceType() == config.VhostUserBlk || device.DeviceType() == config.VhostUserSCSI {
+			if kataDevice := k.appendVhostUserBlkDevice(dev, device); kataDevice != nil {
+				deviceList = append(deviceList, kataDevice)
+			}
+			continue
+		}
+
 		if device.DeviceType() != config.DeviceBlock {
 			continue
 		}
@@ -1041,6 +1094,25 @@ func (k *kataAgent) appendDevices(deviceList []*grpc.Device, c *Container) []*gr
 	return deviceList
 }
 
+// appendVhostUserBlkDevice builds the agent device of a vhost-user block
+// device. A vhost-user-blk device shows up in the guest as a virtio-blk disk
+// at its PCI address. A vhost-user-scsi device is a SCSI controller of its
+// own, its disk is found, as a block device, below the PCI address of the
+// controller: SCSI addresses do not tell the controllers apart.
+func (k *kataAgent) appendVhostUserBlkDevice(dev ContainerDevice, device api.Device) *grpc.Device {
+	d, ok := device.GetDeviceInfo().(*config.VhostUserDeviceAttrs)
+	if !ok || d == nil {
+		k.Logger().WithField("device", device).Error("malformed vhost-user device")
+		return nil
+	}
+
+	return &grpc.Device{
+		ContainerPath: dev.ContainerPath,
+		Type:          kataBlkDevType,
+		Id:            d.PCIAddr,
+	}
+}
+
 // rollbackFailingContainerCreation rolls back important steps that might have
 // been performed before the container creation failed.
 // - Unmount container volumes.
@@ -1057,6 +1129,22 @@ func (k *kataAgent) rollbackFailingContainerCreation(c *Container) {
 	}
 }
 
+// setBlockStorageSource sets the storage driver and source so that the agent
+// finds the block drive in the VM.
+func (k *kataAgent) setBlockStorageSource(sandbox *Sandbox, storage *grpc.Storage, blockDrive *config.BlockDrive) {
+	switch sandbox.config.HypervisorConfig.BlockDeviceDriver {
+	case config.VirtioMmio:
+		storage.Driver = kataMmioBlkDevType
+		storage.Source = blockDrive.VirtPath
+	case config.VirtioBlock:
+		storage.Driver = kataBlkDevType
+		storage.Source = blockDrive.PCIAddr
+	default:
+		storage.Driver = kataSCSIDevType
+		storage.Source = blockDrive.SCSIAddr
+	}
+}
+
 func (k *kataAgent) buildContainerRootfs(sandbox *Sandbox, c *Container, rootPathParent string) (*grpc.Storage, error) {
 	if c.state.Fstype != "" && c.state.BlockDeviceID != "" {
 		// The rootfs storage volume represents the container rootfs
@@ -1079,16 +1167,7 @@ func (k *kataAgent) buildContainerRootfs(sandbox *Sandbox, c *Container, rootPat
 			return nil, fmt.Errorf("malformed block drive")
 		}
 
-		if sandbox.config.HypervisorConfig.BlockDeviceDriver == config.VirtioMmio {
-			rootfs.Driver = kataMmioBlkDevType
-			rootfs.Source = blockDrive.VirtPath
-		} else if sandbox.config.HypervisorConfig.BlockDeviceDriver == config.VirtioBlock {
-			rootfs.Driver = kataBlkDevType
-			rootfs.Source = blockDrive.PCIAddr
-		} else {
-			rootfs.Driver = kataSCSIDevType
-			rootfs.Source = blockDrive.SCSIAddr
-		}
+		k.setBlockStorageSource(sandbox, rootfs, blockDrive)
 		rootfs.MountPoint = rootPathParent
 		rootfs.Fstype = c.state.Fstype
 
@@ -1116,7 +1195,7 @@ func (k *kataAgent) buildContainerRootfs(sandbox *Sandbox, c *Container, rootPat
 
 func (k *kataAgent) hasAgentDebugConsole(sandbox *Sandbox) bool {
 	for _, p := range sandbox.config.HypervisorConfig.KernelParams {
-		if p.Key == "agent.debug_console" {
+		if p.Key == agentDebugConsoleParam {
 			k.Logger().Info("agent has debug console")
 			return true
 		}
@@ -1124,6 +1203,42 @@ func (k *kataAgent) hasAgentDebugConsole(sandbox *Sandbox) bool {
 	return false
 }
 
+func (k *kataAgent) debugConsoleURL(sandbox *Sandbox) (string, error) {
+	if !k.hasAgentDebugConsole(sandbox) {
+		return "", fmt.Errorf("Agent debug console not enabled for sandbox %s", sandbox.id)
+	}
+
+	// The agent serves its debug console on a vsock port, on the same
+	// context ID as the agent itself.
+	for _, p := range sandbox.config.HypervisorConfig.KernelParams {
+		if p.Key != agentDebugConsoleVPortParam {
+			continue
+		}
+
+		u, err := url.Parse(k.state.URL)
+		if err != nil {
+			return "", err
+		}
+
+		if u.Scheme != vsockSocketScheme {
+			return "", fmt.Errorf("Agent debug console needs a vsock agent URL, got %q", k.state.URL)
+		}
+
+		return fmt.Sprintf("%s://%s:%s", vsockSocketScheme, u.Hostname(), p.Value), nil
+	}
+
+	consolePath, err := sandbox.hypervisor.getSandboxConsole(sandbox.id)
+	if err != nil {
+		return "", err
+	}
+
+	if consolePath == "" {
+		return "", fmt.Errorf("No console available for sandbox %s", sandbox.id)
+	}
+
+	return fmt.Sprintf("unix://%s", consolePath), nil
+}
+
 func (k *kataAgent) createContainer(sandbox *Sandbox, c *Container) (p *Process, err error) {
 	span, _ := k.trace("createContainer")
 	defer span.Finish()
@@ -1170,10 +1285,16 @@ func (k *kataAgent) createContainer(sandbox *Sandbox, c *Container) (p *Process,
 		return nil, err
 	}
 
-	epheStorages := k.handleEphemeralStorage(ociSpec.Mounts)
+	epheStorages, err := k.handleEphemeralStorage(ociSpec.Mounts)
+	if err != nil {
+		return nil, err
+	}
 	ctrStorages = append(ctrStorages, epheStorages...)
 
-	localStorages := k.handleLocalStorage(ociSpec.Mounts, sandbox.id)
+	localStorages, err := k.handleLocalStorage(sandbox, ociSpec.Mounts)
+	if err != nil {
+		return nil, err
+	}
 	ctrStorages = append(ctrStorages, localStorages...)
 
 	// We replace all OCI mount sources that match our container mount
@@ -1260,14 +1381,21 @@ func (k *kataAgent) createContainer(sandbox *Sandbox, c *Container) (p *Process,
 
 // handleEphemeralStorage handles ephemeral storages by
 // creating a Storage from corresponding source of the mount point
-func (k *kataAgent) handleEphemeralStorage(mounts []specs.Mount) []*grpc.Storage {
+func (k *kataAgent) handleEphemeralStorage(mounts []specs.Mount) ([]*grpc.Storage, error) {
 	var epheStorages []*grpc.Storage
 	for idx, mnt := range mounts {
 		if mnt.Type == KataEphemeralDevType {
+			size, options, err := storageSizeLimit(mnt.Options)
+			if err != nil {
+				return nil, err
+			}
+
 			// Set the mount source path to a path that resides inside the VM
 			mounts[idx].Source = filepath.Join(ephemeralPath, filepath.Base(mnt.Source))
 			// Set the mount type to "bind"
 			mounts[idx].Type = "bind"
+			// The size limit applies to the tmpfs, not to the bind mount
+			mounts[idx].Options = options
 
 			// Create a storage struct so that kata agent is able to create
 			// tmpfs backed volume inside the VM
@@ -1277,27 +1405,49 @@ func (k *kataAgent) handleEphemeralStorage(mounts []specs.Mount) []*grpc.Storage
 				Fstype:     "tmpfs",
 				MountPoint: mounts[idx].Source,
 			}
+
+			if size > 0 {
+				epheStorage.Options = []string{StorageSizeOption(size)}
+			}
+
 			epheStorages = append(epheStorages, epheStorage)
 		}
 	}
-	return epheStorages
+	return epheStorages, nil
 }
 
 // handleLocalStorage handles local storage within the VM
 // by creating a directory in the VM from the source of the mount point.
-func (k *kataAgent) handleLocalStorage(mounts []specs.Mount, sandboxID string) []*grpc.Storage {
+func (k *kataAgent) handleLocalStorage(sandbox *Sandbox, mounts []specs.Mount) ([]*grpc.Storage, error) {
 	var localStorages []*grpc.Storage
 	for idx, mnt := range mounts {
 		if mnt.Type == KataLocalDevType {
+			size, options, err := storageSizeLimit(mnt.Options)
+			if err != nil {
+				return nil, err
+			}
+
 			// Set the mount source path to a the desired directory point in the VM.
 			// In this case it is located in the sandbox directory.
 			// We rely on the fact that the first container in the VM has the same ID as the sandbox ID.
 			// In Kubernetes, this is usually the pause container and we depend on it existing for
 			// local directories to work.
-			mounts[idx].Source = filepath.Join(kataGuestSharedDir, sandboxID, KataLocalDevType, filepath.Base(mnt.Source))
+			mounts[idx].Source = filepath.Join(kataGuestSharedDir, sandbox.id, KataLocalDevType, filepath.Base(mnt.Source))
+			mounts[idx].Options = options
+
+			if size > 0 {
+				imageStorage, err := k.handleLocalStorageImage(sandbox, mnt.Source, mounts[idx].Source, size)
+				if err != nil {
+					return nil, err
+				}
+
+				if imageStorage != nil {
+					localStorages = append(localStorages, imageStorage)
+				}
+			}
 
 			// Create a storage struct so that the kata agent is able to create the
-			// directory inside the VM.
+			// directory inside the VM, or set the mode of the image root directory.
 			localStorage := &grpc.Storage{
 				Driver:     KataLocalDevType,
 				Source:     KataLocalDevType,
@@ -1308,7 +1458,118 @@ func (k *kataAgent) handleLocalStorage(mounts []specs.Mount, sandboxID string) [
 			localStorages = append(localStorages, localStorage)
 		}
 	}
-	return localStorages
+	return localStorages, nil
+}
+
+// createLocalStorageImage creates a sparse ext4 image of the given size.
+func createLocalStorageImage(path string, size uint64) (err error) {
+	if _, err := exec.LookPath("mkfs.ext4"); err != nil {
+		return fmt.Errorf("mkfs.ext4 is needed to limit the size of local storages: %v", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			os.Remove(path)
+		}
+	}()
+
+	err = f.Truncate(int64(size))
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	if output, err := exec.Command("mkfs.ext4", "-q", "-F", path).CombinedOutput(); err != nil {
+		return fmt.Errorf("Could not format local storage image %s: %v: %s", path, err, output)
+	}
+
+	return nil
+}
+
+var createLocalStorageImageFunc = createLocalStorageImage
+
+// localStorageImagePath returns the path of the local storage image backing
+// the device, or an empty string if it is not such an image.
+func localStorageImagePath(device api.Device) string {
+	blockDrive, ok := device.GetDeviceInfo().(*config.BlockDrive)
+	if !ok || blockDrive == nil || filepath.Base(blockDrive.File) != localStorageImage {
+		return ""
+	}
+
+	return blockDrive.File
+}
+
+// handleLocalStorageImage backs a size limited local storage with an image
+// stored in the host volume directory, so that it is accounted as the volume
+// disk usage, and hotplugged to the VM as a block device. The image is
+// created and hotplugged by the first container using the volume, and lives
+// as long as the sandbox. It is already mounted in the VM for the next
+// containers and nil is returned.
+func (k *kataAgent) handleLocalStorageImage(sandbox *Sandbox, hostPath, guestPath string, size uint64) (*grpc.Storage, error) {
+	imagePath := filepath.Join(hostPath, localStorageImage)
+
+	for _, device := range sandbox.devManager.GetAllDevices() {
+		if localStorageImagePath(device) == imagePath && device.GetAttachCount() > 0 {
+			return nil, nil
+		}
+	}
+
+	caps := sandbox.hypervisor.capabilities()
+	if !caps.IsBlockDeviceHotplugSupported() || sandbox.config.HypervisorConfig.DisableBlockDeviceUse {
+		k.Logger().WithField("volume", hostPath).Warn("Block devices not supported, local storage size not limited")
+		return nil, nil
+	}
+
+	// Images are removed when the sandbox stops, an image left behind
+	// by a sandbox which did not stop cleanly is reused.
+	if _, err := os.Stat(imagePath); os.IsNotExist(err) {
+		if err := createLocalStorageImageFunc(imagePath, size); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	device, err := sandbox.devManager.NewDevice(config.DeviceInfo{
+		HostPath:      imagePath,
+		ContainerPath: guestPath,
+		DevType:       "b",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := sandbox.devManager.AttachDevice(device.DeviceID(), sandbox); err != nil {
+		if err := sandbox.devManager.RemoveDevice(device.DeviceID()); err != nil {
+			k.Logger().WithError(err).WithField("device", device.DeviceID()).Warn("Could not remove local storage device")
+		}
+		return nil, err
+	}
+
+	if !sandbox.supportNewStore() {
+		if err := sandbox.storeSandboxDevices(); err != nil {
+			return nil, err
+		}
+	}
+
+	blockDrive, ok := device.GetDeviceInfo().(*config.BlockDrive)
+	if !ok || blockDrive == nil {
+		return nil, fmt.Errorf("malformed block drive")
+	}
+
+	storage := &grpc.Storage{
+		MountPoint: guestPath,
+		Fstype:     "ext4",
+	}
+
+	k.setBlockStorageSource(sandbox, storage, blockDrive)
+
+	return storage, nil
 }
 
 // handleBlockVolumes handles volumes that are block devices files
@@ -1688,8 +1949,6 @@ type reqFunc func(context.Context, interface{}, ...golangGrpc.CallOption) (inter
 func (k *kataAgent) installReqFunc(c *kataclient.AgentClient) {
 	k.reqHandlers = make(map[string]reqFunc)
 	k.reqHandlers["grpc.CheckRequest"] = func(ctx context.Context, req interface{}, opts ...golangGrpc.CallOption) (interface{}, error) {
-		ctx, cancel := context.WithTimeout(ctx, checkRequestTimeout)
-		defer cancel()
 		return k.client.Check(ctx, req.(*grpc.CheckRequest), opts...)
 	}
 	k.reqHandlers["grpc.ExecProcessRequest"] = func(ctx context.Context, req interface{}, opts ...golangGrpc.CallOption) (interface{}, error) {
@@ -1779,33 +2038,92 @@ func (k *kataAgent) installReqFunc(c *kataclient.AgentClient) {
 }
 
 func (k *kataAgent) sendReq(request interface{}) (interface{}, error) {
-	span, _ := k.trace("sendReq")
+	span, ctx := k.trace("sendReq")
 	span.SetTag("request", request)
 	defer span.Finish()
 
+	msgName := proto.MessageName(request.(proto.Message))
+	if msgName == "" {
+		return nil, errors.New("Invalid request type")
+	}
+
+	maxRetries := k.reqPolicy.maxRetries(msgName)
+	start := time.Now()
+
+	for retry := uint32(0); ; retry++ {
+		resp, err := k.sendReqOnce(ctx, msgName, request)
+		if err == nil || retry >= maxRetries || !isRetryableAgentError(err) {
+			return resp, err
+		}
+
+		k.Logger().WithError(err).WithFields(logrus.Fields{
+			"name":  msgName,
+			"retry": retry + 1,
+		}).Warn("retrying request")
+
+		time.Sleep(agentRetryDelay << retry)
+
+		request = refreshAgentRequest(request, time.Since(start))
+	}
+}
+
+func (k *kataAgent) sendReqOnce(ctx context.Context, msgName string, request interface{}) (interface{}, error) {
 	if k.state.ProxyPid > 0 {
 		// check that proxy is running before talk with it avoiding long timeouts
 		if err := syscall.Kill(k.state.ProxyPid, syscall.Signal(0)); err != nil {
-			return nil, fmt.Errorf("Proxy is not running: %v", err)
+			return nil, &vcTypes.AgentError{
+				Request: msgName,
+				Code:    codes.Unavailable,
+				Err:     fmt.Errorf("Proxy is not running: %v", err),
+			}
 		}
 	}
 
 	if err := k.connect(); err != nil {
-		return nil, err
+		return nil, &vcTypes.AgentError{
+			Request: msgName,
+			Code:    codes.Unavailable,
+			Err:     err,
+		}
 	}
 	if !k.keepConn {
 		defer k.disconnect()
 	}
 
-	msgName := proto.MessageName(request.(proto.Message))
 	handler := k.reqHandlers[msgName]
-	if msgName == "" || handler == nil {
+	if handler == nil {
 		return nil, errors.New("Invalid request type")
 	}
 	message := request.(proto.Message)
 	k.Logger().WithField("name", msgName).WithField("req", message.String()).Debug("sending request")
 
-	return handler(k.ctx, request)
+	if deadline := k.reqPolicy.deadline(msgName); deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, deadline)
+		defer cancel()
+	}
+
+	// Use the request span context so that the client interceptors
+	// propagate it to the agent, making the agent spans its children.
+	resp, err := handler(ctx, request)
+	if err == nil {
+		return resp, nil
+	}
+
+	code := grpcStatus.Convert(err).Code()
+
+	// The connection is broken, because the VM was paused for a while or
+	// the proxy was restarted, or hung: drop it, so that the next request
+	// connects again.
+	if (code == codes.Unavailable || code == codes.DeadlineExceeded) && k.keepConn {
+		k.disconnect()
+	}
+
+	return nil, &vcTypes.AgentError{
+		Request: msgName,
+		Code:    code,
+		Err:     err,
+	}
 }
 
 // readStdout and readStderr are special that we cannot differentiate them with the request types...
@@ -0,0 +1,164 @@
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"time"
+
+	"github.com/kata-containers/agent/protocols/grpc"
+	vcTypes "github.com/kata-containers/runtime/virtcontainers/pkg/types"
+	"google.golang.org/grpc/codes"
+)
+
+// agentRequestClass groups the agent requests sharing the same deadline.
+type agentRequestClass int
+
+const (
+	// agentRequestDefault is the class of the short requests.
+	agentRequestDefault agentRequestClass = iota
+
+	// agentRequestCheck is the class of the agent health checks.
+	agentRequestCheck
+
+	// agentRequestLong is the class of the requests creating or removing
+	// sandboxes, containers and processes, or copying files to the guest.
+	agentRequestLong
+
+	// agentRequestBlocking is the class of the requests which can block
+	// as long as a process runs, like waiting for it or writing to its
+	// standard input. They have no deadline.
+	agentRequestBlocking
+)
+
+const (
+	defaultAgentCheckTimeout       = 30 * time.Second
+	defaultAgentRequestTimeout     = time.Minute
+	defaultAgentLongRequestTimeout = 5 * time.Minute
+	defaultAgentRequestRetries     = 2
+)
+
+// agentRetryDelay is the delay before retrying a failed request, doubled at
+// each retry.
+var agentRetryDelay = 100 * time.Millisecond
+
+var agentRequestClasses = map[string]agentRequestClass{
+	"grpc.CheckRequest":             agentRequestCheck,
+	"grpc.CreateSandboxRequest":     agentRequestLong,
+	"grpc.DestroySandboxRequest":    agentRequestLong,
+	"grpc.CreateContainerRequest":   agentRequestLong,
+	"grpc.StartContainerRequest":    agentRequestLong,
+	"grpc.RemoveContainerRequest":   agentRequestLong,
+	"grpc.ExecProcessRequest":       agentRequestLong,
+	"grpc.UpdateContainerRequest":   agentRequestLong,
+	"grpc.OnlineCPUMemRequest":      agentRequestLong,
+	"grpc.MemHotplugByProbeRequest": agentRequestLong,
+	"grpc.CopyFileRequest":          agentRequestLong,
+	"grpc.WaitProcessRequest":       agentRequestBlocking,
+	"grpc.WriteStreamRequest":       agentRequestBlocking,
+}
+
+// idempotentAgentRequests lists the requests which can safely be sent again
+// after a failure. Health checks are not retried, so that a hung agent is
+// detected in time. CPU and memory onlining requests are not either, as a
+// timed out request could still be onlining the resources in the guest.
+// The guest time request is rebuilt by refreshAgentRequest before a retry.
+var idempotentAgentRequests = map[string]bool{
+	"grpc.ListInterfacesRequest":    true,
+	"grpc.ListRoutesRequest":        true,
+	"grpc.ListProcessesRequest":     true,
+	"grpc.UpdateInterfaceRequest":   true,
+	"grpc.UpdateRoutesRequest":      true,
+	"grpc.StatsContainerRequest":    true,
+	"grpc.TtyWinResizeRequest":      true,
+	"grpc.GuestDetailsRequest":      true,
+	"grpc.SetGuestDateTimeRequest":  true,
+	"grpc.ReseedRandomDevRequest":   true,
+	"grpc.MemHotplugByProbeRequest": true,
+}
+
+// agentRequestPolicy holds the deadlines and the number of retries of the
+// agent requests. Zero values select the defaults.
+type agentRequestPolicy struct {
+	checkTimeout time.Duration
+	timeout      time.Duration
+	longTimeout  time.Duration
+	retries      uint32
+}
+
+func newAgentRequestPolicy(config KataAgentConfig) agentRequestPolicy {
+	return agentRequestPolicy{
+		checkTimeout: config.CheckTimeout,
+		timeout:      config.RequestTimeout,
+		longTimeout:  config.LongRequestTimeout,
+		retries:      config.RequestRetries,
+	}
+}
+
+// deadline returns how long to wait for the answer to a request, zero
+// meaning forever.
+func (p agentRequestPolicy) deadline(msgName string) time.Duration {
+	switch agentRequestClasses[msgName] {
+	case agentRequestCheck:
+		if p.checkTimeout > 0 {
+			return p.checkTimeout
+		}
+		return defaultAgentCheckTimeout
+	case agentRequestLong:
+		if p.longTimeout > 0 {
+			return p.longTimeout
+		}
+		return defaultAgentLongRequestTimeout
+	case agentRequestBlocking:
+		return 0
+	default:
+		if p.timeout > 0 {
+			return p.timeout
+		}
+		return defaultAgentRequestTimeout
+	}
+}
+
+// maxRetries returns how many times a failed request can be sent again.
+func (p agentRequestPolicy) maxRetries(msgName string) uint32 {
+	if !idempotentAgentRequests[msgName] {
+		return 0
+	}
+
+	if p.retries > 0 {
+		return p.retries
+	}
+
+	return defaultAgentRequestRetries
+}
+
+// isRetryableAgentError tells if the failure of a request is transient:
+// the agent could not be reached, or did not answer in time.
+func isRetryableAgentError(err error) bool {
+	agentErr, ok := err.(*vcTypes.AgentError)
+	if !ok {
+		return false
+	}
+
+	return agentErr.Code == codes.Unavailable || agentErr.Code == codes.DeadlineExceeded
+}
+
+// refreshAgentRequest returns the request to send again after it failed,
+// elapsed after the first attempt. The guest time is moved forward by the
+// time spent retrying, so that a retried request does not set the guest
+// clock in the past.
+func refreshAgentRequest(request interface{}, elapsed time.Duration) interface{} {
+	req, ok := request.(*grpc.SetGuestDateTimeRequest)
+	if !ok {
+		return request
+	}
+
+	tv := time.Unix(req.Sec, req.Usec*1e3).Add(elapsed)
+
+	return &grpc.SetGuestDateTimeRequest{
+		Sec:  tv.Unix(),
+		Usec: int64(tv.Nanosecond() / 1e3),
+	}
+}
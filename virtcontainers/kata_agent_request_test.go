@@ -0,0 +1,269 @@
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	gpb "github.com/gogo/protobuf/types"
+	pb "github.com/kata-containers/agent/protocols/grpc"
+	"github.com/kata-containers/runtime/virtcontainers/pkg/mock"
+	vcTypes "github.com/kata-containers/runtime/virtcontainers/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// flakyGRPCProxy is an agent failing the first requests it gets, and
+// answering after a delay.
+type flakyGRPCProxy struct {
+	gRPCProxy
+
+	sync.Mutex
+	failures int
+	delay    time.Duration
+	calls    int
+}
+
+func (p *flakyGRPCProxy) handle() error {
+	p.Lock()
+	p.calls++
+	fail := p.failures > 0
+	if fail {
+		p.failures--
+	}
+	delay := p.delay
+	p.Unlock()
+
+	time.Sleep(delay)
+
+	if fail {
+		return status.Error(codes.Unavailable, "agent unavailable")
+	}
+
+	return nil
+}
+
+func (p *flakyGRPCProxy) callCount() int {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.calls
+}
+
+func (p *flakyGRPCProxy) ListInterfaces(ctx context.Context, req *pb.ListInterfacesRequest) (*pb.Interfaces, error) {
+	if err := p.handle(); err != nil {
+		return nil, err
+	}
+
+	return &pb.Interfaces{}, nil
+}
+
+func (p *flakyGRPCProxy) SignalProcess(ctx context.Context, req *pb.SignalProcessRequest) (*gpb.Empty, error) {
+	if err := p.handle(); err != nil {
+		return nil, err
+	}
+
+	return emptyResp, nil
+}
+
+func startFlakyGRPCProxy(t *testing.T, impl *flakyGRPCProxy) (string, func()) {
+	sockDir, err := testGenerateKataProxySockDir()
+	assert.NoError(t, err)
+
+	proxy := mock.ProxyGRPCMock{
+		GRPCImplementer: impl,
+		GRPCRegister:    gRPCRegister,
+	}
+
+	proxyURL := fmt.Sprintf(testKataProxyURLTempl, sockDir)
+	err = proxy.Start(proxyURL)
+	assert.NoError(t, err)
+
+	return proxyURL, func() {
+		proxy.Stop()
+		os.RemoveAll(sockDir)
+	}
+}
+
+func TestAgentRequestPolicyDeadline(t *testing.T) {
+	assert := assert.New(t)
+
+	p := agentRequestPolicy{}
+
+	assert.Equal(defaultAgentCheckTimeout, p.deadline("grpc.CheckRequest"))
+	assert.Equal(defaultAgentLongRequestTimeout, p.deadline("grpc.CreateContainerRequest"))
+	assert.Equal(defaultAgentRequestTimeout, p.deadline("grpc.SignalProcessRequest"))
+	assert.Equal(time.Duration(0), p.deadline("grpc.WaitProcessRequest"))
+	assert.Equal(time.Duration(0), p.deadline("grpc.WriteStreamRequest"))
+
+	p = newAgentRequestPolicy(KataAgentConfig{
+		CheckTimeout:       time.Second,
+		RequestTimeout:     2 * time.Second,
+		LongRequestTimeout: 3 * time.Second,
+	})
+
+	assert.Equal(time.Second, p.deadline("grpc.CheckRequest"))
+	assert.Equal(3*time.Second, p.deadline("grpc.CreateContainerRequest"))
+	assert.Equal(2*time.Second, p.deadline("grpc.SignalProcessRequest"))
+	assert.Equal(time.Duration(0), p.deadline("grpc.WaitProcessRequest"))
+}
+
+func TestAgentRequestPolicyMaxRetries(t *testing.T) {
+	assert := assert.New(t)
+
+	p := agentRequestPolicy{}
+
+	assert.Equal(uint32(defaultAgentRequestRetries), p.maxRetries("grpc.ListInterfacesRequest"))
+	assert.Equal(uint32(0), p.maxRetries("grpc.CheckRequest"))
+	assert.Equal(uint32(0), p.maxRetries("grpc.SignalProcessRequest"))
+	assert.Equal(uint32(0), p.maxRetries("grpc.CreateContainerRequest"))
+	assert.Equal(uint32(0), p.maxRetries("grpc.OnlineCPUMemRequest"))
+
+	p = newAgentRequestPolicy(KataAgentConfig{RequestRetries: 5})
+
+	assert.Equal(uint32(5), p.maxRetries("grpc.ListInterfacesRequest"))
+	assert.Equal(uint32(0), p.maxRetries("grpc.SignalProcessRequest"))
+}
+
+func TestRefreshAgentRequest(t *testing.T) {
+	assert := assert.New(t)
+
+	req := &pb.ListInterfacesRequest{}
+	assert.True(req == refreshAgentRequest(req, time.Second))
+
+	tv := time.Unix(1000, 999999000)
+	dateReq := &pb.SetGuestDateTimeRequest{
+		Sec:  tv.Unix(),
+		Usec: int64(tv.Nanosecond() / 1e3),
+	}
+
+	refreshed, ok := refreshAgentRequest(dateReq, 1500*time.Millisecond).(*pb.SetGuestDateTimeRequest)
+	assert.True(ok)
+	assert.Equal(int64(1002), refreshed.Sec)
+	assert.Equal(int64(499999), refreshed.Usec)
+	assert.Equal(int64(1000), dateReq.Sec)
+}
+
+func TestIsRetryableAgentError(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.False(isRetryableAgentError(nil))
+	assert.False(isRetryableAgentError(errors.New("error")))
+	assert.False(isRetryableAgentError(&vcTypes.AgentError{Code: codes.NotFound, Err: errors.New("error")}))
+	assert.True(isRetryableAgentError(&vcTypes.AgentError{Code: codes.Unavailable, Err: errors.New("error")}))
+	assert.True(isRetryableAgentError(&vcTypes.AgentError{Code: codes.DeadlineExceeded, Err: errors.New("error")}))
+}
+
+func TestKataAgentSendReqRetry(t *testing.T) {
+	assert := assert.New(t)
+
+	savedDelay := agentRetryDelay
+	agentRetryDelay = time.Millisecond
+	defer func() {
+		agentRetryDelay = savedDelay
+	}()
+
+	impl := &flakyGRPCProxy{failures: 1}
+	proxyURL, stop := startFlakyGRPCProxy(t, impl)
+	defer stop()
+
+	k := &kataAgent{
+		ctx:      context.Background(),
+		keepConn: true,
+		state: KataAgentState{
+			URL: proxyURL,
+		},
+	}
+	defer k.disconnect()
+
+	// The idempotent request is retried on a new connection.
+	_, err := k.sendReq(&pb.ListInterfacesRequest{})
+	assert.NoError(err)
+	assert.Equal(2, impl.callCount())
+	assert.NotNil(k.client)
+
+	// The other requests are not retried, but the next one reconnects.
+	impl.failures = 1
+
+	_, err = k.sendReq(&pb.SignalProcessRequest{})
+	assert.Error(err)
+	assert.Equal(3, impl.callCount())
+	assert.Nil(k.client)
+
+	agentErr, ok := err.(*vcTypes.AgentError)
+	assert.True(ok)
+	assert.Equal(codes.Unavailable, agentErr.Code)
+	assert.Equal("grpc.SignalProcessRequest", agentErr.Request)
+
+	_, err = k.sendReq(&pb.SignalProcessRequest{})
+	assert.NoError(err)
+	assert.Equal(4, impl.callCount())
+}
+
+func TestKataAgentSendReqDeadline(t *testing.T) {
+	assert := assert.New(t)
+
+	savedDelay := agentRetryDelay
+	agentRetryDelay = time.Millisecond
+	defer func() {
+		agentRetryDelay = savedDelay
+	}()
+
+	impl := &flakyGRPCProxy{delay: 500 * time.Millisecond}
+	proxyURL, stop := startFlakyGRPCProxy(t, impl)
+	defer stop()
+
+	k := &kataAgent{
+		ctx:      context.Background(),
+		keepConn: true,
+		reqPolicy: agentRequestPolicy{
+			timeout: 50 * time.Millisecond,
+			retries: 1,
+		},
+		state: KataAgentState{
+			URL: proxyURL,
+		},
+	}
+	defer k.disconnect()
+
+	// The connection to the hung agent is dropped.
+	_, err := k.sendReq(&pb.ListInterfacesRequest{})
+	assert.Error(err)
+	assert.Equal(2, impl.callCount())
+	assert.Nil(k.client)
+
+	s, ok := status.FromError(err)
+	assert.True(ok)
+	assert.Equal(codes.DeadlineExceeded, s.Code())
+}
+
+func TestKataAgentSendReqProxyNotRunning(t *testing.T) {
+	assert := assert.New(t)
+
+	k := &kataAgent{
+		ctx: context.Background(),
+		state: KataAgentState{
+			// No process can have this PID.
+			ProxyPid: 1 << 30,
+		},
+	}
+
+	_, err := k.sendReq(&pb.SignalProcessRequest{})
+	assert.Error(err)
+
+	agentErr, ok := err.(*vcTypes.AgentError)
+	assert.True(ok)
+	assert.Equal(codes.Unavailable, agentErr.Code)
+	assert.Equal(syscall.ESRCH, syscall.Kill(k.state.ProxyPid, syscall.Signal(0)))
+}
@@ -12,6 +12,7 @@ import (
 	"io/ioutil"
 	"net"
 	"os"
+	"os/exec"
 	"path"
 	"path/filepath"
 	"reflect"
@@ -265,6 +266,9 @@ func gRPCRegister(s *grpc.Server, srv interface{}) {
 	case *gRPCProxy:
 		pb.RegisterAgentServiceServer(s, g)
 		pb.RegisterHealthServer(s, g)
+	case *flakyGRPCProxy:
+		pb.RegisterAgentServiceServer(s, g)
+		pb.RegisterHealthServer(s, g)
 	}
 }
 
@@ -374,12 +378,164 @@ func TestHandleEphemeralStorage(t *testing.T) {
 	}
 
 	ociMounts = append(ociMounts, mount)
-	epheStorages := k.handleEphemeralStorage(ociMounts)
+	epheStorages, err := k.handleEphemeralStorage(ociMounts)
+	assert.NoError(t, err)
 
 	epheMountPoint := epheStorages[0].GetMountPoint()
 	expected := filepath.Join(ephemeralPath, filepath.Base(mountSource))
 	assert.Equal(t, epheMountPoint, expected,
 		"Ephemeral mount point didn't match: got %s, expecting %s", epheMountPoint, expected)
+	assert.Empty(t, epheStorages[0].Options)
+}
+
+func TestHandleEphemeralStorageSizeLimit(t *testing.T) {
+	assert := assert.New(t)
+	k := kataAgent{}
+
+	ociMounts := []specs.Mount{
+		{
+			Type:    KataEphemeralDevType,
+			Source:  "/tmp/mountPoint",
+			Options: []string{"rbind", StorageSizeOption(1 << 20)},
+		},
+	}
+
+	epheStorages, err := k.handleEphemeralStorage(ociMounts)
+	assert.NoError(err)
+	assert.Len(epheStorages, 1)
+	assert.Equal([]string{"size=1048576"}, epheStorages[0].Options)
+	assert.Equal([]string{"rbind"}, ociMounts[0].Options)
+	assert.Equal("bind", ociMounts[0].Type)
+
+	ociMounts[0].Type = KataEphemeralDevType
+	ociMounts[0].Options = []string{"size=foo"}
+	_, err = k.handleEphemeralStorage(ociMounts)
+	assert.Error(err)
+}
+
+type blockHotplugHypervisor struct {
+	mockHypervisor
+}
+
+func (h *blockHotplugHypervisor) capabilities() types.Capabilities {
+	caps := types.Capabilities{}
+	caps.SetBlockDeviceHotplugSupport()
+	return caps
+}
+
+func TestHandleLocalStorage(t *testing.T) {
+	assert := assert.New(t)
+	k := kataAgent{}
+
+	dir, err := ioutil.TempDir("", "local-storage")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	savedCreateLocalStorageImageFunc := createLocalStorageImageFunc
+	defer func() {
+		createLocalStorageImageFunc = savedCreateLocalStorageImageFunc
+	}()
+
+	created := 0
+	createLocalStorageImageFunc = func(path string, size uint64) error {
+		created++
+		return ioutil.WriteFile(path, []byte{}, 0600)
+	}
+
+	sandbox := &Sandbox{
+		ctx:        context.Background(),
+		id:         "sandbox",
+		config:     &SandboxConfig{},
+		hypervisor: &mockHypervisor{},
+		devManager: manager.NewDeviceManager(config.VirtioBlock, false, "", nil),
+	}
+
+	ociMounts := []specs.Mount{
+		{
+			Type:   KataLocalDevType,
+			Source: filepath.Join(dir, "volume"),
+		},
+	}
+
+	localStorages, err := k.handleLocalStorage(sandbox, ociMounts)
+	assert.NoError(err)
+	assert.Len(localStorages, 1)
+	assert.Equal(KataLocalDevType, localStorages[0].Driver)
+	assert.Equal(filepath.Join(kataGuestSharedDir, "sandbox", KataLocalDevType, "volume"), localStorages[0].MountPoint)
+
+	// Size limit without block device support
+	ociMounts[0].Source = filepath.Join(dir, "volume")
+	ociMounts[0].Options = []string{StorageSizeOption(1 << 30)}
+
+	localStorages, err = k.handleLocalStorage(sandbox, ociMounts)
+	assert.NoError(err)
+	assert.Len(localStorages, 1)
+	assert.Empty(ociMounts[0].Options)
+	assert.Equal(0, created)
+
+	// Size limit with block device support
+	sandbox.hypervisor = &blockHotplugHypervisor{}
+	sandbox.config.HypervisorConfig.BlockDeviceDriver = config.VirtioBlock
+	vcStore, err := store.NewVCSandboxStore(sandbox.ctx, sandbox.id)
+	assert.NoError(err)
+	sandbox.store = vcStore
+	defer store.DeleteAll()
+
+	assert.NoError(os.MkdirAll(filepath.Join(dir, "volume"), 0750))
+	image := filepath.Join(dir, "volume", localStorageImage)
+
+	ociMounts[0].Source = filepath.Join(dir, "volume")
+	ociMounts[0].Options = []string{StorageSizeOption(1 << 30)}
+
+	localStorages, err = k.handleLocalStorage(sandbox, ociMounts)
+	assert.NoError(err)
+	assert.Len(localStorages, 2)
+	assert.Equal(kataBlkDevType, localStorages[0].Driver)
+	assert.Equal("ext4", localStorages[0].Fstype)
+	assert.Equal(1, created)
+
+	devices := sandbox.devManager.GetAllDevices()
+	assert.Len(devices, 1)
+	assert.Equal(image, localStorageImagePath(devices[0]))
+	assert.Equal(uint(1), devices[0].GetAttachCount())
+
+	// Image already attached for another container
+	ociMounts[0].Source = filepath.Join(dir, "volume")
+	ociMounts[0].Options = []string{StorageSizeOption(1 << 30)}
+
+	localStorages, err = k.handleLocalStorage(sandbox, ociMounts)
+	assert.NoError(err)
+	assert.Len(localStorages, 1)
+	assert.Equal(1, created)
+	assert.Len(sandbox.devManager.GetAllDevices(), 1)
+
+	// The image is removed with the sandbox
+	assert.NoError(sandbox.removeLocalStorageImages())
+	_, err = os.Stat(image)
+	assert.True(os.IsNotExist(err))
+}
+
+func TestCreateLocalStorageImage(t *testing.T) {
+	assert := assert.New(t)
+
+	if _, err := exec.LookPath("mkfs.ext4"); err != nil {
+		t.Skip("mkfs.ext4 not found")
+	}
+
+	dir, err := ioutil.TempDir("", "local-storage")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	image := filepath.Join(dir, localStorageImage)
+
+	assert.NoError(createLocalStorageImage(image, 16<<20))
+
+	fi, err := os.Stat(image)
+	assert.NoError(err)
+	assert.Equal(int64(16<<20), fi.Size())
+
+	// Already created
+	assert.Error(createLocalStorageImage(image, 16<<20))
 }
 
 func TestAppendDevicesEmptyContainerDeviceList(t *testing.T) {
@@ -391,7 +547,7 @@ func TestAppendDevicesEmptyContainerDeviceList(t *testing.T) {
 
 	c := &Container{
 		sandbox: &Sandbox{
-			devManager: manager.NewDeviceManager("virtio-scsi", nil),
+			devManager: manager.NewDeviceManager("virtio-scsi", false, "", nil),
 		},
 		devices: ctrDevices,
 	}
@@ -424,7 +580,7 @@ func TestAppendDevices(t *testing.T) {
 
 	c := &Container{
 		sandbox: &Sandbox{
-			devManager: manager.NewDeviceManager("virtio-blk", ctrDevices),
+			devManager: manager.NewDeviceManager("virtio-blk", false, "", ctrDevices),
 			config:     sandboxConfig,
 		},
 	}
@@ -447,6 +603,66 @@ func TestAppendDevices(t *testing.T) {
 		updatedDevList, expected)
 }
 
+func TestAppendVhostUserBlkDevices(t *testing.T) {
+	k := kataAgent{}
+
+	blkID := "test-append-vhost-user-blk"
+	scsiID := "test-append-vhost-user-scsi"
+	ctrDevices := []api.Device{
+		&drivers.VhostUserBlkDevice{
+			GenericDevice: &drivers.GenericDevice{
+				ID: blkID,
+			},
+			VhostUserDeviceAttrs: config.VhostUserDeviceAttrs{
+				Type:    config.VhostUserBlk,
+				PCIAddr: testPCIAddr,
+			},
+		},
+		&drivers.VhostUserSCSIDevice{
+			GenericDevice: &drivers.GenericDevice{
+				ID: scsiID,
+			},
+			VhostUserDeviceAttrs: config.VhostUserDeviceAttrs{
+				Type:    config.VhostUserSCSI,
+				PCIAddr: "03/00",
+			},
+		},
+	}
+
+	c := &Container{
+		sandbox: &Sandbox{
+			devManager: manager.NewDeviceManager("virtio-scsi", true, "", ctrDevices),
+			config:     &SandboxConfig{},
+		},
+	}
+	c.devices = append(c.devices,
+		ContainerDevice{
+			ID:            blkID,
+			ContainerPath: "/dev/vda",
+		},
+		ContainerDevice{
+			ID:            scsiID,
+			ContainerPath: "/dev/sda",
+		})
+
+	expected := []*pb.Device{
+		{
+			Type:          kataBlkDevType,
+			ContainerPath: "/dev/vda",
+			Id:            testPCIAddr,
+		},
+		{
+			Type:          kataBlkDevType,
+			ContainerPath: "/dev/sda",
+			Id:            "03/00",
+		},
+	}
+	updatedDevList := k.appendDevices([]*pb.Device{}, c)
+	assert.True(t, reflect.DeepEqual(updatedDevList, expected),
+		"Device lists didn't match: got %+v, expecting %+v",
+		updatedDevList, expected)
+}
+
 func TestConstraintGRPCSpec(t *testing.T) {
 	assert := assert.New(t)
 	expectedCgroupPath := "/foo/bar"
@@ -1132,3 +1348,64 @@ func TestKataAgentSetDefaultTraceConfigOptions(t *testing.T) {
 		}
 	}
 }
+
+func TestKataAgentKernelParamsDebugConsole(t *testing.T) {
+	assert := assert.New(t)
+
+	debugConsoleParam := Param{Key: agentDebugConsoleParam}
+	vportParam := Param{Key: agentDebugConsoleVPortParam, Value: "1026"}
+
+	params := KataAgentKernelParams(KataAgentConfig{})
+	assert.Empty(params)
+
+	params = KataAgentKernelParams(KataAgentConfig{DebugConsole: true})
+	assert.Equal([]Param{debugConsoleParam}, params)
+
+	params = KataAgentKernelParams(KataAgentConfig{DebugConsole: true, UseVSock: true})
+	assert.Equal([]Param{debugConsoleParam, vportParam}, params)
+}
+
+func TestKataAgentDebugConsoleURL(t *testing.T) {
+	assert := assert.New(t)
+
+	k := &kataAgent{
+		state: KataAgentState{
+			URL: "vsock://3:1024",
+		},
+	}
+
+	sandbox := &Sandbox{
+		id:         "foobar",
+		hypervisor: &qemu{},
+		config:     &SandboxConfig{},
+	}
+
+	// Debug console not enabled
+	_, err := k.debugConsoleURL(sandbox)
+	assert.Error(err)
+
+	// Debug console on the guest console
+	sandbox.config.HypervisorConfig.KernelParams = []Param{{Key: agentDebugConsoleParam}}
+	consoleURL, err := k.debugConsoleURL(sandbox)
+	assert.NoError(err)
+	consolePath, err := sandbox.hypervisor.getSandboxConsole(sandbox.id)
+	assert.NoError(err)
+	assert.Equal("unix://"+consolePath, consoleURL)
+
+	// No guest console
+	sandbox.hypervisor = &mockHypervisor{}
+	_, err = k.debugConsoleURL(sandbox)
+	assert.Error(err)
+
+	// Debug console on vsock
+	sandbox.config.HypervisorConfig.KernelParams = append(sandbox.config.HypervisorConfig.KernelParams,
+		Param{Key: agentDebugConsoleVPortParam, Value: "1026"})
+	consoleURL, err = k.debugConsoleURL(sandbox)
+	assert.NoError(err)
+	assert.Equal("vsock://3:1026", consoleURL)
+
+	// The agent is not reached through vsock
+	k.state.URL = "unix:///run/vc/sbs/foobar/proxy.sock"
+	_, err = k.debugConsoleURL(sandbox)
+	assert.Error(err)
+}
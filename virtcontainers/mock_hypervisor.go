@@ -91,6 +91,10 @@ func (m *mockHypervisor) resizeVCPUs(cpus uint32) (uint32, uint32, error) {
 	return 0, 0, nil
 }
 
+func (m *mockHypervisor) resizeBalloon(memMB uint32) (uint32, error) {
+	return memMB, nil
+}
+
 func (m *mockHypervisor) disconnect() {
 }
 
@@ -99,6 +103,14 @@ func (m *mockHypervisor) getThreadIDs() (vcpuThreadIDs, error) {
 	return vcpuThreadIDs{vcpus}, nil
 }
 
+func (m *mockHypervisor) getStats() (*HypervisorStats, error) {
+	return nil, nil
+}
+
+func (m *mockHypervisor) publishMetadata(metadata *SandboxMetadata) error {
+	return nil
+}
+
 func (m *mockHypervisor) cleanup() error {
 	return nil
 }
@@ -13,10 +13,13 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
 
+	units "github.com/docker/go-units"
 	merr "github.com/hashicorp/go-multierror"
+	vcAnnotations "github.com/kata-containers/runtime/virtcontainers/pkg/annotations"
 	"github.com/sirupsen/logrus"
 )
 
@@ -187,6 +190,7 @@ const (
 	procDeviceIndex = iota
 	procPathIndex
 	procTypeIndex
+	procOptionsIndex
 )
 
 // GetDevicePathAndFsType gets the device for the mount point and the file system type
@@ -230,6 +234,65 @@ func GetDevicePathAndFsType(mountPoint string) (devicePath, fsType string, err e
 	}
 }
 
+// getMountOptions returns the options of the mount point.
+func getMountOptions(mountPoint string) ([]string, error) {
+	file, err := os.Open(procMountsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != fieldsPerLine {
+			continue
+		}
+
+		if mountPoint == fields[procPathIndex] {
+			return strings.Split(fields[procOptionsIndex], ","), nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, fmt.Errorf("Mount %s not found", mountPoint)
+}
+
+// getMountsUnder returns the mount points at or below root, in mount order.
+func getMountsUnder(root string) ([]string, error) {
+	file, err := os.Open(procMountsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	var mounts []string
+
+	root = filepath.Clean(root)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != fieldsPerLine {
+			continue
+		}
+
+		if mountPoint := fields[procPathIndex]; mountPoint == root || strings.HasPrefix(mountPoint, root+"/") {
+			mounts = append(mounts, mountPoint)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return mounts, nil
+}
+
 var blockFormatTemplate = "/sys/dev/block/%d:%d/dm"
 
 var checkStorageDriver = isDeviceMapper
@@ -328,6 +391,117 @@ type Mount struct {
 	BlockDeviceID string
 }
 
+// SharedMountMode describes how a container bind mount is provided to the guest.
+type SharedMountMode string
+
+const (
+	// SharedMountShare bind mounts the source in the directory shared with
+	// the guest. This is the default mode.
+	SharedMountShare SharedMountMode = "share"
+
+	// SharedMountReadOnly bind mounts the source read-only in the directory
+	// shared with the guest, so that the guest cannot modify it.
+	SharedMountReadOnly SharedMountMode = "readonly"
+
+	// SharedMountCopy copies the source into the guest through the agent.
+	// The source is not part of the directory shared with the guest, and
+	// later host side changes are not seen by the container.
+	SharedMountCopy SharedMountMode = "copy"
+)
+
+// SharedMountPolicy selects, from their source path, the container bind
+// mounts that are not shared read-write with the guest.
+type SharedMountPolicy struct {
+	// CopyMounts is the list of patterns of the mount sources copied into
+	// the guest instead of being shared.
+	CopyMounts []string
+
+	// ReadOnlyMounts is the list of patterns of the mount sources shared
+	// read-only with the guest.
+	ReadOnlyMounts []string
+}
+
+// matchMountSource tells if the mount source matches one of the patterns.
+// A pattern is a list of path components, each one being a shell file name
+// pattern as defined by filepath.Match. An absolute pattern matches the
+// source or one of its parent directories, a relative one matches any run
+// of consecutive components of the source.
+func matchMountSource(source string, patterns []string) bool {
+	components := strings.Split(strings.TrimPrefix(filepath.Clean(source), "/"), "/")
+
+	for _, p := range patterns {
+		if p == "" {
+			continue
+		}
+
+		pComponents := strings.Split(strings.TrimPrefix(filepath.Clean(p), "/"), "/")
+
+		if filepath.IsAbs(p) {
+			if matchPathComponents(components, pComponents) {
+				return true
+			}
+			continue
+		}
+
+		for i := range components {
+			if matchPathComponents(components[i:], pComponents) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// matchPathComponents tells if the first path components match the patterns.
+func matchPathComponents(components, patterns []string) bool {
+	if len(patterns) > len(components) {
+		return false
+	}
+
+	for i, p := range patterns {
+		if match, err := filepath.Match(p, components[i]); err != nil || !match {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isReadOnlyMount tells if the mount has the "ro" option.
+func isReadOnlyMount(m Mount) bool {
+	for _, flag := range m.Options {
+		if flag == "ro" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Mode returns the sharing mode of the mount, a SharedMountModePrefix
+// annotation for the mount destination taking precedence over the policy.
+func (p SharedMountPolicy) Mode(m Mount, annots map[string]string) (SharedMountMode, error) {
+	if mode, ok := annots[vcAnnotations.SharedMountModePrefix+filepath.Clean(m.Destination)]; ok {
+		switch SharedMountMode(mode) {
+		case SharedMountShare, SharedMountReadOnly, SharedMountCopy:
+			return SharedMountMode(mode), nil
+		default:
+			return "", fmt.Errorf("Invalid shared mount mode %q for mount %s", mode, m.Destination)
+		}
+	}
+
+	if matchMountSource(m.Source, p.CopyMounts) {
+		return SharedMountCopy, nil
+	}
+
+	if matchMountSource(m.Source, p.ReadOnlyMounts) {
+		return SharedMountReadOnly, nil
+	}
+
+	return SharedMountShare, nil
+}
+
 func bindUnmountContainerRootfs(ctx context.Context, sharedDir, sandboxID, cID string) error {
 	span, _ := trace(ctx, "bindUnmountContainerRootfs")
 	defer span.Finish()
@@ -375,8 +549,18 @@ func IsDockerVolume(path string) bool {
 const (
 	// K8sEmptyDir is the k8s specific path for `empty-dir` volumes
 	K8sEmptyDir = "kubernetes.io~empty-dir"
+
+	// storageSizeOption is the mount option carrying the size limit, in
+	// bytes, of ephemeral and local storages.
+	storageSizeOption = "size="
 )
 
+// StorageSizeOption returns the mount option setting the size limit of
+// ephemeral and local storages.
+func StorageSizeOption(size uint64) string {
+	return fmt.Sprintf("%s%d", storageSizeOption, size)
+}
+
 // IsEphemeralStorage returns true if the given path
 // to the storage belongs to kubernetes ephemeral storage
 //
@@ -412,6 +596,64 @@ func Isk8sHostEmptyDir(path string) bool {
 	return false
 }
 
+// StorageSizeLimit returns the size limit, in bytes, of the k8s empty-dir
+// volume at path, 0 meaning no limit. The limit is set by a
+// StorageSizeLimitPrefix annotation for the volume, and defaults to the size
+// of the host tmpfs backing an empty-dir of medium "Memory".
+func StorageSizeLimit(path string, annots map[string]string) (uint64, error) {
+	if limit, ok := annots[vcAnnotations.StorageSizeLimitPrefix+filepath.Base(path)]; ok {
+		size, err := units.RAMInBytes(limit)
+		if err != nil || size < 0 {
+			return 0, fmt.Errorf("Invalid size limit %q for volume %s", limit, path)
+		}
+
+		return uint64(size), nil
+	}
+
+	options, err := getMountOptions(path)
+	if err != nil {
+		// Not a mount point, the volume is a directory on the host
+		return 0, nil
+	}
+
+	for _, opt := range options {
+		// tmpfs only shows a size option when it is not the default one
+		if strings.HasPrefix(opt, storageSizeOption) {
+			size, err := units.RAMInBytes(strings.TrimPrefix(opt, storageSizeOption))
+			if err != nil || size < 0 {
+				return 0, fmt.Errorf("Invalid size option %q for volume %s", opt, path)
+			}
+
+			return uint64(size), nil
+		}
+	}
+
+	return 0, nil
+}
+
+// storageSizeLimit splits the size option out of the storage options, and
+// returns the size limit it sets, 0 meaning no limit.
+func storageSizeLimit(options []string) (uint64, []string, error) {
+	var size uint64
+	var others []string
+
+	for _, opt := range options {
+		if !strings.HasPrefix(opt, storageSizeOption) {
+			others = append(others, opt)
+			continue
+		}
+
+		value, err := strconv.ParseUint(strings.TrimPrefix(opt, storageSizeOption), 10, 64)
+		if err != nil {
+			return 0, nil, fmt.Errorf("Invalid storage size option %q", opt)
+		}
+
+		size = value
+	}
+
+	return size, others, nil
+}
+
 func isEmptyDir(path string) bool {
 	splitSourceSlice := strings.Split(path, "/")
 	if len(splitSourceSlice) > 1 {
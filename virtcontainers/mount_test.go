@@ -19,6 +19,7 @@ import (
 	"testing"
 
 	ktu "github.com/kata-containers/runtime/pkg/katatestutils"
+	vcAnnotations "github.com/kata-containers/runtime/virtcontainers/pkg/annotations"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -340,6 +341,145 @@ func TestIsEphemeralStorage(t *testing.T) {
 	assert.False(t, isHostEmptyDir)
 }
 
+func TestStorageSizeLimit(t *testing.T) {
+	assert := assert.New(t)
+
+	path := "/var/lib/kubelet/pods/366c3a75-4869-11e8-b479-507b9ddd5ce4/volumes/kubernetes.io~empty-dir/cache-volume"
+
+	size, err := StorageSizeLimit(path, nil)
+	assert.NoError(err)
+	assert.Zero(size)
+
+	annots := map[string]string{
+		vcAnnotations.StorageSizeLimitPrefix + "cache-volume": "512Mi",
+	}
+
+	size, err = StorageSizeLimit(path, annots)
+	assert.NoError(err)
+	assert.Equal(uint64(512<<20), size)
+
+	annots[vcAnnotations.StorageSizeLimitPrefix+"cache-volume"] = "foo"
+	_, err = StorageSizeLimit(path, annots)
+	assert.Error(err)
+
+	if tc.NotValid(ktu.NeedRoot()) {
+		return
+	}
+
+	dir, err := ioutil.TempDir(testDir, "foo")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	path = filepath.Join(dir, K8sEmptyDir, "tmp-volume")
+	assert.NoError(os.MkdirAll(path, testDirMode))
+
+	assert.NoError(syscall.Mount("tmpfs", path, "tmpfs", 0, "size=16m"))
+	defer syscall.Unmount(path, 0)
+
+	size, err = StorageSizeLimit(path, nil)
+	assert.NoError(err)
+	assert.Equal(uint64(16<<20), size)
+}
+
+func TestSharedMountPolicyMode(t *testing.T) {
+	assert := assert.New(t)
+
+	policy := SharedMountPolicy{
+		CopyMounts:     []string{"kubernetes.io~secret"},
+		ReadOnlyMounts: []string{"/etc/*"},
+	}
+
+	secret := Mount{
+		Source:      "/var/lib/kubelet/pods/366c3a75-4869-11e8-b479-507b9ddd5ce4/volumes/kubernetes.io~secret/token",
+		Destination: "/var/run/secrets/token",
+	}
+	hosts := Mount{
+		Source:      "/etc/hosts",
+		Destination: "/etc/hosts",
+	}
+	data := Mount{
+		Source:      "/srv/data",
+		Destination: "/data",
+	}
+
+	mode, err := policy.Mode(secret, nil)
+	assert.NoError(err)
+	assert.Equal(SharedMountCopy, mode)
+
+	mode, err = policy.Mode(hosts, nil)
+	assert.NoError(err)
+	assert.Equal(SharedMountReadOnly, mode)
+
+	mode, err = policy.Mode(data, nil)
+	assert.NoError(err)
+	assert.Equal(SharedMountShare, mode)
+
+	mode, err = SharedMountPolicy{}.Mode(secret, nil)
+	assert.NoError(err)
+	assert.Equal(SharedMountShare, mode)
+
+	// Annotations take precedence over the policy
+	annots := map[string]string{
+		vcAnnotations.SharedMountModePrefix + "/var/run/secrets/token": "share",
+		vcAnnotations.SharedMountModePrefix + "/data":                  "copy",
+		vcAnnotations.SharedMountModePrefix + "/srv/token":             "copy",
+	}
+
+	mode, err = policy.Mode(secret, annots)
+	assert.NoError(err)
+	assert.Equal(SharedMountShare, mode)
+
+	mode, err = policy.Mode(data, annots)
+	assert.NoError(err)
+	assert.Equal(SharedMountCopy, mode)
+
+	annots[vcAnnotations.SharedMountModePrefix+"/etc/hosts"] = "foo"
+	_, err = policy.Mode(hosts, annots)
+	assert.Error(err)
+}
+
+func TestMatchMountSource(t *testing.T) {
+	assert := assert.New(t)
+
+	source := "/var/lib/kubelet/pods/366c3a75/volumes/kubernetes.io~secret/token"
+
+	assert.False(matchMountSource(source, nil))
+	assert.False(matchMountSource(source, []string{""}))
+
+	// Relative patterns match consecutive components
+	assert.True(matchMountSource(source, []string{"kubernetes.io~secret"}))
+	assert.True(matchMountSource(source, []string{"volumes/kubernetes.io~*"}))
+	assert.True(matchMountSource(source, []string{"token"}))
+	assert.False(matchMountSource(source, []string{"secret"}))
+	assert.False(matchMountSource(source, []string{"kubelet/volumes"}))
+	assert.False(matchMountSource("/srv/kubernetes.io~secret-backup", []string{"kubernetes.io~secret"}))
+
+	// Absolute patterns match the source or a parent directory
+	assert.True(matchMountSource(source, []string{"/var/lib/kubelet"}))
+	assert.True(matchMountSource(source, []string{"/var/lib/kubelet/pods/*/volumes"}))
+	assert.True(matchMountSource("/etc/hosts", []string{"/etc/*"}))
+	assert.False(matchMountSource(source, []string{"/lib/kubelet"}))
+	assert.False(matchMountSource("/etc", []string{"/etc/*"}))
+	assert.False(matchMountSource("/etcd/data", []string{"/etc"}))
+}
+
+func TestStorageSizeOption(t *testing.T) {
+	assert := assert.New(t)
+
+	size, options, err := storageSizeLimit([]string{"rbind", StorageSizeOption(4096), "ro"})
+	assert.NoError(err)
+	assert.Equal(uint64(4096), size)
+	assert.Equal([]string{"rbind", "ro"}, options)
+
+	size, options, err = storageSizeLimit(nil)
+	assert.NoError(err)
+	assert.Zero(size)
+	assert.Empty(options)
+
+	_, _, err = storageSizeLimit([]string{"size=1g"})
+	assert.Error(err)
+}
+
 // TestBindUnmountContainerRootfsENOENTNotError tests that if a file
 // or directory attempting to be unmounted doesn't exist, then it
 // is not considered an error
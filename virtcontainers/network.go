@@ -6,6 +6,7 @@
 package virtcontainers
 
 import (
+	"bufio"
 	"context"
 	cryptoRand "crypto/rand"
 	"encoding/json"
@@ -13,8 +14,10 @@ import (
 	"math/rand"
 	"net"
 	"os"
+	"path/filepath"
 	"runtime"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/containernetworking/plugins/pkg/ns"
@@ -120,6 +123,9 @@ const (
 	defaultQlen      = 1500
 )
 
+// resolvConfPath is the path of the resolv.conf mounted in the containers.
+const resolvConfPath = "/etc/resolv.conf"
+
 // DNSInfo describes the DNS setup related to a network interface.
 type DNSInfo struct {
 	Servers  []string
@@ -173,6 +179,10 @@ type NetworkConfig struct {
 	DisableNewNetNs   bool
 	NetmonConfig      NetmonConfig
 	InterworkingModel NetInterworkingModel
+
+	// VhostUserStorePath is the vhost-user store describing the vhost-user
+	// network interfaces.
+	VhostUserStorePath string
 }
 
 func networkLogger() *logrus.Entry {
@@ -1120,6 +1130,10 @@ func deleteNetNS(netNSPath string) error {
 	return nil
 }
 
+// generateInterfacesAndRoutes returns the interfaces and routes of the
+// network namespace to set up in the guest. The static neighbors (ARP and
+// NDP entries) are not sent: the agent protocol has no request for them, so
+// the guest learns them through ARP and NDP.
 func generateInterfacesAndRoutes(networkNS NetworkNamespace) ([]*vcTypes.Interface, []*vcTypes.Route, error) {
 
 	if networkNS.NetNsPath == "" {
@@ -1129,26 +1143,39 @@ func generateInterfacesAndRoutes(networkNS NetworkNamespace) ([]*vcTypes.Interfa
 	var routes []*vcTypes.Route
 	var ifaces []*vcTypes.Interface
 
+	// Destinations of the multipath routes already generated.
+	multipath := make(map[string]bool)
+
 	for _, endpoint := range networkNS.Endpoints {
 
 		var ipAddresses []*vcTypes.IPAddress
 		for _, addr := range endpoint.Properties().Addrs {
-			// Skip IPv6 because not supported
-			if addr.IP.To4() == nil {
-				// Skip IPv6 because not supported
-				networkLogger().WithFields(logrus.Fields{
-					"unsupported-address-type": "ipv6",
-					"address":                  addr,
-				}).Warn("unsupported address")
-				continue
-			}
 			// Skip localhost interface
 			if addr.IP.IsLoopback() {
 				continue
 			}
+
+			family := netlink.FAMILY_V4
+			if addr.IP.To4() == nil {
+				family = netlink.FAMILY_V6
+
+				// The guest configures the link-local address of
+				// the interface from its hardware address.
+				if addr.IP.IsLinkLocalUnicast() {
+					continue
+				}
+			}
+
+			// Addresses which failed the duplicate address detection
+			// cannot be used.
+			if addr.Flags&unix.IFA_F_DADFAILED != 0 {
+				networkLogger().WithField("address", addr).Warn("Skipping duplicate address")
+				continue
+			}
+
 			netMask, _ := addr.Mask.Size()
 			ipAddress := vcTypes.IPAddress{
-				Family:  netlink.FAMILY_V4,
+				Family:  family,
 				Address: addr.IP.String(),
 				Mask:    fmt.Sprintf("%d", netMask),
 			}
@@ -1173,28 +1200,40 @@ func generateInterfacesAndRoutes(networkNS NetworkNamespace) ([]*vcTypes.Interfa
 			if route.Dst != nil {
 				r.Dest = route.Dst.String()
 
-				if route.Dst.IP.To4() == nil {
-					// Skip IPv6 because not supported
-					networkLogger().WithFields(logrus.Fields{
-						"unsupported-route-type": "ipv6",
-						"destination":            r.Dest,
-					}).Warn("unsupported route")
+				// The guest adds the IPv6 link-local and multicast
+				// routes by itself.
+				if route.Dst.IP.To4() == nil &&
+					(route.Dst.IP.IsLinkLocalUnicast() || route.Dst.IP.IsMulticast()) {
 					continue
 				}
 			}
 
-			if route.Gw != nil {
-				gateway := route.Gw.String()
-
-				if route.Gw.To4() == nil {
-					// Skip IPv6 because is is not supported
-					networkLogger().WithFields(logrus.Fields{
-						"unsupported-route-type": "ipv6",
-						"gateway":                gateway,
-					}).Warn("unsupported route")
+			gw := route.Gw
+			if len(route.MultiPath) > 0 {
+				// The agent cannot set multipath routes up, they
+				// are replaced by a route through their heaviest
+				// nexthop, once per destination.
+				if multipath[r.Dest] {
 					continue
 				}
-				r.Gateway = gateway
+				multipath[r.Dest] = true
+
+				nexthop := route.MultiPath[0]
+				for _, nh := range route.MultiPath[1:] {
+					if nh.Hops > nexthop.Hops {
+						nexthop = nh
+					}
+				}
+				gw = nexthop.Gw
+
+				networkLogger().WithFields(logrus.Fields{
+					"destination": r.Dest,
+					"nexthops":    route.MultiPath,
+				}).Warn("Multipath route replaced by its heaviest nexthop")
+			}
+
+			if gw != nil {
+				r.Gateway = gw.String()
 			}
 
 			if route.Src != nil {
@@ -1210,6 +1249,80 @@ func generateInterfacesAndRoutes(networkNS NetworkNamespace) ([]*vcTypes.Interfa
 	return ifaces, routes, nil
 }
 
+// generateDNS returns the resolv.conf lines describing the DNS setup of the
+// sandbox, read from the resolv.conf the container engine mounts in the
+// containers.
+func generateDNS(containers []ContainerConfig) ([]string, error) {
+	for _, c := range containers {
+		for _, m := range c.Mounts {
+			if filepath.Clean(m.Destination) != resolvConfPath || m.Source == "" {
+				continue
+			}
+
+			dns, err := parseResolvConf(m.Source)
+			if err != nil {
+				return nil, err
+			}
+
+			return resolvConfLines(dns), nil
+		}
+	}
+
+	return nil, nil
+}
+
+// resolvConfLines returns the resolv.conf lines describing a DNS setup.
+func resolvConfLines(dns DNSInfo) []string {
+	var lines []string
+	for _, server := range dns.Servers {
+		lines = append(lines, "nameserver "+server)
+	}
+	if dns.Domain != "" {
+		lines = append(lines, "domain "+dns.Domain)
+	}
+	if len(dns.Searches) > 0 {
+		lines = append(lines, "search "+strings.Join(dns.Searches, " "))
+	}
+	if len(dns.Options) > 0 {
+		lines = append(lines, "options "+strings.Join(dns.Options, " "))
+	}
+
+	return lines
+}
+
+// parseResolvConf returns the DNS setup described by a resolv.conf file.
+func parseResolvConf(path string) (DNSInfo, error) {
+	var dns DNSInfo
+
+	f, err := os.Open(path)
+	if err != nil {
+		return dns, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], ";") {
+			continue
+		}
+
+		switch fields[0] {
+		case "nameserver":
+			dns.Servers = append(dns.Servers, fields[1])
+		case "domain":
+			dns.Domain = fields[1]
+		case "search":
+			// The last search line wins
+			dns.Searches = fields[1:]
+		case "options":
+			dns.Options = append(dns.Options, fields[1:]...)
+		}
+	}
+
+	return dns, scanner.Err()
+}
+
 func createNetworkInterfacePair(idx int, ifName string, interworkingModel NetInterworkingModel) (NetworkInterfacePair, error) {
 	uniqueID := uuid.Generate().String()
 
@@ -1263,6 +1376,27 @@ func networkInfoFromLink(handle *netlink.Handle, link netlink.Link) (NetworkInfo
 		return NetworkInfo{}, err
 	}
 
+	// Multipath routes are not bound to a link, they are listed with the
+	// links of their nexthops.
+	allRoutes, err := handle.RouteList(nil, netlink.FAMILY_ALL)
+	if err != nil {
+		return NetworkInfo{}, err
+	}
+	for _, route := range allRoutes {
+		var nexthops []*netlink.NexthopInfo
+		for _, nh := range route.MultiPath {
+			if nh.LinkIndex == link.Attrs().Index {
+				nexthops = append(nexthops, nh)
+			}
+		}
+
+		if len(nexthops) > 0 {
+			route.LinkIndex = link.Attrs().Index
+			route.MultiPath = nexthops
+			routes = append(routes, route)
+		}
+	}
+
 	return NetworkInfo{
 		Iface: NetlinkIface{
 			LinkAttrs: *(link.Attrs()),
@@ -1319,7 +1453,7 @@ func createEndpointsFromScan(networkNSPath string, config *NetworkConfig) ([]End
 		}
 
 		if err := doNetNS(networkNSPath, func(_ ns.NetNS) error {
-			endpoint, errCreate = createEndpoint(netInfo, idx, config.InterworkingModel)
+			endpoint, errCreate = createEndpoint(netInfo, idx, networkNSPath, config)
 			return errCreate
 		}); err != nil {
 			return []Endpoint{}, err
@@ -1340,7 +1474,7 @@ func createEndpointsFromScan(networkNSPath string, config *NetworkConfig) ([]End
 	return endpoints, nil
 }
 
-func createEndpoint(netInfo NetworkInfo, idx int, model NetInterworkingModel) (Endpoint, error) {
+func createEndpoint(netInfo NetworkInfo, idx int, netNSPath string, config *NetworkConfig) (Endpoint, error) {
 	var endpoint Endpoint
 	// TODO: This is the incoming interface
 	// based on the incoming interface we should create
@@ -1358,20 +1492,20 @@ func createEndpoint(netInfo NetworkInfo, idx int, model NetInterworkingModel) (E
 		networkLogger().WithField("interface", netInfo.Iface.Name).Info("Physical network interface found")
 		endpoint, err = createPhysicalEndpoint(netInfo)
 	} else {
-		var socketPath string
+		var vhostUser *vhostUserNetInfo
 
-		// Check if this is a dummy interface which has a vhost-user socket associated with it
-		socketPath, err = vhostUserSocketPath(netInfo)
+		// Check if this is a placeholder interface which has a vhost-user socket associated with it
+		vhostUser, err = findVhostUserNet(netInfo, netNSPath, config.VhostUserStorePath)
 		if err != nil {
 			return nil, err
 		}
 
-		if socketPath != "" {
+		if vhostUser != nil {
 			networkLogger().WithField("interface", netInfo.Iface.Name).Info("VhostUser network interface found")
-			endpoint, err = createVhostUserEndpoint(netInfo, socketPath)
+			endpoint, err = createVhostUserEndpoint(netInfo, vhostUser)
 		} else if netInfo.Iface.Type == "macvlan" {
 			networkLogger().Infof("macvlan interface found")
-			endpoint, err = createBridgedMacvlanNetworkEndpoint(idx, netInfo.Iface.Name, model)
+			endpoint, err = createBridgedMacvlanNetworkEndpoint(idx, netInfo.Iface.Name, config.InterworkingModel)
 		} else if netInfo.Iface.Type == "macvtap" {
 			networkLogger().Infof("macvtap interface found")
 			endpoint, err = createMacvtapNetworkEndpoint(netInfo)
@@ -1379,7 +1513,7 @@ func createEndpoint(netInfo NetworkInfo, idx int, model NetInterworkingModel) (E
 			networkLogger().Info("tap interface found")
 			endpoint, err = createTapNetworkEndpoint(idx, netInfo.Iface.Name)
 		} else if netInfo.Iface.Type == "veth" {
-			endpoint, err = createVethNetworkEndpoint(idx, netInfo.Iface.Name, model)
+			endpoint, err = createVethNetworkEndpoint(idx, netInfo.Iface.Name, config.InterworkingModel)
 		} else if netInfo.Iface.Type == "ipvlan" {
 			endpoint, err = createIPVlanNetworkEndpoint(idx, netInfo.Iface.Name)
 		} else {
@@ -6,15 +6,19 @@
 package virtcontainers
 
 import (
+	"io/ioutil"
 	"net"
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
+	"github.com/containernetworking/plugins/pkg/ns"
 	ktu "github.com/kata-containers/runtime/pkg/katatestutils"
 	vcTypes "github.com/kata-containers/runtime/virtcontainers/pkg/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
 )
 
 func TestCreateDeleteNetNS(t *testing.T) {
@@ -110,6 +114,287 @@ func TestGenerateInterfacesAndRoutes(t *testing.T) {
 
 }
 
+func TestGenerateInterfacesAndRoutesDualStack(t *testing.T) {
+	assert := assert.New(t)
+
+	_, prefix4, _ := net.ParseCIDR("10.0.0.0/24")
+	_, prefix6, _ := net.ParseCIDR("2001:db8::/64")
+	_, linkLocal, _ := net.ParseCIDR("fe80::/64")
+	_, multicast, _ := net.ParseCIDR("ff00::/8")
+	_, remote6, _ := net.ParseCIDR("2001:db8:1::/64")
+
+	addrs := []netlink.Addr{
+		{IPNet: &net.IPNet{IP: net.ParseIP("10.0.0.2"), Mask: net.CIDRMask(24, 32)}},
+		{IPNet: &net.IPNet{IP: net.ParseIP("2001:db8::2"), Mask: net.CIDRMask(64, 128)}, Flags: unix.IFA_F_NODAD},
+		{IPNet: &net.IPNet{IP: net.ParseIP("2001:db8::3"), Mask: net.CIDRMask(64, 128)}, Flags: unix.IFA_F_DADFAILED},
+		{IPNet: &net.IPNet{IP: net.ParseIP("fe80::ff:fe00:1"), Mask: net.CIDRMask(64, 128)}},
+	}
+
+	routes := []netlink.Route{
+		{Dst: prefix4, Src: net.ParseIP("10.0.0.2"), Scope: netlink.SCOPE_LINK},
+		{Gw: net.ParseIP("10.0.0.1")},
+		{Dst: prefix6},
+		{Dst: linkLocal},
+		{Dst: multicast},
+		{Gw: net.ParseIP("2001:db8::1")},
+		{Dst: remote6, MultiPath: []*netlink.NexthopInfo{
+			{Gw: net.ParseIP("2001:db8::10")},
+			{Gw: net.ParseIP("2001:db8::11"), Hops: 1},
+		}},
+	}
+
+	ep0 := &PhysicalEndpoint{
+		IfaceName: "eth0",
+		HardAddr:  net.HardwareAddr{0x02, 0x00, 0xca, 0xfe, 0x00, 0x04}.String(),
+		EndpointProperties: NetworkInfo{
+			Iface:  NetlinkIface{LinkAttrs: netlink.LinkAttrs{MTU: 1500}},
+			Addrs:  addrs,
+			Routes: routes,
+		},
+	}
+
+	// The same multipath route through a second interface
+	ep1 := &PhysicalEndpoint{
+		IfaceName: "eth1",
+		HardAddr:  net.HardwareAddr{0x02, 0x00, 0xca, 0xfe, 0x00, 0x05}.String(),
+		EndpointProperties: NetworkInfo{
+			Iface: NetlinkIface{LinkAttrs: netlink.LinkAttrs{MTU: 1500}},
+			Routes: []netlink.Route{
+				{Dst: remote6, MultiPath: []*netlink.NexthopInfo{
+					{Gw: net.ParseIP("2001:db8:2::10")},
+				}},
+			},
+		},
+	}
+
+	nns := NetworkNamespace{NetNsPath: "foobar", Endpoints: []Endpoint{ep0, ep1}}
+
+	resInterfaces, resRoutes, err := generateInterfacesAndRoutes(nns)
+	assert.NoError(err)
+
+	expectedInterfaces := []*vcTypes.Interface{
+		{
+			Device: "eth0",
+			Name:   "eth0",
+			IPAddresses: []*vcTypes.IPAddress{
+				{Family: netlink.FAMILY_V4, Address: "10.0.0.2", Mask: "24"},
+				{Family: netlink.FAMILY_V6, Address: "2001:db8::2", Mask: "64"},
+			},
+			Mtu:    1500,
+			HwAddr: "02:00:ca:fe:00:04",
+		},
+		{
+			Device: "eth1",
+			Name:   "eth1",
+			Mtu:    1500,
+			HwAddr: "02:00:ca:fe:00:05",
+		},
+	}
+
+	expectedRoutes := []*vcTypes.Route{
+		{Dest: "10.0.0.0/24", Device: "eth0", Source: "10.0.0.2", Scope: uint32(netlink.SCOPE_LINK)},
+		{Gateway: "10.0.0.1", Device: "eth0"},
+		{Dest: "2001:db8::/64", Device: "eth0"},
+		{Gateway: "2001:db8::1", Device: "eth0"},
+		{Dest: "2001:db8:1::/64", Gateway: "2001:db8::11", Device: "eth0"},
+	}
+
+	assert.Equal(expectedInterfaces, resInterfaces)
+	assert.Equal(expectedRoutes, resRoutes)
+}
+
+func TestParseResolvConf(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir(testDir, "resolv-")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "resolv.conf")
+	_, err = parseResolvConf(path)
+	assert.True(os.IsNotExist(err))
+
+	content := `# comment
+nameserver 10.96.0.10
+nameserver fd00::10
+domain example.com
+search foo.svc.cluster.local svc.cluster.local
+search default.svc.cluster.local cluster.local
+options ndots:5
+options timeout:2 attempts:3
+; comment
+`
+	assert.NoError(ioutil.WriteFile(path, []byte(content), defaultFilePerms))
+
+	dns, err := parseResolvConf(path)
+	assert.NoError(err)
+	assert.Equal(DNSInfo{
+		Servers:  []string{"10.96.0.10", "fd00::10"},
+		Domain:   "example.com",
+		Searches: []string{"default.svc.cluster.local", "cluster.local"},
+		Options:  []string{"ndots:5", "timeout:2", "attempts:3"},
+	}, dns)
+
+}
+
+func TestGenerateDNS(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir(testDir, "resolv-")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "resolv.conf")
+	content := "nameserver 10.96.0.10\nnameserver fd00::10\nsearch default.svc.cluster.local cluster.local\noptions ndots:5\n"
+	assert.NoError(ioutil.WriteFile(path, []byte(content), defaultFilePerms))
+
+	containers := []ContainerConfig{
+		{
+			Mounts: []Mount{
+				{Source: "/dev/shm", Destination: "/dev/shm"},
+			},
+		},
+	}
+
+	// No resolv.conf mounted
+	dns, err := generateDNS(containers)
+	assert.NoError(err)
+	assert.Empty(dns)
+
+	containers = append(containers, ContainerConfig{
+		Mounts: []Mount{
+			{Source: path, Destination: "/etc/resolv.conf"},
+		},
+	})
+
+	dns, err = generateDNS(containers)
+	assert.NoError(err)
+	assert.Equal([]string{
+		"nameserver 10.96.0.10",
+		"nameserver fd00::10",
+		"search default.svc.cluster.local cluster.local",
+		"options ndots:5",
+	}, dns)
+
+	// Missing resolv.conf
+	containers[1].Mounts[0].Source = filepath.Join(dir, "missing")
+	_, err = generateDNS(containers)
+	assert.Error(err)
+}
+
+// testDualStackNetNS creates a network namespace holding a veth pair, eth0 and
+// its peer, configured with IPv4 and IPv6 addresses and routes.
+func testDualStackNetNS(t *testing.T) string {
+	assert := assert.New(t)
+
+	netNSPath, err := createNetNS()
+	assert.NoError(err)
+
+	err = doNetNS(netNSPath, func(_ ns.NetNS) error {
+		veth := &netlink.Veth{
+			LinkAttrs: netlink.LinkAttrs{
+				Name:         "eth0",
+				HardwareAddr: net.HardwareAddr{0x02, 0x00, 0xca, 0xfe, 0x00, 0x04},
+			},
+			PeerName: "eth0peer",
+		}
+		if err := netlink.LinkAdd(veth); err != nil {
+			return err
+		}
+
+		// The peer stays unconfigured, without even a link-local
+		// address.
+		if err := ioutil.WriteFile("/proc/sys/net/ipv6/conf/eth0peer/disable_ipv6", []byte("1"), 0644); err != nil {
+			return err
+		}
+
+		for _, name := range []string{"eth0peer", "eth0"} {
+			link, err := netlink.LinkByName(name)
+			if err != nil {
+				return err
+			}
+			if err := netlink.LinkSetUp(link); err != nil {
+				return err
+			}
+		}
+
+		link, err := netlink.LinkByName("eth0")
+		if err != nil {
+			return err
+		}
+		index := link.Attrs().Index
+
+		for _, a := range []string{"10.0.0.2/24", "2001:db8::2/64"} {
+			addr, err := netlink.ParseAddr(a)
+			if err != nil {
+				return err
+			}
+			addr.Flags = unix.IFA_F_NODAD
+			if err := netlink.AddrAdd(link, addr); err != nil {
+				return err
+			}
+		}
+
+		_, remote6, _ := net.ParseCIDR("2001:db8:1::/64")
+		for _, r := range []netlink.Route{
+			{LinkIndex: index, Gw: net.ParseIP("10.0.0.1")},
+			{LinkIndex: index, Gw: net.ParseIP("2001:db8::1")},
+			{Dst: remote6, MultiPath: []*netlink.NexthopInfo{
+				{LinkIndex: index, Gw: net.ParseIP("2001:db8::10")},
+				{LinkIndex: index, Gw: net.ParseIP("2001:db8::11")},
+			}},
+		} {
+			route := r
+			if err := netlink.RouteAdd(&route); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	assert.NoError(err)
+
+	return netNSPath
+}
+
+func TestCreateEndpointsFromScanDualStack(t *testing.T) {
+	if tc.NotValid(ktu.NeedRoot()) {
+		t.Skip(testDisabledAsNonRoot)
+	}
+
+	assert := assert.New(t)
+
+	netNSPath := testDualStackNetNS(t)
+	defer deleteNetNS(netNSPath)
+
+	endpoints, err := createEndpointsFromScan(netNSPath, &NetworkConfig{InterworkingModel: NetXConnectTCFilterModel})
+	assert.NoError(err)
+
+	// The peer has no address
+	assert.Len(endpoints, 1)
+
+	nns := NetworkNamespace{NetNsPath: netNSPath, Endpoints: endpoints}
+
+	ifaces, routes, err := generateInterfacesAndRoutes(nns)
+	assert.NoError(err)
+	assert.Len(ifaces, 1)
+	assert.Equal([]*vcTypes.IPAddress{
+		{Family: netlink.FAMILY_V4, Address: "10.0.0.2", Mask: "24"},
+		{Family: netlink.FAMILY_V6, Address: "2001:db8::2", Mask: "64"},
+	}, ifaces[0].IPAddresses)
+
+	var resRoutes []string
+	for _, r := range routes {
+		assert.Equal("eth0", r.Device)
+		resRoutes = append(resRoutes, r.Dest+" via "+r.Gateway)
+	}
+	assert.Contains(resRoutes, " via 10.0.0.1")
+	assert.Contains(resRoutes, " via 2001:db8::1")
+	assert.Contains(resRoutes, "2001:db8::/64 via ")
+	assert.Contains(resRoutes, "2001:db8:1::/64 via 2001:db8::10")
+	assert.NotContains(resRoutes, "fe80::/64 via ")
+}
+
 func TestNetInterworkingModelIsValid(t *testing.T) {
 	tests := []struct {
 		name string
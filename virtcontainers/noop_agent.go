@@ -218,6 +218,11 @@ func (n *noopAgent) getGuestDetails(*grpc.GuestDetailsRequest) (*grpc.GuestDetai
 	return nil, nil
 }
 
+// debugConsoleURL is the Noop agent debug console URL getter. It returns nothing.
+func (n *noopAgent) debugConsoleURL(sandbox *Sandbox) (string, error) {
+	return "", nil
+}
+
 // setGuestDateTime is the Noop agent guest time setter. It does nothing.
 func (n *noopAgent) setGuestDateTime(time.Time) error {
 	return nil
@@ -34,6 +34,8 @@ func (s *Sandbox) dumpState(ss *persistapi.SandboxState, cs map[string]persistap
 	ss.GuestMemoryHotplugProbe = s.state.GuestMemoryHotplugProbe
 	ss.State = string(s.state.State)
 	ss.CgroupPath = s.state.CgroupPath
+	ss.BaseVCPUs = s.state.BaseVCPUs
+	ss.BaseMemoryMB = s.state.BaseMemoryMB
 
 	for id, cont := range s.containers {
 		state := persistapi.ContainerState{}
@@ -179,6 +181,8 @@ func (s *Sandbox) loadState(ss persistapi.SandboxState) {
 	s.state.State = types.StateString(ss.State)
 	s.state.CgroupPath = ss.CgroupPath
 	s.state.GuestMemoryHotplugProbe = ss.GuestMemoryHotplugProbe
+	s.state.BaseVCPUs = ss.BaseVCPUs
+	s.state.BaseMemoryMB = ss.BaseMemoryMB
 }
 
 func (c *Container) loadContState(cs persistapi.ContainerState) {
@@ -33,6 +33,11 @@ type HypervisorConfig struct {
 	// Bridges can be used to hot plug devices
 	DefaultBridges uint32
 
+	// PCIeRootPort specifies the number of PCIe root ports to cold plug
+	// in the VM. Devices are hotplugged on a free root port instead of a
+	// bridge when available. Only valid for the q35 machine type.
+	PCIeRootPort uint32
+
 	// Msize9p is used as the msize for 9p shares
 	Msize9p uint32
 
@@ -143,6 +148,30 @@ type HypervisorConfig struct {
 	// root bus instead of a bridge.
 	HotplugVFIOOnRootBus bool
 
+	// VCPUPinning pins each vCPU thread to one CPU of the containers cpuset,
+	// and moves the guest memory to the matching host NUMA nodes.
+	VCPUPinning bool
+
+	// MemoryBalloon adds a virtio-balloon device to the VM, inflated to
+	// give memory back to the host when the sandbox memory shrinks.
+	MemoryBalloon bool
+
+	// FreePageReporting makes the guest report its free pages
+	// through the virtio-balloon device, returning them to the host.
+	FreePageReporting bool
+
+	// CustomAssetDirs lists the directories custom assets, requested
+	// through sandbox annotations, can be picked from.
+	CustomAssetDirs []string
+
+	// CustomAssetManifest is the path of a signed manifest listing the
+	// hashes of the approved custom assets.
+	CustomAssetManifest string
+
+	// CustomAssetManifestKey is the path of the public key verifying the
+	// custom asset manifest signature.
+	CustomAssetManifestKey string
+
 	// BootToBeTemplate used to indicate if the VM is created to be a template VM
 	BootToBeTemplate bool
 
@@ -53,6 +53,9 @@ type VFIODev struct {
 
 	// Sysfsdev of VFIO mediated device
 	SysfsDev string
+
+	// PCIAddr is the guest PCI address of the device
+	PCIAddr string
 }
 
 // VhostUserDeviceAttrs represents data shared by most vhost-user devices
@@ -63,6 +66,9 @@ type VhostUserDeviceAttrs struct {
 
 	// MacAddress is only meaningful for vhost user net device
 	MacAddress string
+
+	// PCIAddr is the guest PCI address of a hotplugged vhost-user device
+	PCIAddr string
 }
 
 // DeviceState is sandbox level resource which represents host devices
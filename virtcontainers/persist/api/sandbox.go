@@ -27,6 +27,18 @@ type Bridge struct {
 	Addr int
 }
 
+// PCIeRootPort is a PCIe root port where a single device can be hot plugged
+type PCIeRootPort struct {
+	// DeviceID is the ID of the device plugged in the root port
+	DeviceID string
+
+	// ID is used to identify the root port in the hypervisor
+	ID string
+
+	// Addr is the PCIe slot of the root port
+	Addr int
+}
+
 // CPUDevice represents a CPU device which was hot-added in a running VM
 type CPUDevice struct {
 	// ID is used to identify this CPU in the hypervisor options.
@@ -38,6 +50,8 @@ type CPUDevice struct {
 type HypervisorState struct {
 	Pid     int
 	Bridges []Bridge
+	// PCIeRootPorts is the list of cold plugged PCIe root ports
+	PCIeRootPorts []PCIeRootPort
 	// HotpluggedCPUs is the list of CPUs that were hot-added
 	HotpluggedVCPUs      []CPUDevice
 	HotpluggedMemory     int
@@ -70,6 +84,12 @@ type SandboxState struct {
 	// GuestMemoryHotplugProbe determines whether guest kernel supports memory hotplug probe interface
 	GuestMemoryHotplugProbe bool
 
+	// BaseVCPUs is the number of vCPUs reserved for the sandbox itself
+	BaseVCPUs uint32
+
+	// BaseMemoryMB is the memory reserved for the sandbox itself
+	BaseMemoryMB uint32
+
 	// SandboxContainer specifies which container is used to start the sandbox/vm
 	SandboxContainer string
 
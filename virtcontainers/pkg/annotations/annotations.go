@@ -23,24 +23,37 @@ const (
 	// FirmwarePath is a sandbox annotation for passing a per container path pointing at the guest firmware that will run the container VM.
 	FirmwarePath = vcAnnotationsPrefix + "FirmwarePath"
 
-	// KernelHash is a sandbox annotation for passing a container kernel image SHA-512 hash value.
+	// KernelHash is a sandbox annotation for passing a container kernel image hash value.
 	KernelHash = vcAnnotationsPrefix + "KernelHash"
 
-	// ImageHash is an sandbox annotation for passing a container guest image SHA-512 hash value.
+	// ImageHash is an sandbox annotation for passing a container guest image hash value.
 	ImageHash = vcAnnotationsPrefix + "ImageHash"
 
-	// InitrdHash is an sandbox annotation for passing a container guest initrd SHA-512 hash value.
+	// InitrdHash is an sandbox annotation for passing a container guest initrd hash value.
 	InitrdHash = vcAnnotationsPrefix + "InitrdHash"
 
-	// HypervisorHash is an sandbox annotation for passing a container hypervisor binary SHA-512 hash value.
+	// HypervisorHash is an sandbox annotation for passing a container hypervisor binary hash value.
 	HypervisorHash = vcAnnotationsPrefix + "HypervisorHash"
 
-	// FirmwareHash is an sandbox annotation for passing a container guest firmware SHA-512 hash value.
+	// FirmwareHash is an sandbox annotation for passing a container guest firmware hash value.
 	FirmwareHash = vcAnnotationsPrefix + "FirmwareHash"
 
-	// AssetHashType is the hash type used for assets verification
+	// AssetHashType is the hash type used for assets verification, "sha512" (default) or "sha256"
 	AssetHashType = vcAnnotationsPrefix + "AssetHashType"
 
+	// StorageSizeLimitPrefix is the prefix of the container annotations setting the size limit of a k8s empty-dir
+	// volume, "StorageSizeLimit.<volume name>", to a size such as "512Mi".
+	StorageSizeLimitPrefix = vcAnnotationsPrefix + "StorageSizeLimit."
+
+	// SharedMountModePrefix is the prefix of the container annotations setting how a bind mount is provided
+	// to the guest, "SharedMountMode.<mount destination path>", to "share", "readonly" or "copy".
+	SharedMountModePrefix = vcAnnotationsPrefix + "SharedMountMode."
+
+	// TraceContext is a sandbox annotation for passing the trace context of
+	// the caller, in the Jaeger "uber-trace-id" format, so that the runtime
+	// spans are part of the caller trace.
+	TraceContext = vcAnnotationsPrefix + "TraceContext"
+
 	// ConfigJSONKey is the annotation key to fetch the OCI configuration.
 	ConfigJSONKey = vcAnnotationsPrefix + "pkg.oci.config"
 
@@ -49,9 +62,21 @@ const (
 
 	// ContainerTypeKey is the annotation key to fetch container type.
 	ContainerTypeKey = vcAnnotationsPrefix + "pkg.oci.container_type"
+
+	// SandboxIDKey is the OCI hook state annotation key holding the ID of the sandbox the container belongs to.
+	SandboxIDKey = vcAnnotationsPrefix + "pkg.hook.sandbox_id"
+
+	// NetNsPathKey is the OCI hook state annotation key holding the path of the sandbox network namespace.
+	NetNsPathKey = vcAnnotationsPrefix + "pkg.hook.netns_path"
+
+	// HypervisorPidKey is the OCI hook state annotation key holding the PID of the hypervisor running the sandbox VM.
+	HypervisorPidKey = vcAnnotationsPrefix + "pkg.hook.hypervisor_pid"
 )
 
 const (
+	// SHA256 is the SHA-256 (32) hash algorithm
+	SHA256 string = "sha256"
+
 	// SHA512 is the SHA-512 (64) hash algorithm
 	SHA512 string = "sha512"
 )
@@ -15,6 +15,7 @@ import (
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	criContainerdAnnotations "github.com/containerd/cri-containerd/pkg/annotations"
 	crioAnnotations "github.com/cri-o/cri-o/pkg/annotations"
@@ -59,6 +60,9 @@ var (
 )
 
 const (
+	// StateCreating represents a container that is being created.
+	StateCreating = "creating"
+
 	// StateCreated represents a container that has been created and is
 	// ready to be run.
 	StateCreated = "created"
@@ -83,6 +87,19 @@ type CompatOCIProcess struct {
 	Capabilities interface{} `json:"capabilities,omitempty" platform:"linux"` //nolint:govet
 }
 
+// CompatOCIHooks holds the createRuntime, createContainer and
+// startContainer hook points introduced by v1.0.2 of the runtime
+// specification, which the vendored runtime-spec package does not know
+// about yet. The other hooks are found in spec.Spec.Hooks.
+// startContainer hooks are not supported: they must run in the container
+// namespaces, inside the VM, and the agent does not run OCI hooks.
+// Refer to: https://github.com/opencontainers/runtime-spec/pull/1008
+type CompatOCIHooks struct {
+	CreateRuntime   []spec.Hook `json:"createRuntime,omitempty"`
+	CreateContainer []spec.Hook `json:"createContainer,omitempty"`
+	StartContainer  []spec.Hook `json:"startContainer,omitempty"`
+}
+
 // CompatOCISpec is a structure inheriting from spec.Spec defined
 // in runtime-spec/specs-go package. It relies on the CompatOCIProcess
 // structure declared above, in order to be compatible with both
@@ -91,6 +108,65 @@ type CompatOCIProcess struct {
 type CompatOCISpec struct {
 	spec.Spec
 	Process *CompatOCIProcess `json:"process,omitempty"` //nolint:govet
+
+	// CompatHooks holds the hook points spec.Spec.Hooks does not know
+	// about. They are encoded in the same "hooks" object.
+	CompatHooks *CompatOCIHooks `json:"-"`
+}
+
+// compatOCISpec has the fields of CompatOCISpec without its JSON methods.
+type compatOCISpec CompatOCISpec
+
+// compatOCISpecJSON is the JSON encoding of CompatOCISpec, merging the
+// standard hooks and the compat ones in the same "hooks" object.
+type compatOCISpecJSON struct {
+	compatOCISpec
+	Hooks *compatOCIHooksJSON `json:"hooks,omitempty"`
+}
+
+type compatOCIHooksJSON struct {
+	spec.Hooks
+	CompatOCIHooks
+}
+
+// UnmarshalJSON decodes the "hooks" object into both spec.Spec.Hooks and
+// CompatHooks.
+func (spec *CompatOCISpec) UnmarshalJSON(data []byte) error {
+	var s compatOCISpecJSON
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	*spec = CompatOCISpec(s.compatOCISpec)
+	spec.Spec.Hooks = nil
+	spec.CompatHooks = nil
+
+	if s.Hooks != nil {
+		spec.Spec.Hooks = &s.Hooks.Hooks
+		spec.CompatHooks = &s.Hooks.CompatOCIHooks
+	}
+
+	return nil
+}
+
+// MarshalJSON encodes spec.Spec.Hooks and CompatHooks in the same "hooks"
+// object.
+func (spec CompatOCISpec) MarshalJSON() ([]byte, error) {
+	s := compatOCISpecJSON{
+		compatOCISpec: compatOCISpec(spec),
+	}
+
+	if spec.Spec.Hooks != nil || spec.CompatHooks != nil {
+		s.Hooks = &compatOCIHooksJSON{}
+		if spec.Spec.Hooks != nil {
+			s.Hooks.Hooks = *spec.Spec.Hooks
+		}
+		if spec.CompatHooks != nil {
+			s.Hooks.CompatOCIHooks = *spec.CompatHooks
+		}
+	}
+
+	return json.Marshal(s)
 }
 
 // FactoryConfig is a structure to set the VM factory configuration.
@@ -108,6 +184,25 @@ type FactoryConfig struct {
 	VMCacheEndpoint string
 }
 
+// TraceConfig is a structure to set the tracer configuration.
+type TraceConfig struct {
+	// SamplerType is the sampler type: const, probabilistic,
+	// ratelimiting or remote.
+	SamplerType string
+
+	// SamplerParam is the sampler parameter, its meaning depends on
+	// the sampler type.
+	SamplerParam float64
+
+	// CollectorEndpoint is the URL of a Jaeger collector spans are sent
+	// to over HTTP. Takes precedence over AgentEndpoint.
+	CollectorEndpoint string
+
+	// AgentEndpoint is the host:port address of a Jaeger agent spans are
+	// sent to over UDP.
+	AgentEndpoint string
+}
+
 // RuntimeConfig aggregates all runtime specific settings
 type RuntimeConfig struct {
 	HypervisorType   vc.HypervisorType
@@ -132,6 +227,7 @@ type RuntimeConfig struct {
 	FactoryConfig     FactoryConfig
 	Debug             bool
 	Trace             bool
+	TraceConfig       TraceConfig
 
 	//Determines if seccomp should be applied inside guest
 	DisableGuestSeccomp bool
@@ -139,6 +235,17 @@ type RuntimeConfig struct {
 	//Determines if create a netns for hypervisor process
 	DisableNewNetNs bool
 
+	//Determines which container bind mounts are copied into the guest, or
+	//shared read-only with it
+	SharedMountPolicy vc.SharedMountPolicy
+
+	//Determines how often the guest clock is synced with the host one
+	GuestClockSyncInterval time.Duration
+
+	//Determines how long to wait for the sandbox lock held by another
+	//runtime process
+	LockTimeout time.Duration
+
 	//Experimental features enabled
 	Experimental []exp.Feature
 }
@@ -347,6 +454,7 @@ func networkConfig(ocispec CompatOCISpec, config RuntimeConfig) (vc.NetworkConfi
 	}
 	netConf.InterworkingModel = config.InterNetworkModel
 	netConf.DisableNewNetNs = config.DisableNewNetNs
+	netConf.VhostUserStorePath = config.HypervisorConfig.VhostUserStorePath
 
 	netConf.NetmonConfig = vc.NetmonConfig{
 		Path:   config.NetmonConfig.Path,
@@ -508,6 +616,10 @@ func SandboxConfig(ocispec CompatOCISpec, runtime RuntimeConfig, bundlePath, cid
 
 		DisableGuestSeccomp: runtime.DisableGuestSeccomp,
 
+		SharedMountPolicy: runtime.SharedMountPolicy,
+
+		GuestClockSyncInterval: runtime.GuestClockSyncInterval,
+
 		Experimental: runtime.Experimental,
 	}
 
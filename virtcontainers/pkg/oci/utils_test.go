@@ -98,7 +98,7 @@ func TestMinimalSandboxConfig(t *testing.T) {
 	savedFunc := config.GetHostPathFunc
 
 	// Simply assign container path to host path for device.
-	config.GetHostPathFunc = func(devInfo config.DeviceInfo) (string, error) {
+	config.GetHostPathFunc = func(devInfo config.DeviceInfo, vhostUserStoreEnabled bool, vhostUserStorePath string) (string, error) {
 		return devInfo.ContainerPath, nil
 	}
 
@@ -776,6 +776,45 @@ func TestCompatOCISpecWithStruct(t *testing.T) {
 	assert.Nil(t, err, "This test should not fail")
 }
 
+func TestCompatOCISpecHooks(t *testing.T) {
+	assert := assert.New(t)
+
+	status := vc.ContainerStatus{
+		Annotations: map[string]string{
+			vcAnnotations.ConfigJSONKey: `{"hooks":{"prestart":[{"path":"/bin/true"}],"createRuntime":[{"path":"/bin/false"}]}}`,
+		},
+	}
+
+	ociSpec, err := GetOCIConfig(status)
+	assert.NoError(err)
+	assert.NotNil(ociSpec.Spec.Hooks)
+	assert.Equal([]specs.Hook{{Path: "/bin/true"}}, ociSpec.Spec.Hooks.Prestart)
+	assert.NotNil(ociSpec.CompatHooks)
+	assert.Equal([]specs.Hook{{Path: "/bin/false"}}, ociSpec.CompatHooks.CreateRuntime)
+
+	// Hooks set on spec.Spec.Hooks are kept along with the compat ones
+	ociSpec.Spec.Hooks.Poststop = []specs.Hook{{Path: "/bin/echo"}}
+
+	ociSpecJSON, err := json.Marshal(ociSpec)
+	assert.NoError(err)
+
+	var decoded CompatOCISpec
+	assert.NoError(json.Unmarshal(ociSpecJSON, &decoded))
+	assert.Equal(ociSpec.Spec.Hooks, decoded.Spec.Hooks)
+	assert.Equal(ociSpec.CompatHooks, decoded.CompatHooks)
+
+	// The standard runtime-spec structure decodes the standard hooks
+	var standard specs.Spec
+	assert.NoError(json.Unmarshal(ociSpecJSON, &standard))
+	assert.Equal(ociSpec.Spec.Hooks, standard.Hooks)
+
+	status.Annotations[vcAnnotations.ConfigJSONKey] = `{}`
+	ociSpec, err = GetOCIConfig(status)
+	assert.NoError(err)
+	assert.Nil(ociSpec.Spec.Hooks)
+	assert.Nil(ociSpec.CompatHooks)
+}
+
 func TestGetShmSize(t *testing.T) {
 	containerConfig := vc.ContainerConfig{
 		Mounts: []vc.Mount{},
@@ -7,6 +7,9 @@ package types
 
 import (
 	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // common error objects used for argument checking
@@ -18,3 +21,31 @@ var (
 	ErrNoSuchContainer   = errors.New("Container does not exist")
 	ErrInvalidConfigType = errors.New("Invalid config type")
 )
+
+// AgentError is returned when a request to the agent fails. Its code tells
+// why the request failed, for example codes.DeadlineExceeded if the agent did
+// not answer in time, or codes.Unavailable if it could not be reached.
+type AgentError struct {
+	// Request is the name of the failed request
+	Request string
+
+	// Code is the gRPC code of the failure
+	Code codes.Code
+
+	// Err is the original error
+	Err error
+}
+
+func (e *AgentError) Error() string {
+	return e.Err.Error()
+}
+
+// GRPCStatus returns the gRPC status of the failure, so that the error can
+// be forwarded to gRPC clients as is.
+func (e *AgentError) GRPCStatus() *status.Status {
+	if s, ok := status.FromError(e.Err); ok {
+		return s
+	}
+
+	return status.New(e.Code, e.Error())
+}
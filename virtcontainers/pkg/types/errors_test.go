@@ -0,0 +1,40 @@
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package types
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAgentError(t *testing.T) {
+	assert := assert.New(t)
+
+	err := &AgentError{
+		Request: "grpc.CheckRequest",
+		Code:    codes.Unavailable,
+		Err:     errors.New("connection refused"),
+	}
+
+	assert.Equal("connection refused", err.Error())
+
+	s, ok := status.FromError(err)
+	assert.True(ok)
+	assert.Equal(codes.Unavailable, s.Code())
+	assert.Equal("connection refused", s.Message())
+
+	// The status of a gRPC error is kept.
+	err.Err = status.Error(codes.DeadlineExceeded, "context deadline exceeded")
+
+	s, ok = status.FromError(err)
+	assert.True(ok)
+	assert.Equal(codes.DeadlineExceeded, s.Code())
+	assert.Equal("context deadline exceeded", s.Message())
+}
@@ -19,11 +19,13 @@ import (
 	"context"
 	"fmt"
 	"syscall"
+	"time"
 
 	vc "github.com/kata-containers/runtime/virtcontainers"
 	"github.com/kata-containers/runtime/virtcontainers/device/api"
 	"github.com/kata-containers/runtime/virtcontainers/device/config"
 	vcTypes "github.com/kata-containers/runtime/virtcontainers/pkg/types"
+	"github.com/kata-containers/runtime/virtcontainers/store"
 	"github.com/kata-containers/runtime/virtcontainers/types"
 	specs "github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/sirupsen/logrus"
@@ -47,6 +49,13 @@ func (m *VCMock) SetFactory(ctx context.Context, factory vc.Factory) {
 	}
 }
 
+// SetLockTimeout implements the VC function of the same name.
+func (m *VCMock) SetLockTimeout(ctx context.Context, timeout time.Duration) {
+	if m.SetLockTimeoutFunc != nil {
+		m.SetLockTimeoutFunc(ctx, timeout)
+	}
+}
+
 // CreateSandbox implements the VC function of the same name.
 func (m *VCMock) CreateSandbox(ctx context.Context, sandboxConfig vc.SandboxConfig) (vc.VCSandbox, error) {
 	if m.CreateSandboxFunc != nil {
@@ -218,6 +227,15 @@ func (m *VCMock) ProcessListContainer(ctx context.Context, sandboxID, containerI
 	return nil, fmt.Errorf("%s: %s (%+v): sandboxID: %v, containerID: %v", mockErrorPrefix, getSelf(), m, sandboxID, containerID)
 }
 
+// UpdateSandbox implements the VC function of the same name.
+func (m *VCMock) UpdateSandbox(ctx context.Context, sandboxID string, resources vc.SandboxResources) error {
+	if m.UpdateSandboxFunc != nil {
+		return m.UpdateSandboxFunc(ctx, sandboxID, resources)
+	}
+
+	return fmt.Errorf("%s: %s (%+v): sandboxID: %v", mockErrorPrefix, getSelf(), m, sandboxID)
+}
+
 // UpdateContainer implements the VC function of the same name.
 func (m *VCMock) UpdateContainer(ctx context.Context, sandboxID, containerID string, resources specs.LinuxResources) error {
 	if m.UpdateContainerFunc != nil {
@@ -298,3 +316,30 @@ func (m *VCMock) ListRoutes(ctx context.Context, sandboxID string) ([]*vcTypes.R
 
 	return nil, fmt.Errorf("%s: %s (%+v): sandboxID: %v", mockErrorPrefix, getSelf(), m, sandboxID)
 }
+
+// DebugConsoleURL implements the VC function of the same name.
+func (m *VCMock) DebugConsoleURL(ctx context.Context, sandboxID string) (string, error) {
+	if m.DebugConsoleURLFunc != nil {
+		return m.DebugConsoleURLFunc(ctx, sandboxID)
+	}
+
+	return "", fmt.Errorf("%s: %s (%+v): sandboxID: %v", mockErrorPrefix, getSelf(), m, sandboxID)
+}
+
+// GarbageCollect implements the VC function of the same name.
+func (m *VCMock) GarbageCollect(ctx context.Context, dryRun bool) ([]vc.GCResource, error) {
+	if m.GarbageCollectFunc != nil {
+		return m.GarbageCollectFunc(ctx, dryRun)
+	}
+
+	return nil, fmt.Errorf("%s: %s (%+v): dryRun: %v", mockErrorPrefix, getSelf(), m, dryRun)
+}
+
+// SandboxLockHolders implements the VC function of the same name.
+func (m *VCMock) SandboxLockHolders(ctx context.Context, sandboxID string) ([]store.LockHolder, error) {
+	if m.SandboxLockHoldersFunc != nil {
+		return m.SandboxLockHoldersFunc(ctx, sandboxID)
+	}
+
+	return nil, fmt.Errorf("%s: %s (%+v): sandboxID: %v", mockErrorPrefix, getSelf(), m, sandboxID)
+}
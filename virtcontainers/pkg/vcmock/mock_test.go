@@ -10,10 +10,12 @@ import (
 	"reflect"
 	"syscall"
 	"testing"
+	"time"
 
 	vc "github.com/kata-containers/runtime/virtcontainers"
 	"github.com/kata-containers/runtime/virtcontainers/factory"
 	vcTypes "github.com/kata-containers/runtime/virtcontainers/pkg/types"
+	"github.com/kata-containers/runtime/virtcontainers/store"
 	"github.com/kata-containers/runtime/virtcontainers/types"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
@@ -359,6 +361,34 @@ func TestVCMockStopSandbox(t *testing.T) {
 	assert.True(IsMockError(err))
 }
 
+func TestVCMockUpdateSandbox(t *testing.T) {
+	assert := assert.New(t)
+
+	m := &VCMock{}
+	assert.Nil(m.UpdateSandboxFunc)
+
+	ctx := context.Background()
+	resources := vc.SandboxResources{VCPUs: 2, MemoryMB: 512}
+	err := m.UpdateSandbox(ctx, testSandboxID, resources)
+	assert.Error(err)
+	assert.True(IsMockError(err))
+
+	m.UpdateSandboxFunc = func(ctx context.Context, sandboxID string, r vc.SandboxResources) error {
+		assert.Equal(resources, r)
+		return nil
+	}
+
+	err = m.UpdateSandbox(ctx, testSandboxID, resources)
+	assert.NoError(err)
+
+	// reset
+	m.UpdateSandboxFunc = nil
+
+	err = m.UpdateSandbox(ctx, testSandboxID, resources)
+	assert.Error(err)
+	assert.True(IsMockError(err))
+}
+
 func TestVCMockCreateContainer(t *testing.T) {
 	assert := assert.New(t)
 
@@ -871,3 +901,103 @@ func TestVCMockListRoutes(t *testing.T) {
 	assert.Error(err)
 	assert.True(IsMockError(err))
 }
+
+func TestVCMockDebugConsoleURL(t *testing.T) {
+	assert := assert.New(t)
+
+	m := &VCMock{}
+	config := &vc.SandboxConfig{}
+	assert.Nil(m.DebugConsoleURLFunc)
+
+	ctx := context.Background()
+	_, err := m.DebugConsoleURL(ctx, config.ID)
+	assert.Error(err)
+	assert.True(IsMockError(err))
+
+	m.DebugConsoleURLFunc = func(ctx context.Context, sid string) (string, error) {
+		return "unix:///run/vc/vm/foo/console.sock", nil
+	}
+
+	consoleURL, err := m.DebugConsoleURL(ctx, config.ID)
+	assert.NoError(err)
+	assert.Equal("unix:///run/vc/vm/foo/console.sock", consoleURL)
+
+	// reset
+	m.DebugConsoleURLFunc = nil
+
+	_, err = m.DebugConsoleURL(ctx, config.ID)
+	assert.Error(err)
+	assert.True(IsMockError(err))
+}
+
+func TestVCMockGarbageCollect(t *testing.T) {
+	assert := assert.New(t)
+
+	m := &VCMock{}
+	assert.Nil(m.GarbageCollectFunc)
+
+	ctx := context.Background()
+	_, err := m.GarbageCollect(ctx, true)
+	assert.Error(err)
+	assert.True(IsMockError(err))
+
+	m.GarbageCollectFunc = func(ctx context.Context, dryRun bool) ([]vc.GCResource, error) {
+		return []vc.GCResource{{SandboxID: testSandboxID, Type: vc.GCDirectory}}, nil
+	}
+
+	resources, err := m.GarbageCollect(ctx, true)
+	assert.NoError(err)
+	assert.Len(resources, 1)
+
+	// reset
+	m.GarbageCollectFunc = nil
+
+	_, err = m.GarbageCollect(ctx, true)
+	assert.Error(err)
+	assert.True(IsMockError(err))
+}
+
+func TestVCMockSetLockTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	m := &VCMock{}
+	assert.Nil(m.SetLockTimeoutFunc)
+
+	ctx := context.Background()
+	m.SetLockTimeout(ctx, time.Second)
+
+	var timeout time.Duration
+	m.SetLockTimeoutFunc = func(ctx context.Context, t time.Duration) {
+		timeout = t
+	}
+
+	m.SetLockTimeout(ctx, time.Second)
+	assert.Equal(time.Second, timeout)
+}
+
+func TestVCMockSandboxLockHolders(t *testing.T) {
+	assert := assert.New(t)
+
+	m := &VCMock{}
+	assert.Nil(m.SandboxLockHoldersFunc)
+
+	ctx := context.Background()
+	_, err := m.SandboxLockHolders(ctx, testSandboxID)
+	assert.Error(err)
+	assert.True(IsMockError(err))
+
+	m.SandboxLockHoldersFunc = func(ctx context.Context, sandboxID string) ([]store.LockHolder, error) {
+		return []store.LockHolder{{Pid: 1234, Command: "kata-runtime state " + sandboxID}}, nil
+	}
+
+	holders, err := m.SandboxLockHolders(ctx, testSandboxID)
+	assert.NoError(err)
+	assert.Len(holders, 1)
+
+	// reset
+	m.SandboxLockHoldersFunc = nil
+
+	_, err = m.SandboxLockHolders(ctx, testSandboxID)
+	assert.Error(err)
+	assert.True(IsMockError(err))
+}
@@ -42,6 +42,11 @@ func (s *Sandbox) GetNetNs() string {
 	return s.MockNetNs
 }
 
+// GetHypervisorPid implements the VCSandbox function of the same name.
+func (s *Sandbox) GetHypervisorPid() (int, error) {
+	return s.MockHypervisorPid, nil
+}
+
 // GetAllContainers implements the VCSandbox function of the same name.
 func (s *Sandbox) GetAllContainers() []vc.VCContainer {
 	var ifa = make([]vc.VCContainer, len(s.MockContainers))
@@ -153,6 +158,11 @@ func (s *Sandbox) Monitor() (chan error, error) {
 	return nil, nil
 }
 
+// UpdateResources implements the VCSandbox function of the same name.
+func (s *Sandbox) UpdateResources(resources vc.SandboxResources) error {
+	return nil
+}
+
 // UpdateContainer implements the VCSandbox function of the same name.
 func (s *Sandbox) UpdateContainer(containerID string, resources specs.LinuxResources) error {
 	return nil
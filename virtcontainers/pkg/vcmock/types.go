@@ -8,11 +8,13 @@ package vcmock
 import (
 	"context"
 	"syscall"
+	"time"
 
 	vc "github.com/kata-containers/runtime/virtcontainers"
 	"github.com/kata-containers/runtime/virtcontainers/device/api"
 	"github.com/kata-containers/runtime/virtcontainers/device/config"
 	vcTypes "github.com/kata-containers/runtime/virtcontainers/pkg/types"
+	"github.com/kata-containers/runtime/virtcontainers/store"
 	"github.com/kata-containers/runtime/virtcontainers/types"
 	specs "github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/sirupsen/logrus"
@@ -20,11 +22,12 @@ import (
 
 // Sandbox is a fake Sandbox type used for testing
 type Sandbox struct {
-	MockID          string
-	MockURL         string
-	MockAnnotations map[string]string
-	MockContainers  []*Container
-	MockNetNs       string
+	MockID            string
+	MockURL           string
+	MockAnnotations   map[string]string
+	MockContainers    []*Container
+	MockNetNs         string
+	MockHypervisorPid int
 }
 
 // Container is a fake Container type used for testing
@@ -41,8 +44,9 @@ type Container struct {
 // VCMock is a type that provides an implementation of the VC interface.
 // It is used for testing.
 type VCMock struct {
-	SetLoggerFunc  func(ctx context.Context, logger *logrus.Entry)
-	SetFactoryFunc func(ctx context.Context, factory vc.Factory)
+	SetLoggerFunc      func(ctx context.Context, logger *logrus.Entry)
+	SetFactoryFunc     func(ctx context.Context, factory vc.Factory)
+	SetLockTimeoutFunc func(ctx context.Context, timeout time.Duration)
 
 	CreateSandboxFunc  func(ctx context.Context, sandboxConfig vc.SandboxConfig) (vc.VCSandbox, error)
 	DeleteSandboxFunc  func(ctx context.Context, sandboxID string) (vc.VCSandbox, error)
@@ -55,6 +59,7 @@ type VCMock struct {
 	StatusSandboxFunc  func(ctx context.Context, sandboxID string) (vc.SandboxStatus, error)
 	StatsContainerFunc func(ctx context.Context, sandboxID, containerID string) (vc.ContainerStats, error)
 	StopSandboxFunc    func(ctx context.Context, sandboxID string) (vc.VCSandbox, error)
+	UpdateSandboxFunc  func(ctx context.Context, sandboxID string, resources vc.SandboxResources) error
 
 	CreateContainerFunc      func(ctx context.Context, sandboxID string, containerConfig vc.ContainerConfig) (vc.VCSandbox, vc.VCContainer, error)
 	DeleteContainerFunc      func(ctx context.Context, sandboxID, containerID string) (vc.VCContainer, error)
@@ -75,4 +80,10 @@ type VCMock struct {
 	ListInterfacesFunc  func(ctx context.Context, sandboxID string) ([]*vcTypes.Interface, error)
 	UpdateRoutesFunc    func(ctx context.Context, sandboxID string, routes []*vcTypes.Route) ([]*vcTypes.Route, error)
 	ListRoutesFunc      func(ctx context.Context, sandboxID string) ([]*vcTypes.Route, error)
+
+	DebugConsoleURLFunc func(ctx context.Context, sandboxID string) (string, error)
+
+	GarbageCollectFunc func(ctx context.Context, dryRun bool) ([]vc.GCResource, error)
+
+	SandboxLockHoldersFunc func(ctx context.Context, sandboxID string) ([]store.LockHolder, error)
 }
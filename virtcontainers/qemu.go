@@ -56,6 +56,8 @@ type CPUDevice struct {
 // QemuState keeps Qemu's state
 type QemuState struct {
 	Bridges []types.PCIBridge
+	// PCIeRootPorts is the list of cold plugged PCIe root ports
+	PCIeRootPorts []types.PCIeRootPort
 	// HotpluggedCPUs is the list of CPUs that were hot-added
 	HotpluggedVCPUs      []CPUDevice
 	HotpluggedMemory     int
@@ -98,6 +100,7 @@ const (
 
 	scsiControllerID         = "scsi0"
 	rngID                    = "rng0"
+	balloonID                = "balloon0"
 	vsockKernelOption        = "agent.use_vsock"
 	fallbackFileBackedMemDir = "/dev/shm"
 )
@@ -176,7 +179,12 @@ func (q *qemu) capabilities() types.Capabilities {
 	span, _ := q.trace("capabilities")
 	defer span.Finish()
 
-	return q.arch.capabilities()
+	caps := q.arch.capabilities()
+	if q.config.MemoryBalloon {
+		caps.SetMemoryBalloonSupport()
+	}
+
+	return caps
 }
 
 func (q *qemu) hypervisorConfig() HypervisorConfig {
@@ -251,6 +259,20 @@ func (q *qemu) setup(id string, hypervisorConfig *HypervisorConfig, vcStore *sto
 		q.Logger().Debug("Creating bridges")
 		q.state.Bridges = q.arch.bridges(q.config.DefaultBridges)
 
+		if q.config.PCIeRootPort > 0 {
+			q.Logger().Debug("Creating PCIe root ports")
+			q.state.PCIeRootPorts = q.arch.pcieRootPorts(q.config.PCIeRootPort)
+			if len(q.state.PCIeRootPorts) == 0 {
+				q.Logger().WithField("machine-type", q.config.HypervisorMachineType).
+					Warn("PCIe root ports not supported by machine type, falling back to bridges")
+			}
+
+			// PCIe root ports take the PCI slots following the bridges ones
+			for idx := range q.state.PCIeRootPorts {
+				q.state.PCIeRootPorts[idx].Addr = bridgePCIStartAddr + len(q.state.Bridges) + idx
+			}
+		}
+
 		q.Logger().Debug("Creating UUID")
 		q.state.UUID = uuid.Generate().String()
 
@@ -386,6 +408,8 @@ func (q *qemu) buildDevices(initrdPath string) ([]govmmQemu.Device, *govmmQemu.I
 	// bridge gets the first available PCI address i.e bridgePCIStartAddr
 	devices = q.arch.appendBridges(devices, q.state.Bridges)
 
+	devices = q.arch.appendPCIeRootPorts(devices, q.state.PCIeRootPorts)
+
 	devices = q.arch.appendConsole(devices, console)
 
 	if initrdPath == "" {
@@ -495,11 +519,13 @@ func (q *qemu) createSandbox(ctx context.Context, id string, hypervisorConfig *H
 	incoming := q.setupTemplate(&knobs, &memory)
 
 	// With the current implementations, VM templating will not work with file
-	// based memory (stand-alone) or virtiofs. This is because VM templating
-	// builds the first VM with file-backed memory and shared=on and the
-	// subsequent ones with shared=off. virtio-fs always requires shared=on for
-	// memory.
-	if q.config.SharedFS == config.VirtioFS || q.config.FileBackedMemRootDir != "" {
+	// based memory (stand-alone), virtiofs or vhost-user block devices. This
+	// is because VM templating builds the first VM with file-backed memory and
+	// shared=on and the subsequent ones with shared=off. virtio-fs and the
+	// vhost-user backends always require shared=on for memory, which huge
+	// pages already provide.
+	if q.config.SharedFS == config.VirtioFS || q.config.FileBackedMemRootDir != "" ||
+		(q.config.EnableVhostUserStore && !q.config.HugePages) {
 		if !(q.config.BootToBeTemplate || q.config.BootFromTemplate) {
 			q.setupFileBackedMem(&knobs, &memory)
 		} else {
@@ -571,6 +597,10 @@ func (q *qemu) createSandbox(ctx context.Context, id string, hypervisorConfig *H
 	}
 	qemuConfig.Devices = q.arch.appendRNGDevice(qemuConfig.Devices, rngDev)
 
+	if q.config.MemoryBalloon {
+		qemuConfig.Devices = q.arch.appendBalloonDevice(qemuConfig.Devices, balloonID, q.config.FreePageReporting)
+	}
+
 	q.qemuConfig = qemuConfig
 
 	return nil
@@ -900,6 +930,50 @@ func (q *qemu) addDeviceToBridge(ID string) (string, types.PCIBridge, error) {
 	return "", types.PCIBridge{}, fmt.Errorf("no more bridge slots available")
 }
 
+// addDeviceToPCIeRootPort looks for a free PCIe root port to hotplug the
+// device ID on. The device address behind a root port is always 0.
+func (q *qemu) addDeviceToPCIeRootPort(ID string) (string, types.PCIeRootPort, error) {
+	for idx := range q.state.PCIeRootPorts {
+		if err := q.state.PCIeRootPorts[idx].AddDevice(ID); err == nil {
+			return "00", q.state.PCIeRootPorts[idx], nil
+		}
+	}
+
+	return "", types.PCIeRootPort{}, fmt.Errorf("no more PCIe root ports available")
+}
+
+// addDeviceToPCISlot hotplugs the device ID on a free PCIe root port if any,
+// or on a bridge otherwise. It returns the device address, the bus the device
+// is plugged on, and the guest PCI path in the format bus-addr/device-addr.
+func (q *qemu) addDeviceToPCISlot(ID string) (string, string, string, error) {
+	if len(q.state.PCIeRootPorts) > 0 {
+		addr, port, err := q.addDeviceToPCIeRootPort(ID)
+		if err == nil {
+			return addr, port.ID, fmt.Sprintf("%02x/%s", port.Addr, addr), nil
+		}
+		q.Logger().WithError(err).WithField("device", ID).Debug("Hotplugging device on a bridge")
+	}
+
+	addr, bridge, err := q.addDeviceToBridge(ID)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	return addr, bridge.ID, fmt.Sprintf("%02x/%s", bridge.Addr, addr), nil
+}
+
+// removeDeviceFromPCISlot frees the PCIe root port or bridge slot used by
+// the device ID.
+func (q *qemu) removeDeviceFromPCISlot(ID string) error {
+	for idx := range q.state.PCIeRootPorts {
+		if q.state.PCIeRootPorts[idx].DeviceID == ID {
+			return q.state.PCIeRootPorts[idx].RemoveDevice(ID)
+		}
+	}
+
+	return q.removeDeviceFromBridge(ID)
+}
+
 func (q *qemu) removeDeviceFromBridge(ID string) error {
 	var err error
 	for _, b := range q.state.Bridges {
@@ -945,15 +1019,15 @@ func (q *qemu) hotplugAddBlockDevice(drive *config.BlockDrive, op operation, dev
 
 	if q.config.BlockDeviceDriver == config.VirtioBlock {
 		driver := "virtio-blk-pci"
-		addr, bridge, err := q.addDeviceToBridge(drive.ID)
+		addr, bus, pciAddr, err := q.addDeviceToPCISlot(drive.ID)
 		if err != nil {
 			return err
 		}
 
-		// PCI address is in the format bridge-addr/device-addr eg. "03/02"
-		drive.PCIAddr = fmt.Sprintf("%02x", bridge.Addr) + "/" + addr
+		// PCI address is in the format bus-addr/device-addr eg. "03/02"
+		drive.PCIAddr = pciAddr
 
-		if err = q.qmpMonitorCh.qmp.ExecutePCIDeviceAdd(q.qmpMonitorCh.ctx, drive.ID, devID, driver, addr, bridge.ID, romFile, true, q.arch.runNested()); err != nil {
+		if err = q.qmpMonitorCh.qmp.ExecutePCIDeviceAdd(q.qmpMonitorCh.ctx, drive.ID, devID, driver, addr, bus, romFile, true, q.arch.runNested()); err != nil {
 			return err
 		}
 	} else {
@@ -988,7 +1062,7 @@ func (q *qemu) hotplugBlockDevice(drive *config.BlockDrive, op operation) error
 		err = q.hotplugAddBlockDevice(drive, op, devID)
 	} else {
 		if q.config.BlockDeviceDriver == config.VirtioBlock {
-			if err := q.removeDeviceFromBridge(drive.ID); err != nil {
+			if err := q.removeDeviceFromPCISlot(drive.ID); err != nil {
 				return err
 			}
 		}
@@ -1017,7 +1091,7 @@ func (q *qemu) hotplugVFIODevice(device *config.VFIODev, op operation) error {
 		// In case HotplugVFIOOnRootBus is true, devices are hotplugged on the root bus
 		// for pc machine type instead of bridge. This is useful for devices that require
 		// a large PCI BAR which is a currently a limitation with PCI bridges.
-		if q.state.HotplugVFIOOnRootBus {
+		if q.state.HotplugVFIOOnRootBus && len(q.state.PCIeRootPorts) == 0 {
 			switch device.Type {
 			case config.VFIODeviceNormalType:
 				return q.qmpMonitorCh.qmp.ExecuteVFIODeviceAdd(q.qmpMonitorCh.ctx, devID, device.BDF, romFile)
@@ -1028,22 +1102,24 @@ func (q *qemu) hotplugVFIODevice(device *config.VFIODev, op operation) error {
 			}
 		}
 
-		addr, bridge, err := q.addDeviceToBridge(devID)
+		addr, bus, pciAddr, err := q.addDeviceToPCISlot(devID)
 		if err != nil {
 			return err
 		}
 
+		device.PCIAddr = pciAddr
+
 		switch device.Type {
 		case config.VFIODeviceNormalType:
-			return q.qmpMonitorCh.qmp.ExecutePCIVFIODeviceAdd(q.qmpMonitorCh.ctx, devID, device.BDF, addr, bridge.ID, romFile)
+			return q.qmpMonitorCh.qmp.ExecutePCIVFIODeviceAdd(q.qmpMonitorCh.ctx, devID, device.BDF, addr, bus, romFile)
 		case config.VFIODeviceMediatedType:
-			return q.qmpMonitorCh.qmp.ExecutePCIVFIOMediatedDeviceAdd(q.qmpMonitorCh.ctx, devID, device.SysfsDev, addr, bridge.ID, romFile)
+			return q.qmpMonitorCh.qmp.ExecutePCIVFIOMediatedDeviceAdd(q.qmpMonitorCh.ctx, devID, device.SysfsDev, addr, bus, romFile)
 		default:
 			return fmt.Errorf("Incorrect VFIO device type found")
 		}
 	} else {
-		if !q.state.HotplugVFIOOnRootBus {
-			if err := q.removeDeviceFromBridge(devID); err != nil {
+		if !q.state.HotplugVFIOOnRootBus || len(q.state.PCIeRootPorts) > 0 {
+			if err := q.removeDeviceFromPCISlot(devID); err != nil {
 				return err
 			}
 		}
@@ -1056,6 +1132,130 @@ func (q *qemu) hotplugVFIODevice(device *config.VFIODev, op operation) error {
 	return nil
 }
 
+func (q *qemu) hotplugVhostUserDevice(vAttr *config.VhostUserDeviceAttrs, op operation) (err error) {
+	err = q.qmpSetup()
+	if err != nil {
+		return err
+	}
+
+	var devType string
+	switch vAttr.Type {
+	case config.VhostUserNet:
+		return q.hotplugVhostUserNetDevice(vAttr, op)
+	case config.VhostUserBlk:
+		devType = "blk"
+	case config.VhostUserSCSI:
+		devType = "scsi"
+	default:
+		return fmt.Errorf("Incorrect vhost-user device type found")
+	}
+
+	devID := utils.MakeNameID(devType, vAttr.DevID, maxDevIDSize)
+	charDevID := utils.MakeNameID("char", vAttr.DevID, maxDevIDSize)
+
+	if op == addDevice {
+		if err = q.qmpMonitorCh.qmp.ExecuteCharDevUnixSocketAdd(q.qmpMonitorCh.ctx, charDevID, vAttr.SocketPath, false, false); err != nil {
+			return err
+		}
+
+		defer func() {
+			if err != nil {
+				q.qmpChardevDel(charDevID)
+			}
+		}()
+
+		var addr, bus, pciAddr string
+		addr, bus, pciAddr, err = q.addDeviceToPCISlot(devID)
+		if err != nil {
+			return err
+		}
+
+		defer func() {
+			if err != nil {
+				q.removeDeviceFromPCISlot(devID)
+			}
+		}()
+
+		vAttr.PCIAddr = pciAddr
+
+		return q.qmpPCIVhostUserDevAdd(string(vAttr.Type), devID, charDevID, addr, bus)
+	}
+
+	if err = q.removeDeviceFromPCISlot(devID); err != nil {
+		return err
+	}
+
+	if err = q.qmpMonitorCh.qmp.ExecuteDeviceDel(q.qmpMonitorCh.ctx, devID); err != nil {
+		return err
+	}
+
+	return q.qmpChardevDel(charDevID)
+}
+
+func (q *qemu) hotplugVhostUserNetDevice(vAttr *config.VhostUserDeviceAttrs, op operation) (err error) {
+	devID := utils.MakeNameID("virtio-net", vAttr.DevID, maxDevIDSize)
+	netDevID := utils.MakeNameID("net", vAttr.DevID, maxDevIDSize)
+	charDevID := utils.MakeNameID("char", vAttr.DevID, maxDevIDSize)
+
+	if op == addDevice {
+		if err = q.qmpMonitorCh.qmp.ExecuteCharDevUnixSocketAdd(q.qmpMonitorCh.ctx, charDevID, vAttr.SocketPath, false, vAttr.Server); err != nil {
+			return err
+		}
+
+		defer func() {
+			if err != nil {
+				q.qmpChardevDel(charDevID)
+			}
+		}()
+
+		if err = q.qmpMonitorCh.qmp.ExecuteNetdevChardevAdd(q.qmpMonitorCh.ctx, "vhost-user", netDevID, charDevID, int(vAttr.Queues)); err != nil {
+			return err
+		}
+
+		defer func() {
+			if err != nil {
+				q.qmpMonitorCh.qmp.ExecuteNetdevDel(q.qmpMonitorCh.ctx, netDevID)
+			}
+		}()
+
+		var addr, bus, pciAddr string
+		addr, bus, pciAddr, err = q.addDeviceToPCISlot(devID)
+		if err != nil {
+			return err
+		}
+
+		defer func() {
+			if err != nil {
+				q.removeDeviceFromPCISlot(devID)
+			}
+		}()
+
+		vAttr.PCIAddr = pciAddr
+
+		// A single queue pair does not need multiqueue
+		queues := 0
+		if vAttr.Queues > 1 {
+			queues = int(vAttr.Queues)
+		}
+
+		return q.qmpMonitorCh.qmp.ExecuteNetPCIDeviceAdd(q.qmpMonitorCh.ctx, netDevID, devID, vAttr.MacAddress, addr, bus, romFile, queues, q.arch.runNested())
+	}
+
+	if err = q.removeDeviceFromPCISlot(devID); err != nil {
+		return err
+	}
+
+	if err = q.qmpMonitorCh.qmp.ExecuteDeviceDel(q.qmpMonitorCh.ctx, devID); err != nil {
+		return err
+	}
+
+	if err = q.qmpMonitorCh.qmp.ExecuteNetdevDel(q.qmpMonitorCh.ctx, netDevID); err != nil {
+		return err
+	}
+
+	return q.qmpChardevDel(charDevID)
+}
+
 func (q *qemu) hotAddNetDevice(name, hardAddr string, VMFds, VhostFds []*os.File) error {
 	var (
 		VMFdNames    []string
@@ -1104,11 +1304,10 @@ func (q *qemu) hotplugNetDevice(endpoint Endpoint, op operation) error {
 			return err
 		}
 
-		addr, bridge, err := q.addDeviceToBridge(tap.ID)
+		addr, bus, pciAddr, err := q.addDeviceToPCISlot(tap.ID)
 		if err != nil {
 			return err
 		}
-		pciAddr := fmt.Sprintf("%02x/%s", bridge.Addr, addr)
 		endpoint.SetPciAddr(pciAddr)
 
 		var machine govmmQemu.Machine
@@ -1117,12 +1316,12 @@ func (q *qemu) hotplugNetDevice(endpoint Endpoint, op operation) error {
 			return err
 		}
 		if machine.Type == QemuCCWVirtio {
-			return q.qmpMonitorCh.qmp.ExecuteNetCCWDeviceAdd(q.qmpMonitorCh.ctx, tap.Name, devID, endpoint.HardwareAddr(), addr, bridge.ID, int(q.config.NumVCPUs))
+			return q.qmpMonitorCh.qmp.ExecuteNetCCWDeviceAdd(q.qmpMonitorCh.ctx, tap.Name, devID, endpoint.HardwareAddr(), addr, bus, int(q.config.NumVCPUs))
 		}
-		return q.qmpMonitorCh.qmp.ExecuteNetPCIDeviceAdd(q.qmpMonitorCh.ctx, tap.Name, devID, endpoint.HardwareAddr(), addr, bridge.ID, romFile, int(q.config.NumVCPUs), q.arch.runNested())
+		return q.qmpMonitorCh.qmp.ExecuteNetPCIDeviceAdd(q.qmpMonitorCh.ctx, tap.Name, devID, endpoint.HardwareAddr(), addr, bus, romFile, int(q.config.NumVCPUs), q.arch.runNested())
 	}
 
-	if err := q.removeDeviceFromBridge(tap.ID); err != nil {
+	if err := q.removeDeviceFromPCISlot(tap.ID); err != nil {
 		return err
 	}
 
@@ -1153,6 +1352,9 @@ func (q *qemu) hotplugDevice(devInfo interface{}, devType deviceType, op operati
 	case netDev:
 		device := devInfo.(Endpoint)
 		return nil, q.hotplugNetDevice(device, op)
+	case vhostuserDev:
+		vAttr := devInfo.(*config.VhostUserDeviceAttrs)
+		return nil, q.hotplugVhostUserDevice(vAttr, op)
 	default:
 		return nil, fmt.Errorf("cannot hotplug device: unsupported device type '%v'", devType)
 	}
@@ -1595,6 +1797,33 @@ func (q *qemu) resizeMemory(reqMemMB uint32, memoryBlockSizeMB uint32, probe boo
 	return currentMemory, addMemDevice, nil
 }
 
+// resizeBalloon sets the guest memory to reqMemMB by inflating or deflating
+// the memory balloon. The balloon cannot give the guest more than the plugged
+// memory, hence the returned guest memory is lower than reqMemMB when memory
+// has to be hot added.
+func (q *qemu) resizeBalloon(reqMemMB uint32) (uint32, error) {
+	if !q.config.MemoryBalloon {
+		return 0, fmt.Errorf("Memory balloon is not enabled")
+	}
+
+	targetMemory := q.config.MemorySize + uint32(q.state.HotpluggedMemory)
+	if reqMemMB < targetMemory {
+		targetMemory = reqMemMB
+	}
+
+	if err := q.qmpSetup(); err != nil {
+		return 0, err
+	}
+
+	q.Logger().WithField("balloon-target-mb", targetMemory).Debug("Resizing memory balloon")
+
+	if err := q.qmpMonitorCh.qmp.ExecuteBalloon(q.qmpMonitorCh.ctx, uint64(targetMemory)<<utils.MibToBytesShift); err != nil {
+		return 0, err
+	}
+
+	return targetMemory, nil
+}
+
 // genericAppendBridges appends to devices the given bridges
 // nolint: unused, deadcode
 func genericAppendBridges(devices []govmmQemu.Device, bridges []types.PCIBridge, machineType string) []govmmQemu.Device {
@@ -1661,6 +1890,24 @@ func genericBridges(number uint32, machineType string) []types.PCIBridge {
 	return bridges
 }
 
+// nolint: unused, deadcode
+func genericPCIeRootPorts(number uint32, machineType string) []types.PCIeRootPort {
+	var rootPorts []types.PCIeRootPort
+
+	// root ports can only be plugged on a PCIe root bus
+	if machineType != QemuQ35 {
+		return nil
+	}
+
+	for i := uint32(0); i < number; i++ {
+		rootPorts = append(rootPorts, types.PCIeRootPort{
+			ID: fmt.Sprintf("rp%d", i),
+		})
+	}
+
+	return rootPorts
+}
+
 // nolint: unused, deadcode
 func genericMemoryTopology(memoryMb, hostMemoryMb uint64, slots uint8, memoryOffset uint32) govmmQemu.Memory {
 	// image NVDIMM device needs memory space 1024MB
@@ -1705,6 +1952,16 @@ func (q *qemu) getThreadIDs() (vcpuThreadIDs, error) {
 	return tid, nil
 }
 
+// getStats returns no statistics, qemu doesn't report any to the runtime.
+func (q *qemu) getStats() (*HypervisorStats, error) {
+	return nil, nil
+}
+
+// publishMetadata does nothing, qemu has no metadata service for the guest.
+func (q *qemu) publishMetadata(metadata *SandboxMetadata) error {
+	return nil
+}
+
 func calcHotplugMemMiBSize(mem uint32, memorySectionSizeMB uint32) (uint32, error) {
 	if memorySectionSizeMB == 0 {
 		return mem, nil
@@ -128,6 +128,10 @@ func (q *qemuAmd64) bridges(number uint32) []types.PCIBridge {
 	return genericBridges(number, q.machineType)
 }
 
+func (q *qemuAmd64) pcieRootPorts(number uint32) []types.PCIeRootPort {
+	return genericPCIeRootPorts(number, q.machineType)
+}
+
 func (q *qemuAmd64) cpuModel() string {
 	cpuModel := defaultCPUModel
 	if q.nestedRun {
@@ -66,6 +66,25 @@ func TestQemuAmd64Bridges(t *testing.T) {
 	assert.Nil(bridges)
 }
 
+func TestQemuAmd64PCIeRootPorts(t *testing.T) {
+	assert := assert.New(t)
+	amd64 := newTestQemu(QemuQ35)
+	len := 3
+
+	rootPorts := amd64.pcieRootPorts(uint32(len))
+	assert.Len(rootPorts, len)
+
+	for i, p := range rootPorts {
+		assert.Equal(fmt.Sprintf("rp%d", i), p.ID)
+		assert.Empty(p.DeviceID)
+	}
+
+	// root ports need a PCIe root bus
+	amd64 = newTestQemu(QemuPC)
+	rootPorts = amd64.pcieRootPorts(uint32(len))
+	assert.Nil(rootPorts)
+}
+
 func TestQemuAmd64CPUModel(t *testing.T) {
 	assert := assert.New(t)
 	amd64 := newTestQemu(QemuPC)
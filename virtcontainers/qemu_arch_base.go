@@ -11,6 +11,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 
 	govmmQemu "github.com/intel/govmm/qemu"
 
@@ -51,6 +52,9 @@ type qemuArch interface {
 	// bridges returns the number bridges for the machine type
 	bridges(number uint32) []types.PCIBridge
 
+	// pcieRootPorts returns the PCIe root ports for the machine type
+	pcieRootPorts(number uint32) []types.PCIeRootPort
+
 	// cpuTopology returns the CPU topology for the given amount of vcpus
 	cpuTopology(vcpus, maxvcpus uint32) govmmQemu.SMP
 
@@ -72,6 +76,9 @@ type qemuArch interface {
 	// appendBridges appends bridges to devices
 	appendBridges(devices []govmmQemu.Device, bridges []types.PCIBridge) []govmmQemu.Device
 
+	// appendPCIeRootPorts appends PCIe root ports to devices
+	appendPCIeRootPorts(devices []govmmQemu.Device, rootPorts []types.PCIeRootPort) []govmmQemu.Device
+
 	// append9PVolume appends a 9P volume to devices
 	append9PVolume(devices []govmmQemu.Device, volume types.Volume) []govmmQemu.Device
 
@@ -96,6 +103,9 @@ type qemuArch interface {
 	// appendRNGDevice appends a RNG device to devices
 	appendRNGDevice(devices []govmmQemu.Device, rngDevice config.RNGDev) []govmmQemu.Device
 
+	// appendBalloonDevice appends a memory balloon device to devices
+	appendBalloonDevice(devices []govmmQemu.Device, id string, freePageReporting bool) []govmmQemu.Device
+
 	// handleImagePath handles the Hypervisor Config image path
 	handleImagePath(config HypervisorConfig)
 
@@ -135,6 +145,47 @@ const (
 // 0 is reserved.
 const bridgePCIStartAddr = 2
 
+// pcieRootPortDriver is the QEMU driver of a PCIe root port
+const pcieRootPortDriver = "pcie-root-port"
+
+// pcieRootPortDevice represents a PCIe root port cold plugged on the
+// PCIe root bus, a single device can be hot plugged behind it.
+type pcieRootPortDevice struct {
+	// ID is used to identify the root port in qemu
+	ID string
+
+	// Bus number where the root port is plugged, typically pcie.0
+	Bus string
+
+	// Chassis number
+	Chassis int
+
+	// Slot number
+	Slot int
+
+	// PCI Slot on the root bus
+	Addr string
+}
+
+// Valid returns true if the pcieRootPortDevice structure is valid and complete.
+func (p pcieRootPortDevice) Valid() bool {
+	return p.ID != "" && p.Bus != ""
+}
+
+// QemuParams returns the qemu parameters built out of this root port device.
+func (p pcieRootPortDevice) QemuParams(config *govmmQemu.Config) []string {
+	deviceParam := fmt.Sprintf("%s,id=%s,bus=%s,chassis=%d,slot=%d", pcieRootPortDriver, p.ID, p.Bus, p.Chassis, p.Slot)
+
+	if p.Addr != "" {
+		addr, err := strconv.Atoi(p.Addr)
+		if err == nil && addr >= 0 {
+			deviceParam += fmt.Sprintf(",addr=%x", addr)
+		}
+	}
+
+	return []string{"-device", deviceParam}
+}
+
 const (
 	// QemuPCLite is the QEMU pc-lite machine type for amd64
 	QemuPCLite = "pc-lite"
@@ -254,6 +305,11 @@ func (q *qemuArchBase) bridges(number uint32) []types.PCIBridge {
 	return bridges
 }
 
+func (q *qemuArchBase) pcieRootPorts(number uint32) []types.PCIeRootPort {
+	// PCIe root ports are only available on machine types with a PCIe root bus
+	return nil
+}
+
 func (q *qemuArchBase) cpuTopology(vcpus, maxvcpus uint32) govmmQemu.SMP {
 	smp := govmmQemu.SMP{
 		CPUs:    vcpus,
@@ -374,6 +430,25 @@ func (q *qemuArchBase) appendBridges(devices []govmmQemu.Device, bridges []types
 	return devices
 }
 
+// appendPCIeRootPorts appends to devices the given PCIe root ports
+func (q *qemuArchBase) appendPCIeRootPorts(devices []govmmQemu.Device, rootPorts []types.PCIeRootPort) []govmmQemu.Device {
+	for _, p := range rootPorts {
+		devices = append(devices,
+			pcieRootPortDevice{
+				ID:  p.ID,
+				Bus: defaultBridgeBus,
+				// Each root port is required to be assigned a unique
+				// chassis/slot pair, the PCI slot is unique on the root bus.
+				Chassis: p.Addr,
+				Slot:    p.Addr,
+				Addr:    strconv.FormatInt(int64(p.Addr), 10),
+			},
+		)
+	}
+
+	return devices
+}
+
 func (q *qemuArchBase) append9PVolume(devices []govmmQemu.Device, volume types.Volume) []govmmQemu.Device {
 	if volume.MountTag == "" || volume.HostPath == "" {
 		return devices
@@ -517,13 +592,81 @@ func (q *qemuArchBase) appendBlockDevice(devices []govmmQemu.Device, drive confi
 	return devices
 }
 
+// vhostUserNetDevice is a virtio-net device backed by a vhost-user socket.
+// Unlike the govmm vhost-user device, it supports the server mode of the
+// socket and multiqueue, and gives an ID to the virtio-net device.
+type vhostUserNetDevice struct {
+	// CharDevID identifies the chardev of the vhost-user socket.
+	CharDevID string
+
+	// NetDevID identifies the vhost-user netdev.
+	NetDevID string
+
+	// DeviceID identifies the virtio-net device, to hot unplug it.
+	DeviceID string
+
+	SocketPath string
+	MacAddress string
+	ROMFile    string
+
+	// Server makes QEMU create the socket, the switch connecting to it.
+	Server bool
+
+	// Queues is the number of queue pairs.
+	Queues uint32
+}
+
+// Valid returns true if the vhostUserNetDevice structure is valid and complete.
+func (v vhostUserNetDevice) Valid() bool {
+	return v.CharDevID != "" && v.NetDevID != "" && v.SocketPath != "" && v.MacAddress != ""
+}
+
+// QemuParams returns the qemu parameters built out of the vhostUserNetDevice.
+func (v vhostUserNetDevice) QemuParams(config *govmmQemu.Config) []string {
+	charParams := []string{"socket", "id=" + v.CharDevID, "path=" + v.SocketPath}
+	if v.Server {
+		charParams = append(charParams, "server", "nowait")
+	}
+
+	netParams := []string{"type=vhost-user", "id=" + v.NetDevID, "chardev=" + v.CharDevID, "vhostforce"}
+	devParams := []string{string(govmmQemu.VhostUserNet), "netdev=" + v.NetDevID, "mac=" + v.MacAddress}
+	devParams = append(devParams, "romfile="+v.ROMFile)
+
+	if v.Queues > 1 {
+		// 2N+2 vectors: N for tx queues, N for rx queues, 1 for
+		// config, and one for a possible control vq.
+		netParams = append(netParams, fmt.Sprintf("queues=%d", v.Queues))
+		devParams = append(devParams, "mq=on", fmt.Sprintf("vectors=%d", 2*v.Queues+2))
+	}
+
+	if v.DeviceID != "" {
+		devParams = append(devParams, "id="+v.DeviceID)
+	}
+
+	return []string{
+		"-chardev", strings.Join(charParams, ","),
+		"-netdev", strings.Join(netParams, ","),
+		"-device", strings.Join(devParams, ","),
+	}
+}
+
 func (q *qemuArchBase) appendVhostUserDevice(devices []govmmQemu.Device, attr config.VhostUserDeviceAttrs) ([]govmmQemu.Device, error) {
+	if attr.Type == config.VhostUserNet {
+		devices = append(devices, vhostUserNetDevice{
+			CharDevID:  utils.MakeNameID("char", attr.DevID, maxDevIDSize),
+			NetDevID:   utils.MakeNameID("net", attr.DevID, maxDevIDSize),
+			DeviceID:   utils.MakeNameID("virtio-net", attr.DevID, maxDevIDSize),
+			SocketPath: attr.SocketPath,
+			MacAddress: attr.MacAddress,
+			Server:     attr.Server,
+			Queues:     attr.Queues,
+		})
+		return devices, nil
+	}
+
 	qemuVhostUserDevice := govmmQemu.VhostUserDevice{}
 
 	switch attr.Type {
-	case config.VhostUserNet:
-		qemuVhostUserDevice.TypeDevID = utils.MakeNameID("net", attr.DevID, maxDevIDSize)
-		qemuVhostUserDevice.Address = attr.MacAddress
 	case config.VhostUserSCSI:
 		qemuVhostUserDevice.TypeDevID = utils.MakeNameID("scsi", attr.DevID, maxDevIDSize)
 	case config.VhostUserBlk:
@@ -567,6 +710,77 @@ func (q *qemuArchBase) appendRNGDevice(devices []govmmQemu.Device, rngDev config
 	return devices
 }
 
+// balloonPCIDriver is the QEMU driver of a PCI memory balloon
+const balloonPCIDriver = "virtio-balloon-pci"
+
+// balloonDevice is a virtio-balloon device. Unlike the govmm one, it
+// supports free page reporting.
+type balloonDevice struct {
+	// Driver is the QEMU driver of the balloon, depending on the transport.
+	Driver string
+
+	// ID is used to identify the balloon in qemu
+	ID string
+
+	// DeflateOnOOM gives memory back to the guest before it runs out of memory.
+	DeflateOnOOM bool
+
+	// FreePageReporting makes the guest report its free pages to the host.
+	FreePageReporting bool
+
+	// DisableModern prevents qemu from relying on fast MMIO, PCI only.
+	DisableModern bool
+
+	// ROMFile specifies the ROM file being used for this device, PCI only.
+	ROMFile string
+}
+
+// Valid returns true if the balloonDevice structure is valid and complete.
+func (b balloonDevice) Valid() bool {
+	return b.Driver != "" && b.ID != ""
+}
+
+// QemuParams returns the qemu parameters built out of the balloonDevice.
+func (b balloonDevice) QemuParams(config *govmmQemu.Config) []string {
+	deviceParams := []string{b.Driver, "id=" + b.ID}
+
+	if b.Driver == balloonPCIDriver {
+		deviceParams = append(deviceParams, "romfile="+b.ROMFile)
+	}
+
+	if b.DeflateOnOOM {
+		deviceParams = append(deviceParams, "deflate-on-oom=on")
+	} else {
+		deviceParams = append(deviceParams, "deflate-on-oom=off")
+	}
+
+	if b.FreePageReporting {
+		deviceParams = append(deviceParams, "free-page-reporting=on")
+	}
+
+	if b.Driver == balloonPCIDriver {
+		deviceParams = append(deviceParams, fmt.Sprintf("disable-modern=%t", b.DisableModern))
+	}
+
+	return []string{"-device", strings.Join(deviceParams, ",")}
+}
+
+func (q *qemuArchBase) appendBalloonDevice(devices []govmmQemu.Device, id string, freePageReporting bool) []govmmQemu.Device {
+	devices = append(devices,
+		balloonDevice{
+			Driver: balloonPCIDriver,
+			ID:     id,
+			// Give memory back to the guest rather than letting
+			// it run out of memory.
+			DeflateOnOOM:      true,
+			FreePageReporting: freePageReporting,
+			DisableModern:     q.nestedRun,
+		},
+	)
+
+	return devices
+}
+
 func (q *qemuArchBase) handleImagePath(config HypervisorConfig) {
 	if config.ImagePath != "" {
 		q.kernelParams = append(q.kernelParams, kernelRootParams...)
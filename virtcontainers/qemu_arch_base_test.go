@@ -303,6 +303,39 @@ func TestQemuArchBaseAppendBridges(t *testing.T) {
 	assert.Equal(expectedOut, devices)
 }
 
+func TestQemuArchBaseAppendPCIeRootPorts(t *testing.T) {
+	var devices []govmmQemu.Device
+	assert := assert.New(t)
+	qemuArchBase := newQemuArchBase()
+
+	rootPorts := []types.PCIeRootPort{{ID: "rp0", Addr: 3}, {ID: "rp1", Addr: 4}}
+
+	devices = qemuArchBase.appendPCIeRootPorts(devices, rootPorts)
+	assert.Len(devices, 2)
+
+	expectedOut := []govmmQemu.Device{
+		pcieRootPortDevice{
+			ID:      "rp0",
+			Bus:     defaultBridgeBus,
+			Chassis: 3,
+			Slot:    3,
+			Addr:    "3",
+		},
+		pcieRootPortDevice{
+			ID:      "rp1",
+			Bus:     defaultBridgeBus,
+			Chassis: 4,
+			Slot:    4,
+			Addr:    "4",
+		},
+	}
+
+	assert.Equal(expectedOut, devices)
+
+	params := devices[1].QemuParams(nil)
+	assert.Equal([]string{"-device", "pcie-root-port,id=rp1,bus=pcie.0,chassis=4,slot=4,addr=4"}, params)
+}
+
 func TestQemuArchBaseAppend9PVolume(t *testing.T) {
 	mountTag := "testMountTag"
 	hostPath := "testHostPath"
@@ -384,12 +417,12 @@ func TestQemuArchBaseAppendVhostUserDevice(t *testing.T) {
 	id := "deadbeef"
 
 	expectedOut := []govmmQemu.Device{
-		govmmQemu.VhostUserDevice{
-			SocketPath:    socketPath,
-			CharDevID:     fmt.Sprintf("char-%s", id),
-			TypeDevID:     fmt.Sprintf("net-%s", id),
-			Address:       macAddress,
-			VhostUserType: config.VhostUserNet,
+		vhostUserNetDevice{
+			SocketPath: socketPath,
+			CharDevID:  fmt.Sprintf("char-%s", id),
+			NetDevID:   fmt.Sprintf("net-%s", id),
+			DeviceID:   fmt.Sprintf("virtio-net-%s", id),
+			MacAddress: macAddress,
 		},
 	}
 
@@ -403,6 +436,43 @@ func TestQemuArchBaseAppendVhostUserDevice(t *testing.T) {
 	testQemuArchBaseAppend(t, vhostUserDevice, expectedOut)
 }
 
+func TestQemuArchBaseVhostUserNetDevice(t *testing.T) {
+	assert := assert.New(t)
+	qemuArchBase := newQemuArchBase()
+
+	attr := config.VhostUserDeviceAttrs{
+		DevID:      "deadbeef",
+		SocketPath: "/tmp/vhu.sock",
+		Type:       config.VhostUserNet,
+		MacAddress: "02:00:ca:fe:00:48",
+		Queues:     1,
+	}
+
+	devices, err := qemuArchBase.appendVhostUserDevice(nil, attr)
+	assert.NoError(err)
+	assert.Len(devices, 1)
+
+	params := devices[0].QemuParams(&govmmQemu.Config{})
+	assert.Len(params, 6)
+	assert.Equal("socket,id=char-deadbeef,path=/tmp/vhu.sock", params[1])
+	assert.Equal("type=vhost-user,id=net-deadbeef,chardev=char-deadbeef,vhostforce", params[3])
+	assert.Equal("virtio-net-pci,netdev=net-deadbeef,mac=02:00:ca:fe:00:48,romfile=,id=virtio-net-deadbeef", params[5])
+	assert.True(devices[0].Valid())
+
+	// Server mode and multiqueue
+	attr.Server = true
+	attr.Queues = 4
+
+	devices, err = qemuArchBase.appendVhostUserDevice(nil, attr)
+	assert.NoError(err)
+
+	params = devices[0].QemuParams(&govmmQemu.Config{})
+	assert.Len(params, 6)
+	assert.Equal("socket,id=char-deadbeef,path=/tmp/vhu.sock,server,nowait", params[1])
+	assert.Equal("type=vhost-user,id=net-deadbeef,chardev=char-deadbeef,vhostforce,queues=4", params[3])
+	assert.Equal("virtio-net-pci,netdev=net-deadbeef,mac=02:00:ca:fe:00:48,romfile=,mq=on,vectors=10,id=virtio-net-deadbeef", params[5])
+}
+
 func TestQemuArchBaseAppendVFIODevice(t *testing.T) {
 	bdf := "02:10.1"
 
@@ -501,3 +571,24 @@ func TestQemuArchBaseAppendNetwork(t *testing.T) {
 	devices = qemuArchBase.appendNetwork(devices, macvtapEp)
 	assert.Equal(expectedOut, devices)
 }
+
+func TestQemuArchBaseAppendBalloonDevice(t *testing.T) {
+	assert := assert.New(t)
+	qemuArchBase := newQemuArchBase()
+
+	devices := qemuArchBase.appendBalloonDevice(nil, "balloonTest", false)
+	assert.Equal([]govmmQemu.Device{
+		balloonDevice{
+			Driver:       balloonPCIDriver,
+			ID:           "balloonTest",
+			DeflateOnOOM: true,
+		},
+	}, devices)
+
+	params := devices[0].QemuParams(&govmmQemu.Config{})
+	assert.Equal([]string{"-device", "virtio-balloon-pci,id=balloonTest,romfile=,deflate-on-oom=on,disable-modern=false"}, params)
+
+	devices = qemuArchBase.appendBalloonDevice(nil, "balloonTest", true)
+	params = devices[0].QemuParams(&govmmQemu.Config{})
+	assert.Equal([]string{"-device", "virtio-balloon-pci,id=balloonTest,romfile=,deflate-on-oom=on,free-page-reporting=on,disable-modern=false"}, params)
+}
@@ -0,0 +1,165 @@
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+)
+
+// qmpResponse is a message read from the QMP socket: a command response, an
+// asynchronous event or the greeting.
+type qmpResponse struct {
+	Return json.RawMessage `json:"return"`
+	Error  *struct {
+		Class string `json:"class"`
+		Desc  string `json:"desc"`
+	} `json:"error"`
+	Event string          `json:"event"`
+	QMP   json.RawMessage `json:"QMP"`
+}
+
+// qmpConn is a QMP connection sending the commands govmm does not provide.
+type qmpConn struct {
+	conn    net.Conn
+	scanner *bufio.Scanner
+}
+
+// qmpDial connects to the QMP socket at path and negotiates the
+// capabilities.
+func qmpDial(ctx context.Context, path string) (*qmpConn, error) {
+	var d net.Dialer
+
+	conn, err := d.DialContext(ctx, "unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c := &qmpConn{
+		conn:    conn,
+		scanner: bufio.NewScanner(conn),
+	}
+
+	greeting, err := c.read()
+	if err == nil && greeting.QMP == nil {
+		err = fmt.Errorf("Unexpected QMP greeting")
+	}
+
+	if err == nil {
+		err = c.execute("qmp_capabilities", nil)
+	}
+
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// read returns the next message read from the socket.
+func (c *qmpConn) read() (*qmpResponse, error) {
+	if !c.scanner.Scan() {
+		if err := c.scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("QMP socket closed")
+	}
+
+	var resp qmpResponse
+	if err := json.Unmarshal(c.scanner.Bytes(), &resp); err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// execute sends a command and waits for its response, skipping the events
+// received in between.
+func (c *qmpConn) execute(name string, args map[string]interface{}) error {
+	cmd := map[string]interface{}{"execute": name}
+	if args != nil {
+		cmd["arguments"] = args
+	}
+
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		return err
+	}
+
+	if _, err := c.conn.Write(append(data, '\n')); err != nil {
+		return err
+	}
+
+	for {
+		resp, err := c.read()
+		if err != nil {
+			return err
+		}
+
+		if resp.Error != nil {
+			return fmt.Errorf("QMP command %s failed: %s", name, resp.Error.Desc)
+		}
+
+		if resp.Return != nil {
+			return nil
+		}
+	}
+}
+
+func (c *qmpConn) close() error {
+	return c.conn.Close()
+}
+
+// qmpExecute sends a QMP command govmm does not provide. QEMU serves a
+// single client on its QMP socket, so the govmm connection is closed while
+// the command is sent, and reopened afterwards.
+func (q *qemu) qmpExecute(name string, args map[string]interface{}) error {
+	q.qmpShutdown()
+
+	c, err := qmpDial(q.qmpMonitorCh.ctx, q.qmpMonitorCh.path)
+	if err == nil {
+		err = c.execute(name, args)
+		c.close()
+	}
+
+	if setupErr := q.qmpSetup(); err == nil {
+		err = setupErr
+	}
+
+	return err
+}
+
+// qmpChardevDel removes the char device identified by id.
+func (q *qemu) qmpChardevDel(id string) error {
+	return q.qmpExecute("chardev-remove", map[string]interface{}{
+		"id": id,
+	})
+}
+
+// qmpPCIVhostUserDevAdd hot plugs a vhost-user device, backed by the char
+// device chardevID, at the addr slot of the PCI bus.
+func (q *qemu) qmpPCIVhostUserDevAdd(driver, devID, chardevID, addr, bus string) error {
+	args := map[string]interface{}{
+		"driver":  driver,
+		"id":      devID,
+		"chardev": chardevID,
+		"addr":    addr,
+	}
+
+	if bus != "" {
+		args["bus"] = bus
+	}
+
+	return q.qmpExecute("device_add", args)
+}
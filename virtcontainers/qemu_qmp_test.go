@@ -0,0 +1,120 @@
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// startFakeQMPServer serves a single QMP client on a socket in dir,
+// answering the commands with an event followed by a response, an error for
+// the failing command. The received commands are sent to cmdCh.
+func startFakeQMPServer(t *testing.T, dir, failing string, cmdCh chan<- map[string]interface{}) string {
+	path := filepath.Join(dir, "qmp.sock")
+
+	l, err := net.Listen("unix", path)
+	assert.NoError(t, err)
+
+	go func() {
+		defer l.Close()
+
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		fmt.Fprintln(conn, `{"QMP": {"version": {"qemu": {"micro": 0, "minor": 1, "major": 4}}, "capabilities": []}}`)
+
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			var cmd map[string]interface{}
+			if err := json.Unmarshal(scanner.Bytes(), &cmd); err != nil {
+				return
+			}
+
+			cmdCh <- cmd
+
+			fmt.Fprintln(conn, `{"event": "NIC_RX_FILTER_CHANGED", "data": {}}`)
+
+			if cmd["execute"] == failing {
+				fmt.Fprintln(conn, `{"error": {"class": "GenericError", "desc": "command failed"}}`)
+			} else {
+				fmt.Fprintln(conn, `{"return": {}}`)
+			}
+		}
+	}()
+
+	return path
+}
+
+func TestQMPConnExecute(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "qmp")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	cmdCh := make(chan map[string]interface{}, 3)
+	path := startFakeQMPServer(t, dir, "device_add", cmdCh)
+
+	c, err := qmpDial(context.Background(), path)
+	assert.NoError(err)
+	defer c.close()
+
+	cmd := <-cmdCh
+	assert.Equal("qmp_capabilities", cmd["execute"])
+	assert.Nil(cmd["arguments"])
+
+	err = c.execute("chardev-remove", map[string]interface{}{"id": "char0"})
+	assert.NoError(err)
+
+	cmd = <-cmdCh
+	assert.Equal("chardev-remove", cmd["execute"])
+	assert.Equal(map[string]interface{}{"id": "char0"}, cmd["arguments"])
+
+	err = c.execute("device_add", map[string]interface{}{"id": "blk0"})
+	assert.Error(err)
+	assert.Contains(err.Error(), "command failed")
+}
+
+func TestQMPDialNoGreeting(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "qmp")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "qmp.sock")
+	l, err := net.Listen("unix", path)
+	assert.NoError(err)
+	defer l.Close()
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		fmt.Fprintln(conn, `{"return": {}}`)
+		conn.Close()
+	}()
+
+	_, err = qmpDial(context.Background(), path)
+	assert.Error(err)
+
+	_, err = qmpDial(context.Background(), filepath.Join(dir, "missing.sock"))
+	assert.Error(err)
+}
@@ -26,6 +26,8 @@ const defaultQemuMachineOptions = "accel=kvm"
 
 const virtioSerialCCW = "virtio-serial-ccw"
 
+const virtioBalloonCCW = "virtio-balloon-ccw"
+
 const qmpCapMigrationBypassSharedMemory = "bypass-shared-memory"
 
 const qmpMigrationWaitTimeout = 5 * time.Second
@@ -116,6 +118,21 @@ func (q *qemuS390x) appendConsole(devices []govmmQemu.Device, path string) []gov
 	return devices
 }
 
+// appendBalloonDevice appends a memory balloon device to devices.
+// The function has been overwriten to correctly set the driver to the CCW device
+func (q *qemuS390x) appendBalloonDevice(devices []govmmQemu.Device, id string, freePageReporting bool) []govmmQemu.Device {
+	devices = append(devices,
+		balloonDevice{
+			Driver:            virtioBalloonCCW,
+			ID:                id,
+			DeflateOnOOM:      true,
+			FreePageReporting: freePageReporting,
+		},
+	)
+
+	return devices
+}
+
 // appendVhostUserDevice throws an error if vhost devices are tried to be used.
 // See issue https://github.com/kata-containers/runtime/issues/659
 func (q *qemuS390x) appendVhostUserDevice(devices []govmmQemu.Device, attr config.VhostUserDeviceAttrs) ([]govmmQemu.Device, error) {
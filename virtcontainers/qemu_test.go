@@ -328,6 +328,26 @@ func TestQemuCapabilities(t *testing.T) {
 	if !caps.IsBlockDeviceHotplugSupported() {
 		t.Fatal("Block device hotplug should be supported")
 	}
+
+	if caps.IsMemoryBalloonSupported() {
+		t.Fatal("Memory balloon should not be supported")
+	}
+
+	q.config.MemoryBalloon = true
+	caps = q.capabilities()
+	if !caps.IsMemoryBalloonSupported() {
+		t.Fatal("Memory balloon should be supported")
+	}
+}
+
+func TestQemuResizeBalloonDisabled(t *testing.T) {
+	q := &qemu{
+		ctx:  context.Background(),
+		arch: &qemuArchBase{},
+	}
+
+	_, err := q.resizeBalloon(1024)
+	assert.Error(t, err)
 }
 
 func TestQemuQemuPath(t *testing.T) {
@@ -484,6 +504,49 @@ func TestQemuAddDeviceToBridge(t *testing.T) {
 	assert.Equal(exceptErr, err)
 }
 
+func TestQemuAddDeviceToPCISlot(t *testing.T) {
+	assert := assert.New(t)
+
+	config := newQemuConfig()
+	config.DefaultBridges = defaultBridges
+	config.PCIeRootPort = 1
+	config.HypervisorMachineType = QemuQ35
+
+	q := &qemu{
+		config: config,
+		arch:   newQemuArch(config),
+	}
+	q.state.Bridges = q.arch.bridges(q.config.DefaultBridges)
+	q.state.PCIeRootPorts = q.arch.pcieRootPorts(q.config.PCIeRootPort)
+	q.state.Bridges[0].Addr = bridgePCIStartAddr
+	q.state.PCIeRootPorts[0].Addr = bridgePCIStartAddr + 1
+
+	// the first device goes to the free root port
+	addr, bus, pciAddr, err := q.addDeviceToPCISlot("dev0")
+	assert.NoError(err)
+	assert.Equal("00", addr)
+	assert.Equal(q.state.PCIeRootPorts[0].ID, bus)
+	assert.Equal("03/00", pciAddr)
+	assert.Equal("dev0", q.state.PCIeRootPorts[0].DeviceID)
+
+	// next devices fall back to the bridge
+	addr, bus, pciAddr, err = q.addDeviceToPCISlot("dev1")
+	assert.NoError(err)
+	assert.Equal("01", addr)
+	assert.Equal(q.state.Bridges[0].ID, bus)
+	assert.Equal("02/01", pciAddr)
+
+	// freeing the root port makes it available again
+	assert.NoError(q.removeDeviceFromPCISlot("dev0"))
+	assert.Empty(q.state.PCIeRootPorts[0].DeviceID)
+	assert.NoError(q.removeDeviceFromPCISlot("dev1"))
+	assert.Error(q.removeDeviceFromPCISlot("dev2"))
+
+	_, bus, _, err = q.addDeviceToPCISlot("dev2")
+	assert.NoError(err)
+	assert.Equal(q.state.PCIeRootPorts[0].ID, bus)
+}
+
 func TestQemuFileBackedMem(t *testing.T) {
 	assert := assert.New(t)
 
@@ -516,6 +579,20 @@ func TestQemuFileBackedMem(t *testing.T) {
 	expectErr := errors.New("VM templating has been enabled with either virtio-fs or file backed memory and this configuration will not work")
 	assert.Equal(expectErr, err)
 
+	// Check setting vhost-user store requires shared memory
+	sandbox, err = createQemuSandboxConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	q = &qemu{}
+	sandbox.config.HypervisorConfig.EnableVhostUserStore = true
+	if err = q.createSandbox(context.Background(), sandbox.id, &sandbox.config.HypervisorConfig, sandbox.store); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(q.qemuConfig.Knobs.FileBackedMem, true)
+	assert.Equal(q.qemuConfig.Knobs.FileBackedMemShared, true)
+	assert.Equal(q.qemuConfig.Memory.Path, fallbackFileBackedMemDir)
+
 	// Check Setting of non-existent shared-mem path
 	sandbox, err = createQemuSandboxConfig()
 	if err != nil {
@@ -13,6 +13,7 @@ import (
 	"os"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/containernetworking/plugins/pkg/ns"
 	specs "github.com/opencontainers/runtime-spec/specs-go"
@@ -57,6 +58,32 @@ type SandboxStatus struct {
 	Annotations map[string]string
 }
 
+// SandboxResources describes the resources reserved for a sandbox itself,
+// on top of the resources of its containers.
+type SandboxResources struct {
+	// VCPUs is the number of vCPUs of the sandbox.
+	VCPUs uint32
+
+	// MemoryMB is the memory of the sandbox, in MiB.
+	MemoryMB uint32
+}
+
+// SandboxMetadata is the sandbox information published to the guest by the
+// hypervisors providing a metadata service.
+type SandboxMetadata struct {
+	ID          string              `json:"id"`
+	Annotations map[string]string   `json:"annotations,omitempty"`
+	Interfaces  []InterfaceMetadata `json:"interfaces,omitempty"`
+}
+
+// InterfaceMetadata describes a network interface of the sandbox.
+type InterfaceMetadata struct {
+	Name         string   `json:"name"`
+	HardwareAddr string   `json:"hwaddr"`
+	Addresses    []string `json:"addresses,omitempty"`
+	Routes       []string `json:"routes,omitempty"`
+}
+
 // SandboxConfig is a Sandbox configuration.
 type SandboxConfig struct {
 	ID string
@@ -104,6 +131,15 @@ type SandboxConfig struct {
 
 	DisableGuestSeccomp bool
 
+	// SharedMountPolicy selects the container bind mounts copied into the
+	// guest, or shared read-only with it.
+	SharedMountPolicy SharedMountPolicy
+
+	// GuestClockSyncInterval is how often the guest clock of a stateful
+	// sandbox is synced with the host one. Zero disables the periodic
+	// sync, the guest clock still being synced after a resume.
+	GuestClockSyncInterval time.Duration
+
 	// Experimental features enabled
 	Experimental []exp.Feature
 }
@@ -164,8 +200,9 @@ type Sandbox struct {
 	// store is used to replace VCStore step by step
 	newStore persistapi.PersistDriver
 
-	network Network
-	monitor *monitor
+	network    Network
+	monitor    *monitor
+	maintainer *guestMaintainer
 
 	config *SandboxConfig
 
@@ -238,11 +275,66 @@ func (s *Sandbox) GetAnnotations() map[string]string {
 	return s.config.Annotations
 }
 
+// metadata returns the metadata of the sandbox published to the guest. The
+// OCI configuration, which may hold secrets, is not part of it.
+func (s *Sandbox) metadata() *SandboxMetadata {
+	metadata := &SandboxMetadata{
+		ID:          s.id,
+		Annotations: make(map[string]string),
+	}
+
+	for k, v := range s.GetAnnotations() {
+		if k == annotations.ConfigJSONKey {
+			continue
+		}
+		metadata.Annotations[k] = v
+	}
+
+	for _, endpoint := range s.networkNS.Endpoints {
+		iface := InterfaceMetadata{
+			Name:         endpoint.Name(),
+			HardwareAddr: endpoint.HardwareAddr(),
+		}
+
+		props := endpoint.Properties()
+		for _, addr := range props.Addrs {
+			if addr.IPNet != nil {
+				iface.Addresses = append(iface.Addresses, addr.IPNet.String())
+			}
+		}
+
+		for _, route := range props.Routes {
+			dst := "default"
+			if route.Dst != nil {
+				dst = route.Dst.String()
+			}
+			if route.Gw != nil {
+				dst += " via " + route.Gw.String()
+			}
+			iface.Routes = append(iface.Routes, dst)
+		}
+
+		metadata.Interfaces = append(metadata.Interfaces, iface)
+	}
+
+	return metadata
+}
+
 // GetNetNs returns the network namespace of the current sandbox.
 func (s *Sandbox) GetNetNs() string {
 	return s.networkNS.NetNsPath
 }
 
+// GetHypervisorPid returns the PID of the hypervisor running the sandbox VM.
+func (s *Sandbox) GetHypervisorPid() (int, error) {
+	pid := s.hypervisor.pid()
+	if pid <= 0 {
+		return -1, fmt.Errorf("Invalid hypervisor PID: %d", pid)
+	}
+
+	return pid, nil
+}
+
 // GetAllContainers returns all containers.
 func (s *Sandbox) GetAllContainers() []VCContainer {
 	ifa := make([]VCContainer, len(s.containers))
@@ -273,6 +365,7 @@ func (s *Sandbox) Release() error {
 	if s.monitor != nil {
 		s.monitor.stop()
 	}
+	s.stopGuestMaintainer()
 	s.hypervisor.disconnect()
 	return s.agent.disconnect()
 }
@@ -411,7 +504,13 @@ func createAssets(ctx context.Context, sandboxConfig *SandboxConfig) error {
 		return fmt.Errorf("%s and %s cannot be both set", types.ImageAsset, types.InitrdAsset)
 	}
 
+	policy := sandboxConfig.HypervisorConfig.assetPolicy()
+
 	for _, a := range []*types.Asset{kernel, image, initrd} {
+		if err := policy.Check(a); err != nil {
+			return err
+		}
+
 		if err := sandboxConfig.HypervisorConfig.addCustomAsset(a); err != nil {
 			return err
 		}
@@ -475,7 +574,9 @@ func createSandbox(ctx context.Context, sandboxConfig SandboxConfig, factory Fac
 	}
 
 	if s.supportNewStore() {
-		s.devManager = deviceManager.NewDeviceManager(sandboxConfig.HypervisorConfig.BlockDeviceDriver, nil)
+		s.devManager = deviceManager.NewDeviceManager(sandboxConfig.HypervisorConfig.BlockDeviceDriver,
+			sandboxConfig.HypervisorConfig.EnableVhostUserStore,
+			sandboxConfig.HypervisorConfig.VhostUserStorePath, nil)
 
 		if err := s.Restore(); err == nil && s.state.State != "" {
 			return s, nil
@@ -486,7 +587,9 @@ func createSandbox(ctx context.Context, sandboxConfig SandboxConfig, factory Fac
 		if err != nil {
 			s.Logger().WithError(err).WithField("sandboxid", s.id).Warning("load sandbox devices failed")
 		}
-		s.devManager = deviceManager.NewDeviceManager(sandboxConfig.HypervisorConfig.BlockDeviceDriver, devices)
+		s.devManager = deviceManager.NewDeviceManager(sandboxConfig.HypervisorConfig.BlockDeviceDriver,
+			sandboxConfig.HypervisorConfig.EnableVhostUserStore,
+			sandboxConfig.HypervisorConfig.VhostUserStorePath, devices)
 
 		// We first try to fetch the sandbox state from storage.
 		// If it exists, this means this is a re-creation, i.e.
@@ -761,6 +864,8 @@ func (s *Sandbox) Delete() error {
 		s.monitor.stop()
 	}
 
+	s.stopGuestMaintainer()
+
 	if err := s.hypervisor.cleanup(); err != nil {
 		s.Logger().WithError(err).Error("failed to cleanup hypervisor")
 	}
@@ -895,7 +1000,7 @@ func (s *Sandbox) AddInterface(inf *vcTypes.Interface) (*vcTypes.Interface, erro
 		return nil, err
 	}
 
-	endpoint, err := createEndpoint(netInfo, len(s.networkNS.Endpoints), s.config.NetworkConfig.InterworkingModel)
+	endpoint, err := createEndpoint(netInfo, len(s.networkNS.Endpoints), s.networkNS.NetNsPath, &s.config.NetworkConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -1007,6 +1112,10 @@ func (s *Sandbox) startVM() (err error) {
 		}
 	}
 
+	if err := s.hypervisor.publishMetadata(s.metadata()); err != nil {
+		return err
+	}
+
 	s.Logger().Info("VM started")
 
 	// Once the hypervisor is done starting the sandbox,
@@ -1359,6 +1468,11 @@ func (s *Sandbox) ResumeContainer(containerID string) error {
 		return err
 	}
 
+	// The container may have been paused along with the whole VM.
+	if err := s.syncGuestClock(); err != nil {
+		s.Logger().WithError(err).Warn("Failed to sync guest time after container resume")
+	}
+
 	if err = s.storeSandbox(); err != nil {
 		return err
 	}
@@ -1421,6 +1535,12 @@ func (s *Sandbox) Start() error {
 		return err
 	}
 
+	// Only a stateful sandbox lives as long as the VM, and can keep the
+	// guest clock in sync.
+	if s.stateful {
+		s.maintainGuest()
+	}
+
 	s.Logger().Info("Sandbox is started")
 
 	return nil
@@ -1447,10 +1567,16 @@ func (s *Sandbox) Stop() error {
 		}
 	}
 
+	s.stopGuestMaintainer()
+
 	if err := s.stopVM(); err != nil {
 		return err
 	}
 
+	if err := s.removeLocalStorageImages(); err != nil {
+		return err
+	}
+
 	if err := s.setSandboxState(types.StateStopped); err != nil {
 		return err
 	}
@@ -1467,6 +1593,24 @@ func (s *Sandbox) Stop() error {
 	return nil
 }
 
+// removeLocalStorageImages removes the images backing the size limited
+// local storages, which live as long as the sandbox. The VM being stopped,
+// they do not need to be detached.
+func (s *Sandbox) removeLocalStorageImages() error {
+	for _, device := range s.devManager.GetAllDevices() {
+		path := localStorageImagePath(device)
+		if path == "" {
+			continue
+		}
+
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // Pause pauses the sandbox
 func (s *Sandbox) Pause() error {
 	if err := s.hypervisor.pauseSandbox(); err != nil {
@@ -1481,6 +1625,9 @@ func (s *Sandbox) Pause() error {
 		s.monitor.stop()
 	}
 
+	// The guest cannot be reached while paused.
+	s.stopGuestMaintainer()
+
 	if err := s.pauseSetStates(); err != nil {
 		return err
 	}
@@ -1498,6 +1645,20 @@ func (s *Sandbox) Resume() error {
 		return err
 	}
 
+	// The guest clock stood still while the VM was paused, and the
+	// guest RNG state could have been restored elsewhere.
+	if err := s.syncGuestClock(); err != nil {
+		s.Logger().WithError(err).Warn("Failed to sync guest time after resume")
+	}
+
+	if err := s.reseedGuestRNG(); err != nil {
+		s.Logger().WithError(err).Warn("Failed to reseed guest random number generator after resume")
+	}
+
+	if s.maintainer != nil {
+		s.maintainer.start()
+	}
+
 	if err := s.resumeSetStates(); err != nil {
 		return err
 	}
@@ -1509,6 +1670,20 @@ func (s *Sandbox) Resume() error {
 	return nil
 }
 
+// debugConsoleURL returns the URL of the guest debug console.
+func (s *Sandbox) debugConsoleURL() (string, error) {
+	consoleURL, err := s.agent.debugConsoleURL(s)
+	if err != nil {
+		return "", err
+	}
+
+	if consoleURL == "" {
+		return "", fmt.Errorf("No debug console for sandbox %s", s.id)
+	}
+
+	return consoleURL, nil
+}
+
 // list lists all sandbox running on the host.
 func (s *Sandbox) list() ([]Sandbox, error) {
 	return nil, nil
@@ -1674,6 +1849,13 @@ func (s *Sandbox) HotplugAddDevice(device api.Device, devType config.DeviceType)
 		}
 		_, err := s.hypervisor.hotplugAddDevice(blockDevice.BlockDrive, blockDev)
 		return err
+	case config.VhostUserBlk, config.VhostUserSCSI:
+		vhostUserDev, ok := device.GetDeviceInfo().(*config.VhostUserDeviceAttrs)
+		if !ok {
+			return fmt.Errorf("device type mismatch, expect device type to be %s", devType)
+		}
+		_, err := s.hypervisor.hotplugAddDevice(vhostUserDev, vhostuserDev)
+		return err
 	case config.DeviceGeneric:
 		// TODO: what?
 		return nil
@@ -1711,6 +1893,13 @@ func (s *Sandbox) HotplugRemoveDevice(device api.Device, devType config.DeviceTy
 		}
 		_, err := s.hypervisor.hotplugRemoveDevice(blockDrive, blockDev)
 		return err
+	case config.VhostUserBlk, config.VhostUserSCSI:
+		vhostUserDev, ok := device.GetDeviceInfo().(*config.VhostUserDeviceAttrs)
+		if !ok {
+			return fmt.Errorf("device type mismatch, expect device type to be %s", devType)
+		}
+		_, err := s.hypervisor.hotplugRemoveDevice(vhostUserDev, vhostuserDev)
+		return err
 	case config.DeviceGeneric:
 		// TODO: what?
 		return nil
@@ -1765,6 +1954,56 @@ func (s *Sandbox) AddDevice(info config.DeviceInfo) (api.Device, error) {
 	return b, nil
 }
 
+// baseResources returns the vCPUs and the memory reserved for the sandbox
+// itself, either set through UpdateResources or the hypervisor defaults.
+func (s *Sandbox) baseResources() (uint32, uint32) {
+	vcpus := s.state.BaseVCPUs
+	if vcpus == 0 {
+		vcpus = s.hypervisor.hypervisorConfig().NumVCPUs
+	}
+
+	memoryMB := s.state.BaseMemoryMB
+	if memoryMB == 0 {
+		memoryMB = s.hypervisor.hypervisorConfig().MemorySize
+	}
+
+	return vcpus, memoryMB
+}
+
+// UpdateResources sets the vCPUs and the memory reserved for the sandbox
+// itself, on top of the containers resources, and resizes the VM
+// accordingly. Zero values keep the current settings.
+func (s *Sandbox) UpdateResources(resources SandboxResources) error {
+	if s.state.State != types.StateRunning {
+		return fmt.Errorf("Sandbox not running, impossible to update its resources")
+	}
+
+	oldState := s.state
+	if resources.VCPUs > 0 {
+		s.state.BaseVCPUs = resources.VCPUs
+	}
+	if resources.MemoryMB > 0 {
+		s.state.BaseMemoryMB = resources.MemoryMB
+	}
+
+	if err := s.updateResources(); err != nil {
+		s.state = oldState
+		return err
+	}
+
+	if err := s.updateCgroups(); err != nil {
+		return err
+	}
+
+	if !s.supportNewStore() {
+		if err := s.store.Store(store.State, s.state); err != nil {
+			return err
+		}
+	}
+
+	return s.storeSandbox()
+}
+
 func (s *Sandbox) updateResources() error {
 	// the hypervisor.MemorySize is the amount of memory reserved for
 	// the VM and contaniners without memory limit
@@ -1777,11 +2016,13 @@ func (s *Sandbox) updateResources() error {
 		return fmt.Errorf("sandbox config is nil")
 	}
 
+	baseVCPUs, baseMemoryMB := s.baseResources()
+
 	sandboxVCPUs := s.calculateSandboxCPUs()
-	// Add default vcpus for sandbox
-	sandboxVCPUs += s.hypervisor.hypervisorConfig().NumVCPUs
+	// Add base vcpus for sandbox
+	sandboxVCPUs += baseVCPUs
 
-	sandboxMemoryByte := int64(s.hypervisor.hypervisorConfig().MemorySize) << utils.MibToBytesShift
+	sandboxMemoryByte := int64(baseMemoryMB) << utils.MibToBytesShift
 	sandboxMemoryByte += s.calculateSandboxMemory()
 
 	// Update VCPUs
@@ -1801,7 +2042,24 @@ func (s *Sandbox) updateResources() error {
 
 	// Update Memory
 	s.Logger().WithField("memory-sandbox-size-byte", sandboxMemoryByte).Debugf("Request to hypervisor to update memory")
-	newMemory, updatedMemoryDevice, err := s.hypervisor.resizeMemory(uint32(sandboxMemoryByte>>utils.MibToBytesShift), s.state.GuestMemoryBlockSizeMB, s.state.GuestMemoryHotplugProbe)
+	sandboxMemoryMB := uint32(sandboxMemoryByte >> utils.MibToBytesShift)
+
+	caps := s.hypervisor.capabilities()
+	balloon := caps.IsMemoryBalloonSupported()
+	if balloon {
+		// Inflate the balloon when the sandbox memory shrinks, and
+		// deflate it before hot adding memory.
+		balloonMemory, err := s.hypervisor.resizeBalloon(sandboxMemoryMB)
+		if err != nil {
+			return err
+		}
+		if balloonMemory >= sandboxMemoryMB {
+			s.Logger().Debugf("Sandbox memory size: %d MB", balloonMemory)
+			return nil
+		}
+	}
+
+	newMemory, updatedMemoryDevice, err := s.hypervisor.resizeMemory(sandboxMemoryMB, s.state.GuestMemoryBlockSizeMB, s.state.GuestMemoryHotplugProbe)
 	if err != nil {
 		return err
 	}
@@ -1813,6 +2071,13 @@ func (s *Sandbox) updateResources() error {
 			return err
 		}
 	}
+	if balloon {
+		// The balloon target is relative to the whole VM memory, keep it
+		// deflated so that it does not take the hot added memory back.
+		if _, err := s.hypervisor.resizeBalloon(newMemory); err != nil {
+			return err
+		}
+	}
 	if err := s.agent.onlineCPUMem(0, false); err != nil {
 		return err
 	}
@@ -1821,10 +2086,23 @@ func (s *Sandbox) updateResources() error {
 
 func (s *Sandbox) calculateSandboxMemory() int64 {
 	memorySandbox := int64(0)
+	ephemeralVolumes := make(map[string]bool)
 	for _, c := range s.config.Containers {
 		if m := c.Resources.Memory; m != nil && m.Limit != nil {
 			memorySandbox += *m.Limit
 		}
+
+		// Ephemeral volumes are tmpfs backed, shared by the containers
+		for _, m := range c.Mounts {
+			if m.Type != KataEphemeralDevType || ephemeralVolumes[m.Source] {
+				continue
+			}
+
+			if size, _, err := storageSizeLimit(m.Options); err == nil && size > 0 {
+				memorySandbox += int64(size)
+				ephemeralVolumes[m.Source] = true
+			}
+		}
 	}
 	return memorySandbox
 }
@@ -10,6 +10,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"os"
 	"os/exec"
 	"path"
@@ -28,6 +29,7 @@ import (
 	"github.com/kata-containers/runtime/virtcontainers/types"
 	specs "github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/stretchr/testify/assert"
+	"github.com/vishvananda/netlink"
 	"golang.org/x/sys/unix"
 )
 
@@ -146,6 +148,14 @@ func TestCalculateSandboxMem(t *testing.T) {
 	constrained := newTestContainerConfigNoop("cont-00001")
 	limit := int64(4000)
 	constrained.Resources.Memory = &specs.LinuxMemory{Limit: &limit}
+	ephemeral := newTestContainerConfigNoop("cont-00002")
+	ephemeral.Mounts = []Mount{
+		{
+			Source:  "/kubernetes.io~empty-dir/volume",
+			Type:    KataEphemeralDevType,
+			Options: []string{StorageSizeOption(1000)},
+		},
+	}
 
 	tests := []struct {
 		name       string
@@ -158,6 +168,8 @@ func TestCalculateSandboxMem(t *testing.T) {
 		{"2-constrained", []ContainerConfig{constrained, constrained}, limit * 2},
 		{"3-mix-constraints", []ContainerConfig{unconstrained, constrained, constrained}, limit * 2},
 		{"3-constrained", []ContainerConfig{constrained, constrained, constrained}, limit * 3},
+		{"1-ephemeral", []ContainerConfig{ephemeral}, 1000},
+		{"2-ephemeral-shared", []ContainerConfig{constrained, ephemeral, ephemeral}, limit + 1000},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -868,7 +880,7 @@ func TestSandboxAttachDevicesVFIO(t *testing.T) {
 		config.SysIOMMUPath = savedIOMMUPath
 	}()
 
-	dm := manager.NewDeviceManager(manager.VirtioSCSI, nil)
+	dm := manager.NewDeviceManager(manager.VirtioSCSI, false, "", nil)
 	path := filepath.Join(vfioPath, testFDIOGroup)
 	deviceInfo := config.DeviceInfo{
 		HostPath:      path,
@@ -967,6 +979,26 @@ func TestSandboxCreateAssets(t *testing.T) {
 
 	err = createAssets(context.Background(), p)
 	assert.NotNil(err)
+
+	// Custom asset outside of the allowed directories
+	hc.CustomAssetDirs = []string{testDir}
+
+	p = &SandboxConfig{
+		Annotations: map[string]string{
+			annotations.KernelPath: tmpfile.Name(),
+		},
+
+		HypervisorConfig: hc,
+	}
+
+	err = createAssets(context.Background(), p)
+	assert.NotNil(err)
+
+	hc.CustomAssetDirs = append(hc.CustomAssetDirs, filepath.Dir(tmpfile.Name()))
+	p.HypervisorConfig = hc
+
+	err = createAssets(context.Background(), p)
+	assert.Nil(err)
 }
 
 func testFindContainerFailure(t *testing.T, sandbox *Sandbox, cid string) {
@@ -1321,7 +1353,7 @@ func TestAttachBlockDevice(t *testing.T) {
 		DevType:       "b",
 	}
 
-	dm := manager.NewDeviceManager(config.VirtioBlock, nil)
+	dm := manager.NewDeviceManager(config.VirtioBlock, false, "", nil)
 	device, err := dm.NewDevice(deviceInfo)
 	assert.Nil(t, err)
 	_, ok := device.(*drivers.BlockDevice)
@@ -1367,7 +1399,7 @@ func TestPreAddDevice(t *testing.T) {
 		HypervisorConfig: hConfig,
 	}
 
-	dm := manager.NewDeviceManager(config.VirtioBlock, nil)
+	dm := manager.NewDeviceManager(config.VirtioBlock, false, "", nil)
 	// create a sandbox first
 	sandbox := &Sandbox{
 		id:         testSandboxID,
@@ -1457,6 +1489,70 @@ func TestGetNetNs(t *testing.T) {
 	assert.Equal(t, netNs, expected)
 }
 
+func TestGetHypervisorPid(t *testing.T) {
+	assert := assert.New(t)
+
+	h := &mockHypervisor{}
+	s := Sandbox{hypervisor: h}
+
+	_, err := s.GetHypervisorPid()
+	assert.Error(err)
+
+	h.mockPid = 1234
+	pid, err := s.GetHypervisorPid()
+	assert.NoError(err)
+	assert.Equal(pid, 1234)
+}
+
+func TestSandboxMetadata(t *testing.T) {
+	assert := assert.New(t)
+
+	_, ipNet, err := net.ParseCIDR("172.17.0.2/16")
+	assert.NoError(err)
+	ipNet.IP = net.ParseIP("172.17.0.2")
+
+	s := &Sandbox{
+		id:              testSandboxID,
+		annotationsLock: &sync.RWMutex{},
+		config: &SandboxConfig{
+			Annotations: map[string]string{
+				annotations.ConfigJSONKey:    "{}",
+				annotations.ContainerTypeKey: "pod_sandbox",
+			},
+		},
+		networkNS: NetworkNamespace{
+			Endpoints: []Endpoint{
+				&PhysicalEndpoint{
+					IfaceName: "eth0",
+					HardAddr:  "02:00:ca:fe:00:04",
+					EndpointProperties: NetworkInfo{
+						Addrs: []netlink.Addr{{IPNet: ipNet}},
+						Routes: []netlink.Route{
+							{Gw: net.ParseIP("172.17.0.1")},
+							{Dst: &net.IPNet{IP: net.IPv4(10, 0, 0, 0), Mask: net.CIDRMask(8, 32)}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	assert.Equal(&SandboxMetadata{
+		ID: testSandboxID,
+		Annotations: map[string]string{
+			annotations.ContainerTypeKey: "pod_sandbox",
+		},
+		Interfaces: []InterfaceMetadata{
+			{
+				Name:         "eth0",
+				HardwareAddr: "02:00:ca:fe:00:04",
+				Addresses:    []string{"172.17.0.2/16"},
+				Routes:       []string{"default via 172.17.0.1", "10.0.0.0/8"},
+			},
+		},
+	}, s.metadata())
+}
+
 func TestStartNetworkMonitor(t *testing.T) {
 	trueBinPath, err := exec.LookPath("true")
 	assert.Nil(t, err)
@@ -1584,6 +1680,83 @@ func TestSandboxUpdateResources(t *testing.T) {
 	}
 }
 
+// resizeHypervisor records the sizes requested by the sandbox.
+type resizeHypervisor struct {
+	mockHypervisor
+	config   HypervisorConfig
+	vcpus    uint32
+	memoryMB uint32
+}
+
+func (h *resizeHypervisor) hypervisorConfig() HypervisorConfig {
+	return h.config
+}
+
+func (h *resizeHypervisor) resizeVCPUs(cpus uint32) (uint32, uint32, error) {
+	h.vcpus = cpus
+	return 0, 0, nil
+}
+
+func (h *resizeHypervisor) resizeMemory(memMB uint32, memorySectionSizeMB uint32, probe bool) (uint32, memoryDevice, error) {
+	h.memoryMB = memMB
+	return memMB, memoryDevice{}, nil
+}
+
+func TestSandboxUpdateBaseResources(t *testing.T) {
+	assert := assert.New(t)
+
+	defer cleanUp()
+
+	s, err := testCreateSandbox(t,
+		testSandboxID,
+		MockHypervisor,
+		newHypervisorConfig(nil, nil),
+		NoopAgentType,
+		NetworkConfig{},
+		nil,
+		nil)
+	assert.NoError(err)
+
+	h := &resizeHypervisor{
+		config: HypervisorConfig{
+			NumVCPUs:   1,
+			MemorySize: 2048,
+		},
+	}
+	s.hypervisor = h
+
+	// the sandbox must be running
+	err = s.UpdateResources(SandboxResources{VCPUs: 4})
+	assert.Error(err)
+
+	err = s.setSandboxState(types.StateRunning)
+	assert.NoError(err)
+
+	err = s.UpdateResources(SandboxResources{VCPUs: 4})
+	assert.NoError(err)
+	assert.Equal(uint32(4), h.vcpus)
+	assert.Equal(uint32(2048), h.memoryMB)
+
+	// unset values are kept
+	err = s.UpdateResources(SandboxResources{MemoryMB: 4096})
+	assert.NoError(err)
+	assert.Equal(uint32(4), h.vcpus)
+	assert.Equal(uint32(4096), h.memoryMB)
+
+	// container creations keep the base resources
+	err = s.updateResources()
+	assert.NoError(err)
+	assert.Equal(uint32(4), h.vcpus)
+	assert.Equal(uint32(4096), h.memoryMB)
+
+	// the base resources are stored with the sandbox state
+	globalSandboxList.removeSandbox(s.id)
+	fetched, err := fetchSandbox(context.Background(), s.id)
+	assert.NoError(err)
+	assert.Equal(uint32(4), fetched.state.BaseVCPUs)
+	assert.Equal(uint32(4096), fetched.state.BaseMemoryMB)
+}
+
 func TestSandboxExperimentalFeature(t *testing.T) {
 	testFeature := exp.Feature{
 		Name:        "mock",
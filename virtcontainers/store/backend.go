@@ -55,4 +55,7 @@ type backend interface {
 	raw(id string) (string, error)
 	lock(item Item, exclusive bool) (string, error)
 	unlock(item Item, token string) error
+	// lockHolders returns the processes holding, or which were holding
+	// when they died, the item lock.
+	lockHolders(item Item) ([]LockHolder, error)
 }
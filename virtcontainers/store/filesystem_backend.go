@@ -12,6 +12,8 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"syscall"
 
 	"github.com/kata-containers/runtime/virtcontainers/pkg/uuid"
@@ -286,14 +288,32 @@ func (f *filesystem) lock(item Item, exclusive bool) (string, error) {
 		lockType = syscall.LOCK_SH
 	}
 
-	if err := syscall.Flock(int(itemFile.Fd()), lockType); err != nil {
+	timeout := getLockTimeout()
+	locked, err := flock(itemFile, lockType, timeout)
+	if err != nil {
 		itemFile.Close()
 		return "", err
 	}
 
+	if !locked {
+		itemFile.Close()
+		holders, _ := f.lockHolders(item)
+		return "", &LockTimeoutError{
+			Path:    itemPath,
+			Timeout: timeout,
+			Holders: holders,
+		}
+	}
+
 	token := uuid.Generate().String()
 	f.lockTokens[token] = itemFile
 
+	// The holder is only recorded for diagnostics, the lock is taken
+	// even if it cannot be recorded.
+	if err := f.addLockHolder(item, token, exclusive); err != nil {
+		f.logger().WithError(err).WithField("item", item).Warn("Could not record lock holder")
+	}
+
 	return token, nil
 }
 
@@ -310,5 +330,110 @@ func (f *filesystem) unlock(item Item, token string) error {
 	itemFile.Close()
 	delete(f.lockTokens, token)
 
+	if holdersPath, err := f.lockHoldersPath(item); err == nil {
+		os.Remove(filepath.Join(holdersPath, token+".json"))
+	}
+
 	return nil
 }
+
+// lockHoldersPath returns the directory recording the holders of the
+// item lock, one file per lock token.
+func (f *filesystem) lockHoldersPath(item Item) (string, error) {
+	itemPath, err := f.itemToPath(item)
+	if err != nil {
+		return "", err
+	}
+
+	return itemPath + ".holders", nil
+}
+
+func (f *filesystem) addLockHolder(item Item, token string, exclusive bool) error {
+	holdersPath, err := f.lockHoldersPath(item)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(holdersPath, DirMode); err != nil {
+		return err
+	}
+
+	// Holders which were killed while holding the lock left their
+	// record behind.
+	if holders, err := f.readLockHolders(item); err == nil {
+		for t, h := range holders {
+			if h.Stale {
+				f.logger().WithField("holder", h.String()).Info("Removing stale lock holder")
+				os.Remove(filepath.Join(holdersPath, t+".json"))
+			}
+		}
+	}
+
+	data, err := json.Marshal(newLockHolder(exclusive))
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(holdersPath, token+".json"), data, 0640)
+}
+
+// lockHolders returns the recorded holders of the item lock, the oldest
+// first.
+func (f *filesystem) lockHolders(item Item) ([]LockHolder, error) {
+	tokens, err := f.readLockHolders(item)
+	if err != nil {
+		return nil, err
+	}
+
+	var holders []LockHolder
+	for _, h := range tokens {
+		holders = append(holders, h)
+	}
+
+	sort.Slice(holders, func(i, j int) bool {
+		return holders[i].Acquired.Before(holders[j].Acquired)
+	})
+
+	return holders, nil
+}
+
+// readLockHolders returns the recorded holders of the item lock, by lock
+// token.
+func (f *filesystem) readLockHolders(item Item) (map[string]LockHolder, error) {
+	holdersPath, err := f.lockHoldersPath(item)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := ioutil.ReadDir(holdersPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	holders := make(map[string]LockHolder)
+	for _, file := range files {
+		token := strings.TrimSuffix(file.Name(), ".json")
+		if token == file.Name() {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(holdersPath, file.Name()))
+		if err != nil {
+			// Released meanwhile
+			continue
+		}
+
+		var h LockHolder
+		if err := json.Unmarshal(data, &h); err != nil {
+			continue
+		}
+
+		h.Stale = h.isStale()
+		holders[token] = h
+	}
+
+	return holders, nil
+}
@@ -7,10 +7,14 @@ package store
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -147,3 +151,85 @@ func TestStoreFilesystemLockExclusive(t *testing.T) {
 	err = f.unlock(Lock, token)
 	assert.NotNil(t, err)
 }
+
+func TestStoreFilesystemLockTimeout(t *testing.T) {
+	f := filesystem{}
+
+	err := f.new(context.Background(), rootPath, "")
+	defer f.delete()
+	assert.Nil(t, err)
+
+	SetLockTimeout(50 * time.Millisecond)
+	defer SetLockTimeout(0)
+
+	token, err := f.lock(Lock, true)
+	assert.Nil(t, err)
+
+	holders, err := f.lockHolders(Lock)
+	assert.Nil(t, err)
+	assert.Len(t, holders, 1)
+	assert.Equal(t, os.Getpid(), holders[0].Pid)
+	assert.True(t, holders[0].Exclusive)
+	assert.False(t, holders[0].Stale)
+
+	// Every lock is a new open file description, conflicting with the
+	// exclusive lock even in the same process.
+	_, err = f.lock(Lock, false)
+	assert.True(t, IsLockTimeout(err))
+	assert.Contains(t, err.Error(), fmt.Sprintf("pid %d", os.Getpid()))
+
+	err = f.unlock(Lock, token)
+	assert.Nil(t, err)
+
+	holders, err = f.lockHolders(Lock)
+	assert.Nil(t, err)
+	assert.Empty(t, holders)
+
+	token, err = f.lock(Lock, false)
+	assert.Nil(t, err)
+
+	err = f.unlock(Lock, token)
+	assert.Nil(t, err)
+}
+
+func TestStoreFilesystemLockStaleHolder(t *testing.T) {
+	f := filesystem{}
+
+	err := f.new(context.Background(), rootPath, "")
+	defer f.delete()
+	assert.Nil(t, err)
+
+	// A holder killed while holding the lock
+	cmd := exec.Command("true")
+	assert.Nil(t, cmd.Run())
+
+	holdersPath, err := f.lockHoldersPath(Lock)
+	assert.Nil(t, err)
+	assert.Nil(t, os.MkdirAll(holdersPath, DirMode))
+
+	data, err := json.Marshal(LockHolder{
+		Pid:       cmd.Process.Pid,
+		Command:   "true",
+		Acquired:  time.Now(),
+		Exclusive: true,
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(holdersPath, "stale.json"), data, 0640))
+
+	holders, err := f.lockHolders(Lock)
+	assert.Nil(t, err)
+	assert.Len(t, holders, 1)
+	assert.True(t, holders[0].Stale)
+
+	// The lock was released with the holder, the stale record is removed
+	token, err := f.lock(Lock, true)
+	assert.Nil(t, err)
+
+	holders, err = f.lockHolders(Lock)
+	assert.Nil(t, err)
+	assert.Len(t, holders, 1)
+	assert.False(t, holders[0].Stale)
+
+	err = f.unlock(Lock, token)
+	assert.Nil(t, err)
+}
@@ -0,0 +1,147 @@
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package store
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// lockRetryInterval is how often a lock is retried until the lock timeout
+// expires.
+var lockRetryInterval = 10 * time.Millisecond
+
+var (
+	lockTimeout     time.Duration
+	lockTimeoutLock sync.RWMutex
+)
+
+// SetLockTimeout sets how long taking a lock waits for its holders to
+// release it. A zero timeout waits forever.
+func SetLockTimeout(timeout time.Duration) {
+	lockTimeoutLock.Lock()
+	defer lockTimeoutLock.Unlock()
+
+	lockTimeout = timeout
+}
+
+func getLockTimeout() time.Duration {
+	lockTimeoutLock.RLock()
+	defer lockTimeoutLock.RUnlock()
+
+	return lockTimeout
+}
+
+// LockHolder describes a process holding a lock.
+type LockHolder struct {
+	Pid       int       `json:"pid"`
+	Command   string    `json:"command"`
+	Acquired  time.Time `json:"acquired"`
+	Exclusive bool      `json:"exclusive"`
+
+	// Stale is set when the holder process is gone. The lock was
+	// released with it.
+	Stale bool `json:"-"`
+}
+
+func (h LockHolder) String() string {
+	mode := "shared"
+	if h.Exclusive {
+		mode = "exclusive"
+	}
+
+	return fmt.Sprintf("pid %d (%s), %s since %s", h.Pid, h.Command, mode, h.Acquired.Format(time.RFC3339))
+}
+
+// isStale returns true if the holder process is gone, or if its PID has
+// been reused by another process.
+func (h LockHolder) isStale() bool {
+	if err := syscall.Kill(h.Pid, 0); err == syscall.ESRCH {
+		return true
+	}
+
+	cmdline, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/cmdline", h.Pid))
+	if err != nil {
+		return os.IsNotExist(err)
+	}
+
+	return strings.Join(strings.Split(strings.TrimRight(string(cmdline), "\x00"), "\x00"), " ") != h.Command
+}
+
+func newLockHolder(exclusive bool) LockHolder {
+	return LockHolder{
+		Pid:       os.Getpid(),
+		Command:   strings.Join(os.Args, " "),
+		Acquired:  time.Now(),
+		Exclusive: exclusive,
+	}
+}
+
+// LockTimeoutError is returned when a lock could not be taken before the
+// lock timeout expired.
+type LockTimeoutError struct {
+	Path    string
+	Timeout time.Duration
+	Holders []LockHolder
+}
+
+func (e *LockTimeoutError) Error() string {
+	var holders []string
+	for _, h := range e.Holders {
+		if !h.Stale {
+			holders = append(holders, h.String())
+		}
+	}
+
+	msg := fmt.Sprintf("Timeout after %s waiting for lock %s", e.Timeout, e.Path)
+	if len(holders) > 0 {
+		msg += ", held by " + strings.Join(holders, ", ")
+	}
+
+	return msg
+}
+
+// IsLockTimeout returns true if err is a lock timeout error.
+func IsLockTimeout(err error) bool {
+	_, ok := err.(*LockTimeoutError)
+	return ok
+}
+
+// flock takes a lock on file, waiting at most timeout for it to be
+// released. A zero timeout waits forever.
+func flock(file *os.File, how int, timeout time.Duration) (bool, error) {
+	if timeout <= 0 {
+		for {
+			err := syscall.Flock(int(file.Fd()), how)
+			if err != syscall.EINTR {
+				return err == nil, err
+			}
+		}
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		err := syscall.Flock(int(file.Fd()), how|syscall.LOCK_NB)
+		if err == nil {
+			return true, nil
+		}
+
+		if err != syscall.EWOULDBLOCK && err != syscall.EINTR {
+			return false, err
+		}
+
+		if time.Now().After(deadline) {
+			return false, nil
+		}
+
+		time.Sleep(lockRetryInterval)
+	}
+}
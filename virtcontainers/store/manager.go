@@ -278,3 +278,8 @@ func (s *Store) ItemLock(item Item, exclusive bool) (string, error) {
 func (s *Store) ItemUnlock(item Item, token string) error {
 	return s.backend.unlock(item, token)
 }
+
+// ItemLockHolders returns the holders of an item lock.
+func (s *Store) ItemLockHolders(item Item) ([]LockHolder, error) {
+	return s.backend.lockHolders(item)
+}
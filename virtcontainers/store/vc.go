@@ -228,6 +228,11 @@ func (s *VCStore) Unlock(token string) error {
 	return s.state.ItemUnlock(Lock, token)
 }
 
+// LockHolders returns the holders of the virtcontainers state Lock item.
+func (s *VCStore) LockHolders() ([]LockHolder, error) {
+	return s.state.ItemLockHolders(Lock)
+}
+
 // Utilities for virtcontainers
 
 // SandboxConfigurationRoot returns a virtcontainers sandbox configuration root URL.
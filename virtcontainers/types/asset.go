@@ -6,11 +6,17 @@
 package types
 
 import (
+	"crypto/sha256"
 	"crypto/sha512"
 	"encoding/hex"
 	"fmt"
-	"io/ioutil"
+	"hash"
+	"io"
+	"os"
 	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/kata-containers/runtime/virtcontainers/pkg/annotations"
 )
@@ -92,37 +98,107 @@ func (a *Asset) Valid() bool {
 	return false
 }
 
+// assetHashKey identifies a cached asset hash.
+type assetHashKey struct {
+	path     string
+	hashType string
+}
+
+// assetHashEntry is a cached asset hash, valid as long as the asset file
+// inode, size and modification time do not change.
+type assetHashEntry struct {
+	dev   uint64
+	ino   uint64
+	size  int64
+	mtime time.Time
+	hash  string
+}
+
+// assetHashCache avoids hashing the same assets again for every sandbox
+// created by a long running process.
+var assetHashCache = struct {
+	sync.Mutex
+	entries map[assetHashKey]assetHashEntry
+}{
+	entries: make(map[assetHashKey]assetHashEntry),
+}
+
+func newHash(hashType string) (hash.Hash, error) {
+	switch hashType {
+	case annotations.SHA256:
+		return sha256.New(), nil
+	case annotations.SHA512:
+		return sha512.New(), nil
+	}
+
+	return nil, fmt.Errorf("Invalid hash type %s", hashType)
+}
+
 // Hash returns the hex encoded string for the asset hash
 func (a *Asset) Hash(hashType string) (string, error) {
-	var hashEncodedLen int
-	var hash string
+	h, err := newHash(hashType)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.Open(a.path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
 
-	// We read the actual asset content
-	bytes, err := ioutil.ReadFile(a.path)
+	fi, err := f.Stat()
 	if err != nil {
 		return "", err
 	}
 
-	if len(bytes) == 0 {
+	if fi.Size() == 0 {
 		return "", fmt.Errorf("Empty asset file at %s", a.path)
 	}
 
-	// Build the asset hash and convert it to a string.
-	// We only support SHA512 for now.
-	switch hashType {
-	case annotations.SHA512:
-		hashComputed := sha512.Sum512(bytes)
-		hashEncodedLen = hex.EncodedLen(len(hashComputed))
-		hashEncoded := make([]byte, hashEncodedLen)
-		hex.Encode(hashEncoded, hashComputed[:])
-		hash = string(hashEncoded[:])
-	default:
-		return "", fmt.Errorf("Invalid hash type %s", hashType)
+	entry := assetHashEntry{
+		size:  fi.Size(),
+		mtime: fi.ModTime(),
 	}
 
-	a.computedHash = hash
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		entry.dev = uint64(st.Dev)
+		entry.ino = uint64(st.Ino)
+	}
+
+	key := assetHashKey{path: a.path, hashType: hashType}
+
+	assetHashCache.Lock()
+	cached, ok := assetHashCache.entries[key]
+	assetHashCache.Unlock()
+
+	if ok && cached.dev == entry.dev && cached.ino == entry.ino &&
+		cached.size == entry.size && cached.mtime.Equal(entry.mtime) {
+		a.computedHash = cached.hash
+		return cached.hash, nil
+	}
+
+	// Stream the asset content, assets can be hundreds of MiB.
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	entry.hash = hex.EncodeToString(h.Sum(nil))
+
+	assetHashCache.Lock()
+	assetHashCache.entries[key] = entry
+	assetHashCache.Unlock()
+
+	a.computedHash = entry.hash
+
+	return entry.hash, nil
+}
 
-	return hash, nil
+// FileHash returns the hex encoded hash of the file at path, computed as
+// for an asset.
+func FileHash(path, hashType string) (string, error) {
+	a := Asset{path: path}
+	return a.Hash(hashType)
 }
 
 // NewAsset returns a new asset from a slice of annotations.
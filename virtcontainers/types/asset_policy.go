@@ -0,0 +1,201 @@
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package types
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"strings"
+
+	"github.com/kata-containers/runtime/virtcontainers/pkg/annotations"
+)
+
+// AssetManifestSignatureSuffix is appended to the asset manifest path to
+// get the path of its detached signature.
+const AssetManifestSignatureSuffix = ".sig"
+
+// AssetPolicy restricts the custom assets a sandbox can use. A custom asset
+// is allowed if it lives in one of the allowed directories, or if its hash
+// is listed in the signed manifest. An empty policy allows any asset.
+type AssetPolicy struct {
+	// AllowedDirs lists the directories custom assets can be picked from.
+	AllowedDirs []string
+
+	// Manifest is the path of the manifest listing the approved asset
+	// hashes, one "<hex hash> [<path>]" entry per line, as generated by
+	// sha256sum or sha512sum.
+	Manifest string
+
+	// ManifestKey is the path of the PEM encoded RSA or ECDSA public key
+	// verifying the manifest signature, a SHA-256 signature stored next to
+	// the manifest with an AssetManifestSignatureSuffix suffix.
+	ManifestKey string
+}
+
+// Enabled tells if the policy restricts custom assets.
+func (p AssetPolicy) Enabled() bool {
+	return len(p.AllowedDirs) > 0 || p.Manifest != ""
+}
+
+// Check returns an error if the asset is not allowed by the policy. The
+// asset path is replaced with its resolved one, so that the checked file
+// is the one used afterwards.
+func (p AssetPolicy) Check(a *Asset) error {
+	if a == nil || !p.Enabled() {
+		return nil
+	}
+
+	// Resolve symbolic links, so that a link in an allowed directory
+	// cannot point to an asset outside of it, or be retargeted once
+	// checked.
+	path, err := filepath.EvalSymlinks(a.path)
+	if err != nil {
+		return err
+	}
+
+	a.path = path
+
+	for _, dir := range p.AllowedDirs {
+		dir, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			continue
+		}
+
+		if rel, err := filepath.Rel(dir, path); err == nil && rel != ".." && !strings.HasPrefix(rel, "../") {
+			return nil
+		}
+	}
+
+	if p.Manifest != "" {
+		hashes, err := p.approvedHashes()
+		if err != nil {
+			return err
+		}
+
+		for hashType, approved := range hashes {
+			hash, err := a.Hash(hashType)
+			if err != nil {
+				return err
+			}
+
+			if approved[hash] {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("Custom %s %s is not allowed by the asset policy", a.kind, a.path)
+}
+
+// approvedHashes returns the hashes listed in the manifest, by hash type,
+// after checking the manifest signature.
+func (p AssetPolicy) approvedHashes() (map[string]map[string]bool, error) {
+	manifest, err := ioutil.ReadFile(p.Manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.verifyManifest(manifest); err != nil {
+		return nil, err
+	}
+
+	hashes := make(map[string]map[string]bool)
+
+	scanner := bufio.NewScanner(bytes.NewReader(manifest))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		hash := strings.ToLower(strings.Fields(line)[0])
+		if _, err := hex.DecodeString(hash); err != nil {
+			return nil, fmt.Errorf("Invalid hash %q in asset manifest %s", hash, p.Manifest)
+		}
+
+		var hashType string
+
+		switch len(hash) {
+		case hex.EncodedLen(sha256.Size):
+			hashType = annotations.SHA256
+		case hex.EncodedLen(sha512.Size):
+			hashType = annotations.SHA512
+		default:
+			return nil, fmt.Errorf("Invalid hash %q in asset manifest %s", hash, p.Manifest)
+		}
+
+		if hashes[hashType] == nil {
+			hashes[hashType] = make(map[string]bool)
+		}
+
+		hashes[hashType][hash] = true
+	}
+
+	return hashes, scanner.Err()
+}
+
+// verifyManifest checks the manifest signature against the policy key.
+func (p AssetPolicy) verifyManifest(manifest []byte) error {
+	if p.ManifestKey == "" {
+		return fmt.Errorf("Missing public key to verify asset manifest %s", p.Manifest)
+	}
+
+	keyPEM, err := ioutil.ReadFile(p.ManifestKey)
+	if err != nil {
+		return err
+	}
+
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return fmt.Errorf("No PEM data found in %s", p.ManifestKey)
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return err
+	}
+
+	signature, err := ioutil.ReadFile(p.Manifest + AssetManifestSignatureSuffix)
+	if err != nil {
+		return err
+	}
+
+	digest := sha256.Sum256(manifest)
+
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		err = rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], signature)
+	case *ecdsa.PublicKey:
+		var sig struct {
+			R, S *big.Int
+		}
+
+		if _, err = asn1.Unmarshal(signature, &sig); err == nil && !ecdsa.Verify(pub, digest[:], sig.R, sig.S) {
+			err = fmt.Errorf("ECDSA verification failure")
+		}
+	default:
+		return fmt.Errorf("Unsupported public key type %T in %s", key, p.ManifestKey)
+	}
+
+	if err != nil {
+		return fmt.Errorf("Invalid signature for asset manifest %s: %v", p.Manifest, err)
+	}
+
+	return nil
+}
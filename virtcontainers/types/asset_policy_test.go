@@ -0,0 +1,173 @@
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package types
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeAssetPolicyKey(t *testing.T, path string, pub interface{}) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	assert.NoError(t, err)
+
+	err = ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0640)
+	assert.NoError(t, err)
+}
+
+func TestAssetPolicyAllowedDirs(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "asset-policy")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	allowedDir := filepath.Join(dir, "allowed")
+	otherDir := filepath.Join(dir, "other")
+	assert.NoError(os.MkdirAll(allowedDir, 0750))
+	assert.NoError(os.MkdirAll(otherDir, 0750))
+
+	allowed := filepath.Join(allowedDir, "kernel")
+	other := filepath.Join(otherDir, "kernel")
+	link := filepath.Join(allowedDir, "link")
+	assert.NoError(ioutil.WriteFile(allowed, assetContent, 0640))
+	assert.NoError(ioutil.WriteFile(other, assetContent, 0640))
+	assert.NoError(os.Symlink(other, link))
+
+	// Empty policy
+	var policy AssetPolicy
+	assert.False(policy.Enabled())
+	assert.NoError(policy.Check(&Asset{path: other, kind: KernelAsset}))
+
+	policy.AllowedDirs = []string{allowedDir}
+	assert.True(policy.Enabled())
+	assert.NoError(policy.Check(nil))
+	assert.NoError(policy.Check(&Asset{path: allowed, kind: KernelAsset}))
+	assert.Error(policy.Check(&Asset{path: other, kind: KernelAsset}))
+
+	// A checked asset is pinned to its resolved path
+	allowedLink := filepath.Join(otherDir, "link")
+	assert.NoError(os.Symlink(allowed, allowedLink))
+	a := &Asset{path: allowedLink, kind: KernelAsset}
+	assert.NoError(policy.Check(a))
+	resolved, err := filepath.EvalSymlinks(allowed)
+	assert.NoError(err)
+	assert.Equal(resolved, a.Path())
+	assert.Error(policy.Check(&Asset{path: link, kind: KernelAsset}))
+	assert.Error(policy.Check(&Asset{path: filepath.Join(allowedDir, "..", "other", "kernel"), kind: KernelAsset}))
+
+	// Prefix of an allowed directory
+	policy.AllowedDirs = []string{filepath.Join(dir, "oth")}
+	assert.Error(policy.Check(&Asset{path: other, kind: KernelAsset}))
+}
+
+func TestAssetPolicyManifest(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "asset-policy")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	asset := &Asset{path: filepath.Join(dir, "image"), kind: ImageAsset}
+	assert.NoError(ioutil.WriteFile(asset.path, assetContent, 0640))
+
+	manifestPath := filepath.Join(dir, "manifest")
+	keyPath := filepath.Join(dir, "key.pub")
+
+	policy := AssetPolicy{
+		Manifest: manifestPath,
+	}
+
+	manifest := []byte("# approved assets\n\n" + assetContentSHA256Hash + "  /some/where/image\n")
+	digest := sha256.Sum256(manifest)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(err)
+	rsaSignature, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+	assert.NoError(err)
+
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(err)
+	ecdsaSignature, err := ecdsaKey.Sign(rand.Reader, digest[:], crypto.SHA256)
+	assert.NoError(err)
+
+	// Missing manifest
+	assert.Error(policy.Check(asset))
+
+	assert.NoError(ioutil.WriteFile(manifestPath, manifest, 0640))
+
+	// Missing key
+	assert.Error(policy.Check(asset))
+
+	policy.ManifestKey = keyPath
+	writeAssetPolicyKey(t, keyPath, rsaKey.Public())
+
+	// Missing signature
+	assert.Error(policy.Check(asset))
+
+	assert.NoError(ioutil.WriteFile(manifestPath+AssetManifestSignatureSuffix, rsaSignature, 0640))
+	assert.NoError(policy.Check(asset))
+
+	writeAssetPolicyKey(t, keyPath, ecdsaKey.Public())
+	assert.Error(policy.Check(asset))
+
+	assert.NoError(ioutil.WriteFile(manifestPath+AssetManifestSignatureSuffix, ecdsaSignature, 0640))
+	assert.NoError(policy.Check(asset))
+
+	// Asset not in the manifest
+	assert.NoError(ioutil.WriteFile(asset.path, []byte("foo"), 0640))
+	assert.Error(policy.Check(asset))
+
+	// Tampered manifest
+	assert.NoError(ioutil.WriteFile(manifestPath, append(manifest, []byte(assetContentHash+"\n")...), 0640))
+	assert.Error(policy.Check(asset))
+}
+
+func TestAssetPolicyInvalidManifest(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "asset-policy")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	policy := AssetPolicy{
+		Manifest:    filepath.Join(dir, "manifest"),
+		ManifestKey: filepath.Join(dir, "key.pub"),
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(err)
+	writeAssetPolicyKey(t, policy.ManifestKey, key.Public())
+
+	for _, manifest := range []string{"foo\n", "abcd\n"} {
+		digest := sha256.Sum256([]byte(manifest))
+		signature, err := key.Sign(rand.Reader, digest[:], crypto.SHA256)
+		assert.NoError(err)
+
+		assert.NoError(ioutil.WriteFile(policy.Manifest, []byte(manifest), 0640))
+		assert.NoError(ioutil.WriteFile(policy.Manifest+AssetManifestSignatureSuffix, signature, 0640))
+
+		_, err = policy.approvedHashes()
+		assert.Error(err, manifest)
+	}
+
+	// Not a PEM key
+	assert.NoError(ioutil.WriteFile(policy.ManifestKey, []byte("foo"), 0640))
+	_, err = policy.approvedHashes()
+	assert.Error(err)
+}
@@ -17,6 +17,7 @@ import (
 var assetContent = []byte("FakeAsset fake asset FAKE ASSET")
 var assetContentHash = "92549f8d2018a95a294d28a65e795ed7d1a9d150009a28cea108ae10101178676f04ab82a6950d0099e4924f9c5e41dcba8ece56b75fc8b4e0a7492cb2a8c880"
 var assetContentWrongHash = "92549f8d2018a95a294d28a65e795ed7d1a9d150009a28cea108ae10101178676f04ab82a6950d0099e4924f9c5e41dcba8ece56b75fc8b4e0a7492cb2a8c881"
+var assetContentSHA256Hash = "d58d4016d9fec8e1db4e53548aa79cb08943f7d2f6b48283dab7d6e821deecb6"
 
 func TestAssetWrongHashType(t *testing.T) {
 	assert := assert.New(t)
@@ -65,6 +66,29 @@ func TestAssetHash(t *testing.T) {
 	assert.Equal(assetContentHash, a.computedHash)
 }
 
+func TestFileHash(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpfile, err := ioutil.TempFile("", "virtcontainers-test-")
+	assert.Nil(err)
+
+	defer func() {
+		tmpfile.Close()
+		os.Remove(tmpfile.Name()) // clean up
+	}()
+
+	// Empty file
+	_, err = FileHash(tmpfile.Name(), annotations.SHA512)
+	assert.NotNil(err)
+
+	_, err = tmpfile.Write(assetContent)
+	assert.Nil(err)
+
+	hash, err := FileHash(tmpfile.Name(), annotations.SHA512)
+	assert.Nil(err)
+	assert.Equal(assetContentHash, hash)
+}
+
 func TestAssetNew(t *testing.T) {
 	assert := assert.New(t)
 
@@ -99,3 +123,81 @@ func TestAssetNew(t *testing.T) {
 	_, err = NewAsset(anno, KernelAsset)
 	assert.NotNil(err)
 }
+
+func TestAssetHashSHA256(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpfile, err := ioutil.TempFile("", "virtcontainers-test-")
+	assert.Nil(err)
+
+	defer func() {
+		tmpfile.Close()
+		os.Remove(tmpfile.Name()) // clean up
+	}()
+
+	_, err = tmpfile.Write(assetContent)
+	assert.Nil(err)
+
+	anno := map[string]string{
+		annotations.KernelPath:    tmpfile.Name(),
+		annotations.KernelHash:    assetContentSHA256Hash,
+		annotations.AssetHashType: annotations.SHA256,
+	}
+
+	a, err := NewAsset(anno, KernelAsset)
+	assert.Nil(err)
+	assert.Equal(assetContentSHA256Hash, a.computedHash)
+}
+
+func TestAssetHashCache(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpfile, err := ioutil.TempFile("", "virtcontainers-test-")
+	assert.Nil(err)
+
+	defer func() {
+		tmpfile.Close()
+		os.Remove(tmpfile.Name()) // clean up
+	}()
+
+	a := &Asset{
+		path: tmpfile.Name(),
+	}
+
+	// Empty asset
+	_, err = a.Hash(annotations.SHA512)
+	assert.NotNil(err)
+
+	_, err = tmpfile.Write(assetContent)
+	assert.Nil(err)
+
+	hash, err := a.Hash(annotations.SHA512)
+	assert.Nil(err)
+	assert.Equal(assetContentHash, hash)
+
+	key := assetHashKey{path: tmpfile.Name(), hashType: annotations.SHA512}
+	assetHashCache.Lock()
+	entry, ok := assetHashCache.entries[key]
+	assetHashCache.Unlock()
+	assert.True(ok)
+	assert.Equal(assetContentHash, entry.hash)
+
+	// A cached hash is returned as long as the file is unchanged
+	entry.hash = assetContentWrongHash
+	assetHashCache.Lock()
+	assetHashCache.entries[key] = entry
+	assetHashCache.Unlock()
+
+	hash, err = a.Hash(annotations.SHA512)
+	assert.Nil(err)
+	assert.Equal(assetContentWrongHash, hash)
+
+	// The asset is hashed again when modified
+	_, err = tmpfile.Write(assetContent)
+	assert.Nil(err)
+
+	hash, err = a.Hash(annotations.SHA512)
+	assert.Nil(err)
+	assert.NotEqual(assetContentWrongHash, hash)
+	assert.NotEqual(assetContentHash, hash)
+}
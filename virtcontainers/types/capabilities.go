@@ -10,6 +10,7 @@ const (
 	blockDeviceHotplugSupport
 	multiQueueSupport
 	fsSharingUnsupported
+	memoryBalloonSupport
 )
 
 // Capabilities describe a virtcontainers hypervisor capabilities
@@ -57,3 +58,14 @@ func (caps *Capabilities) IsFsSharingSupported() bool {
 func (caps *Capabilities) SetFsSharingUnsupported() {
 	caps.flags |= fsSharingUnsupported
 }
+
+// IsMemoryBalloonSupported tells if an hypervisor supports resizing the guest
+// memory through a memory balloon.
+func (caps *Capabilities) IsMemoryBalloonSupported() bool {
+	return caps.flags&memoryBalloonSupport != 0
+}
+
+// SetMemoryBalloonSupport sets the memory balloon capability to true.
+func (caps *Capabilities) SetMemoryBalloonSupport() {
+	caps.flags |= memoryBalloonSupport
+}
@@ -48,3 +48,17 @@ func TestFsSharingCapability(t *testing.T) {
 		t.Fatal()
 	}
 }
+
+func TestMemoryBalloonCapability(t *testing.T) {
+	var caps Capabilities
+
+	if caps.IsMemoryBalloonSupported() {
+		t.Fatal()
+	}
+
+	caps.SetMemoryBalloonSupport()
+
+	if !caps.IsMemoryBalloonSupported() {
+		t.Fatal()
+	}
+}
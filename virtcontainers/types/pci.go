@@ -70,3 +70,37 @@ func (b *PCIBridge) RemoveDevice(ID string) error {
 
 	return fmt.Errorf("Unable to hot unplug device %s: not present on bridge", ID)
 }
+
+// PCIeRootPort is a PCIe root port where a single device can be hot plugged
+type PCIeRootPort struct {
+	// DeviceID is the ID of the device plugged in the root port,
+	// empty if the root port is free
+	DeviceID string
+
+	// ID is used to identify the root port in the hypervisor
+	ID string
+
+	// Addr is the PCIe slot of the root port on the root bus
+	Addr int
+}
+
+// AddDevice on success adds the device ID to the PCIe root port.
+// The device is always plugged at slot 0 behind a root port.
+func (p *PCIeRootPort) AddDevice(ID string) error {
+	if p.DeviceID != "" {
+		return fmt.Errorf("Unable to hot plug device on root port %s: already used by %s", p.ID, p.DeviceID)
+	}
+
+	p.DeviceID = ID
+	return nil
+}
+
+// RemoveDevice removes the device ID from the PCIe root port.
+func (p *PCIeRootPort) RemoveDevice(ID string) error {
+	if p.DeviceID != ID || ID == "" {
+		return fmt.Errorf("Unable to hot unplug device %s: not present on root port %s", ID, p.ID)
+	}
+
+	p.DeviceID = ""
+	return nil
+}
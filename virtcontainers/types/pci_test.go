@@ -45,3 +45,32 @@ func TestAddRemoveDevice(t *testing.T) {
 		assert.Fail("address should be 0")
 	}
 }
+
+func TestAddRemoveRootPortDevice(t *testing.T) {
+	assert := assert.New(t)
+
+	port := &PCIeRootPort{ID: "rp0", Addr: 3}
+
+	devID := "abc123"
+	err := port.AddDevice(devID)
+	assert.NoError(err)
+	assert.Equal(devID, port.DeviceID)
+
+	// a root port accepts a single device
+	err = port.AddDevice("def456")
+	assert.Error(err)
+
+	err = port.RemoveDevice("")
+	assert.Error(err)
+
+	err = port.RemoveDevice("def456")
+	assert.Error(err)
+
+	err = port.RemoveDevice(devID)
+	assert.NoError(err)
+	assert.Empty(port.DeviceID)
+
+	// slot can be reused once freed
+	err = port.AddDevice("def456")
+	assert.NoError(err)
+}
@@ -44,6 +44,14 @@ type SandboxState struct {
 	// including the hypervisor are placed.
 	CgroupPath string `json:"cgroupPath,omitempty"`
 
+	// BaseVCPUs is the number of vCPUs reserved for the sandbox itself,
+	// on top of the containers ones. Zero means the hypervisor default.
+	BaseVCPUs uint32 `json:"baseVCPUs,omitempty"`
+
+	// BaseMemoryMB is the memory reserved for the sandbox itself, on top
+	// of the containers one. Zero means the hypervisor default.
+	BaseMemoryMB uint32 `json:"baseMemoryMB,omitempty"`
+
 	// PersistVersion indicates current storage api version.
 	// It's also known as ABI version of kata-runtime.
 	// Note: it won't be written to disk
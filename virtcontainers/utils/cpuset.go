@@ -0,0 +1,119 @@
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package utils
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// variable rather than const to allow tests to modify it
+var sysNodeDir = "/sys/devices/system/node"
+
+// ParseCPUSet parses a cpuset list, as used by the cpuset cgroup
+// (e.g. "0-3,8,10-11"), and returns the sorted list of unique CPUs
+// or NUMA nodes it contains.
+func ParseCPUSet(cpuset string) ([]int, error) {
+	set := make(map[int]bool)
+
+	for _, field := range strings.Split(cpuset, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		bounds := strings.SplitN(field, "-", 2)
+
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil || first < 0 {
+			return nil, fmt.Errorf("Invalid cpuset %q", cpuset)
+		}
+
+		last := first
+		if len(bounds) == 2 {
+			last, err = strconv.Atoi(bounds[1])
+			if err != nil || last < first {
+				return nil, fmt.Errorf("Invalid cpuset %q", cpuset)
+			}
+		}
+
+		for i := first; i <= last; i++ {
+			set[i] = true
+		}
+	}
+
+	var list []int
+	for i := range set {
+		list = append(list, i)
+	}
+
+	sort.Ints(list)
+
+	return list, nil
+}
+
+// HostNUMANodes returns the host NUMA nodes and the CPUs they hold.
+func HostNUMANodes() (map[int][]int, error) {
+	dirs, err := filepath.Glob(filepath.Join(sysNodeDir, "node[0-9]*"))
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make(map[int][]int)
+
+	for _, dir := range dirs {
+		node, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(dir), "node"))
+		if err != nil {
+			continue
+		}
+
+		cpulist, err := ioutil.ReadFile(filepath.Join(dir, "cpulist"))
+		if err != nil {
+			return nil, err
+		}
+
+		cpus, err := ParseCPUSet(strings.TrimSpace(string(cpulist)))
+		if err != nil {
+			return nil, err
+		}
+
+		nodes[node] = cpus
+	}
+
+	return nodes, nil
+}
+
+// CPUsNUMANodes returns the sorted list of the host NUMA nodes holding
+// the specified CPUs.
+func CPUsNUMANodes(cpus []int) ([]int, error) {
+	nodes, err := HostNUMANodes()
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := make(map[int]bool)
+	for _, cpu := range cpus {
+		wanted[cpu] = true
+	}
+
+	var list []int
+	for node, nodeCPUs := range nodes {
+		for _, cpu := range nodeCPUs {
+			if wanted[cpu] {
+				list = append(list, node)
+				break
+			}
+		}
+	}
+
+	sort.Ints(list)
+
+	return list, nil
+}
@@ -0,0 +1,96 @@
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCPUSet(t *testing.T) {
+	assert := assert.New(t)
+
+	type testData struct {
+		cpuset      string
+		expected    []int
+		expectError bool
+	}
+
+	data := []testData{
+		{"", nil, false},
+		{"0", []int{0}, false},
+		{"0-3", []int{0, 1, 2, 3}, false},
+		{"8,0-1,10-11", []int{0, 1, 8, 10, 11}, false},
+		{"1-2,2-3,", []int{1, 2, 3}, false},
+		{" 4 , 5", []int{4, 5}, false},
+		{"a", nil, true},
+		{"-1", nil, true},
+		{"3-1", nil, true},
+		{"1-b", nil, true},
+	}
+
+	for i, d := range data {
+		cpus, err := ParseCPUSet(d.cpuset)
+		if d.expectError {
+			assert.Error(err, "test %d (%+v)", i, d)
+			continue
+		}
+
+		assert.NoError(err, "test %d (%+v)", i, d)
+		assert.Equal(d.expected, cpus, "test %d (%+v)", i, d)
+	}
+}
+
+func TestCPUsNUMANodes(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	savedSysNodeDir := sysNodeDir
+	defer func() {
+		sysNodeDir = savedSysNodeDir
+	}()
+
+	sysNodeDir = dir
+
+	for node, cpulist := range map[string]string{
+		"node0": "0-3,8-11\n",
+		"node1": "4-7,12-15\n",
+	} {
+		err = os.MkdirAll(filepath.Join(dir, node), 0750)
+		assert.NoError(err)
+
+		err = ioutil.WriteFile(filepath.Join(dir, node, "cpulist"), []byte(cpulist), 0640)
+		assert.NoError(err)
+	}
+
+	// Not a node
+	err = os.MkdirAll(filepath.Join(dir, "power"), 0750)
+	assert.NoError(err)
+
+	nodes, err := HostNUMANodes()
+	assert.NoError(err)
+	assert.Len(nodes, 2)
+	assert.Equal([]int{4, 5, 6, 7, 12, 13, 14, 15}, nodes[1])
+
+	nodes2, err := CPUsNUMANodes([]int{2, 3})
+	assert.NoError(err)
+	assert.Equal([]int{0}, nodes2)
+
+	nodes2, err = CPUsNUMANodes([]int{3, 12})
+	assert.NoError(err)
+	assert.Equal([]int{0, 1}, nodes2)
+
+	nodes2, err = CPUsNUMANodes([]int{42})
+	assert.NoError(err)
+	assert.Empty(nodes2)
+}
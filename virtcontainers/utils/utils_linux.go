@@ -88,3 +88,88 @@ func FindContextID() (*os.File, uint64, error) {
 	vsockFd.Close()
 	return nil, 0, fmt.Errorf("Could not get a unique context ID for the vsock")
 }
+
+// bitmask returns the kernel bitmask representation of the specified
+// list of CPUs or NUMA nodes.
+func bitmask(bits []int) []uint64 {
+	max := 0
+	for _, bit := range bits {
+		if bit > max {
+			max = bit
+		}
+	}
+
+	mask := make([]uint64, max/64+1)
+	for _, bit := range bits {
+		mask[bit/64] |= 1 << uint(bit%64)
+	}
+
+	return mask
+}
+
+// SetThreadAffinity restricts the specified thread to run on the
+// specified host CPUs.
+func SetThreadAffinity(tid int, cpus []int) error {
+	if len(cpus) == 0 {
+		return fmt.Errorf("No CPU to set thread %d affinity to", tid)
+	}
+
+	mask := bitmask(cpus)
+
+	if _, _, errno := unix.Syscall(unix.SYS_SCHED_SETAFFINITY, uintptr(tid),
+		uintptr(len(mask)*8), uintptr(unsafe.Pointer(&mask[0]))); errno != 0 {
+		return os.NewSyscallError("sched_setaffinity", errno)
+	}
+
+	return nil
+}
+
+// MigratePages moves the memory pages of the specified process that are
+// not on the specified host NUMA nodes to those nodes.
+func MigratePages(pid int, nodes []int) error {
+	hostNodes, err := HostNUMANodes()
+	if err != nil {
+		return err
+	}
+
+	wanted := make(map[int]bool)
+	for _, node := range nodes {
+		if _, ok := hostNodes[node]; !ok {
+			return fmt.Errorf("Invalid host NUMA node %d", node)
+		}
+		wanted[node] = true
+	}
+
+	var from []int
+	for node := range hostNodes {
+		if !wanted[node] {
+			from = append(from, node)
+		}
+	}
+
+	if len(nodes) == 0 || len(from) == 0 {
+		// Nothing to move
+		return nil
+	}
+
+	maxNode := 0
+	for node := range hostNodes {
+		if node > maxNode {
+			maxNode = node
+		}
+	}
+
+	// The kernel ignores the last bit of the masks, hence the extra
+	// bit, which both masks must be able to hold.
+	bits := maxNode + 2
+	size := (bits-1)/64 + 1
+	oldMask := append(bitmask(from), make([]uint64, size)...)[:size]
+	newMask := append(bitmask(nodes), make([]uint64, size)...)[:size]
+
+	if _, _, errno := unix.Syscall6(unix.SYS_MIGRATE_PAGES, uintptr(pid), uintptr(bits),
+		uintptr(unsafe.Pointer(&oldMask[0])), uintptr(unsafe.Pointer(&newMask[0])), 0, 0); errno != 0 {
+		return os.NewSyscallError("migrate_pages", errno)
+	}
+
+	return nil
+}
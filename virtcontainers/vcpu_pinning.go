@@ -0,0 +1,145 @@
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/containerd/cgroups"
+	"github.com/kata-containers/runtime/virtcontainers/utils"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/sirupsen/logrus"
+)
+
+var setThreadAffinityFunc = utils.SetThreadAffinity
+var migratePagesFunc = utils.MigratePages
+var cpusNUMANodesFunc = utils.CPUsNUMANodes
+
+// vcpusPinning maps each vCPU thread to the host CPU it is pinned to,
+// pinning the vCPUs one-to-one to the CPUs, in order. If there are more
+// vCPUs than CPUs, the CPUs are shared by wrapping around the cpuset.
+func vcpusPinning(tids vcpuThreadIDs, cpus []int) map[int]int {
+	pinning := make(map[int]int)
+	if len(cpus) == 0 {
+		return pinning
+	}
+
+	var vcpus []int
+	for vcpu := range tids.vcpus {
+		vcpus = append(vcpus, vcpu)
+	}
+
+	sort.Ints(vcpus)
+
+	for i, vcpu := range vcpus {
+		pinning[tids.vcpus[vcpu]] = cpus[i%len(cpus)]
+	}
+
+	return pinning
+}
+
+// pinVCPUs pins each vCPU thread to one CPU of the containers cpuset, and
+// binds the guest memory to the host NUMA nodes holding those CPUs. It is
+// called every time the sandbox cgroups are updated, as a container update
+// or a CPU hotplug changes the cpuset or the vCPU threads.
+func (s *Sandbox) pinVCPUs() error {
+	if s.config == nil || !s.config.HypervisorConfig.VCPUPinning {
+		return nil
+	}
+
+	cpuset := s.cpuResources().Cpus
+
+	cpus, err := utils.ParseCPUSet(cpuset)
+	if err != nil {
+		return err
+	}
+
+	if len(cpus) == 0 {
+		s.Logger().Debug("No cpuset defined, vCPUs not pinned")
+		return nil
+	}
+
+	tids, err := s.hypervisor.getThreadIDs()
+	if err != nil {
+		return fmt.Errorf("failed to get thread ids from hypervisor: %v", err)
+	}
+
+	if len(tids.vcpus) > len(cpus) {
+		s.Logger().WithFields(logrus.Fields{
+			"vcpus":  len(tids.vcpus),
+			"cpuset": cpuset,
+		}).Warn("More vCPUs than CPUs in the cpuset, some vCPUs share a CPU")
+	}
+
+	for tid, cpu := range vcpusPinning(tids, cpus) {
+		if err := setThreadAffinityFunc(tid, []int{cpu}); err != nil {
+			return fmt.Errorf("Could not pin vCPU thread %d to CPU %d: %v", tid, cpu, err)
+		}
+
+		s.Logger().WithFields(logrus.Fields{
+			"tid": tid,
+			"cpu": cpu,
+		}).Debug("vCPU thread pinned")
+	}
+
+	nodes, err := cpusNUMANodesFunc(cpus)
+	if err != nil {
+		return err
+	}
+
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	if err := s.bindVCPUsMemory(nodes); err != nil {
+		return err
+	}
+
+	// Move the pages allocated before the binding. Only a best effort,
+	// the guest memory is still usable from other nodes.
+	pid := s.hypervisor.pid()
+	if err := migratePagesFunc(pid, nodes); err != nil {
+		s.Logger().WithError(err).WithField("nodes", nodes).Warn("Could not move guest memory to the cpuset NUMA nodes")
+	}
+
+	return nil
+}
+
+// bindVCPUsMemory restricts the memory allocations of the vCPU threads to
+// the specified host NUMA nodes, through the cpuset.mems of the sandbox
+// cgroup holding them. The guest memory, hotplugged or not, is allocated
+// when the guest first touches it, hence from the vCPU threads. The
+// memory nodes requested by the containers are left untouched.
+func (s *Sandbox) bindVCPUsMemory(nodes []int) error {
+	if s.state.CgroupPath == "" || s.cpuResources().Mems != "" {
+		return nil
+	}
+
+	var mems []string
+	for _, node := range nodes {
+		mems = append(mems, strconv.Itoa(node))
+	}
+
+	cgroup, err := cgroupsLoadFunc(V1Constraints, cgroups.StaticPath(s.state.CgroupPath))
+	if err != nil {
+		return fmt.Errorf("Could not load cgroup %v: %v", s.state.CgroupPath, err)
+	}
+
+	resources := &specs.LinuxResources{
+		CPU: &specs.LinuxCPU{
+			Mems: strings.Join(mems, ","),
+		},
+	}
+
+	if err := cgroup.Update(resources); err != nil {
+		return fmt.Errorf("Could not bind cgroup %v memory to NUMA nodes %v: %v", s.state.CgroupPath, mems, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/containerd/cgroups"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVCPUsPinning(t *testing.T) {
+	assert := assert.New(t)
+
+	tids := vcpuThreadIDs{
+		vcpus: map[int]int{
+			0: 100,
+			1: 101,
+			2: 102,
+		},
+	}
+
+	// No cpuset
+	assert.Empty(vcpusPinning(tids, nil))
+
+	// One-to-one pinning
+	assert.Equal(map[int]int{100: 4, 101: 5, 102: 6}, vcpusPinning(tids, []int{4, 5, 6}))
+
+	// More vCPUs than CPUs
+	assert.Equal(map[int]int{100: 4, 101: 5, 102: 4}, vcpusPinning(tids, []int{4, 5}))
+}
+
+type updatedCgroup struct {
+	mockCgroup
+	resources *specs.LinuxResources
+}
+
+func (m *updatedCgroup) Update(resources *specs.LinuxResources) error {
+	m.resources = resources
+	return nil
+}
+
+func TestSandboxPinVCPUs(t *testing.T) {
+	assert := assert.New(t)
+
+	savedSetThreadAffinityFunc := setThreadAffinityFunc
+	savedMigratePagesFunc := migratePagesFunc
+	savedCPUsNUMANodesFunc := cpusNUMANodesFunc
+	savedCgroupsLoadFunc := cgroupsLoadFunc
+	defer func() {
+		setThreadAffinityFunc = savedSetThreadAffinityFunc
+		migratePagesFunc = savedMigratePagesFunc
+		cpusNUMANodesFunc = savedCPUsNUMANodesFunc
+		cgroupsLoadFunc = savedCgroupsLoadFunc
+	}()
+
+	pinned := make(map[int][]int)
+	setThreadAffinityFunc = func(tid int, cpus []int) error {
+		pinned[tid] = cpus
+		return nil
+	}
+
+	var migratedPid int
+	var migratedNodes []int
+	migratePagesFunc = func(pid int, nodes []int) error {
+		migratedPid = pid
+		migratedNodes = nodes
+		return errors.New("migration is only a best effort")
+	}
+
+	cpusNUMANodesFunc = func(cpus []int) ([]int, error) {
+		return []int{1}, nil
+	}
+
+	s := &Sandbox{
+		config:     &SandboxConfig{},
+		hypervisor: &mockHypervisor{mockPid: 1234},
+	}
+
+	// Pinning disabled
+	assert.NoError(s.pinVCPUs())
+	assert.Empty(pinned)
+
+	s.config.HypervisorConfig.VCPUPinning = true
+
+	// No cpuset
+	assert.NoError(s.pinVCPUs())
+	assert.Empty(pinned)
+
+	s.containers = map[string]*Container{
+		"foo": {
+			config: &ContainerConfig{
+				Resources: specs.LinuxResources{
+					CPU: &specs.LinuxCPU{
+						Cpus: "2-3",
+					},
+				},
+			},
+		},
+	}
+
+	assert.NoError(s.pinVCPUs())
+	assert.Equal(map[int][]int{os.Getpid(): {2}}, pinned)
+	assert.Equal(1234, migratedPid)
+	assert.Equal([]int{1}, migratedNodes)
+
+	// Memory bound to the NUMA nodes through the sandbox cgroup
+	cgroup := &updatedCgroup{}
+	cgroupsLoadFunc = func(hierarchy cgroups.Hierarchy, path cgroups.Path) (cgroups.Cgroup, error) {
+		return cgroup, nil
+	}
+
+	s.state.CgroupPath = "/kata/foo"
+	assert.NoError(s.pinVCPUs())
+	assert.NotNil(cgroup.resources)
+	assert.Equal("1", cgroup.resources.CPU.Mems)
+
+	// Memory nodes requested by the container
+	cgroup.resources = nil
+	s.containers["foo"].config.Resources.CPU.Mems = "0"
+	assert.NoError(s.pinVCPUs())
+	assert.Nil(cgroup.resources)
+
+	// Pinning failure
+	setThreadAffinityFunc = func(tid int, cpus []int) error {
+		return errors.New("invalid CPU")
+	}
+	assert.Error(s.pinVCPUs())
+
+	// Invalid cpuset
+	s.containers["foo"].config.Resources.CPU.Cpus = "3-2"
+	assert.Error(s.pinVCPUs())
+}
@@ -7,23 +7,96 @@ package virtcontainers
 
 import (
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 
 	"github.com/kata-containers/runtime/virtcontainers/device/config"
 	"github.com/kata-containers/runtime/virtcontainers/utils"
 )
 
-// Long term, this should be made more configurable.  For now matching path
-// provided by CNM VPP and OVS-DPDK plugins, available at github.com/clearcontainers/vpp and
-// github.com/clearcontainers/ovsdpdk.  The plugins create the socket on the host system
-// using this path.
+// Matching the path used by the CNM VPP and OVS-DPDK plugins, available at
+// github.com/clearcontainers/vpp and github.com/clearcontainers/ovsdpdk. The
+// plugins create the socket on the host system using this path. It is only
+// looked up for the interfaces not described in the vhost-user store.
 const hostSocketSearchPath = "/tmp/vhostuser_%s/vhu.sock"
 
+// vhostUserNetDir is the directory of the vhost-user store describing the
+// vhost-user network interfaces.
+//
+// The CNI plugin of a userspace switch, like OVS-DPDK or VPP, creates a
+// placeholder interface in the network namespace of the pod, holding the
+// MAC address, addresses and routes of the interface, and describes the
+// vhost-user port backing it in <store>/net/<netns>/<interface>.json:
+//
+//	{
+//	  "socket": "/var/run/openvswitch/vhu-pod0",
+//	  "mode": "server",
+//	  "queues": 4
+//	}
+//
+// <netns> is the base name of the network namespace path and <interface>
+// the name of the placeholder interface. In the default "client" mode, the
+// switch listens on the socket. In the "server" mode, the hypervisor creates
+// the socket and the switch connects to it. "queues" is the number of queue
+// pairs of the interface, 1 by default.
+const vhostUserNetDir = "net"
+
+const (
+	vhostUserNetClientMode = "client"
+	vhostUserNetServerMode = "server"
+)
+
+// vhostUserNetInfo describes the vhost-user port backing a network interface.
+type vhostUserNetInfo struct {
+	Socket string `json:"socket"`
+	Mode   string `json:"mode"`
+	Queues uint32 `json:"queues"`
+}
+
+func (info *vhostUserNetInfo) valid() error {
+	if !filepath.IsAbs(info.Socket) {
+		return fmt.Errorf("Invalid vhost-user socket path %q", info.Socket)
+	}
+
+	switch info.Mode {
+	case "":
+		info.Mode = vhostUserNetClientMode
+		fallthrough
+	case vhostUserNetClientMode:
+		// The switch listens on the socket already
+		if _, err := os.Stat(info.Socket); err != nil {
+			return err
+		}
+	case vhostUserNetServerMode:
+		// The hypervisor creates the socket
+		if _, err := os.Stat(filepath.Dir(info.Socket)); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("Invalid vhost-user mode %q", info.Mode)
+	}
+
+	if info.Queues == 0 {
+		info.Queues = 1
+	}
+
+	return nil
+}
+
 // VhostUserEndpoint represents a vhost-user socket based network interface
 type VhostUserEndpoint struct {
 	// Path to the vhost-user socket on the host system
 	SocketPath string
+	// Server is set when the hypervisor creates the socket, the switch
+	// connecting to it.
+	Server bool
+	// Queues is the number of queue pairs of the interface.
+	Queues uint32
+	// DevID identifies the device of the interface in the hypervisor.
+	DevID string
 	// MAC address of the interface
 	HardAddr           string
 	IfaceName          string
@@ -72,20 +145,33 @@ func (endpoint *VhostUserEndpoint) NetworkPair() *NetworkInterfacePair {
 	return nil
 }
 
-// Attach for vhostuser endpoint
-func (endpoint *VhostUserEndpoint) Attach(h hypervisor) error {
-	// Generate a unique ID to be used for hypervisor commandline fields
-	randBytes, err := utils.GenerateRandomBytes(8)
-	if err != nil {
-		return err
+func (endpoint *VhostUserEndpoint) deviceAttrs() (config.VhostUserDeviceAttrs, error) {
+	// Generate a unique ID to be used for hypervisor commandline fields.
+	// It is kept with the endpoint to detach the device later.
+	if endpoint.DevID == "" {
+		randBytes, err := utils.GenerateRandomBytes(8)
+		if err != nil {
+			return config.VhostUserDeviceAttrs{}, err
+		}
+		endpoint.DevID = hex.EncodeToString(randBytes)
 	}
-	id := hex.EncodeToString(randBytes)
 
-	d := config.VhostUserDeviceAttrs{
-		DevID:      id,
+	return config.VhostUserDeviceAttrs{
+		DevID:      endpoint.DevID,
 		SocketPath: endpoint.SocketPath,
 		MacAddress: endpoint.HardAddr,
 		Type:       config.VhostUserNet,
+		PCIAddr:    endpoint.PCIAddr,
+		Server:     endpoint.Server,
+		Queues:     endpoint.Queues,
+	}, nil
+}
+
+// Attach for vhostuser endpoint
+func (endpoint *VhostUserEndpoint) Attach(h hypervisor) error {
+	d, err := endpoint.deviceAttrs()
+	if err != nil {
+		return err
 	}
 
 	return h.addDevice(d, vhostuserDev)
@@ -96,21 +182,44 @@ func (endpoint *VhostUserEndpoint) Detach(netNsCreated bool, netNsPath string) e
 	return nil
 }
 
-// HotAttach for vhostuser endpoint not supported yet
+// HotAttach for vhostuser endpoint
 func (endpoint *VhostUserEndpoint) HotAttach(h hypervisor) error {
-	return fmt.Errorf("VhostUserEndpoint does not support Hot attach")
+	d, err := endpoint.deviceAttrs()
+	if err != nil {
+		return err
+	}
+
+	if _, err := h.hotplugAddDevice(&d, vhostuserDev); err != nil {
+		networkLogger().WithError(err).Error("Error attach vhost-user interface")
+		return err
+	}
+	endpoint.PCIAddr = d.PCIAddr
+
+	return nil
 }
 
-// HotDetach for vhostuser endpoint not supported yet
+// HotDetach for vhostuser endpoint
 func (endpoint *VhostUserEndpoint) HotDetach(h hypervisor, netNsCreated bool, netNsPath string) error {
-	return fmt.Errorf("VhostUserEndpoint does not support Hot detach")
+	d, err := endpoint.deviceAttrs()
+	if err != nil {
+		return err
+	}
+
+	if _, err := h.hotplugRemoveDevice(&d, vhostuserDev); err != nil {
+		networkLogger().WithError(err).Error("Error detach vhost-user interface")
+		return err
+	}
+
+	return nil
 }
 
 // Create a vhostuser endpoint
-func createVhostUserEndpoint(netInfo NetworkInfo, socket string) (*VhostUserEndpoint, error) {
+func createVhostUserEndpoint(netInfo NetworkInfo, info *vhostUserNetInfo) (*VhostUserEndpoint, error) {
 
 	vhostUserEndpoint := &VhostUserEndpoint{
-		SocketPath:   socket,
+		SocketPath:   info.Socket,
+		Server:       info.Mode == vhostUserNetServerMode,
+		Queues:       info.Queues,
 		HardAddr:     netInfo.Iface.HardwareAddr.String(),
 		IfaceName:    netInfo.Iface.Name,
 		EndpointType: VhostUserEndpointType,
@@ -136,16 +245,44 @@ func findVhostUserNetSocketPath(netInfo NetworkInfo) (string, error) {
 	return "", nil
 }
 
-// vhostUserSocketPath returns the path of the socket discovered.  This discovery
-// will vary depending on the type of vhost-user socket.
-//  Today only VhostUserNetDevice is supported.
-func vhostUserSocketPath(info interface{}) (string, error) {
+// findVhostUserNet checks if an interface is a placeholder for a vhost-user
+// port, described in the vhost-user store or found at the legacy socket path,
+// and if it is it returns the description of the port.
+func findVhostUserNet(netInfo NetworkInfo, netNSPath, storePath string) (*vhostUserNetInfo, error) {
+	if netInfo.Iface.Name == "lo" {
+		return nil, nil
+	}
 
-	switch v := info.(type) {
-	case NetworkInfo:
-		return findVhostUserNetSocketPath(v)
-	default:
-		return "", nil
+	if netNSPath != "" && storePath != "" {
+		path := filepath.Join(storePath, vhostUserNetDir, filepath.Base(netNSPath), netInfo.Iface.Name+".json")
+
+		data, err := ioutil.ReadFile(path)
+		if err == nil {
+			var info vhostUserNetInfo
+			if err := json.Unmarshal(data, &info); err != nil {
+				return nil, fmt.Errorf("Invalid vhost-user interface description %s: %v", path, err)
+			}
+
+			if err := info.valid(); err != nil {
+				return nil, fmt.Errorf("Invalid vhost-user interface description %s: %v", path, err)
+			}
+
+			return &info, nil
+		}
+
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	socketPath, err := findVhostUserNetSocketPath(netInfo)
+	if err != nil || socketPath == "" {
+		return nil, err
 	}
 
+	return &vhostUserNetInfo{
+		Socket: socketPath,
+		Mode:   vhostUserNetClientMode,
+		Queues: 1,
+	}, nil
 }
@@ -6,9 +6,12 @@
 package virtcontainers
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
@@ -51,10 +54,10 @@ func TestVhostUserSocketPath(t *testing.T) {
 		Addrs: addresses,
 	}
 
-	path, _ := vhostUserSocketPath(netinfo)
+	info, _ := findVhostUserNet(netinfo, "", "")
 
-	if path != expectedResult {
-		t.Fatalf("Got %+v\nExpecting %+v", path, expectedResult)
+	if info == nil || info.Socket != expectedResult {
+		t.Fatalf("Got %+v\nExpecting %+v", info, expectedResult)
 	}
 
 	// Second test case: search doesn't include matching vsock:
@@ -70,9 +73,9 @@ func TestVhostUserSocketPath(t *testing.T) {
 		Addrs: addressesFalse,
 	}
 
-	path, _ = vhostUserSocketPath(netinfoFail)
-	if path != "" {
-		t.Fatalf("Got %+v\nExpecting %+v", path, "")
+	info, _ = findVhostUserNet(netinfoFail, "", "")
+	if info != nil {
+		t.Fatalf("Got %+v\nExpecting %+v", info, nil)
 	}
 
 	err = os.Remove(expectedResult)
@@ -100,6 +103,9 @@ func TestVhostUserEndpointAttach(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+
+	// The device ID is kept to detach the device
+	assert.NotEmpty(t, v.DevID)
 }
 
 func TestVhostUserEndpoint_HotAttach(t *testing.T) {
@@ -113,7 +119,13 @@ func TestVhostUserEndpoint_HotAttach(t *testing.T) {
 	h := &mockHypervisor{}
 
 	err := v.HotAttach(h)
-	assert.Error(err)
+	assert.NoError(err)
+	assert.NotEmpty(v.DevID)
+
+	devID := v.DevID
+	err = v.HotAttach(h)
+	assert.NoError(err)
+	assert.Equal(devID, v.DevID)
 }
 
 func TestVhostUserEndpoint_HotDetach(t *testing.T) {
@@ -127,7 +139,7 @@ func TestVhostUserEndpoint_HotDetach(t *testing.T) {
 	h := &mockHypervisor{}
 
 	err := v.HotDetach(h, true, "")
-	assert.Error(err)
+	assert.NoError(err)
 }
 
 func TestCreateVhostUserEndpoint(t *testing.T) {
@@ -146,12 +158,20 @@ func TestCreateVhostUserEndpoint(t *testing.T) {
 
 	expected := &VhostUserEndpoint{
 		SocketPath:   socket,
+		Server:       true,
+		Queues:       4,
 		HardAddr:     macAddr.String(),
 		IfaceName:    ifcName,
 		EndpointType: VhostUserEndpointType,
 	}
 
-	result, err := createVhostUserEndpoint(netinfo, socket)
+	info := &vhostUserNetInfo{
+		Socket: socket,
+		Mode:   vhostUserNetServerMode,
+		Queues: 4,
+	}
+
+	result, err := createVhostUserEndpoint(netinfo, info)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -160,3 +180,93 @@ func TestCreateVhostUserEndpoint(t *testing.T) {
 		t.Fatalf("\n\tGot %v\n\tExpecting %v", result, expected)
 	}
 }
+
+func TestFindVhostUserNet(t *testing.T) {
+	assert := assert.New(t)
+
+	storePath, err := ioutil.TempDir(testDir, "vhost-user-")
+	assert.NoError(err)
+	defer os.RemoveAll(storePath)
+
+	netNSPath := "/var/run/netns/vhutest"
+	dir := filepath.Join(storePath, vhostUserNetDir, "vhutest")
+	assert.NoError(os.MkdirAll(dir, testDirMode))
+
+	socket := filepath.Join(storePath, "vhu.sock")
+	netinfo := NetworkInfo{
+		Iface: NetlinkIface{
+			LinkAttrs: netlink.LinkAttrs{Name: "vhu0"},
+		},
+	}
+	desc := filepath.Join(dir, "vhu0.json")
+
+	// Not a vhost-user interface
+	info, err := findVhostUserNet(netinfo, netNSPath, storePath)
+	assert.NoError(err)
+	assert.Nil(info)
+
+	// The socket of the switch does not exist
+	assert.NoError(ioutil.WriteFile(desc, []byte(`{"socket": "`+socket+`"}`), defaultFilePerms))
+	_, err = findVhostUserNet(netinfo, netNSPath, storePath)
+	assert.Error(err)
+
+	_, err = os.Create(socket)
+	assert.NoError(err)
+
+	info, err = findVhostUserNet(netinfo, netNSPath, storePath)
+	assert.NoError(err)
+	assert.Equal(&vhostUserNetInfo{Socket: socket, Mode: vhostUserNetClientMode, Queues: 1}, info)
+
+	// The hypervisor creates the socket
+	serverSocket := filepath.Join(storePath, "server.sock")
+	assert.NoError(ioutil.WriteFile(desc, []byte(`{"socket": "`+serverSocket+`", "mode": "server", "queues": 4}`), defaultFilePerms))
+	info, err = findVhostUserNet(netinfo, netNSPath, storePath)
+	assert.NoError(err)
+	assert.Equal(&vhostUserNetInfo{Socket: serverSocket, Mode: vhostUserNetServerMode, Queues: 4}, info)
+
+	endpoint, err := createEndpoint(netinfo, 0, netNSPath, &NetworkConfig{VhostUserStorePath: storePath})
+	assert.NoError(err)
+	assert.Equal(VhostUserEndpointType, endpoint.Type())
+	assert.True(endpoint.(*VhostUserEndpoint).Server)
+	assert.Equal(uint32(4), endpoint.(*VhostUserEndpoint).Queues)
+
+	// Invalid descriptions
+	for _, content := range []string{
+		"{",
+		`{"socket": "vhu.sock"}`,
+		`{"socket": "` + socket + `", "mode": "foo"}`,
+	} {
+		assert.NoError(ioutil.WriteFile(desc, []byte(content), defaultFilePerms))
+		_, err = findVhostUserNet(netinfo, netNSPath, storePath)
+		assert.Error(err, content)
+	}
+
+	// Only the interfaces of the network namespace are described
+	info, err = findVhostUserNet(netinfo, "/var/run/netns/other", storePath)
+	assert.NoError(err)
+	assert.Nil(info)
+}
+
+func TestVhostUserEndpointRestore(t *testing.T) {
+	assert := assert.New(t)
+
+	v := &VhostUserEndpoint{
+		SocketPath:   "/tmp/sock",
+		Server:       true,
+		Queues:       2,
+		HardAddr:     "02:00:ca:fe:00:48",
+		IfaceName:    "vhu0",
+		EndpointType: VhostUserEndpointType,
+	}
+	assert.NoError(v.HotAttach(&mockHypervisor{}))
+
+	data, err := json.Marshal(NetworkNamespace{NetNsPath: "/var/run/netns/vhutest", Endpoints: []Endpoint{v}})
+	assert.NoError(err)
+
+	var nns NetworkNamespace
+	assert.NoError(json.Unmarshal(data, &nns))
+	assert.Len(nns.Endpoints, 1)
+
+	// The restored endpoint detaches the same device
+	assert.Equal(v, nns.Endpoints[0])
+}